@@ -5,6 +5,7 @@ package models
 
 import (
 	strfmt "github.com/go-openapi/strfmt"
+	"github.com/go-openapi/swag"
 
 	"github.com/go-openapi/errors"
 	"github.com/go-openapi/validate"
@@ -20,6 +21,7 @@ type Maintainer struct {
 
 	Required: true
 	Min Length: 1
+	Format: email
 	*/
 	Email *string `json:"email"`
 
@@ -29,6 +31,10 @@ type Maintainer struct {
 	Min Length: 1
 	*/
 	Name *string `json:"name"`
+
+	/* url
+	 */
+	URL *string `json:"url,omitempty"`
 }
 
 // Validate validates this maintainer
@@ -45,6 +51,11 @@ func (m *Maintainer) Validate(formats strfmt.Registry) error {
 		res = append(res, err)
 	}
 
+	if err := m.validateURL(formats); err != nil {
+		// prop
+		res = append(res, err)
+	}
+
 	if len(res) > 0 {
 		return errors.CompositeValidationError(res...)
 	}
@@ -61,6 +72,10 @@ func (m *Maintainer) validateEmail(formats strfmt.Registry) error {
 		return err
 	}
 
+	if err := validate.FormatOf("email", "body", "email", string(*m.Email), formats); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -76,3 +91,16 @@ func (m *Maintainer) validateName(formats strfmt.Registry) error {
 
 	return nil
 }
+
+func (m *Maintainer) validateURL(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.URL) { // not required
+		return nil
+	}
+
+	if err := validate.FormatOf("url", "body", "uri", string(*m.URL), formats); err != nil {
+		return err
+	}
+
+	return nil
+}
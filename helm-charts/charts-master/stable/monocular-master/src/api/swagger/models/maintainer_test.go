@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/arschles/assert"
+	"github.com/go-openapi/errors"
+	strfmt "github.com/go-openapi/strfmt"
+	"github.com/helm/monocular/src/api/data/pointerto"
+)
+
+func TestMaintainerValidate(t *testing.T) {
+	formats := strfmt.NewFormats()
+	maintainer := Maintainer{
+		Email: pointerto.String("jdoe@example.com"),
+		Name:  pointerto.String("John Doe"),
+	}
+	assert.NoErr(t, maintainer.Validate(formats))
+
+	maintainer.URL = pointerto.String("https://example.com/jdoe")
+	assert.NoErr(t, maintainer.Validate(formats))
+}
+
+func TestMaintainerValidateEmail(t *testing.T) {
+	formats := strfmt.NewFormats()
+	for _, email := range []string{"x", "jdoe", "jdoe@", "@example.com", "jdoe example.com"} {
+		maintainer := Maintainer{
+			Email: pointerto.String(email),
+			Name:  pointerto.String("John Doe"),
+		}
+		assert.ExistsErr(t, maintainer.Validate(formats), "malformed email "+email)
+	}
+
+	// a missing email is reported along with the name validation errors
+	maintainer := Maintainer{
+		Name: pointerto.String(""),
+	}
+	err := maintainer.Validate(formats)
+	assert.ExistsErr(t, err, "missing email and empty name")
+	compositeErr, ok := err.(*errors.CompositeError)
+	assert.True(t, ok, "expected a composite validation error but got %T", err)
+	assert.Equal(t, len(compositeErr.Errors), 2, "number of validation errors")
+
+	maintainer.Email = pointerto.String("x")
+	err = maintainer.Validate(formats)
+	compositeErr, ok = err.(*errors.CompositeError)
+	assert.True(t, ok, "expected a composite validation error but got %T", err)
+	assert.Equal(t, len(compositeErr.Errors), 2, "number of validation errors")
+}
@@ -3,6 +3,7 @@ package v1
 import (
 	"strings"
 
+	"github.com/blang/semver"
 	batchv1 "k8s.io/api/batch/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -37,6 +38,8 @@ type EnvironmentSpec struct {
 	PullRequestURL    string                `json:"pullRequestURL,omitempty" protobuf:"bytes,8,opt,name=pullRequestURL"`
 	TeamSettings      TeamSettings          `json:"teamSettings,omitempty" protobuf:"bytes,9,opt,name=teamSettings"`
 	PreviewGitSpec    PreviewGitSpec        `json:"previewGitInfo,omitempty" protobuf:"bytes,10,opt,name=previewGitInfo"`
+	PromotionPolicy   PromotionPolicy       `json:"promotionPolicy,omitempty" protobuf:"bytes,11,opt,name=promotionPolicy"`
+	MergePolicy       MergePolicy           `json:"mergePolicy,omitempty" protobuf:"bytes,12,opt,name=mergePolicy"`
 }
 
 // EnvironmentStatus is the status for an Environment resource
@@ -114,6 +117,69 @@ type EnvironmentRepository struct {
 	Ref  string                    `json:"ref,omitempty" protobuf:"bytes,3,opt,name=ref"`
 }
 
+// PromotionPolicy restricts the versions of applications which can be promoted to an environment
+type PromotionPolicy struct {
+	// AllowedVersions the versions or semantic version ranges (such as '>=1.0.0 <2.0.0') which can be promoted
+	AllowedVersions []string `json:"allowedVersions,omitempty" protobuf:"bytes,1,rep,name=allowedVersions"`
+	// RequiredEnvironments the environments a version must already have been promoted to
+	RequiredEnvironments []string `json:"requiredEnvironments,omitempty" protobuf:"bytes,2,rep,name=requiredEnvironments"`
+}
+
+// IsRestricted returns true if the policy restricts the versions which can be promoted
+func (p *PromotionPolicy) IsRestricted() bool {
+	return len(p.AllowedVersions) > 0 || len(p.RequiredEnvironments) > 0
+}
+
+// AllowsVersion returns true if the version matches one of the allowed versions or semantic version ranges. All
+// versions are allowed if the policy has no allowed versions. Allowed versions which are not valid ranges only match
+// the exact version
+func (p *PromotionPolicy) AllowsVersion(version string) bool {
+	if len(p.AllowedVersions) == 0 {
+		return true
+	}
+	sv, svErr := semver.Parse(version)
+	for _, allowed := range p.AllowedVersions {
+		if allowed == version {
+			return true
+		}
+		if svErr != nil {
+			continue
+		}
+		versionRange, err := semver.ParseRange(allowed)
+		if err == nil && versionRange(sv) {
+			return true
+		}
+	}
+	return false
+}
+
+// MergePolicyKind is the kind of merge policy of the promotion Pull Requests of an environment
+type MergePolicyKind string
+
+const (
+	// MergePolicyKindImmediate specifies that promotion Pull Requests are merged without waiting for CI
+	MergePolicyKindImmediate MergePolicyKind = "Immediate"
+	// MergePolicyKindCISuccess specifies that promotion Pull Requests are merged when CI succeeds
+	MergePolicyKindCISuccess MergePolicyKind = "CISuccess"
+	// MergePolicyKindApproved specifies that promotion Pull Requests are merged when CI succeeds and they are approved
+	MergePolicyKindApproved MergePolicyKind = "Approved"
+)
+
+// MergePolicyKindValues is the list of all values
+var MergePolicyKindValues = []string{
+	string(MergePolicyKindImmediate),
+	string(MergePolicyKindCISuccess),
+	string(MergePolicyKindApproved),
+}
+
+// MergePolicy specifies when the promotion Pull Requests of an environment can be merged automatically
+type MergePolicy struct {
+	// Kind the kind of policy which defaults to CISuccess
+	Kind MergePolicyKind `json:"kind,omitempty" protobuf:"bytes,1,opt,name=kind"`
+	// RequiredApprovals the number of approvals needed by the Approved policy which defaults to 1
+	RequiredApprovals int32 `json:"requiredApprovals,omitempty" protobuf:"bytes,2,opt,name=requiredApprovals"`
+}
+
 // TeamSettings the default settings for a team
 type TeamSettings struct {
 	UseGitOPs           bool                 `json:"useGitOps,omitempty" protobuf:"bytes,1,opt,name=useGitOps"`
@@ -287,6 +353,20 @@ type PromoteActivityStep struct {
 	PullRequest    *PromotePullRequestStep `json:"pullRequest,omitempty" protobuf:"bytes,2,opt,name=pullRequest"`
 	Update         *PromoteUpdateStep      `json:"update,omitempty" protobuf:"bytes,3,opt,name=update"`
 	ApplicationURL string                  `json:"applicationURL,omitempty" protobuf:"bytes,4,opt,name=environment"`
+	// Version is the version of the application being promoted
+	Version string `json:"version,omitempty" protobuf:"bytes,5,opt,name=version"`
+	// PreviousVersion is the version of the application deployed in the environment before the promotion
+	PreviousVersion string `json:"previousVersion,omitempty" protobuf:"bytes,6,opt,name=previousVersion"`
+	// Canary is true if the version was rolled out as a canary which only receives a share of the traffic
+	Canary bool `json:"canary,omitempty" protobuf:"varint,7,opt,name=canary"`
+	// CanaryWeight is the percentage of the traffic sent to the canary
+	CanaryWeight int `json:"canaryWeight,omitempty" protobuf:"varint,8,opt,name=canaryWeight"`
+	// ValuesFile is the values file passed to helm when the version was promoted directly via helm
+	ValuesFile string `json:"valuesFile,omitempty" protobuf:"bytes,9,opt,name=valuesFile"`
+	// CommitSHA is the git commit of the source of the application the promoted version was built from
+	CommitSHA string `json:"commitSHA,omitempty" protobuf:"bytes,10,opt,name=commitSHA"`
+	// PromotedBy is the user or service account which triggered the promotion
+	PromotedBy string `json:"promotedBy,omitempty" protobuf:"bytes,11,opt,name=promotedBy"`
 }
 
 // GitStatus the status of a git commit in terms of CI/CD
@@ -302,6 +382,8 @@ type PromotePullRequestStep struct {
 
 	PullRequestURL string `json:"pullRequestURL,omitempty" protobuf:"bytes,1,opt,name=pullRequestURL"`
 	MergeCommitSHA string `json:"mergeCommitSHA,omitempty" protobuf:"bytes,2,opt,name=mergeCommitSHA"`
+	// Statuses are the statuses of the last commit of the Pull Request while it is waiting to merge
+	Statuses []GitStatus `json:"statuses,omitempty" protobuf:"bytes,3,opt,name=statuses"`
 }
 
 // PromoteUpdateStep is the step for updating a promotion after the Pull Request merges to master
@@ -309,6 +391,8 @@ type PromoteUpdateStep struct {
 	CoreActivityStep
 
 	Statuses []GitStatus `json:"statuses,omitempty" protobuf:"bytes,1,opt,name=statuses"`
+	// Duration is the time the update took from its start until it completed or failed
+	Duration *metav1.Duration `json:"duration,omitempty" protobuf:"bytes,2,opt,name=duration"`
 }
 
 // PipelineActivityStatus is the status for an Environment resource
@@ -296,6 +296,8 @@ func (in *EnvironmentSpec) DeepCopyInto(out *EnvironmentSpec) {
 	out.Source = in.Source
 	in.TeamSettings.DeepCopyInto(&out.TeamSettings)
 	out.PreviewGitSpec = in.PreviewGitSpec
+	in.PromotionPolicy.DeepCopyInto(&out.PromotionPolicy)
+	out.MergePolicy = in.MergePolicy
 	return
 }
 
@@ -487,6 +489,22 @@ func (in *IssueSummary) DeepCopy() *IssueSummary {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MergePolicy) DeepCopyInto(out *MergePolicy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MergePolicy.
+func (in *MergePolicy) DeepCopy() *MergePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(MergePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PipelineActivity) DeepCopyInto(out *PipelineActivity) {
 	*out = *in
@@ -719,6 +737,11 @@ func (in *PromoteActivityStep) DeepCopy() *PromoteActivityStep {
 func (in *PromotePullRequestStep) DeepCopyInto(out *PromotePullRequestStep) {
 	*out = *in
 	in.CoreActivityStep.DeepCopyInto(&out.CoreActivityStep)
+	if in.Statuses != nil {
+		in, out := &in.Statuses, &out.Statuses
+		*out = make([]GitStatus, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -741,6 +764,14 @@ func (in *PromoteUpdateStep) DeepCopyInto(out *PromoteUpdateStep) {
 		*out = make([]GitStatus, len(*in))
 		copy(*out, *in)
 	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = (*in).DeepCopy()
+		}
+	}
 	return
 }
 
@@ -754,6 +785,32 @@ func (in *PromoteUpdateStep) DeepCopy() *PromoteUpdateStep {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PromotionPolicy) DeepCopyInto(out *PromotionPolicy) {
+	*out = *in
+	if in.AllowedVersions != nil {
+		in, out := &in.AllowedVersions, &out.AllowedVersions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RequiredEnvironments != nil {
+		in, out := &in.RequiredEnvironments, &out.RequiredEnvironments
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PromotionPolicy.
+func (in *PromotionPolicy) DeepCopy() *PromotionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PromotionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *QuickStartLocation) DeepCopyInto(out *QuickStartLocation) {
 	*out = *in
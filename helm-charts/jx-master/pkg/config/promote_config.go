@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/jenkins-x/jx/pkg/util"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// PromoteConfigFileName is the name of the promotion configuration file of an app
+	PromoteConfigFileName = "promote.yaml"
+
+	// PromoteConfigDir is the directory of the app which contains the promotion configuration file
+	PromoteConfigDir = ".jx"
+
+	maxStatusContextLength = 255
+)
+
+var (
+	reviewerUserRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,37}[A-Za-z0-9])?$`)
+	reviewerTeamRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// PromoteConfig is the configuration of how an app is promoted
+type PromoteConfig struct {
+	// Environments the names of the environments the app is promoted to
+	Environments []string `yaml:"environments,omitempty"`
+	// Reviewers the users or 'org/team' teams asked to review the promotion Pull Requests
+	Reviewers []string `yaml:"reviewers,omitempty"`
+	// StatusContexts the commit status contexts which must succeed before a promotion Pull Request is merged
+	StatusContexts []string `yaml:"statusContexts,omitempty"`
+}
+
+// LoadPromoteConfig loads the promotion configuration of the app in the given directory. Returns false if there is no
+// configuration file
+func LoadPromoteConfig(projectDir string) (*PromoteConfig, string, bool, error) {
+	fileName := filepath.Join(PromoteConfigDir, PromoteConfigFileName)
+	if projectDir != "" {
+		fileName = filepath.Join(projectDir, fileName)
+	}
+	config := PromoteConfig{}
+	exists, err := util.FileExists(fileName)
+	if err != nil || !exists {
+		return &config, fileName, false, err
+	}
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return &config, fileName, true, fmt.Errorf("Failed to load file %s due to %s", fileName, err)
+	}
+	err = yaml.UnmarshalStrict(data, &config)
+	if err != nil {
+		return &config, fileName, true, fmt.Errorf("Failed to unmarshal YAML file %s due to %s", fileName, err)
+	}
+	return &config, fileName, true, nil
+}
+
+// Validate returns the problems found in the configuration given the names of the environments of the team
+func (c *PromoteConfig) Validate(environmentNames []string) []error {
+	problems := []error{}
+	found := map[string]bool{}
+	for _, name := range c.Environments {
+		if found[name] {
+			problems = append(problems, fmt.Errorf("environment %s is listed more than once", name))
+			continue
+		}
+		found[name] = true
+		if util.StringArrayIndex(environmentNames, name) < 0 {
+			problems = append(problems, fmt.Errorf("environment %s does not exist. Available environments: %s", name, strings.Join(environmentNames, ", ")))
+		}
+	}
+	found = map[string]bool{}
+	for _, reviewer := range c.Reviewers {
+		if found[reviewer] {
+			problems = append(problems, fmt.Errorf("reviewer %s is listed more than once", reviewer))
+			continue
+		}
+		found[reviewer] = true
+		if !isValidReviewer(reviewer) {
+			problems = append(problems, fmt.Errorf("reviewer '%s' is not a valid user name or 'org/team' name", reviewer))
+		}
+	}
+	found = map[string]bool{}
+	for _, context := range c.StatusContexts {
+		if found[context] {
+			problems = append(problems, fmt.Errorf("status context %s is listed more than once", context))
+			continue
+		}
+		found[context] = true
+		err := validateStatusContext(context)
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return problems
+}
+
+func isValidReviewer(reviewer string) bool {
+	paths := strings.Split(reviewer, "/")
+	switch len(paths) {
+	case 1:
+		return isValidUserName(reviewer)
+	case 2:
+		return isValidUserName(paths[0]) && reviewerTeamRegex.MatchString(paths[1])
+	default:
+		return false
+	}
+}
+
+func isValidUserName(name string) bool {
+	return reviewerUserRegex.MatchString(name) && !strings.Contains(name, "--")
+}
+
+func validateStatusContext(context string) error {
+	if strings.TrimSpace(context) == "" {
+		return fmt.Errorf("status context must not be empty")
+	}
+	if strings.TrimSpace(context) != context {
+		return fmt.Errorf("status context '%s' must not start or end with whitespace", context)
+	}
+	if len(context) > maxStatusContextLength {
+		return fmt.Errorf("status context %s is longer than %d characters", context, maxStatusContextLength)
+	}
+	for _, r := range context {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("status context %q must not contain control characters", context)
+		}
+	}
+	return nil
+}
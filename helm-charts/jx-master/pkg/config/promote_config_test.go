@@ -0,0 +1,107 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func writePromoteConfig(t *testing.T, yaml string) string {
+	dir, err := ioutil.TempDir("", "test-promote-config-")
+	assert.NoError(t, err)
+	configDir := filepath.Join(dir, PromoteConfigDir)
+	assert.NoError(t, os.MkdirAll(configDir, util.DefaultWritePermissions))
+	err = ioutil.WriteFile(filepath.Join(configDir, PromoteConfigFileName), []byte(yaml), util.DefaultWritePermissions)
+	assert.NoError(t, err)
+	return dir
+}
+
+func TestLoadPromoteConfig(t *testing.T) {
+	dir := writePromoteConfig(t, `environments:
+- staging
+- production
+reviewers:
+- jstrachan
+- jenkins-x/core
+statusContexts:
+- continuous-integration/jenkins/pr-merge
+`)
+	defer os.RemoveAll(dir)
+
+	config, _, exists, err := LoadPromoteConfig(dir)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, []string{"staging", "production"}, config.Environments)
+	assert.Empty(t, config.Validate([]string{"dev", "staging", "production"}))
+
+	// no configuration file
+	_, _, exists, err = LoadPromoteConfig(filepath.Join(dir, PromoteConfigDir))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	// unknown fields are reported
+	badDir := writePromoteConfig(t, "environment:\n- staging\n")
+	defer os.RemoveAll(badDir)
+	_, _, exists, err = LoadPromoteConfig(badDir)
+	assert.True(t, exists)
+	assert.Error(t, err)
+}
+
+func TestPromoteConfigValidate(t *testing.T) {
+	environmentNames := []string{"dev", "staging", "production"}
+	testCases := []struct {
+		name     string
+		config   PromoteConfig
+		problems []string
+	}{
+		{
+			name: "unknown environment",
+			config: PromoteConfig{
+				Environments: []string{"staging", "prod"},
+			},
+			problems: []string{"environment prod does not exist. Available environments: dev, staging, production"},
+		},
+		{
+			name: "duplicate environment",
+			config: PromoteConfig{
+				Environments: []string{"staging", "staging"},
+			},
+			problems: []string{"environment staging is listed more than once"},
+		},
+		{
+			name: "invalid reviewers",
+			config: PromoteConfig{
+				Reviewers: []string{"jstrachan", "-bad", "two--dashes", "a/b/c", "org/"},
+			},
+			problems: []string{
+				"reviewer '-bad' is not a valid user name or 'org/team' name",
+				"reviewer 'two--dashes' is not a valid user name or 'org/team' name",
+				"reviewer 'a/b/c' is not a valid user name or 'org/team' name",
+				"reviewer 'org/' is not a valid user name or 'org/team' name",
+			},
+		},
+		{
+			name: "malformed status contexts",
+			config: PromoteConfig{
+				StatusContexts: []string{"ci/build", "", " ci/test", "ci/\tlint", "ci/build"},
+			},
+			problems: []string{
+				"status context must not be empty",
+				"status context ' ci/test' must not start or end with whitespace",
+				`status context "ci/\tlint" must not contain control characters`,
+				"status context ci/build is listed more than once",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		problems := []string{}
+		for _, err := range tc.config.Validate(environmentNames) {
+			problems = append(problems, err.Error())
+		}
+		assert.Equal(t, tc.problems, problems, tc.name)
+	}
+}
@@ -612,12 +612,25 @@ func (b *BitbucketCloudProvider) ListCommitStatus(org string, repo string, sha s
 }
 
 func (b *BitbucketCloudProvider) MergePullRequest(pr *GitPullRequest, message string) error {
+	return b.MergePullRequestUsingMethod(pr, message, "")
+}
 
+func (b *BitbucketCloudProvider) MergePullRequestUsingMethod(pr *GitPullRequest, message string, method string) error {
+	parameters := map[string]interface{}{
+		"message": message,
+	}
+	switch method {
+	case "":
+	case MergeMethodMerge:
+		parameters["merge_strategy"] = "merge_commit"
+	case MergeMethodSquash:
+		parameters["merge_strategy"] = "squash"
+	default:
+		return fmt.Errorf("The %s merge method is not supported for bitbucket cloud", method)
+	}
 	options := map[string]interface{}{
 		"body": map[string]interface{}{
-			"pullrequest_merge_parameters": map[string]interface{}{
-				"message": message,
-			},
+			"pullrequest_merge_parameters": parameters,
 		},
 	}
 
@@ -854,6 +867,47 @@ func (p *BitbucketCloudProvider) UserInfo(username string) *GitUser {
 	}
 }
 
+func (b *BitbucketCloudProvider) FindOpenPullRequest(owner string, repo string, head string) (*GitPullRequest, error) {
+	return nil, fmt.Errorf("Finding open Pull Requests is not supported for bitbucket cloud")
+}
+
+func (b *BitbucketCloudProvider) ClosePullRequest(pr *GitPullRequest) error {
+	if pr.Number == nil {
+		return fmt.Errorf("Missing Number for Pull Request %s", pr.URL)
+	}
+	result, _, err := b.Client.PullrequestsApi.RepositoriesUsernameRepoSlugPullrequestsPullRequestIdDeclinePost(
+		b.Context,
+		b.Username,
+		strconv.FormatInt(int64(*pr.Number), 10),
+		strings.TrimPrefix(pr.Repo, pr.Owner+"/"),
+	)
+	if err != nil {
+		return err
+	}
+	pr.markClosed(result.State)
+	return nil
+}
+
+func (b *BitbucketCloudProvider) PullRequestApprovers(pr *GitPullRequest) ([]string, error) {
+	return nil, fmt.Errorf("Listing the approvers of Pull Requests is not supported for bitbucket cloud")
+}
+
+func (b *BitbucketCloudProvider) AddLabelsToPullRequest(pr *GitPullRequest, labels []string) error {
+	return fmt.Errorf("Adding labels to Pull Requests is not supported for bitbucket cloud")
+}
+
+func (b *BitbucketCloudProvider) RequestReviewers(pr *GitPullRequest, reviewers []string) error {
+	return fmt.Errorf("Requesting reviewers of Pull Requests is not supported for bitbucket cloud")
+}
+
+func (b *BitbucketCloudProvider) CreateDeployment(owner string, repo string, ref string, environment string) (*GitDeployment, error) {
+	return nil, fmt.Errorf("Deployments are not supported for bitbucket cloud")
+}
+
+func (b *BitbucketCloudProvider) DeploymentStatus(owner string, repo string, id int64) (string, error) {
+	return "", fmt.Errorf("Deployments are not supported for bitbucket cloud")
+}
+
 func (b *BitbucketCloudProvider) UpdateRelease(owner string, repo string, tag string, releaseInfo *GitRelease) error {
 	log.Warn("Bitbucket Cloud doesn't support releases")
 	return nil
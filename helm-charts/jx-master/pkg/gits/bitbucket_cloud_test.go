@@ -283,6 +283,20 @@ func (suite *BitbucketCloudProviderTestSuite) TestMergePullRequest() {
 	suite.Require().Nil(err)
 }
 
+func (suite *BitbucketCloudProviderTestSuite) TestMergePullRequestUsingMethod() {
+
+	id := 1
+	pr := &GitPullRequest{
+		Repo:   "test-repo",
+		Number: &id,
+	}
+	err := suite.provider.MergePullRequestUsingMethod(pr, "Merging from unit tests", MergeMethodSquash)
+	suite.Require().Nil(err)
+
+	err = suite.provider.MergePullRequestUsingMethod(pr, "Merging from unit tests", MergeMethodRebase)
+	suite.Require().NotNil(err)
+}
+
 func (suite *BitbucketCloudProviderTestSuite) TestCreateWebHook() {
 
 	data := &GitWebHookArguments{
@@ -805,6 +805,41 @@ func (b *BitbucketServerProvider) UserInfo(username string) *GitUser {
 	}
 }
 
+func (b *BitbucketServerProvider) MergePullRequestUsingMethod(pr *GitPullRequest, message string, method string) error {
+	if method != "" && method != MergeMethodMerge {
+		return fmt.Errorf("The %s merge method is not supported for bitbucket server", method)
+	}
+	return b.MergePullRequest(pr, message)
+}
+
+func (b *BitbucketServerProvider) FindOpenPullRequest(owner string, repo string, head string) (*GitPullRequest, error) {
+	return nil, fmt.Errorf("Finding open Pull Requests is not supported for bitbucket server")
+}
+
+func (b *BitbucketServerProvider) ClosePullRequest(pr *GitPullRequest) error {
+	return fmt.Errorf("Closing Pull Requests is not supported for bitbucket server")
+}
+
+func (b *BitbucketServerProvider) PullRequestApprovers(pr *GitPullRequest) ([]string, error) {
+	return nil, fmt.Errorf("Listing the approvers of Pull Requests is not supported for bitbucket server")
+}
+
+func (b *BitbucketServerProvider) AddLabelsToPullRequest(pr *GitPullRequest, labels []string) error {
+	return fmt.Errorf("Adding labels to Pull Requests is not supported for bitbucket server")
+}
+
+func (b *BitbucketServerProvider) RequestReviewers(pr *GitPullRequest, reviewers []string) error {
+	return fmt.Errorf("Requesting reviewers of Pull Requests is not supported for bitbucket server")
+}
+
+func (b *BitbucketServerProvider) CreateDeployment(owner string, repo string, ref string, environment string) (*GitDeployment, error) {
+	return nil, fmt.Errorf("Deployments are not supported for bitbucket server")
+}
+
+func (b *BitbucketServerProvider) DeploymentStatus(owner string, repo string, id int64) (string, error) {
+	return "", fmt.Errorf("Deployments are not supported for bitbucket server")
+}
+
 func (b *BitbucketServerProvider) UpdateRelease(owner string, repo string, tag string, releaseInfo *GitRelease) error {
 	log.Warn("Bitbucket Server doesn't support releases")
 	return nil
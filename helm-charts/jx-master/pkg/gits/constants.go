@@ -1,5 +1,7 @@
 package gits
 
+import "strings"
+
 const (
 	KindBitBucketCloud  = "bitbucketcloud"
 	KindBitBucketServer = "bitbucketserver"
@@ -9,8 +11,81 @@ const (
 	KindUnknown         = "unknown"
 
 	BitbucketCloudURL = "https://bitbucket.org"
+
+	// MergeMethodMerge merges the Pull Request with a merge commit
+	MergeMethodMerge = "merge"
+	// MergeMethodSquash squashes the commits of the Pull Request into a single commit
+	MergeMethodSquash = "squash"
+	// MergeMethodRebase rebases the commits of the Pull Request onto the base branch
+	MergeMethodRebase = "rebase"
+
+	// CommitStatePending the checks of the commit have not started
+	CommitStatePending = "pending"
+	// CommitStateInProgress the checks of the commit are running
+	CommitStateInProgress = "in-progress"
+	// CommitStateSuccess the checks of the commit passed
+	CommitStateSuccess = "success"
+	// CommitStateFailure the checks of the commit failed
+	CommitStateFailure = "failure"
+	// CommitStateError the checks of the commit could not complete
+	CommitStateError = "error"
 )
 
 var (
 	KindGits = []string{KindBitBucketCloud, KindBitBucketServer, KindGitea, KindGitHub, KindGitlab}
+
+	// MergeMethods the methods which can be used to merge a Pull Request
+	MergeMethods = []string{MergeMethodMerge, MergeMethodSquash, MergeMethodRebase}
 )
+
+// SupportedMergeMethods returns the methods the given kind of git provider can use to merge a Pull Request
+func SupportedMergeMethods(kind string) []string {
+	switch kind {
+	case KindGitHub:
+		return []string{MergeMethodMerge, MergeMethodSquash, MergeMethodRebase}
+	case KindBitBucketCloud:
+		return []string{MergeMethodMerge, MergeMethodSquash}
+	default:
+		return []string{MergeMethodMerge}
+	}
+}
+
+// NormalizeCommitStatus maps the commit status reported by the given kind of git provider onto one of the canonical
+// CommitState values. Statuses which are not recognised are returned in lower case
+func NormalizeCommitStatus(kind string, status string) string {
+	state := strings.ToLower(strings.TrimSpace(status))
+	switch kind {
+	case KindGitlab:
+		switch state {
+		case "created", "waiting_for_resource", "preparing", "scheduled", "manual":
+			return CommitStatePending
+		case "running":
+			return CommitStateInProgress
+		case "failed":
+			return CommitStateFailure
+		case "canceled", "skipped":
+			return CommitStateError
+		}
+	case KindBitBucketCloud, KindBitBucketServer:
+		switch state {
+		case "successful":
+			return CommitStateSuccess
+		case "inprogress":
+			return CommitStateInProgress
+		case "failed":
+			return CommitStateFailure
+		case "stopped":
+			return CommitStateError
+		}
+	case KindGitea:
+		if state == "warning" {
+			return CommitStateSuccess
+		}
+	}
+	switch state {
+	case "queued", "expected", "requested", "waiting":
+		// the checks are known about but have not started yet
+		return CommitStatePending
+	}
+	return state
+}
@@ -0,0 +1,46 @@
+package gits
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeCommitStatus(t *testing.T) {
+	testCases := []struct {
+		kind     string
+		status   string
+		expected string
+	}{
+		{KindGitHub, "success", CommitStateSuccess},
+		{KindGitHub, "pending", CommitStatePending},
+		{KindGitHub, "failure", CommitStateFailure},
+		{KindGitHub, "error", CommitStateError},
+		{KindGitlab, "success", CommitStateSuccess},
+		{KindGitlab, "created", CommitStatePending},
+		{KindGitlab, "pending", CommitStatePending},
+		{KindGitlab, "manual", CommitStatePending},
+		{KindGitlab, "running", CommitStateInProgress},
+		{KindGitlab, "failed", CommitStateFailure},
+		{KindGitlab, "canceled", CommitStateError},
+		{KindGitlab, "skipped", CommitStateError},
+		{KindBitBucketCloud, "SUCCESSFUL", CommitStateSuccess},
+		{KindBitBucketCloud, "INPROGRESS", CommitStateInProgress},
+		{KindBitBucketCloud, "FAILED", CommitStateFailure},
+		{KindBitBucketCloud, "STOPPED", CommitStateError},
+		{KindBitBucketServer, "SUCCESSFUL", CommitStateSuccess},
+		{KindBitBucketServer, "INPROGRESS", CommitStateInProgress},
+		{KindBitBucketServer, "FAILED", CommitStateFailure},
+		{KindGitea, "warning", CommitStateSuccess},
+		{KindGitea, "failure", CommitStateFailure},
+		{KindGitHub, "queued", CommitStatePending},
+		{KindGitHub, "Expected", CommitStatePending},
+		{KindGitHub, "requested", CommitStatePending},
+		{KindGitHub, "waiting", CommitStatePending},
+		{KindGitea, "queued", CommitStatePending},
+		{KindUnknown, "Unknown", "unknown"},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, NormalizeCommitStatus(tc.kind, tc.status), "%s status %s", tc.kind, tc.status)
+	}
+}
@@ -2,6 +2,7 @@ package gits
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	gerrit "github.com/andygrunwald/go-gerrit"
@@ -169,3 +170,38 @@ func (p *GerritProvider) UserAuth() auth.UserAuth {
 func (p *GerritProvider) UserInfo(username string) *GitUser {
 	return nil
 }
+
+func (p *GerritProvider) MergePullRequestUsingMethod(pr *GitPullRequest, message string, method string) error {
+	if method != "" && method != MergeMethodMerge {
+		return fmt.Errorf("The %s merge method is not supported for gerrit", method)
+	}
+	return p.MergePullRequest(pr, message)
+}
+
+func (p *GerritProvider) FindOpenPullRequest(owner string, repo string, head string) (*GitPullRequest, error) {
+	return nil, fmt.Errorf("Finding open Pull Requests is not supported for gerrit")
+}
+
+func (p *GerritProvider) ClosePullRequest(pr *GitPullRequest) error {
+	return fmt.Errorf("Closing Pull Requests is not supported for gerrit")
+}
+
+func (p *GerritProvider) PullRequestApprovers(pr *GitPullRequest) ([]string, error) {
+	return nil, fmt.Errorf("Listing the approvers of Pull Requests is not supported for gerrit")
+}
+
+func (p *GerritProvider) AddLabelsToPullRequest(pr *GitPullRequest, labels []string) error {
+	return fmt.Errorf("Adding labels to Pull Requests is not supported for gerrit")
+}
+
+func (p *GerritProvider) RequestReviewers(pr *GitPullRequest, reviewers []string) error {
+	return fmt.Errorf("Requesting reviewers of Pull Requests is not supported for gerrit")
+}
+
+func (p *GerritProvider) CreateDeployment(owner string, repo string, ref string, environment string) (*GitDeployment, error) {
+	return nil, fmt.Errorf("Deployments are not supported for gerrit")
+}
+
+func (p *GerritProvider) DeploymentStatus(owner string, repo string, id int64) (string, error) {
+	return "", fmt.Errorf("Deployments are not supported for gerrit")
+}
@@ -45,6 +45,7 @@ type Gitter interface {
 	DiscoverUpstreamGitURL(gitConf string) (string, error)
 	RemoteBranches(dir string) ([]string, error)
 	RemoteBranchNames(dir string, prefix string) ([]string, error)
+	DefaultBranch(dir string) (string, error)
 	GetRemoteUrl(config *gitcfg.Config, name string) string
 
 	Branch(dir string) (string, error)
@@ -65,6 +66,7 @@ type Gitter interface {
 
 	GetPreviousGitTagSHA(dir string) (string, error)
 	GetCurrentGitTagSHA(dir string) (string, error)
+	GetLatestCommitSha(dir string) (string, error)
 	FetchTags(dir string) error
 	Tags(dir string) ([]string, error)
 	CreateTag(dir string, tag string, msg string) error
@@ -427,6 +427,15 @@ func (g *GitCLI) RemoteBranchNames(dir string, prefix string) ([]string, error)
 	return answer, nil
 }
 
+// DefaultBranch returns the default branch of the origin remote of the repository at the given directory
+func (g *GitCLI) DefaultBranch(dir string) (string, error) {
+	text, err := g.gitCmdWithOutput(dir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(strings.TrimSpace(text), "origin/"), nil
+}
+
 // GetPreviousGitTagSHA returns the previous git tag from the repository at the given directory
 func (g *GitCLI) GetPreviousGitTagSHA(dir string) (string, error) {
 	// when in a release branch we need to skip 2 rather that 1 to find the revision of the previous tag
@@ -454,6 +463,11 @@ func (g *GitCLI) GetCurrentGitTagSHA(dir string) (string, error) {
 	return g.gitCmdWithOutput(dir, "rev-list", "--tags", "--max-count=1")
 }
 
+// GetLatestCommitSha returns the SHA of the HEAD commit of the repository at the given directory
+func (g *GitCLI) GetLatestCommitSha(dir string) (string, error) {
+	return g.gitCmdWithOutput(dir, "rev-parse", "HEAD")
+}
+
 // FetchTags fetches all the tags
 func (g *GitCLI) FetchTags(dir string) error {
 	return g.gitCmd("", "fetch", "--tags", "-v")
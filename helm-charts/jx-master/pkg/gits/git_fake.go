@@ -37,6 +37,9 @@ type GitFake struct {
 	Changes        bool
 	GitTags        []GitTag
 	Revision       string
+
+	// DefaultBranchName is the default branch of the origin remote
+	DefaultBranchName string
 }
 
 func (g *GitFake) FindGitConfigDir(dir string) (string, string, error) {
@@ -221,6 +224,10 @@ func (g *GitFake) RemoteBranches(dir string) ([]string, error) {
 	return g.BranchesRemote, nil
 }
 
+func (g *GitFake) DefaultBranch(dir string) (string, error) {
+	return g.DefaultBranchName, nil
+}
+
 func (g *GitFake) RemoteBranchNames(dir string, prefix string) ([]string, error) {
 	remoteBranches := []string{}
 	for _, remoteBranch := range g.BranchesRemote {
@@ -347,6 +354,14 @@ func (g *GitFake) GetCurrentGitTagSHA(dir string) (string, error) {
 	return g.Commits[len-1].SHA, nil
 }
 
+func (g *GitFake) GetLatestCommitSha(dir string) (string, error) {
+	len := len(g.Commits)
+	if len < 1 {
+		return "", errors.New("no commits found")
+	}
+	return g.Commits[len-1].SHA, nil
+}
+
 func (g *GitFake) FetchTags(dir string) error {
 	return nil
 }
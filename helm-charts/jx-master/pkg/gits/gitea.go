@@ -654,3 +654,74 @@ func (p *GiteaProvider) UserInfo(username string) *GitUser {
 		URL: p.Server.URL + "/" + username,
 	}
 }
+
+func (p *GiteaProvider) MergePullRequestUsingMethod(pr *GitPullRequest, message string, method string) error {
+	if method != "" && method != MergeMethodMerge {
+		return fmt.Errorf("The %s merge method is not supported for gitea", method)
+	}
+	return p.MergePullRequest(pr, message)
+}
+
+func (p *GiteaProvider) FindOpenPullRequest(owner string, repo string, head string) (*GitPullRequest, error) {
+	prs, err := p.Client.ListRepoPullRequests(owner, repo, gitea.ListPullRequestsOptions{State: "open"})
+	if err != nil {
+		return nil, err
+	}
+	for _, pr := range prs {
+		if pr.Head == nil || pr.Head.Ref != head {
+			continue
+		}
+		id := int(pr.Index)
+		answer := &GitPullRequest{
+			URL:    pr.HTMLURL,
+			Number: &id,
+			Owner:  owner,
+			Repo:   repo,
+		}
+		return answer, p.UpdatePullRequestStatus(answer)
+	}
+	return nil, nil
+}
+
+func (p *GiteaProvider) ClosePullRequest(pr *GitPullRequest) error {
+	if pr.Number == nil {
+		return fmt.Errorf("Missing Number for Pull Request %s", pr.URL)
+	}
+	n := int64(*pr.Number)
+	// the title and body are always sent so lets keep their current values
+	current, err := p.Client.GetPullRequest(pr.Owner, pr.Repo, n)
+	if err != nil {
+		return err
+	}
+	state := "closed"
+	_, err = p.Client.EditPullRequest(pr.Owner, pr.Repo, n, gitea.EditPullRequestOption{
+		Title: current.Title,
+		Body:  current.Body,
+		State: &state,
+	})
+	if err != nil {
+		return err
+	}
+	pr.markClosed(state)
+	return nil
+}
+
+func (p *GiteaProvider) PullRequestApprovers(pr *GitPullRequest) ([]string, error) {
+	return nil, fmt.Errorf("Listing the approvers of Pull Requests is not supported for gitea")
+}
+
+func (p *GiteaProvider) AddLabelsToPullRequest(pr *GitPullRequest, labels []string) error {
+	return fmt.Errorf("Adding labels to Pull Requests is not supported for gitea")
+}
+
+func (p *GiteaProvider) RequestReviewers(pr *GitPullRequest, reviewers []string) error {
+	return fmt.Errorf("Requesting reviewers of Pull Requests is not supported for gitea")
+}
+
+func (p *GiteaProvider) CreateDeployment(owner string, repo string, ref string, environment string) (*GitDeployment, error) {
+	return nil, fmt.Errorf("Deployments are not supported for gitea")
+}
+
+func (p *GiteaProvider) DeploymentStatus(owner string, repo string, id int64) (string, error) {
+	return "", fmt.Errorf("Deployments are not supported for gitea")
+}
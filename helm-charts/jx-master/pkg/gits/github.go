@@ -498,13 +498,18 @@ func (p *GitHubProvider) GetPullRequestCommits(owner string, repository *GitRepo
 }
 
 func (p *GitHubProvider) MergePullRequest(pr *GitPullRequest, message string) error {
+	return p.MergePullRequestUsingMethod(pr, message, "")
+}
+
+func (p *GitHubProvider) MergePullRequestUsingMethod(pr *GitPullRequest, message string, method string) error {
 	if pr.Number == nil {
 		return fmt.Errorf("Missing Number for GitPullRequest %#v", pr)
 	}
 	n := *pr.Number
 	ref := pr.LastCommitSha
 	options := &github.PullRequestOptions{
-		SHA: ref,
+		SHA:         ref,
+		MergeMethod: method,
 	}
 	result, _, err := p.Client.PullRequests.Merge(p.Context, pr.Owner, pr.Repo, n, message, options)
 	if err != nil {
@@ -543,6 +548,94 @@ func (p *GitHubProvider) CreateIssueComment(owner string, repo string, number in
 	return nil
 }
 
+func (p *GitHubProvider) AddLabelsToPullRequest(pr *GitPullRequest, labels []string) error {
+	if pr.Number == nil {
+		return fmt.Errorf("Missing Number for Pull Request %s", pr.URL)
+	}
+	_, _, err := p.Client.Issues.AddLabelsToIssue(p.Context, pr.Owner, pr.Repo, *pr.Number, labels)
+	return err
+}
+
+func (p *GitHubProvider) RequestReviewers(pr *GitPullRequest, reviewers []string) error {
+	if pr.Number == nil {
+		return fmt.Errorf("Missing Number for Pull Request %s", pr.URL)
+	}
+	request := github.ReviewersRequest{}
+	for _, reviewer := range reviewers {
+		paths := strings.Split(reviewer, "/")
+		if len(paths) == 2 {
+			request.TeamReviewers = append(request.TeamReviewers, paths[1])
+		} else {
+			request.Reviewers = append(request.Reviewers, reviewer)
+		}
+	}
+	_, _, err := p.Client.PullRequests.RequestReviewers(p.Context, pr.Owner, pr.Repo, *pr.Number, request)
+	return err
+}
+
+func (p *GitHubProvider) FindOpenPullRequest(owner string, repo string, head string) (*GitPullRequest, error) {
+	opts := &github.PullRequestListOptions{
+		State: "open",
+		Head:  owner + ":" + head,
+	}
+	prs, _, err := p.Client.PullRequests.List(p.Context, owner, repo, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, pr := range prs {
+		if pr.Number == nil {
+			continue
+		}
+		answer := &GitPullRequest{
+			URL:    notNullString(pr.HTMLURL),
+			Owner:  owner,
+			Repo:   repo,
+			Number: pr.Number,
+		}
+		return answer, p.UpdatePullRequestStatus(answer)
+	}
+	return nil, nil
+}
+
+func (p *GitHubProvider) ClosePullRequest(pr *GitPullRequest) error {
+	if pr.Number == nil {
+		return fmt.Errorf("Missing Number for Pull Request %s", pr.URL)
+	}
+	state := "closed"
+	_, _, err := p.Client.PullRequests.Edit(p.Context, pr.Owner, pr.Repo, *pr.Number, &github.PullRequest{State: &state})
+	if err != nil {
+		return err
+	}
+	pr.markClosed(state)
+	return nil
+}
+
+func (p *GitHubProvider) PullRequestApprovers(pr *GitPullRequest) ([]string, error) {
+	if pr.Number == nil {
+		return nil, fmt.Errorf("Missing Number for Pull Request %s", pr.URL)
+	}
+	reviews, _, err := p.Client.PullRequests.ListReviews(p.Context, pr.Owner, pr.Repo, *pr.Number, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the reviews are in chronological order so the last review of each user wins
+	states := map[string]string{}
+	for _, review := range reviews {
+		user := review.GetUser().GetLogin()
+		state := review.GetState()
+		if user != "" && state != "COMMENTED" {
+			states[user] = state
+		}
+	}
+	approvers := []string{}
+	for _, user := range util.SortedMapKeys(states) {
+		if states[user] == "APPROVED" {
+			approvers = append(approvers, user)
+		}
+	}
+	return approvers, nil
+}
+
 func (p *GitHubProvider) PullRequestLastCommitStatus(pr *GitPullRequest) (string, error) {
 	ref := pr.LastCommitSha
 	if ref == "" {
@@ -891,6 +984,37 @@ func (p *GitHubProvider) UserInfo(username string) *GitUser {
 	}
 }
 
+func (p *GitHubProvider) CreateDeployment(owner string, repo string, ref string, environment string) (*GitDeployment, error) {
+	autoMerge := false
+	request := &github.DeploymentRequest{
+		Ref:         &ref,
+		Environment: &environment,
+		AutoMerge:   &autoMerge,
+	}
+	deployment, _, err := p.Client.Repositories.CreateDeployment(p.Context, owner, repo, request)
+	if err != nil {
+		return nil, err
+	}
+	return &GitDeployment{
+		ID:          notNullInt64(deployment.ID),
+		URL:         notNullString(deployment.URL),
+		Ref:         notNullString(deployment.Ref),
+		Environment: notNullString(deployment.Environment),
+	}, nil
+}
+
+func (p *GitHubProvider) DeploymentStatus(owner string, repo string, id int64) (string, error) {
+	statuses, _, err := p.Client.Repositories.ListDeploymentStatuses(p.Context, owner, repo, id, nil)
+	if err != nil {
+		return "", err
+	}
+	if len(statuses) == 0 {
+		return "pending", nil
+	}
+	// the statuses are returned newest first
+	return notNullString(statuses[0].State), nil
+}
+
 func asBool(b *bool) bool {
 	if b != nil {
 		return *b
@@ -209,6 +209,29 @@ func fromMergeRequest(mr *gitlab.MergeRequest, owner, repo string) *GitPullReque
 	}
 }
 
+func (g *GitlabProvider) AddLabelsToPullRequest(pr *GitPullRequest, labels []string) error {
+	if pr.Number == nil {
+		return fmt.Errorf("Missing Number for Merge Request %s", pr.URL)
+	}
+	pid := projectId(pr.Owner, g.Username, pr.Repo)
+	mr, _, err := g.Client.MergeRequests.GetMergeRequest(pid, *pr.Number)
+	if err != nil {
+		return err
+	}
+	answer := gitlab.Labels(mr.Labels)
+	for _, label := range labels {
+		if util.StringArrayIndex(answer, label) < 0 {
+			answer = append(answer, label)
+		}
+	}
+	_, _, err = g.Client.MergeRequests.UpdateMergeRequest(pid, *pr.Number, &gitlab.UpdateMergeRequestOptions{Labels: answer})
+	return err
+}
+
+func (g *GitlabProvider) RequestReviewers(pr *GitPullRequest, reviewers []string) error {
+	return fmt.Errorf("Requesting reviewers of Pull Requests is not supported for gitlab")
+}
+
 func (g *GitlabProvider) UpdatePullRequestStatus(pr *GitPullRequest) error {
 	owner := pr.Owner
 	repo := pr.Repo
@@ -509,6 +532,58 @@ func (p *GitlabProvider) UserInfo(username string) *GitUser {
 	}
 }
 
+func (g *GitlabProvider) MergePullRequestUsingMethod(pr *GitPullRequest, message string, method string) error {
+	if method != "" && method != MergeMethodMerge {
+		return fmt.Errorf("The %s merge method is not supported for gitlab", method)
+	}
+	return g.MergePullRequest(pr, message)
+}
+
+func (g *GitlabProvider) FindOpenPullRequest(owner string, repo string, head string) (*GitPullRequest, error) {
+	pid := projectId(owner, g.Username, repo)
+	state := "opened"
+	opts := &gitlab.ListProjectMergeRequestsOptions{
+		State: &state,
+	}
+	mrs, _, err := g.Client.MergeRequests.ListProjectMergeRequests(pid, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, mr := range mrs {
+		if mr.SourceBranch == head {
+			return fromMergeRequest(mr, owner, repo), nil
+		}
+	}
+	return nil, nil
+}
+
+func (g *GitlabProvider) ClosePullRequest(pr *GitPullRequest) error {
+	if pr.Number == nil {
+		return fmt.Errorf("Missing Number for Merge Request %s", pr.URL)
+	}
+	pid := projectId(pr.Owner, g.Username, pr.Repo)
+	stateEvent := "close"
+	opt := &gitlab.UpdateMergeRequestOptions{StateEvent: &stateEvent}
+	mr, _, err := g.Client.MergeRequests.UpdateMergeRequest(pid, *pr.Number, opt)
+	if err != nil {
+		return err
+	}
+	pr.markClosed(mr.State)
+	return nil
+}
+
+func (g *GitlabProvider) PullRequestApprovers(pr *GitPullRequest) ([]string, error) {
+	return nil, fmt.Errorf("Listing the approvers of Pull Requests is not supported for gitlab")
+}
+
+func (g *GitlabProvider) CreateDeployment(owner string, repo string, ref string, environment string) (*GitDeployment, error) {
+	return nil, fmt.Errorf("Deployments are not supported for gitlab")
+}
+
+func (g *GitlabProvider) DeploymentStatus(owner string, repo string, id int64) (string, error) {
+	return "", fmt.Errorf("Deployments are not supported for gitlab")
+}
+
 func (g *GitlabProvider) UpdateRelease(owner string, repo string, tag string, releaseInfo *GitRelease) error {
 	return nil
 }
@@ -24,6 +24,12 @@ var gitlabRouter = util.Router{
 	"/api/v4/projects/testperson%2Ftest-project": util.MethodMap{
 		"GET": "project.json",
 	},
+	"/api/v4/projects/testperson%2Ftest-project/merge_requests": util.MethodMap{
+		"GET": "merge-requests.json",
+	},
+	"/api/v4/projects/testperson%2Ftest-project/merge_requests/2": util.MethodMap{
+		"PUT": "merge-request-closed.json",
+	},
 }
 
 type GitlabProviderSuite struct {
@@ -142,6 +148,34 @@ func (suite *GitlabProviderSuite) TestGetRepository() {
 	suite.Require().Equal(repo.Name, "test-project")
 }
 
+func (suite *GitlabProviderSuite) TestFindOpenPullRequest() {
+	pr, err := suite.provider.FindOpenPullRequest("testperson", "test-project", "promote-myapp-1.0.0")
+
+	suite.Require().Nil(err)
+	suite.Require().NotNil(pr)
+	suite.Require().Equal(2, *pr.Number)
+	suite.Require().Equal("https://gitlab.com/testperson/test-project/merge_requests/2", pr.URL)
+
+	pr, err = suite.provider.FindOpenPullRequest("testperson", "test-project", "promote-myapp-2.0.0")
+
+	suite.Require().Nil(err)
+	suite.Require().Nil(pr)
+}
+
+func (suite *GitlabProviderSuite) TestClosePullRequest() {
+	number := 2
+	pr := &GitPullRequest{
+		URL:    "https://gitlab.com/testperson/test-project/merge_requests/2",
+		Owner:  "testperson",
+		Repo:   "test-project",
+		Number: &number,
+	}
+	err := suite.provider.ClosePullRequest(pr)
+
+	suite.Require().Nil(err)
+	suite.Require().True(pr.IsClosed())
+}
+
 // In order for 'go test' to run this suite, we need to create
 // a normal test function and pass our suite to suite.Run
 func TestGitlabProviderSuite(t *testing.T) {
@@ -2,15 +2,28 @@ package gits
 
 import (
 	"fmt"
+	"net"
+	"net/http"
 	"net/url"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/google/go-github/github"
 	"github.com/jenkins-x/jx/pkg/auth"
+	"github.com/wbrefvem/go-gitlab"
 	"gopkg.in/AlecAivazis/survey.v1"
 )
 
+var (
+	// transientStatusCodeRegex matches the HTTP status codes of transient failures in the errors of the git providers
+	transientStatusCodeRegex = regexp.MustCompile(`(^|[:\s])(429|5\d\d)(\s|$)`)
+
+	// transientErrorMessages are the messages of transient network failures
+	transientErrorMessages = []string{"connection reset", "connection refused", "i/o timeout", "tls handshake timeout", "unexpected eof", "temporarily unavailable"}
+)
+
 type OrganisationLister interface {
 	ListOrganisations() ([]GitOrganisation, error)
 }
@@ -40,12 +53,23 @@ type GitProvider interface {
 
 	GetPullRequestCommits(owner string, repo *GitRepositoryInfo, number int) ([]*GitCommit, error)
 
+	// FindOpenPullRequest returns the open Pull Request of the repository from the given head branch or nil if there
+	// is no such Pull Request
+	FindOpenPullRequest(owner string, repo string, head string) (*GitPullRequest, error)
+
+	// ClosePullRequest closes the Pull Request without merging it
+	ClosePullRequest(pr *GitPullRequest) error
+
 	PullRequestLastCommitStatus(pr *GitPullRequest) (string, error)
 
 	ListCommitStatus(org string, repo string, sha string) ([]*GitRepoStatus, error)
 
 	MergePullRequest(pr *GitPullRequest, message string) error
 
+	// MergePullRequestUsingMethod merges the Pull Request using one of the SupportedMergeMethods of the provider.
+	// The default method of the provider is used if the method is blank
+	MergePullRequestUsingMethod(pr *GitPullRequest, message string, method string) error
+
 	CreateWebHook(data *GitWebHookArguments) error
 
 	IsGitHub() bool
@@ -80,6 +104,21 @@ type GitProvider interface {
 
 	ListReleases(org string, name string) ([]*GitRelease, error)
 
+	// AddLabelsToPullRequest adds the labels to the Pull Request keeping any existing labels
+	AddLabelsToPullRequest(pr *GitPullRequest, labels []string) error
+
+	// RequestReviewers requests the review of the Pull Request by the users or 'org/team' teams
+	RequestReviewers(pr *GitPullRequest, reviewers []string) error
+
+	// PullRequestApprovers returns the users whose latest review of the Pull Request approves it
+	PullRequestApprovers(pr *GitPullRequest) ([]string, error)
+
+	// CreateDeployment creates a deployment of the given ref to the named environment of the repository
+	CreateDeployment(owner string, repo string, ref string, environment string) (*GitDeployment, error)
+
+	// DeploymentStatus returns the latest state of the deployment such as waiting, queued, in_progress or success
+	DeploymentStatus(owner string, repo string, id int64) (string, error)
+
 	// returns the path relative to the Jenkins URL to trigger webhooks on this kind of repository
 	//
 
@@ -201,6 +240,13 @@ type GitRelease struct {
 	DownloadCount int
 }
 
+type GitDeployment struct {
+	ID          int64
+	URL         string
+	Ref         string
+	Environment string
+}
+
 type GitLabel struct {
 	URL   string
 	Name  string
@@ -243,6 +289,13 @@ func (pr *GitPullRequest) IsClosed() bool {
 	return pr.ClosedAt != nil
 }
 
+// markClosed records that the Pull Request has been closed with the given state
+func (pr *GitPullRequest) markClosed(state string) {
+	closedAt := time.Now()
+	pr.State = &state
+	pr.ClosedAt = &closedAt
+}
+
 func CreateProvider(server *auth.AuthServer, user *auth.UserAuth, git Gitter) (GitProvider, error) {
 	switch server.Kind {
 	case KindBitBucketCloud:
@@ -449,3 +502,30 @@ func CreateProviderForURL(authConfigSvc auth.AuthConfigService, gitKind string,
 	}
 	return nil, fmt.Errorf("Could not create Git provider for host %s as no user auths could be found", hostUrl)
 }
+
+// IsTransientError returns true if the error of a git provider is a transient failure such as a network error or a
+// 5xx or 429 response which is worth retrying rather than a genuine failure such as a 404 for a missing Pull Request
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch e := err.(type) {
+	case *github.ErrorResponse:
+		return e.Response != nil && isTransientStatusCode(e.Response.StatusCode)
+	case *gitlab.ErrorResponse:
+		return e.Response != nil && isTransientStatusCode(e.Response.StatusCode)
+	case net.Error:
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	for _, m := range transientErrorMessages {
+		if strings.Contains(message, m) {
+			return true
+		}
+	}
+	return transientStatusCodeRegex.MatchString(message)
+}
+
+func isTransientStatusCode(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
@@ -3,6 +3,7 @@ package gits
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -33,14 +34,19 @@ const (
 )
 
 type FakeCommit struct {
-	Commit *GitCommit
-	Status CommitStatus
+	Commit  *GitCommit
+	Status  CommitStatus
+	Context string
 }
 
 type FakePullRequest struct {
 	PullRequest *GitPullRequest
 	Commits     []*FakeCommit
 	Comment     string
+	Labels      []string
+	Reviewers   []string
+	Approvers   []string
+	MergeMethod string
 }
 
 type FakeIssue struct {
@@ -48,6 +54,12 @@ type FakeIssue struct {
 	Comment string
 }
 
+type FakeDeployment struct {
+	Deployment *GitDeployment
+	// Statuses the states returned by each query of the deployment status; the last state is then returned forever
+	Statuses []string
+}
+
 type FakeRepository struct {
 	GitRepo      *GitRepository
 	PullRequests map[int]*FakePullRequest
@@ -55,6 +67,9 @@ type FakeRepository struct {
 	Commits      []*FakeCommit
 	issueCount   int
 	Releases     map[string]*GitRelease
+	Deployments  map[int64]*FakeDeployment
+	// DeploymentStatuses the states of the deployments created for each environment name
+	DeploymentStatuses map[string][]string
 }
 
 type FakeProvider struct {
@@ -182,7 +197,7 @@ func (f *FakeProvider) CreatePullRequest(data *GitPullRequestArguments) (*GitPul
 		Number:         &number,
 		Mergeable:      nil,
 		Merged:         nil,
-		HeadRef:        nil,
+		HeadRef:        &data.Head,
 		State:          nil,
 		StatusesURL:    nil,
 		IssueURL:       nil,
@@ -290,6 +305,7 @@ func (f *FakeProvider) ListCommitStatus(org string, repoName string, sha string)
 		if commit.Commit.SHA == sha {
 			status := &GitRepoStatus{
 				ID:          commit.Commit.SHA,
+				Context:     commit.Context,
 				URL:         commit.Commit.URL,
 				State:       string(commit.Status),
 				Description: commit.Commit.Message,
@@ -302,7 +318,96 @@ func (f *FakeProvider) ListCommitStatus(org string, repoName string, sha string)
 	return answer, nil
 }
 
+func (f *FakeProvider) AddLabelsToPullRequest(pr *GitPullRequest, labels []string) error {
+	repo, err := f.findRepository(pr.Owner, pr.Repo)
+	if err != nil {
+		return err
+	}
+	fakePR, ok := repo.PullRequests[*pr.Number]
+	if !ok {
+		return fmt.Errorf("pull request with id '%d' not found", *pr.Number)
+	}
+	for _, label := range labels {
+		if util.StringArrayIndex(fakePR.Labels, label) < 0 {
+			fakePR.Labels = append(fakePR.Labels, label)
+		}
+	}
+	return nil
+}
+
+func (f *FakeProvider) RequestReviewers(pr *GitPullRequest, reviewers []string) error {
+	repo, err := f.findRepository(pr.Owner, pr.Repo)
+	if err != nil {
+		return err
+	}
+	fakePR, ok := repo.PullRequests[*pr.Number]
+	if !ok {
+		return fmt.Errorf("pull request with id '%d' not found", *pr.Number)
+	}
+	for _, reviewer := range reviewers {
+		if util.StringArrayIndex(fakePR.Reviewers, reviewer) < 0 {
+			fakePR.Reviewers = append(fakePR.Reviewers, reviewer)
+		}
+	}
+	return nil
+}
+
+func (f *FakeProvider) FindOpenPullRequest(owner string, repoName string, head string) (*GitPullRequest, error) {
+	repo, err := f.findRepository(owner, repoName)
+	if err != nil {
+		return nil, err
+	}
+	numbers := []int{}
+	for number := range repo.PullRequests {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	for _, number := range numbers {
+		pr := repo.PullRequests[number].PullRequest
+		merged := pr.Merged != nil && *pr.Merged
+		if pr.HeadRef != nil && *pr.HeadRef == head && !pr.IsClosed() && !merged {
+			return pr, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *FakeProvider) ClosePullRequest(pr *GitPullRequest) error {
+	repo, err := f.findRepository(pr.Owner, pr.Repo)
+	if err != nil {
+		return err
+	}
+	fakePR, ok := repo.PullRequests[*pr.Number]
+	if !ok {
+		return fmt.Errorf("pull request with id '%d' not found", *pr.Number)
+	}
+	fakePR.PullRequest.markClosed("closed")
+	if pr != fakePR.PullRequest {
+		pr.markClosed("closed")
+	}
+	return nil
+}
+
+func (f *FakeProvider) PullRequestApprovers(pr *GitPullRequest) ([]string, error) {
+	repo, err := f.findRepository(pr.Owner, pr.Repo)
+	if err != nil {
+		return nil, err
+	}
+	fakePR, ok := repo.PullRequests[*pr.Number]
+	if !ok {
+		return nil, fmt.Errorf("pull request with id '%d' not found", *pr.Number)
+	}
+	return fakePR.Approvers, nil
+}
+
 func (f *FakeProvider) MergePullRequest(pr *GitPullRequest, message string) error {
+	return f.MergePullRequestUsingMethod(pr, message, "")
+}
+
+func (f *FakeProvider) MergePullRequestUsingMethod(pr *GitPullRequest, message string, method string) error {
+	if method != "" && util.StringArrayIndex(SupportedMergeMethods(f.Kind()), method) < 0 {
+		return fmt.Errorf("The %s merge method is not supported for %s", method, f.Kind())
+	}
 	owner := pr.Owner
 	repos, ok := f.Repositories[owner]
 	if !ok {
@@ -312,10 +417,11 @@ func (f *FakeProvider) MergePullRequest(pr *GitPullRequest, message string) erro
 	number := *pr.Number
 	for _, r := range repos {
 		if r.GitRepo.Name == repoName {
-			_, ok := r.PullRequests[number]
+			fakePR, ok := r.PullRequests[number]
 			if !ok {
 				return fmt.Errorf("pull request with id '%d' not found", number)
 			}
+			fakePR.MergeMethod = method
 			delete(r.PullRequests, number)
 			return nil
 		}
@@ -343,6 +449,10 @@ func (f *FakeProvider) IsBitbucketServer() bool {
 	return f.Type == BitbucketServer
 }
 
+func (f *FakeProvider) IsGerrit() bool {
+	return false
+}
+
 func (f *FakeProvider) Kind() string {
 	switch f.Type {
 	case GitHub:
@@ -548,3 +658,56 @@ func (f *FakeProvider) UserInfo(username string) *GitUser {
 	}
 	return nil
 }
+
+func (f *FakeProvider) CreateDeployment(owner string, repo string, ref string, environment string) (*GitDeployment, error) {
+	r, err := f.findRepository(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	if r.Deployments == nil {
+		r.Deployments = map[int64]*FakeDeployment{}
+	}
+	id := int64(len(r.Deployments) + 1)
+	deployment := &GitDeployment{
+		ID:          id,
+		Ref:         ref,
+		Environment: environment,
+	}
+	r.Deployments[id] = &FakeDeployment{
+		Deployment: deployment,
+		Statuses:   r.DeploymentStatuses[environment],
+	}
+	return deployment, nil
+}
+
+func (f *FakeProvider) DeploymentStatus(owner string, repo string, id int64) (string, error) {
+	r, err := f.findRepository(owner, repo)
+	if err != nil {
+		return "", err
+	}
+	deployment, ok := r.Deployments[id]
+	if !ok {
+		return "", fmt.Errorf("deployment with id '%d' not found", id)
+	}
+	if len(deployment.Statuses) == 0 {
+		return "pending", nil
+	}
+	state := deployment.Statuses[0]
+	if len(deployment.Statuses) > 1 {
+		deployment.Statuses = deployment.Statuses[1:]
+	}
+	return state, nil
+}
+
+func (f *FakeProvider) findRepository(owner string, repoName string) (*FakeRepository, error) {
+	repos, ok := f.Repositories[owner]
+	if !ok {
+		return nil, fmt.Errorf("organization '%s' not found", owner)
+	}
+	for _, r := range repos {
+		if r.GitRepo.Name == repoName {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("repository with name '%s' not found", repoName)
+}
@@ -2,8 +2,13 @@ package gits
 
 import (
 	"errors"
-	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/url"
 	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/wbrefvem/go-gitlab"
 )
 
 type FakeOrgLister struct {
@@ -42,3 +47,29 @@ func Test_getOrganizations(t *testing.T) {
 		})
 	}
 }
+
+func TestIsTransientError(t *testing.T) {
+	request := &http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: "api.github.com", Path: "/repos/jstrachan/environment-production/pulls/1"}}
+	githubError := func(code int) error {
+		return &github.ErrorResponse{Response: &http.Response{StatusCode: code, Request: request}}
+	}
+	testCases := []struct {
+		err       error
+		transient bool
+	}{
+		{nil, false},
+		{githubError(http.StatusBadGateway), true},
+		{githubError(http.StatusTooManyRequests), true},
+		{githubError(http.StatusNotFound), false},
+		{&gitlab.ErrorResponse{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}}, true},
+		{&gitlab.ErrorResponse{Response: &http.Response{StatusCode: http.StatusUnauthorized}}, false},
+		{&url.Error{Op: "Get", URL: "https://api.github.com", Err: errors.New("dial tcp: lookup api.github.com: no such host")}, true},
+		{errors.New("read tcp 10.0.0.1:443: connection reset by peer"), true},
+		{errors.New("GET https://bitbucket.org/api/2.0/repositories/jstrachan/env/pullrequests/503: 503 Service Unavailable"), true},
+		{errors.New("GET https://bitbucket.org/api/2.0/repositories/jstrachan/env/pullrequests/503: 404 Not Found"), false},
+		{errors.New("pull request with id '1' not found"), false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.transient, IsTransientError(tc.err), "%v", tc.err)
+	}
+}
@@ -13,6 +13,7 @@ type Helmer interface {
 	SetCWD(dir string)
 	HelmBinary() string
 	SetHelmBinary(binary string)
+	SetKubeContext(context string)
 	Init(clientOnly bool, serviceAccount string, tillerNamespace string, upgrade bool) error
 	AddRepo(repo string, URL string) error
 	RemoveRepo(repo string) error
@@ -25,9 +26,13 @@ type Helmer interface {
 		values []string, valueFiles []string) error
 	UpgradeChart(chart string, releaseName string, ns string, version *string, install bool,
 		timeout *int, force bool, wait bool, values []string, valueFiles []string) error
+	FetchChart(chart string, version *string, untar bool, untardir string) error
+	Template(chartDir string, releaseName string, ns string, outputDir string, values []string,
+		valueFiles []string) error
 	DeleteRelease(releaseName string, purge bool) error
 	ListCharts() (string, error)
 	SearchChartVersions(chart string) ([]string, error)
+	SearchCharts(filter string) ([]string, error)
 	FindChart() (string, error)
 	PackageChart() error
 	StatusRelease(releaseName string) error
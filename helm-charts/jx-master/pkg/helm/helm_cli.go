@@ -21,10 +21,11 @@ type helmRunner struct {
 
 // HelmCLI implements common helm actions based on helm CLI
 type HelmCLI struct {
-	Binary     string
-	BinVersion Version
-	CWD        string
-	runner     helmRunner
+	Binary      string
+	BinVersion  Version
+	CWD         string
+	KubeContext string
+	runner      helmRunner
 }
 
 // NewHelmCLI creates a new HelmCLI instance configured to used the provided helm CLI in
@@ -65,12 +66,24 @@ func (h *HelmCLI) SetHelmBinary(binary string) {
 	h.Binary = binary
 }
 
+// SetKubeContext configures the kube context of the cluster helm operates on. The current context is used if empty
+func (h *HelmCLI) SetKubeContext(context string) {
+	h.KubeContext = context
+}
+
 func (h *HelmCLI) runHelm(args ...string) error {
-	return h.runner.run(h.CWD, h.Binary, args...)
+	return h.runner.run(h.CWD, h.Binary, h.withKubeContext(args)...)
 }
 
 func (h *HelmCLI) runHelmWithOutput(args ...string) (string, error) {
-	return h.runner.runWithOutput(h.CWD, h.Binary, args...)
+	return h.runner.runWithOutput(h.CWD, h.Binary, h.withKubeContext(args)...)
+}
+
+func (h *HelmCLI) withKubeContext(args []string) []string {
+	if h.KubeContext == "" {
+		return args
+	}
+	return append(args, "--kube-context", h.KubeContext)
 }
 
 // Init executes the helm init command according with the given flags
@@ -224,6 +237,37 @@ func (h *HelmCLI) UpgradeChart(chart string, releaseName string, ns string, vers
 	return h.runHelm(args...)
 }
 
+// FetchChart downloads the given chart from the helm repositories optionally unpacking it into the untardir
+func (h *HelmCLI) FetchChart(chart string, version *string, untar bool, untardir string) error {
+	args := []string{}
+	args = append(args, "fetch", chart)
+	if version != nil && *version != "" {
+		args = append(args, "--version", *version)
+	}
+	if untar {
+		args = append(args, "--untar")
+	}
+	if untardir != "" {
+		args = append(args, "--untardir", untardir)
+	}
+	return h.runHelm(args...)
+}
+
+// Template renders the manifests of the chart in the given directory into the output directory
+func (h *HelmCLI) Template(chartDir string, releaseName string, ns string, outputDir string, values []string,
+	valueFiles []string) error {
+	args := []string{}
+	args = append(args, "template", "--name", releaseName, "--namespace", ns, "--output-dir", outputDir)
+	for _, value := range values {
+		args = append(args, "--set", value)
+	}
+	for _, valueFile := range valueFiles {
+		args = append(args, "--values", valueFile)
+	}
+	args = append(args, chartDir)
+	return h.runHelm(args...)
+}
+
 // DeleteRelease removes the given release
 func (h *HelmCLI) DeleteRelease(releaseName string, purge bool) error {
 	args := []string{}
@@ -260,6 +304,30 @@ func (h *HelmCLI) SearchChartVersions(chart string) ([]string, error) {
 	return versions, nil
 }
 
+// SearchCharts searches the charts in the helm repositories which match the given filter returning their names
+func (h *HelmCLI) SearchCharts(filter string) ([]string, error) {
+	args := []string{"search"}
+	if filter != "" {
+		args = append(args, filter)
+	}
+	output, err := h.runHelmWithOutput(args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to search charts '%s'", filter)
+	}
+	charts := []string{}
+	lines := strings.Split(output, "\n")
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] == "NAME" || strings.HasPrefix(line, "No results") {
+			continue
+		}
+		if util.StringArrayIndex(charts, fields[0]) < 0 {
+			charts = append(charts, fields[0])
+		}
+	}
+	return charts, nil
+}
+
 // FindChart find a chart in the current working directory, if no chart file is found an error is returned
 func (h *HelmCLI) FindChart() (string, error) {
 	dir := h.CWD
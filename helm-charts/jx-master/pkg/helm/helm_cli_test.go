@@ -31,6 +31,11 @@ jenkins-x/jenkins-x-platform            0.0.1481                        Jenkins
 jenkins-x/jenkins-x-platform            0.0.1480                        Jenkins X 
 jenkins-x/jenkins-x-platform            0.0.1479                        Jenkins X 
 `
+const searchChartsOutput = `
+NAME                                    CHART VERSION   APP VERSION     DESCRIPTION
+jenkins-x/jenkins-x-platform            0.0.1481                        Jenkins X 
+jenkins-x/jenkins-x-prow                0.0.12                          Prow 
+`
 const listReleasesOutput = `
 NAME                            REVISION        UPDATED                         STATUS          CHART                           NAMESPACE
 jenkins-x                       1               Mon Jul  2 16:16:20 2018        DEPLOYED        jenkins-x-platform-0.0.1655     jx
@@ -179,6 +184,36 @@ func TestUpgradeChart(t *testing.T) {
 	assert.NoError(t, err, "should upgrade the chart without any error")
 }
 
+func TestUpgradeChartWithKubeContext(t *testing.T) {
+	version := "0.0.1"
+	expectedArgs := fmt.Sprintf("upgrade --namespace %s --install --version %s %s %s --kube-context other-cluster",
+		namespace, version, releaseName, chart)
+	helm := createHelm(expectedArgs)
+	helm.SetKubeContext("other-cluster")
+	err := helm.UpgradeChart(chart, releaseName, namespace, &version, true, nil, false, false, nil, nil)
+	assert.NoError(t, err, "should upgrade the chart in the cluster of the kube context without any error")
+}
+
+func TestFetchChart(t *testing.T) {
+	version := "0.0.1"
+	untardir := "/tmp/charts"
+	expectedArgs := fmt.Sprintf("fetch %s --version %s --untar --untardir %s", chart, version, untardir)
+	helm := createHelm(expectedArgs)
+	err := helm.FetchChart(chart, &version, true, untardir)
+	assert.NoError(t, err, "should fetch the chart without any error")
+}
+
+func TestTemplate(t *testing.T) {
+	value := []string{"test"}
+	valueFile := []string{"./myvalues.yaml"}
+	outputDir := "/tmp/output"
+	expectedArgs := fmt.Sprintf("template --name %s --namespace %s --output-dir %s --set %s --values %s %s",
+		releaseName, namespace, outputDir, value[0], valueFile[0], chart)
+	helm := createHelm(expectedArgs)
+	err := helm.Template(chart, releaseName, namespace, outputDir, value, valueFile)
+	assert.NoError(t, err, "should render the chart templates without any error")
+}
+
 func TestDeleteRelaese(t *testing.T) {
 	expectedArgs := fmt.Sprintf("delete --purge %s", releaseName)
 	helm := createHelm(expectedArgs)
@@ -240,6 +275,20 @@ func TestSearchChartVersions(t *testing.T) {
 	}
 }
 
+func TestSearchCharts(t *testing.T) {
+	expectedOutput := searchChartsOutput
+	expectedArgs := "search jenkins-x/jenkins-x"
+	helm := createHelmWithOutput(expectedArgs, expectedOutput)
+	charts, err := helm.SearchCharts("jenkins-x/jenkins-x")
+	assert.NoError(t, err, "should search charts without any error")
+	assert.Equal(t, []string{"jenkins-x/jenkins-x-platform", "jenkins-x/jenkins-x-prow"}, charts)
+
+	helm = createHelmWithOutput("search cheese", "No results found\n")
+	charts, err = helm.SearchCharts("cheese")
+	assert.NoError(t, err, "should search charts without any error")
+	assert.Empty(t, charts)
+}
+
 func TestFindChart(t *testing.T) {
 	chartFile := "Chart.yaml"
 	dir, err := ioutil.TempDir("/tmp", "charttest")
@@ -38,6 +38,10 @@ func (h *HelmFake) SetCWD(dir string) {
 	h.helm.SetCWD(dir)
 }
 
+func (h *HelmFake) SetKubeContext(context string) {
+	h.helm.SetKubeContext(context)
+}
+
 func (h *HelmFake) HelmBinary() string {
 	return h.helm.HelmBinary()
 }
@@ -88,6 +92,15 @@ func (h *HelmFake) UpgradeChart(chart string, releaseName string, ns string, ver
 	return h.helm.UpgradeChart(chart, releaseName, ns, version, install, timeout, force, wait, values, valueFiles)
 }
 
+func (h *HelmFake) FetchChart(chart string, version *string, untar bool, untardir string) error {
+	return h.helm.FetchChart(chart, version, untar, untardir)
+}
+
+func (h *HelmFake) Template(chartDir string, releaseName string, ns string, outputDir string, values []string,
+	valueFiles []string) error {
+	return h.helm.Template(chartDir, releaseName, ns, outputDir, values, valueFiles)
+}
+
 func (h *HelmFake) DeleteRelease(releaseName string, purge bool) error {
 	return h.helm.DeleteRelease(releaseName, purge)
 }
@@ -100,6 +113,10 @@ func (h *HelmFake) SearchChartVersions(chart string) ([]string, error) {
 	return h.helm.SearchChartVersions(chart)
 }
 
+func (h *HelmFake) SearchCharts(filter string) ([]string, error) {
+	return h.helm.SearchCharts(filter)
+}
+
 func (h *HelmFake) FindChart() (string, error) {
 	return h.helm.FindChart()
 }
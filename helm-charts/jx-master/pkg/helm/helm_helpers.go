@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strings"
 
 	"github.com/ghodss/yaml"
 	"github.com/jenkins-x/jx/pkg/util"
@@ -13,12 +15,21 @@ import (
 
 const (
 	RequirementsFileName = "requirements.yaml"
+	ValuesFileName       = "values.yaml"
 
 	DefaultHelmRepositoryURL = "http://jenkins-x-chartmuseum:8080"
 
 	defaultEnvironmentChartDir = "env"
+
+	// OCIScheme is the scheme of a reference to a chart in an OCI registry
+	OCIScheme = "oci://"
+
+	// DigestPrefix is the prefix of a version which pins a chart by its sha256 digest
+	DigestPrefix = "sha256:"
 )
 
+var digestRegex = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
 // copied from helm to minimise dependencies...
 
 // Dependency describes a chart upon which another chart depends.
@@ -71,8 +82,10 @@ func (a DepSorter) Len() int           { return len(a) }
 func (a DepSorter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a DepSorter) Less(i, j int) bool { return a[i].Name < a[j].Name }
 
-// SetAppVersion sets the version of the app to use
+// SetAppVersion sets the version of the app to use. A version pinned by digest is stored verbatim and the repository
+// of an OCI reference is used instead of the given repository
 func (r *Requirements) SetAppVersion(app string, version string, repository string) {
+	repository = dependencyRepository(version, repository)
 	if r.Dependencies == nil {
 		r.Dependencies = []*Dependency{}
 	}
@@ -91,6 +104,95 @@ func (r *Requirements) SetAppVersion(app string, version string, repository stri
 	sort.Sort(DepSorter(r.Dependencies))
 }
 
+// SetAliasedAppVersion sets the version of the chart used by the app with the given alias so that a single chart can
+// be deployed as many different apps in the same environment
+func (r *Requirements) SetAliasedAppVersion(chart string, alias string, version string, repository string) {
+	repository = dependencyRepository(version, repository)
+	if r.Dependencies == nil {
+		r.Dependencies = []*Dependency{}
+	}
+	for _, dep := range r.Dependencies {
+		if dep != nil && dep.Name == chart && dep.Alias == alias {
+			dep.Version = version
+			dep.Repository = repository
+			return
+		}
+	}
+	r.Dependencies = append(r.Dependencies, &Dependency{
+		Name:       chart,
+		Alias:      alias,
+		Version:    version,
+		Repository: repository,
+	})
+	sort.Sort(DepSorter(r.Dependencies))
+}
+
+// IsDigestVersion returns true if the version pins a chart by digest rather than being a semantic version. The version
+// is either 'sha256:<digest>' or an OCI reference such as 'oci://registry/charts/myapp@sha256:<digest>'
+func IsDigestVersion(version string) bool {
+	return strings.HasPrefix(version, DigestPrefix) || strings.HasPrefix(version, OCIScheme)
+}
+
+// ValidateDigestVersion returns an error if the version is not a sha256 digest or an OCI reference pinned by a sha256
+// digest
+func ValidateDigestVersion(version string) error {
+	digest := version
+	if strings.HasPrefix(version, OCIScheme) {
+		idx := strings.LastIndex(version, "@")
+		if idx < 0 {
+			return fmt.Errorf("The OCI reference %s is not pinned by digest. Use %sregistry/charts/myapp@%s<digest>", version, OCIScheme, DigestPrefix)
+		}
+		ref := strings.TrimPrefix(version[0:idx], OCIScheme)
+		if strings.Index(ref, "/") <= 0 || strings.HasSuffix(ref, "/") {
+			return fmt.Errorf("The OCI reference %s does not include the registry and chart name. Use %sregistry/charts/myapp@%s<digest>", version, OCIScheme, DigestPrefix)
+		}
+		digest = version[idx+1:]
+	}
+	if !digestRegex.MatchString(digest) {
+		return fmt.Errorf("The digest %s is not valid. A digest is %s followed by 64 lowercase hexadecimal characters", digest, DigestPrefix)
+	}
+	return nil
+}
+
+// dependencyRepository returns the repository of the chart in the OCI registry if the version is an OCI reference,
+// otherwise the given repository
+func dependencyRepository(version string, repository string) string {
+	if !strings.HasPrefix(version, OCIScheme) {
+		return repository
+	}
+	ref := version
+	idx := strings.LastIndex(ref, "@")
+	if idx >= 0 {
+		ref = ref[0:idx]
+	}
+	idx = strings.LastIndex(ref, "/")
+	if idx <= len(OCIScheme) {
+		return repository
+	}
+	return ref[0:idx]
+}
+
+// FindAppVersion returns the version of the given app or an empty string if the app is not a dependency
+func (r *Requirements) FindAppVersion(app string) string {
+	for _, dep := range r.Dependencies {
+		if dep != nil && dep.Name == app {
+			return dep.Version
+		}
+	}
+	return ""
+}
+
+// FindAliasedAppVersion returns the version of the chart used by the app with the given alias or an empty string if
+// the app is not a dependency
+func (r *Requirements) FindAliasedAppVersion(chart string, alias string) string {
+	for _, dep := range r.Dependencies {
+		if dep != nil && dep.Name == chart && dep.Alias == alias {
+			return dep.Version
+		}
+	}
+	return ""
+}
+
 // RemoveApp removes the given app name. Returns true if a dependency was removed
 func (r *Requirements) RemoveApp(app string) bool {
 	for i, dep := range r.Dependencies {
@@ -183,6 +285,70 @@ func SaveRequirementsFile(fileName string, requirements *Requirements) error {
 	return ioutil.WriteFile(fileName, data, util.DefaultWritePermissions)
 }
 
+// LoadValuesFile loads the values file or creates empty values if the file does not exist
+func LoadValuesFile(fileName string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	exists, err := util.FileExists(fileName)
+	if err != nil {
+		return values, err
+	}
+	if exists {
+		data, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return values, err
+		}
+		err = yaml.Unmarshal(data, &values)
+		if err != nil {
+			return values, err
+		}
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+	}
+	return values, nil
+}
+
+// SaveValuesFile saves the values file
+func SaveValuesFile(fileName string, values map[string]interface{}) error {
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, util.DefaultWritePermissions)
+}
+
+// SetValue sets the value at the given dot separated path such as 'foo.bar' creating any missing nested maps
+func SetValue(values map[string]interface{}, path string, value interface{}) {
+	paths := strings.Split(path, ".")
+	last := len(paths) - 1
+	m := values
+	for _, p := range paths[0:last] {
+		child, ok := m[p].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			m[p] = child
+		}
+		m = child
+	}
+	m[paths[last]] = value
+}
+
+// MergeValues merges the values from src into dst recursively so that nested maps are combined and the values of src
+// take precedence
+func MergeValues(dst map[string]interface{}, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, ok := v.(map[string]interface{})
+		if ok {
+			dstMap, ok := dst[k].(map[string]interface{})
+			if ok {
+				MergeValues(dstMap, srcMap)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
 func LoadChartName(chartFile string) (string, error) {
 	chart, err := chartutil.LoadChartfile(chartFile)
 	if err != nil {
@@ -0,0 +1,81 @@
+package helm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestVersions(t *testing.T) {
+	digest := DigestPrefix + strings.Repeat("ab", 32)
+	testCases := []struct {
+		version string
+		digest  bool
+		valid   bool
+	}{
+		{"1.2.3", false, false},
+		{"^1.2.0", false, false},
+		{digest, true, true},
+		{"oci://registry.example.com/charts/myapp@" + digest, true, true},
+		{"sha256:abc", true, false},
+		{DigestPrefix + strings.Repeat("AB", 32), true, false},
+		{"oci://registry.example.com/charts/myapp:1.2.3", true, false},
+		{"oci://myapp@" + digest, true, false},
+		{"oci://registry.example.com/@" + digest, true, false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.digest, IsDigestVersion(tc.version), "IsDigestVersion(%s)", tc.version)
+		if tc.digest {
+			err := ValidateDigestVersion(tc.version)
+			if tc.valid {
+				assert.NoError(t, err, tc.version)
+			} else {
+				assert.Error(t, err, tc.version)
+			}
+		}
+	}
+}
+
+func TestSetAppVersionDigest(t *testing.T) {
+	digest := DigestPrefix + strings.Repeat("ab", 32)
+	requirements := &Requirements{}
+	requirements.SetAppVersion("myapp", digest, "http://chartmuseum")
+	requirements.SetAliasedAppVersion("shared", "otherapp", "oci://registry.example.com/charts/shared@"+digest, "http://chartmuseum")
+	if assert.Len(t, requirements.Dependencies, 2) {
+		assert.Equal(t, digest, requirements.Dependencies[0].Version)
+		assert.Equal(t, "http://chartmuseum", requirements.Dependencies[0].Repository)
+		assert.Equal(t, "oci://registry.example.com/charts/shared@"+digest, requirements.Dependencies[1].Version)
+		assert.Equal(t, "oci://registry.example.com/charts", requirements.Dependencies[1].Repository)
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	values := map[string]interface{}{
+		"replicaCount": 1,
+		"ingress": map[string]interface{}{
+			"class": "nginx",
+			"host":  "old.example.com",
+		},
+		"image": "myapp:1.0.0",
+	}
+	MergeValues(values, map[string]interface{}{
+		"replicaCount": 3,
+		"ingress": map[string]interface{}{
+			"host": "myapp.example.com",
+		},
+		"image": map[string]interface{}{
+			"tag": "1.2.0",
+		},
+	})
+	assert.Equal(t, map[string]interface{}{
+		"replicaCount": 3,
+		"ingress": map[string]interface{}{
+			"class": "nginx",
+			"host":  "myapp.example.com",
+		},
+		"image": map[string]interface{}{
+			"tag": "1.2.0",
+		},
+	}, values)
+}
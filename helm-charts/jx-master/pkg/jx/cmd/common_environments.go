@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
 	"github.com/jenkins-x/jx/pkg/client/clientset/versioned"
@@ -20,77 +19,28 @@ import (
 // callback for modifying requirements
 type ModifyRequirementsFn func(requirements *helm.Requirements) error
 
-func (o *CommonOptions) createEnvironmentPullRequest(env *v1.Environment, modifyRequirementsFn ModifyRequirementsFn, branchNameText string, title string, message string, pullRequestInfo *ReleasePullRequestInfo) (*ReleasePullRequestInfo, error) {
-	var answer *ReleasePullRequestInfo
-	source := &env.Spec.Source
-	gitURL := source.URL
-	if gitURL == "" {
-		return answer, fmt.Errorf("No source git URL")
-	}
-	gitInfo, err := gits.ParseGitURL(gitURL)
-	if err != nil {
-		return answer, err
-	}
+// callback for modifying the helm values of the environment
+type ModifyValuesFn func(values map[string]interface{}) error
 
-	environmentsDir, err := util.EnvironmentsDir()
+// createEnvironmentPullRequest creates a Pull Request which modifies the environment git repository. The Pull Request
+// targets the base branch if specified, otherwise the ref of the environment source or the default branch of the
+// repository. No Pull Request is returned if the environment git repository is not changed. The progress is left to
+// the caller to log so that it can be logged in the format of the command
+func (o *CommonOptions) createEnvironmentPullRequest(env *v1.Environment, modifyRequirementsFn ModifyRequirementsFn, modifyValuesFn ModifyValuesFn, branchNameText string, baseBranch string, title string, message string, pullRequestInfo *ReleasePullRequestInfo) (*ReleasePullRequestInfo, error) {
+	var answer *ReleasePullRequestInfo
+	dir, base, err := o.cloneEnvironmentRepository(env, baseBranch)
 	if err != nil {
 		return answer, err
 	}
-	dir := filepath.Join(environmentsDir, gitInfo.Organisation, gitInfo.Name)
-
-	// now lets clone the fork and push it...
-	exists, err := util.FileExists(dir)
+	gitInfo, err := gits.ParseGitURL(env.Spec.Source.URL)
 	if err != nil {
 		return answer, err
 	}
-
 	branchName := o.Git().ConvertToValidBranchName(branchNameText)
-	base := source.Ref
-	if base == "" {
-		base = "master"
-	}
-
-	if exists {
-		// lets check the git remote URL is setup correctly
-		err = o.Git().SetRemoteURL(dir, "origin", gitURL)
-		if err != nil {
-			return answer, err
-		}
-		err = o.Git().Stash(dir)
-		if err != nil {
-			return answer, err
-		}
-		err = o.Git().Checkout(dir, base)
-		if err != nil {
-			return answer, err
-		}
-		err = o.Git().Pull(dir)
-		if err != nil {
-			return answer, err
-		}
-	} else {
-		err := os.MkdirAll(dir, DefaultWritePermissions)
-		if err != nil {
-			return answer, fmt.Errorf("Failed to create directory %s due to %s", dir, err)
-		}
-		err = o.Git().Clone(gitURL, dir)
-		if err != nil {
-			return answer, err
-		}
-		if base != "master" {
-			err = o.Git().Checkout(dir, base)
-			if err != nil {
-				return answer, err
-			}
-		}
-
-		// TODO lets fork if required???
-	}
 	branchNames, err := o.Git().RemoteBranchNames(dir, "remotes/origin/")
 	if err != nil {
 		return answer, fmt.Errorf("Failed to load remote branch names: %s", err)
 	}
-	log.Infof("Found remote branch names %s\n", strings.Join(branchNames, ", "))
 	if util.StringArrayIndex(branchNames, branchName) >= 0 {
 		// lets append a UUID as the branch name already exists
 		branchName += "-" + string(uuid.NewUUID())
@@ -114,8 +64,29 @@ func (o *CommonOptions) createEnvironmentPullRequest(env *v1.Environment, modify
 	}
 
 	err = modifyRequirementsFn(requirements)
-
+	if err != nil {
+		return answer, err
+	}
 	err = helm.SaveRequirementsFile(requirementsFile, requirements)
+	if err != nil {
+		return answer, err
+	}
+
+	if modifyValuesFn != nil {
+		valuesFile := filepath.Join(filepath.Dir(requirementsFile), helm.ValuesFileName)
+		values, err := helm.LoadValuesFile(valuesFile)
+		if err != nil {
+			return answer, err
+		}
+		err = modifyValuesFn(values)
+		if err != nil {
+			return answer, err
+		}
+		err = helm.SaveValuesFile(valuesFile, values)
+		if err != nil {
+			return answer, err
+		}
+	}
 
 	err = o.Git().Add(dir, "*", "*/*")
 	if err != nil {
@@ -126,7 +97,6 @@ func (o *CommonOptions) createEnvironmentPullRequest(env *v1.Environment, modify
 		return answer, err
 	}
 	if !changed {
-		log.Warnf("%s\n", "No changes made to the GitOps Environment source code. Code must be up to date!")
 		return answer, nil
 	}
 	err = o.Git().CommitDir(dir, message)
@@ -145,17 +115,7 @@ func (o *CommonOptions) createEnvironmentPullRequest(env *v1.Environment, modify
 		return answer, err
 	}
 
-	authConfigSvc, err := o.CreateGitAuthConfigService()
-	if err != nil {
-		return answer, err
-	}
-
-	gitKind, err := o.GitServerKind(gitInfo)
-	if err != nil {
-		return answer, err
-	}
-
-	provider, err := gitInfo.PickOrCreateProvider(authConfigSvc, "user name to submit the Pull Request", o.BatchMode, gitKind, o.Git())
+	provider, err := o.environmentGitProvider(gitInfo)
 	if err != nil {
 		return answer, err
 	}
@@ -172,7 +132,6 @@ func (o *CommonOptions) createEnvironmentPullRequest(env *v1.Environment, modify
 	if err != nil {
 		return answer, err
 	}
-	log.Infof("Created Pull Request: %s\n\n", util.ColorInfo(pr.URL))
 	return &ReleasePullRequestInfo{
 		GitProvider:          provider,
 		PullRequest:          pr,
@@ -180,6 +139,144 @@ func (o *CommonOptions) createEnvironmentPullRequest(env *v1.Environment, modify
 	}, nil
 }
 
+// cloneEnvironmentRepository clones the environment git repository or updates the existing clone returning the
+// directory of the clone checked out at the base branch along with the name of the base branch. The base branch is
+// the given branch if specified, otherwise the ref of the environment source or the default branch of the repository
+func (o *CommonOptions) cloneEnvironmentRepository(env *v1.Environment, baseBranch string) (string, string, error) {
+	source := &env.Spec.Source
+	gitURL := source.URL
+	if gitURL == "" {
+		return "", "", fmt.Errorf("No source git URL")
+	}
+	gitInfo, err := gits.ParseGitURL(gitURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	environmentsDir, err := util.EnvironmentsDir()
+	if err != nil {
+		return "", "", err
+	}
+	dir := filepath.Join(environmentsDir, gitInfo.Organisation, gitInfo.Name)
+
+	// now lets clone the fork and push it...
+	exists, err := util.FileExists(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	base := baseBranch
+	if base == "" {
+		base = source.Ref
+	}
+
+	if exists {
+		// lets check the git remote URL is setup correctly
+		err = o.Git().SetRemoteURL(dir, "origin", gitURL)
+		if err != nil {
+			return "", "", err
+		}
+		err = o.Git().Stash(dir)
+		if err != nil {
+			return "", "", err
+		}
+		if base == "" {
+			base = o.environmentDefaultBranch(dir)
+		}
+		err = o.Git().Checkout(dir, base)
+		if err != nil {
+			return "", "", err
+		}
+		err = o.Git().Pull(dir)
+		if err != nil {
+			return "", "", err
+		}
+	} else {
+		err := os.MkdirAll(dir, DefaultWritePermissions)
+		if err != nil {
+			return "", "", fmt.Errorf("Failed to create directory %s due to %s", dir, err)
+		}
+		err = o.Git().Clone(gitURL, dir)
+		if err != nil {
+			return "", "", err
+		}
+		if base == "" {
+			base = o.environmentDefaultBranch(dir)
+		} else {
+			err = o.Git().Checkout(dir, base)
+			if err != nil {
+				return "", "", err
+			}
+		}
+
+		// TODO lets fork if required???
+	}
+	return dir, base, nil
+}
+
+// findEnvironmentPullRequest returns the open Pull Request of the environment git repository from the branch which
+// createEnvironmentPullRequest would create for the branch name text or nil if there is no such Pull Request
+func (o *CommonOptions) findEnvironmentPullRequest(env *v1.Environment, branchNameText string, baseBranch string, title string, message string) (*ReleasePullRequestInfo, error) {
+	source := &env.Spec.Source
+	if source.URL == "" {
+		return nil, fmt.Errorf("No source git URL")
+	}
+	gitInfo, err := gits.ParseGitURL(source.URL)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := o.environmentGitProvider(gitInfo)
+	if err != nil {
+		return nil, err
+	}
+	branchName := o.Git().ConvertToValidBranchName(branchNameText)
+	pr, err := provider.FindOpenPullRequest(gitInfo.Organisation, gitInfo.Name, branchName)
+	if err != nil || pr == nil {
+		return nil, err
+	}
+	base := baseBranch
+	if base == "" {
+		base = source.Ref
+	}
+	return &ReleasePullRequestInfo{
+		GitProvider: provider,
+		PullRequest: pr,
+		PullRequestArguments: &gits.GitPullRequestArguments{
+			GitRepositoryInfo: gitInfo,
+			Title:             title,
+			Body:              message,
+			Base:              base,
+			Head:              branchName,
+		},
+	}, nil
+}
+
+// environmentDefaultBranch returns the default branch of the clone of the environment git repository in the given
+// directory falling back to master if it cannot be found
+func (o *CommonOptions) environmentDefaultBranch(dir string) string {
+	branch, err := o.Git().DefaultBranch(dir)
+	if err != nil {
+		log.Warnf("Failed to find the default branch of the environment repository in %s so using master: %s\n", dir, err)
+	}
+	if branch == "" {
+		return "master"
+	}
+	return branch
+}
+
+// environmentGitProvider returns the git provider used to submit Pull Requests to the environment git repository
+func (o *CommonOptions) environmentGitProvider(gitInfo *gits.GitRepositoryInfo) (gits.GitProvider, error) {
+	authConfigSvc, err := o.CreateGitAuthConfigService()
+	if err != nil {
+		return nil, err
+	}
+	gitKind, err := o.GitServerKind(gitInfo)
+	if err != nil {
+		return nil, err
+	}
+	return gitInfo.PickOrCreateProvider(authConfigSvc, "user name to submit the Pull Request", o.BatchMode, gitKind, o.Git())
+}
+
 func (o *CommonOptions) registerEnvironmentCRD() error {
 	apisClient, err := o.Factory.CreateApiExtensionsClient()
 	if err != nil {
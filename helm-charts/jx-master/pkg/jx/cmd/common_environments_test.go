@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/jenkins-x/jx/pkg/helm"
+	"github.com/jenkins-x/jx/pkg/kube"
+	"github.com/jenkins-x/jx/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// checkoutRecordingGitter records the branches checked out in the environment repository
+type checkoutRecordingGitter struct {
+	gits.GitFake
+
+	checkouts []string
+}
+
+func (g *checkoutRecordingGitter) Checkout(dir string, branch string) error {
+	g.checkouts = append(g.checkouts, branch)
+	return g.GitFake.Checkout(dir, branch)
+}
+
+func TestCreateEnvironmentPullRequestBaseBranch(t *testing.T) {
+	jxHome, err := ioutil.TempDir("", "test-jx-home-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(jxHome)
+	oldJxHome := os.Getenv("JX_HOME")
+	os.Setenv("JX_HOME", jxHome)
+	defer os.Setenv("JX_HOME", oldJxHome)
+
+	// an existing clone of the environment repository
+	environmentsDir, err := util.EnvironmentsDir()
+	assert.NoError(t, err)
+	chartDir := filepath.Join(environmentsDir, "jstrachan", "environment-staging", "env")
+	assert.NoError(t, os.MkdirAll(chartDir, util.DefaultWritePermissions))
+	err = ioutil.WriteFile(filepath.Join(chartDir, helm.RequirementsFileName), []byte("dependencies: []\n"), util.DefaultWritePermissions)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		ref        string
+		baseBranch string
+		expected   string
+	}{
+		{
+			name:     "default branch of the repository",
+			expected: "main",
+		},
+		{
+			name:     "ref of the environment source",
+			ref:      "production",
+			expected: "production",
+		},
+		{
+			name:       "base branch overrides the ref",
+			ref:        "production",
+			baseBranch: "region-eu",
+			expected:   "region-eu",
+		},
+	}
+	for _, tc := range testCases {
+		env := kube.NewPermanentEnvironment("staging")
+		env.Spec.Source.URL = "https://github.com/jstrachan/environment-staging.git"
+		env.Spec.Source.Ref = tc.ref
+		gitter := &checkoutRecordingGitter{
+			GitFake: gits.GitFake{
+				DefaultBranchName: "main",
+			},
+		}
+		o := &CommonOptions{}
+		ConfigureTestOptions(o, gitter, &promoteTestHelmer{})
+
+		modifyRequirementsFn := func(requirements *helm.Requirements) error {
+			return nil
+		}
+		// there are no changes so no Pull Request is created
+		info, err := o.createEnvironmentPullRequest(env, modifyRequirementsFn, nil, "promote-myapp-1.0.0", tc.baseBranch, "myapp to 1.0.0", "Promote myapp to version 1.0.0", nil)
+		assert.NoError(t, err, tc.name)
+		assert.Nil(t, info, tc.name)
+		if assert.NotEmpty(t, gitter.checkouts, tc.name) {
+			assert.Equal(t, tc.expected, gitter.checkouts[0], tc.name)
+		}
+	}
+}
@@ -225,10 +225,15 @@ func (o *DeleteAppOptions) deleteAppFromEnvironment(env *v1.Environment, appName
 		requirements.RemoveApp(appName)
 		return nil
 	}
-	info, err := o.createEnvironmentPullRequest(env, modifyRequirementsFn, branchName, title, message, nil)
+	info, err := o.createEnvironmentPullRequest(env, modifyRequirementsFn, nil, branchName, "", title, message, nil)
 	if err != nil {
 		return err
 	}
+	if info == nil {
+		log.Warnf("%s\n", "No changes made to the GitOps Environment source code. Code must be up to date!")
+	} else {
+		log.Infof("Created Pull Request: %s\n\n", util.ColorInfo(info.PullRequest.URL))
+	}
 
 	duration := *o.TimeoutDuration
 	end := time.Now().Add(duration)
@@ -423,7 +423,7 @@ func (f *factory) getImpersonateUser() string {
 }
 
 func (f *factory) CreateTable(out io.Writer) table.Table {
-	return table.CreateTable(os.Stdout)
+	return table.CreateTable(out)
 }
 
 // IsInCDPIpeline we should only load the git / issue tracker API tokens if the current pod
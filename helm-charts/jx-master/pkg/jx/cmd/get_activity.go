@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	"io"
 	"strings"
 	"time"
@@ -249,7 +250,7 @@ func addPreviewRow(table *tbl.Table, parent *v1.PreviewActivityStep, indent stri
 }
 
 func addPromoteRow(table *tbl.Table, parent *v1.PromoteActivityStep, indent string) {
-	addStepRowItem(table, &parent.CoreActivityStep, indent, "Promote: "+parent.Environment, "")
+	addStepRowItem(table, &parent.CoreActivityStep, indent, "Promote: "+parent.Environment, describePromoteVersion(parent)+describePromotedBy(parent))
 	indent += indentation
 
 	pullRequest := parent.PullRequest
@@ -302,6 +303,28 @@ func statusString(statusType v1.ActivityStatusType) string {
 	return text
 }
 
+func describePromoteVersion(promote *v1.PromoteActivityStep) string {
+	version := promote.Version
+	if version == "" {
+		return ""
+	}
+	canary := ""
+	if promote.Canary {
+		canary = fmt.Sprintf(" canary %d%%", promote.CanaryWeight)
+	}
+	if promote.PreviousVersion != "" && promote.PreviousVersion != version {
+		return " " + util.ColorInfo(promote.PreviousVersion) + " → " + util.ColorInfo(version) + canary
+	}
+	return " " + util.ColorInfo(version) + canary
+}
+
+func describePromotedBy(promote *v1.PromoteActivityStep) string {
+	if promote.PromotedBy == "" {
+		return ""
+	}
+	return " by " + util.ColorInfo(promote.PromotedBy)
+}
+
 func describePromotePullRequest(promote *v1.PromotePullRequestStep) string {
 	description := ""
 	if promote.PullRequestURL != "" {
@@ -310,12 +333,16 @@ func describePromotePullRequest(promote *v1.PromotePullRequestStep) string {
 	if promote.MergeCommitSHA != "" {
 		description += " Merge SHA: " + util.ColorInfo(promote.MergeCommitSHA)
 	}
-	return description
+	return description + describeGitStatuses(promote.Statuses)
 }
 
 func describePromoteUpdate(promote *v1.PromoteUpdateStep) string {
+	return describeGitStatuses(promote.Statuses)
+}
+
+func describeGitStatuses(statuses []v1.GitStatus) string {
 	description := ""
-	for _, status := range promote.Statuses {
+	for _, status := range statuses {
 		url := status.URL
 		state := status.Status
 
@@ -20,6 +20,11 @@ type debugError interface {
 	DebugError() (msg string, args []interface{})
 }
 
+// exitCodeError is an error which exits with its own exit code rather than DefaultErrorExitCode
+type exitCodeError interface {
+	ExitCode() int
+}
+
 var fatalErrHandler = fatal
 
 // BehaviorOnFatal allows you to override the default behavior when a fatal
@@ -87,6 +92,9 @@ func checkErr(prefix string, err error, handleErr func(string, int)) {
 	case err == ErrExit:
 		handleErr("", DefaultErrorExitCode)
 		return
+	case isExitCodeError(err):
+		handleErr(err.Error(), err.(exitCodeError).ExitCode())
+		return
 	/*
 		case kerrors.IsInvalid(err):
 			details := err.(*kerrors.StatusError).Status().Details
@@ -133,6 +141,11 @@ func checkErr(prefix string, err error, handleErr func(string, int)) {
 	}
 }
 
+func isExitCodeError(err error) bool {
+	_, ok := err.(exitCodeError)
+	return ok
+}
+
 // StandardErrorMessage translates common errors into a human readable message, or returns
 // false if the error is not one of the recognized types. It may also log extended
 // information to glog.
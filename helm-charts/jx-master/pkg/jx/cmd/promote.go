@@ -1,57 +1,361 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
 	"time"
 
+	msemver "github.com/Masterminds/semver"
 	"github.com/blang/semver"
+	"github.com/ghodss/yaml"
 	"github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/auth"
 	typev1 "github.com/jenkins-x/jx/pkg/client/clientset/versioned/typed/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/config"
 	"github.com/jenkins-x/jx/pkg/gits"
 	"github.com/jenkins-x/jx/pkg/helm"
 	"github.com/jenkins-x/jx/pkg/jx/cmd/templates"
 	"github.com/jenkins-x/jx/pkg/kube"
 	"github.com/jenkins-x/jx/pkg/log"
 	"github.com/jenkins-x/jx/pkg/util"
+	"github.com/nlopes/slack"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
 	"gopkg.in/AlecAivazis/survey.v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 const (
 	optionEnvironment         = "env"
+	optionNamespaceSelector   = "namespace-selector"
 	optionApplication         = "app"
 	optionTimeout             = "timeout"
 	optionPullRequestPollTime = "pull-request-poll-time"
+	optionPullRequestCreate   = "pr-create-timeout"
+	optionPullRequestMerge    = "pr-merge-timeout"
+	optionPollBackoffMax      = "poll-backoff-max"
+	optionHelmTimeout         = "helm-timeout"
+	optionKubeContext         = "kube-context"
+	optionPostPRDelay         = "post-pr-delay"
+	optionForce               = "force"
+	optionPromotedBy          = "promoted-by"
+	optionEnvironmentRepo     = "environment-repo"
+	optionChartPath           = "chart-path"
+	optionHelmUpdateTTL       = "helm-update-ttl"
+	optionEnvironmentOrder    = "env-order"
+	optionProviderRetries     = "provider-retries"
+	optionEnvironmentSelector = "env-selector"
+	optionPullRequestReviewer = "pr-reviewer"
+	optionRequiredContext     = "required-status-context"
+	optionPullRequestLabel    = "pr-label"
+	optionNoDefaultPRLabels   = "no-default-pr-labels"
+	optionNoActivity          = "no-activity"
+	optionEnvValuesConfig     = "env-values-config"
+	optionServiceName         = "service-name"
+	optionServiceSelector     = "service-selector"
+	optionPlatform            = "platform"
+	optionHelmRepositoryURL   = "helm-repo-url"
+	optionHelmRepoName        = "helm-repo-name"
+	optionRequireIssues       = "require-issues"
+	optionMergePolicy         = "merge-policy"
+	optionMergeMethod         = "merge-method"
+	optionRollback            = "rollback"
+	optionOutput              = "output"
+	optionManifest            = "manifest"
+	optionPullRequestTitle    = "pr-title-template"
+	optionPullRequestBody     = "pr-body-template"
+	optionIssueComment        = "issue-comment-template"
+	optionCanaryWeight        = "canary-weight"
+	optionSet                 = "set"
+	optionValues              = "values"
+	optionVersionFromGitTag   = "version-from-git-tag"
+	optionFromPreview         = "from-preview"
+	optionCanaryPromote       = "canary-promote"
+	optionWaitForReady        = "wait-for-ready"
+	optionNoWait              = "no-wait"
+	optionAutoRebase          = "auto-rebase"
+	optionConfirm             = "confirm"
+	optionTimeoutAction       = "timeout-action"
+	optionCommitSHA           = "commit-sha"
+	optionCommentSinceVersion = "comment-since-version"
+	optionReleaseNotesURL     = "release-notes-url"
+	optionAllowNoop           = "allow-noop"
+
+	// the environment variables used for default values of the options if the flags are not specified
+	envVarPromoteEnvironment       = "JX_PROMOTE_ENV"
+	envVarPromoteVersion           = "JX_PROMOTE_VERSION"
+	envVarPromoteTimeout           = "JX_PROMOTE_TIMEOUT"
+	envVarPromoteHelmRepositoryURL = "JX_PROMOTE_HELM_REPO_URL"
 
 	gitStatusSuccess = "success"
+
+	// timeoutActionFail fails the promotion Pull Request when the promotion times out
+	timeoutActionFail = "fail"
+	// timeoutActionClose closes the promotion Pull Request when the promotion times out
+	timeoutActionClose = "close"
+	// timeoutActionLeave leaves the promotion Pull Request open for a human to finish when the promotion times out
+	timeoutActionLeave = "leave"
+
+	// defaultManifestValidator the schema validator used to validate the rendered manifests
+	defaultManifestValidator = "kubeconform"
+
+	// forceRolloutValue the name of the chart value which is changed on each promotion to force a rollout
+	forceRolloutValue = "rolloutTimestamp"
+
+	// completionWebhookSignatureHeader the header of the HMAC SHA256 signature of the --completion-webhook payload
+	completionWebhookSignatureHeader = "X-Jx-Signature-256"
+
+	// canaryEnabledValue the name of the chart value which enables the canary of the application
+	canaryEnabledValue = "canary.enabled"
+	// canaryWeightValue the name of the chart value which is the percentage of the traffic sent to the canary
+	canaryWeightValue = "canary.weight"
+
+	// helmRepoUpdateFileName the file in the helm home dir recording when the helm repositories were last updated
+	helmRepoUpdateFileName = "jx-promote-repo-update"
 )
 
 var (
 	waitAfterPullRequestCreated = time.Second * 3
+
+	// pullRequestQueryRetries the number of times the first query of the status of a new Pull Request is retried as
+	// the git provider may not be able to return it straight away
+	pullRequestQueryRetries = 3
+	// pullRequestQueryRetryTime the time between the retries of the first query of the status of a new Pull Request
+	pullRequestQueryRetryTime = time.Second
+	// providerRetryBackoff the initial time to wait before retrying a query of the git provider which failed with a
+	// transient error. The time doubles on each retry
+	providerRetryBackoff = time.Second
+
+	// defaultApprovalPollTime the time between checks for the approval of a promotion if no
+	// --pull-request-poll-time is specified
+	defaultApprovalPollTime = time.Second * 10
+
+	// waitForReadyPollTime the time between checks of the Deployments and StatefulSets of the release when using
+	// --wait-for-ready
+	waitForReadyPollTime = time.Second * 5
+
+	// slackWebhookTimeout the timeout of posting a notification to a Slack incoming webhook
+	slackWebhookTimeout = time.Second * 10
+
+	// completionWebhookTimeout the timeout of posting the completion of a promotion to the --completion-webhook
+	completionWebhookTimeout = time.Second * 10
+
+	// metricsPushTimeout the timeout of pushing the promotion metrics to the --metrics-pushgateway
+	metricsPushTimeout = time.Second * 10
+
+	// helmChartNotFoundMessages the messages helm fails with when the chart or chart version is not in the index of
+	// the helm repositories. helm upgrade reports a missing chart version with the hint to update the repositories
+	helmChartNotFoundMessages = []string{"no chart version found", "no chart name found", "(hint: running `helm repo update` may help)"}
+
+	// timeoutActionValues the actions which can be taken on the promotion Pull Request when the promotion times out
+	timeoutActionValues = []string{timeoutActionFail, timeoutActionClose, timeoutActionLeave}
+
+	// environmentPullRequestLabelPrefix the prefix of the environment/<name> label which is one of the
+	// defaultPullRequestLabels
+	environmentPullRequestLabelPrefix = "environment/"
+
+	// promotePlatforms the values of the --platform option
+	promotePlatforms = []string{KUBERNETES, OPENSHIFT}
+
+	// findRouteURL finds the URL of an OpenShift Route. It is a variable so that tests can fake the Route API
+	findRouteURL = kube.FindRouteURL
+
+	// helmRepoNameRegex matches the names of helm repositories which can prefix the name of a chart
+	helmRepoNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+	// commitSHARegex matches abbreviated and full SHA-1 and SHA-256 git commit SHAs
+	commitSHARegex = regexp.MustCompile(`^[0-9a-f]{7,64}$`)
+
+	// appPromotionLocks ensures an application is never promoted to more than one environment at the same time by the
+	// promotions running in this process, such as a program embedding PromoteOptions which promotes several
+	// applications concurrently. Promotions by other jx processes are not serialized
+	appPromotionLocks = &util.KeyedMutex{}
+
+	// runManifestValidator runs the schema validator against the rendered manifests returning its output
+	runManifestValidator = func(validator string, args ...string) (string, error) {
+		return util.RunCommandWithOutput("", validator, args...)
+	}
+
+	// confirmPromotionPlan asks the user to confirm the promotion plan
+	confirmPromotionPlan = func(message string) (bool, error) {
+		confirm := &survey.Confirm{
+			Message: message,
+			Default: false,
+		}
+		flag := false
+		err := survey.AskOne(confirm, &flag, nil)
+		return flag, err
+	}
+
+	// confirmAutomaticPromotion asks the user to confirm promoting to an environment which is promoted automatically
+	confirmAutomaticPromotion = func(message string) (bool, error) {
+		confirm := &survey.Confirm{
+			Message: message,
+			Default: false,
+		}
+		flag := false
+		err := survey.AskOne(confirm, &flag, nil)
+		return flag, err
+	}
+
+	// stdinIsTerminal returns true if the user can be prompted on standard input
+	stdinIsTerminal = func() bool {
+		return terminal.IsTerminal(int(os.Stdin.Fd()))
+	}
+
+	// createKubeContextClient creates the kubernetes client of the cluster of the kube context returning an error if
+	// there is no such context
+	createKubeContextClient = func(context string) (kubernetes.Interface, error) {
+		config, _, err := kube.LoadConfig()
+		if err != nil {
+			return nil, err
+		}
+		if config.Contexts[context] == nil {
+			return nil, util.InvalidOption(optionKubeContext, context, kube.ContextNames(config))
+		}
+		restConfig, err := clientcmd.NewNonInteractiveClientConfig(*config, context, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load the configuration of kube context %s: %s", context, err)
+		}
+		return kubernetes.NewForConfig(restConfig)
+	}
+
+	// rolloutNonce returns a new value for each promotion which forces a rollout of the application
+	rolloutNonce = func() string {
+		return time.Now().UTC().Format(time.RFC3339Nano)
+	}
 )
 
 // PromoteOptions containers the CLI options
 type PromoteOptions struct {
 	CommonOptions
 
-	Namespace           string
-	Environment         string
-	Application         string
-	Version             string
-	ReleaseName         string
-	LocalHelmRepoName   string
-	HelmRepositoryURL   string
-	NoHelmUpdate        bool
-	AllAutomatic        bool
-	NoMergePullRequest  bool
-	Timeout             string
-	PullRequestPollTime string
+	Namespace                string
+	NamespaceSelector        string
+	Environment              string
+	Application              string
+	ChartName                string
+	ChartPath                string
+	Version                  string
+	ReleaseName              string
+	LocalHelmRepoName        string
+	HelmRepositoryURL        string
+	NoHelmUpdate             bool
+	ExcludePrereleases       bool
+	VersionFromGitTag        bool
+	FromPreview              string
+	Rollback                 bool
+	Force                    bool
+	AllowNoop                bool
+	Validate                 bool
+	Output                   string
+	Manifest                 string
+	AllAutomatic             bool
+	SkipEnvironments         []string
+	OnlyEnvironments         []string
+	EnvironmentOrder         []string
+	EnvironmentSelector      string
+	NoMergePullRequest       bool
+	Confirm                  bool
+	AutoRebase               bool
+	MaxRebaseAttempts        int
+	MergePolicy              string
+	MergeMethod              string
+	RequiredApprovals        int
+	ForceRollout             bool
+	CanaryWeight             int
+	CanaryPromote            bool
+	SetValues                []string
+	ValuesFile               string
+	EnvironmentValuesConfig  string
+	WaitForReady             bool
+	NoWait                   bool
+	EnvBranch                string
+	EnvironmentRepo          string
+	GitHubEnvironment        string
+	NotifyOnFirstFailureOnly bool
+	SlackWebhookURL          string
+	CompletionWebhookURL     string
+	CompletionWebhookSecret  string
+	MetricsPushgatewayURL    string
+	RequireIssues            bool
+	RequireApproval          bool
+	RequireActivity          bool
+	NoActivity               bool
+	CommentAs                string
+	CommentOnPullRequestOpen bool
+	CommentSinceVersion      string
+	IssueCommentTemplate     string
+	ReleaseNotesURL          string
+	ChartRetries             int
+	ChartRetryBackoff        time.Duration
+	MergeRetries             int
+	MergeRetryInterval       time.Duration
+	ProviderRetries          int
+	PullRequestReviewers     []string
+	RequiredStatusContexts   []string
+
+	// PullRequestLabelTemplates the go templates of the labels added to the promotion Pull Request
+	PullRequestLabelTemplates []string
+	// PullRequestLabels the labels added to the promotion Pull Request as they are rather than rendered as templates
+	PullRequestLabels []string
+	// NoDefaultPullRequestLabels disables the defaultPullRequestLabels of the promotion Pull Request
+	NoDefaultPullRequestLabels bool
+
+	PullRequestTitleTemplate string
+	PullRequestBodyTemplate  string
+	DryRun                   bool
+	PrintPlanThenConfirm     bool
+	ValidateManifests        bool
+	ManifestValidator        string
+	KubernetesVersion        string
+	Timeout                  string
+	TimeoutAction            string
+	LogFormat                string
+	PullRequestPollTime      string
+	PullRequestCreateTimeout string
+	PullRequestMergeTimeout  string
+	PollBackoffMax           string
+	HelmTimeout              string
+	PostPullRequestDelay     string
+	HelmUpdateTTL            string
+	KubeContext              string
+	AppURLs                  []string
+	ServiceNames             []string
+	ServiceSelector          string
+	Platform                 string
+	CommitSHA                string
+	PromotedBy               string
+
+	// Notify if specified is invoked with the failures and completion of each promotion
+	Notify PromoteNotifyFn
+
+	// Results the outcome of each promotion performed by the last call of Run or PromoteAllAutomatic
+	Results []PromoteResult
 
 	// calculated fields
 	TimeoutDuration         *time.Duration
@@ -60,25 +364,264 @@ type PromoteOptions struct {
 	GitInfo                 *gits.GitRepositoryInfo
 	jenkinsURL              string
 	releaseResource         *v1.Release
+	applications            []applicationVersion
+	contextKubeClient       kubernetes.Interface
+
+	PullRequestCreateTimeoutDuration *time.Duration
+	PullRequestMergeTimeoutDuration  *time.Duration
+	PollBackoffMaxDuration           *time.Duration
+	HelmTimeoutDuration              *time.Duration
+	PostPullRequestDelayDuration     *time.Duration
+	HelmUpdateTTLDuration            *time.Duration
+
+	results        *promoteResults
+	helmRepoUpdate *helmRepoUpdate
+
+	environmentValuesConfig map[string]*PromoteEnvironmentValues
+	environmentValues       *PromoteEnvironmentValues
+}
+
+// applicationVersion is an application and the version of it to promote
+type applicationVersion struct {
+	Name    string
+	Version string
 }
 
 type ReleaseInfo struct {
 	ReleaseName     string
 	FullAppName     string
 	Version         string
+	PreviousVersion string
+	CanaryWeight    int
+	ValuesFile      string
+	CommitSHA       string
+	PromotedBy      string
+	StartTime       time.Time
 	PullRequestInfo *ReleasePullRequestInfo
+
+	// Skipped is true if the user declined to promote to the automatic environment
+	Skipped bool
+
+	// AlreadyDeployed is true if no Pull Request was created as the environment already has the promoted version
+	AlreadyDeployed bool
+
+	// promoteKey is the key of the promotion which is created once per promotion as creating it discovers the git
+	// repository and may query Jenkins for the current pipeline
+	promoteKey *kube.PromoteStepActivityKey
+}
+
+// PromoteSkippedExitCode is the exit code of jx promote when a promotion was skipped because the user declined it so
+// that scripts can tell a skipped promotion from a successful or failed one
+const PromoteSkippedExitCode = 3
+
+// PromotionSkippedError indicates that nothing was promoted to the environments as the user declined the promotion
+type PromotionSkippedError struct {
+	Environments []string
+}
+
+func (e *PromotionSkippedError) Error() string {
+	return fmt.Sprintf("The promotion to environment %s was skipped as it was not confirmed", strings.Join(e.Environments, ", "))
+}
+
+// ExitCode returns the exit code of jx promote for the skipped promotion
+func (e *PromotionSkippedError) ExitCode() int {
+	return PromoteSkippedExitCode
+}
+
+// promoteCancelledError indicates that the promotion was cancelled before it completed
+type promoteCancelledError struct {
+	message string
+}
+
+func (e *promoteCancelledError) Error() string {
+	return e.message
+}
+
+// isPromoteCancelled returns true if the error is due to the promotion being cancelled
+func isPromoteCancelled(err error) bool {
+	_, cancelled := err.(*promoteCancelledError)
+	return cancelled
+}
+
+// promoteNoopError indicates that promoting would not change the versions in the environment requirements
+type promoteNoopError struct{}
+
+func (e *promoteNoopError) Error() string {
+	return "The environment already has the promoted versions"
+}
+
+// PromoteNotificationKind the kind of a notification about a promotion
+type PromoteNotificationKind string
+
+const (
+	// PromoteNotificationFailure a failure was observed while waiting for the promotion
+	PromoteNotificationFailure PromoteNotificationKind = "failure"
+	// PromoteNotificationSuccess the promotion completed successfully
+	PromoteNotificationSuccess PromoteNotificationKind = "success"
+)
+
+// PromoteNotifyFn notifies about the progress of the promotion of the application to an environment
+type PromoteNotifyFn func(env *v1.Environment, kind PromoteNotificationKind, message string) error
+
+// promoteNotifier sends the notifications of a single promotion optionally only notifying the first failure
+type promoteNotifier struct {
+	options          *PromoteOptions
+	notify           PromoteNotifyFn
+	env              *v1.Environment
+	firstFailureOnly bool
+	failureNotified  bool
+}
+
+// failure notifies a failure unless a failure has already been notified and only the first failure should be
+func (n *promoteNotifier) failure(message string) {
+	if n.firstFailureOnly && n.failureNotified {
+		return
+	}
+	n.failureNotified = true
+	n.send(PromoteNotificationFailure, message)
+}
+
+// success notifies that the promotion completed
+func (n *promoteNotifier) success(message string) {
+	n.send(PromoteNotificationSuccess, message)
+}
+
+func (n *promoteNotifier) send(kind PromoteNotificationKind, message string) {
+	if n.notify == nil {
+		return
+	}
+	err := n.notify(n.env, kind, message)
+	if err != nil {
+		n.options.warnEvent(n.env, promoteEvent{Event: "notification-failed"}, "Failed to send the promotion %s notification: %s\n", kind, err)
+	}
+}
+
+// PullRequestLabelData the promotion metadata available to the templates of the Pull Request labels
+type PullRequestLabelData struct {
+	App         string
+	Environment string
+	Version     string
+	Pipeline    string
+	Build       string
+}
+
+// PullRequestTemplateData the promotion metadata available to the templates of the Pull Request title and body
+type PullRequestTemplateData struct {
+	App             string
+	Version         string
+	Environment     string
+	ReleaseNotesURL string
+	CommitSHA       string
+	Reviewers       []string
+	GitInfo         *gits.GitRepositoryInfo
+
+	// url looks up the URL of the application in the environment when a template uses it
+	url func() string
+}
+
+// URL returns the URL of the application in the environment
+func (d *PullRequestTemplateData) URL() string {
+	if d.url == nil {
+		return ""
+	}
+	return d.url()
+}
+
+// IssueCommentTemplateData the promotion metadata available to the --issue-comment-template
+type IssueCommentTemplateData struct {
+	Environment     string
+	Version         string
+	ReleaseNotesURL string
+	URL             string
+}
+
+// PromoteOutput is the result of a promotion printed by the --output option
+type PromoteOutput struct {
+	Application    string `json:"application"`
+	Version        string `json:"version,omitempty"`
+	Namespace      string `json:"namespace,omitempty"`
+	Environment    string `json:"environment,omitempty"`
+	ReleaseName    string `json:"releaseName,omitempty"`
+	PullRequestURL string `json:"pullRequestURL,omitempty"`
+	MergeCommitSHA string `json:"mergeCommitSHA,omitempty"`
+	Status         string `json:"status"`
+	Error          string `json:"error,omitempty"`
+}
+
+// PromoteResultStatus is the outcome of the promotion of an application to an environment
+type PromoteResultStatus string
+
+const (
+	// PromoteResultSucceeded the application was promoted to the environment
+	PromoteResultSucceeded PromoteResultStatus = "Succeeded"
+	// PromoteResultFailed the promotion failed
+	PromoteResultFailed PromoteResultStatus = "Failed"
+	// PromoteResultPending the promotion was started but was not waited for or its Pull Request was left open
+	PromoteResultPending PromoteResultStatus = "Pending"
+	// PromoteResultSkipped nothing was promoted as --dry-run was specified or the user declined the promotion
+	PromoteResultSkipped PromoteResultStatus = "Skipped"
+)
+
+// PromoteResult is the outcome of the promotion of an application to an environment performed by Run or
+// PromoteAllAutomatic
+type PromoteResult struct {
+	Application    string
+	Environment    string
+	Namespace      string
+	Version        string
+	ReleaseName    string
+	PullRequestURL string
+	MergeCommitSHA string
+	Status         PromoteResultStatus
+	Error          error
+}
+
+// promoteResults collects the results of the promotions performed by the options and the copies of them made for
+// each application
+type promoteResults struct {
+	lock    sync.Mutex
+	results []PromoteResult
+}
+
+// PromoteManifestEntry is a promotion listed in a promotion manifest file
+type PromoteManifestEntry struct {
+	App     string `json:"app"`
+	Version string `json:"version,omitempty"`
+	Env     string `json:"env"`
+}
+
+// PromoteEnvironmentValues are the chart value overrides of an environment in the --env-values-config file
+type PromoteEnvironmentValues struct {
+	ValuesFile string   `json:"valuesFile,omitempty"`
+	Set        []string `json:"set,omitempty"`
+
+	values map[string]interface{}
+}
+
+// PlannedPromotion describes a promotion of the application to an environment which would be performed
+type PlannedPromotion struct {
+	Environment    string
+	Namespace      string
+	Version        string
+	ReleaseName    string
+	ViaPullRequest bool
 }
 
 type ReleasePullRequestInfo struct {
 	GitProvider          gits.GitProvider
 	PullRequest          *gits.GitPullRequest
 	PullRequestArguments *gits.GitPullRequestArguments
+	Deployment           *gits.GitDeployment
 }
 
 var (
 	promote_long = templates.LongDesc(`
 		Promotes a version of an application to zero to many permanent environments.
 
+		The --env, --version, --timeout and --helm-repo-url options default to the values of the JX_PROMOTE_ENV,
+		JX_PROMOTE_VERSION, JX_PROMOTE_TIMEOUT and JX_PROMOTE_HELM_REPO_URL environment variables if they are set.
+		Options specified on the command line always take precedence over the environment variables.
+
 		For more documentation see: [https://jenkins-x.io/about/features/#promotion](https://jenkins-x.io/about/features/#promotion)
 
 `)
@@ -91,6 +634,58 @@ var (
 		# Promote a version of the myapp application to production
 		jx promote myapp --version 1.2.3 --env production
 
+		# Promote several applications to staging in a single Pull Request
+		jx promote svc-a svc-b svc-c --version 1.4.0 --env staging
+
+		# Promote different versions of several applications to staging
+		jx promote svc-a@1.4.0 svc-b@2.0.1 --env staging
+
+		# Promote all the applications whose charts in the helm repository match a pattern
+		jx promote 'payments-*' --version 1.2.0 --env staging
+
+		# Promote the apps and versions listed in a release train manifest
+		jx promote --manifest release-train.yaml
+
+		# Roll out a version to production as a canary receiving 20% of the traffic
+		jx promote myapp --version 1.2.3 --env production --canary-weight 20
+
+		# Then send all of the production traffic to the canary
+		jx promote myapp --version 1.2.3 --env production --canary-promote
+
+		# Override chart values when promoting
+		jx promote myapp --version 1.2.3 --env staging --set resources.limits.memory=512Mi --set featureX.enabled=true
+
+		# Pass a values file to the helm upgrade when promoting to an environment without a GitOps repository
+		jx promote myapp --version 1.2.3 --env staging --values staging-values.yaml
+
+		# Promote myapp to all the automatic environments using the chart value overrides of each environment
+		# listed in env-values.yaml such as 'production: {valuesFile: production-values.yaml, set: [replicaCount=3]}'
+		jx promote myapp --version 1.2.3 --all-auto --env-values-config env-values.yaml
+
+		# Promote myapp to the automatic environments labelled region=eu
+		jx promote myapp --version 1.2.3 --all-auto --env-selector region=eu
+
+		# Link the promotion and the comments on the closed issues to the release notes of the version
+		jx promote myapp --version 1.2.3 --env staging --release-notes-url https://github.com/myorg/myapp/releases/tag/v1.2.3
+
+		# Create the promotion Pull Request even if staging already has version 1.2.3 of myapp
+		jx promote myapp --version 1.2.3 --env staging --allow-noop
+
+		# Validate the .jx/promote.yaml promotion configuration of the current application
+		jx promote --validate
+
+		# Promote the current application to the environments, reviewers and status contexts of its .jx/promote.yaml
+		jx promote --version 1.2.3
+
+		# List the history of the promotions of myapp
+		jx promote history --app myapp
+
+		# Check the status of a promotion of myapp to production which was run with --no-wait
+		jx promote status --app myapp --env production
+
+		# Display the changes promoting myapp to production would make to the environment requirements
+		jx promote diff myapp --version 1.2.3 --env production
+
 		# To create or update a Preview Environment please see the 'jx preview' command
 		jx preview
 	`)
@@ -106,23 +701,97 @@ func NewCmdPromote(f Factory, out io.Writer, errOut io.Writer) *cobra.Command {
 		},
 	}
 	cmd := &cobra.Command{
-		Use:     "promote [application]",
+		Use:     "promote [application...]",
 		Short:   "Promotes a version of an application to an environment",
 		Long:    promote_long,
 		Example: promote_example,
 		Run: func(cmd *cobra.Command, args []string) {
 			options.Cmd = cmd
 			options.Args = args
-			err := options.Run()
+			ctx, cancel := options.signalContext()
+			defer cancel()
+			err := options.RunWithContext(ctx)
 			CheckErr(err)
 		},
 	}
 
 	options.addCommonFlags(cmd)
 
+	cmd.AddCommand(NewCmdPromoteHistory(f, out, errOut))
+	cmd.AddCommand(NewCmdPromoteStatus(f, out, errOut))
+	cmd.AddCommand(NewCmdPromoteDiff(f, out, errOut))
+
 	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "The Namespace to promote to")
+	cmd.Flags().StringVarP(&options.NamespaceSelector, optionNamespaceSelector, "", "", "The label selector such as 'key=value' of the Namespace to promote to as an alternative to --namespace or --env. Exactly one Namespace must match")
 	cmd.Flags().StringVarP(&options.Environment, optionEnvironment, "e", "", "The Environment to promote to")
 	cmd.Flags().BoolVarP(&options.AllAutomatic, "all-auto", "", false, "Promote to all automatic environments in order")
+	cmd.Flags().StringArrayVarP(&options.SkipEnvironments, "skip-env", "", nil, "The name of an environment which --all-auto does not promote to. Can be specified multiple times")
+	cmd.Flags().StringSliceVarP(&options.OnlyEnvironments, "only-env", "", nil, "The comma separated names of the automatic environments which --all-auto promotes to. Other environments are not promoted to")
+	cmd.Flags().StringVarP(&options.EnvironmentSelector, optionEnvironmentSelector, "", "", "The label selector such as 'region=eu' of the environments which --all-auto promotes to so that a group of environments can be promoted at a time")
+	cmd.Flags().StringSliceVarP(&options.EnvironmentOrder, optionEnvironmentOrder, "", nil, "The comma separated names of the environments in the order which --all-auto promotes to them. Unlisted environments are promoted to afterwards in their default order")
+	cmd.Flags().StringVarP(&options.Manifest, optionManifest, "", "", "A YAML file listing the app, version and env of each promotion to perform in order")
+	cmd.Flags().StringVarP(&options.GitHubEnvironment, "github-environment", "", "", "The GitHub Environment whose deployment protection rules must approve the promotion before the Pull Request is merged")
+	cmd.Flags().BoolVarP(&options.NotifyOnFirstFailureOnly, "notify-on-first-failure-only", "", false, "Only sends a notification for the first failure observed while waiting for each promotion. Successful promotions are always notified")
+	cmd.Flags().BoolVarP(&options.RequireApproval, "require-approval", "", false, fmt.Sprintf("Waits for the promotion to environments labelled with %s=true to be approved by annotating the PipelineActivity with %s<environment>=<user>. Fails in batch mode if the promotion is not already approved", kube.LabelProtected, kube.AnnotationPromoteApprovedByPrefix))
+	cmd.Flags().BoolVarP(&options.RequireActivity, "require-activity", "", false, "Fails the promotion if it cannot be recorded in a PipelineActivity as the pipeline and build cannot be found from the $JOB_NAME and $BUILD_NUMBER environment variables or the latest Jenkins build")
+	cmd.Flags().BoolVarP(&options.NoActivity, optionNoActivity, "", false, "Performs the promotion without creating or updating a PipelineActivity for it")
+	cmd.Flags().StringVarP(&options.SlackWebhookURL, "slack-webhook", "", "", "The Slack incoming webhook URL which is notified when a promotion succeeds or fails")
+	cmd.Flags().StringVarP(&options.CompletionWebhookURL, "completion-webhook", "", "", "The URL which is sent a JSON description of each promotion when it succeeds or fails")
+	cmd.Flags().StringVarP(&options.MetricsPushgatewayURL, "metrics-pushgateway", "", "", "The URL of the Prometheus Pushgateway which is pushed the jx_promote_duration_seconds and jx_promote_total metrics when the promotion completes")
+	cmd.Flags().StringVarP(&options.CompletionWebhookSecret, "completion-webhook-secret", "", "", "The secret used to sign the --completion-webhook payload with HMAC SHA256 in the "+completionWebhookSignatureHeader+" header")
+	cmd.Flags().BoolVarP(&options.ValidateManifests, "validate-manifests", "", false, "Renders the chart and validates the manifests before promoting directly via helm")
+	cmd.Flags().StringVarP(&options.ManifestValidator, "manifest-validator", "", defaultManifestValidator, "The schema validator binary used by --validate-manifests")
+	cmd.Flags().StringVarP(&options.KubernetesVersion, "kubernetes-version", "", "", "The Kubernetes version the manifests are validated against. Defaults to the latest version supported by the validator")
+	cmd.Flags().BoolVarP(&options.RequireIssues, optionRequireIssues, "", false, "Fails the promotion if the release does not reference any issues to comment on")
+	cmd.Flags().StringVarP(&options.CommentAs, "comment-as", "", "", "The git user name, such as a bot account, whose API token is used to comment on issues. Defaults to picking the user from the git auth configuration")
+	cmd.Flags().StringVarP(&options.IssueCommentTemplate, optionIssueComment, "", "", "The Go template of the comment added to the closed issues of the release when it is deployed. The template can use .Environment, .Version, .ReleaseNotesURL and .URL. Defaults to a comment that the fix is now deployed to the environment")
+	cmd.Flags().StringVarP(&options.ReleaseNotesURL, optionReleaseNotesURL, "", "", "The URL of the release notes of the version which is recorded in the PipelineActivity and linked from the comments on the closed issues. Overrides the release notes URL of the Release resource")
+	cmd.Flags().StringVarP(&options.CommentSinceVersion, optionCommentSinceVersion, "", "", "Only comments on the closed issues of the release which are not issues of the release of this version. Defaults to the version previously promoted to the environment found in the PipelineActivity history")
+	cmd.Flags().BoolVarP(&options.CommentOnPullRequestOpen, "comment-on-pr-open", "", false, "Comments on the closed issues of the release that the fix is pending deployment when the promotion Pull Request is created, as well as when it is deployed")
+	cmd.Flags().IntVarP(&options.ChartRetries, "chart-retries", "", 3, "The number of times to retry if the chart version cannot be found in the helm repository as it has not been indexed yet")
+	cmd.Flags().DurationVarP(&options.ChartRetryBackoff, "chart-retry-backoff", "", 5*time.Second, "The initial time to wait before retrying if the chart cannot be found. The time doubles on each retry")
+	cmd.Flags().StringVarP(&options.MergeMethod, optionMergeMethod, "", "", fmt.Sprintf("The method used to merge the promotion Pull Request. Possible values: %s. Defaults to the default method of the git provider", strings.Join(gits.MergeMethods, ", ")))
+	cmd.Flags().BoolVarP(&options.AutoRebase, optionAutoRebase, "", true, "Recreates the promotion Pull Request on top of the environment branch if it has conflicts")
+	cmd.Flags().IntVarP(&options.ProviderRetries, optionProviderRetries, "", 3, "The number of times a query of the status of the promotion Pull Request is retried with a backoff if the git provider fails with a transient error such as a 5xx response or a network error")
+	cmd.Flags().IntVarP(&options.MaxRebaseAttempts, "max-rebase-attempts", "", 3, "The number of times the promotion Pull Request is rebased due to conflicts by --"+optionAutoRebase+" before the promotion fails")
+	cmd.Flags().IntVarP(&options.MergeRetries, "merge-retries", "", 3, "The number of times to retry merging the Pull Request if the merge fails after its last commit status succeeded")
+	cmd.Flags().DurationVarP(&options.MergeRetryInterval, "merge-retry-interval", "", 10*time.Second, "The time to wait before retrying to merge the Pull Request")
+	cmd.Flags().StringArrayVarP(&options.PullRequestReviewers, optionPullRequestReviewer, "", nil, "A user or 'org/team' team whose review of the promotion Pull Request is requested. Can be specified multiple times. Defaults to the reviewers of the "+filepath.Join(config.PromoteConfigDir, config.PromoteConfigFileName)+" of the app in the current directory")
+	cmd.Flags().StringArrayVarP(&options.RequiredStatusContexts, optionRequiredContext, "", nil, "A commit status context which must succeed before the promotion Pull Request is merged. Can be specified multiple times. Defaults to the status contexts of the "+filepath.Join(config.PromoteConfigDir, config.PromoteConfigFileName)+" of the app in the current directory")
+	cmd.Flags().StringArrayVarP(&options.PullRequestLabels, optionPullRequestLabel, "", nil, "A label added to the promotion Pull Request such as 'promotion'. Can be specified multiple times")
+	cmd.Flags().BoolVarP(&options.NoDefaultPullRequestLabels, optionNoDefaultPRLabels, "", false, "Disables the default labels of the promotion Pull Request which are the "+environmentPullRequestLabelPrefix+"<name> label of the environment. The --"+optionPullRequestLabel+" and --pr-label-template labels are still added")
+	cmd.Flags().StringArrayVarP(&options.PullRequestLabelTemplates, "pr-label-template", "", nil, "The go template of a label added to the promotion Pull Request such as 'app:{{.App}}'. The templates can use {{.App}}, {{.Environment}}, {{.Version}}, {{.Pipeline}} and {{.Build}}. Labels which render as empty are ignored. Can be specified multiple times")
+	cmd.Flags().StringVarP(&options.PullRequestTitleTemplate, optionPullRequestTitle, "", "", "The Go template of the title of the promotion Pull Request. The template can use .App, .Version, .Environment, .ReleaseNotesURL, .CommitSHA, .GitInfo and .URL")
+	cmd.Flags().StringVarP(&options.PullRequestBodyTemplate, optionPullRequestBody, "", "", "The Go template of the body of the promotion Pull Request. The template can use .App, .Version, .Environment, .ReleaseNotesURL, .CommitSHA, .Reviewers, .GitInfo and .URL")
+	cmd.Flags().StringVarP(&options.EnvironmentRepo, optionEnvironmentRepo, "", "", "The URL of the git repository to create the promotion Pull Request on rather than the source repository of the environment")
+	cmd.Flags().StringVarP(&options.EnvBranch, "env-branch", "", "", "The branch of the environment git repository the promotion Pull Request targets. Defaults to the ref of the environment source or the default branch of the repository")
+	cmd.Flags().StringVarP(&options.MergePolicy, optionMergePolicy, "", "", fmt.Sprintf("Overrides the merge policy of the environment for the promotion Pull Request. Valid values: %s", strings.Join(v1.MergePolicyKindValues, ", ")))
+	cmd.Flags().IntVarP(&options.RequiredApprovals, "required-approvals", "", 0, "Overrides the number of approvals the Pull Request needs before it is merged by the Approved merge policy")
+	cmd.Flags().StringVarP(&options.Output, optionOutput, "o", "", "Prints the result of the promotion in the given format (json or yaml) and writes the log messages to standard error")
+	cmd.Flags().BoolVarP(&options.Validate, "validate", "", false, "Validates the "+filepath.Join(config.PromoteConfigDir, config.PromoteConfigFileName)+" promotion configuration of the app in the current directory and reports any problems without promoting")
+	cmd.Flags().BoolVarP(&options.Force, optionForce, "", false, "Promotes the version even if it is lower than the version currently in the environment requirements")
+	cmd.Flags().BoolVarP(&options.Rollback, optionRollback, "", false, "Creates a Pull Request which promotes the version deployed in the environment before the current version. Requires a GitOps environment")
+	cmd.Flags().BoolVarP(&options.ExcludePrereleases, "exclude-prereleases", "", false, "Ignores prerelease versions such as 2.0.0-rc.1 when resolving the latest version to promote")
+	cmd.Flags().StringVarP(&options.FromPreview, optionFromPreview, "", "", "Promotes the version of the application currently deployed in the given preview environment rather than the latest chart version")
+	cmd.Flags().BoolVarP(&options.VersionFromGitTag, optionVersionFromGitTag, "", false, "Promotes the version of the highest semantic version git tag of the current directory, ignoring any 'v' prefix, rather than the latest chart version")
+	cmd.Flags().BoolVarP(&options.DryRun, "dry-run", "", false, "Resolves the version and environments and logs what would be promoted without creating Pull Requests, upgrading helm releases or updating PipelineActivity resources")
+	cmd.Flags().BoolVarP(&options.Confirm, optionConfirm, "", false, "Promotes to an environment which is promoted automatically by the CI/CD pipelines without asking for confirmation. --batch-mode also promotes without asking. Otherwise the confirmation is asked for and the promotion fails if there is no terminal to ask on")
+	cmd.Flags().BoolVarP(&options.PrintPlanThenConfirm, "print-plan-then-confirm", "", false, "Prints the promotion plan and asks for a single confirmation before promoting to all of its environments. In batch mode the plan is promoted without asking")
+	cmd.Flags().IntVarP(&options.CanaryWeight, optionCanaryWeight, "", 0, "Rolls out the version as a canary which receives the given percentage of the traffic by setting the '"+canaryEnabledValue+"' and '"+canaryWeightValue+"' chart values")
+	cmd.Flags().BoolVarP(&options.CanaryPromote, optionCanaryPromote, "", false, "Promotes the canary of the application so that it receives all of the traffic and disables the canary")
+	cmd.Flags().StringArrayVarP(&options.SetValues, optionSet, "", nil, "Overrides a chart value using 'key=value' when promoting. The value is passed to the helm upgrade or written to the app values of a GitOps environment. Can be specified multiple times")
+	cmd.Flags().StringVarP(&options.ValuesFile, optionValues, "", "", "A YAML file of chart values passed to the helm upgrade when promoting directly via helm to an environment without a GitOps source repository")
+	cmd.Flags().StringVarP(&options.EnvironmentValuesConfig, optionEnvValuesConfig, "", "", "A YAML file mapping environment names to a 'valuesFile' and 'set' list of chart value overrides which are used when promoting to that environment. The overrides are passed to the helm upgrade or written to the app values of a GitOps environment. --set values take precedence")
+	cmd.Flags().BoolVarP(&options.WaitForReady, optionWaitForReady, "", false, "Waits for the Deployments and StatefulSets of the release to have all of their replicas ready after the helm upgrade when promoting directly via helm. Fails the promotion if they are not ready within the --"+optionTimeout)
+	cmd.Flags().BoolVarP(&options.NoWait, optionNoWait, "", false, "Creates the promotion Pull Request or runs the helm upgrade and returns without waiting for the promotion to complete. The PipelineActivity records the promotion as in progress")
+	cmd.Flags().BoolVarP(&options.AllowNoop, optionAllowNoop, "", false, "Creates the promotion Pull Request even if the environment already has the promoted version. Specify --force-rollout to also roll out the application again")
+	cmd.Flags().BoolVarP(&options.ForceRollout, "force-rollout", "", false, "Changes the '"+forceRolloutValue+"' chart value on each promotion so that the application is rolled out again even if the version is unchanged")
+	cmd.Flags().StringVarP(&options.PromotedBy, optionPromotedBy, "", "", "The user or service account triggering the promotion which is recorded in the PipelineActivity. Defaults to the git user email or $USER")
+	cmd.Flags().StringVarP(&options.CommitSHA, optionCommitSHA, "", "", "The git commit SHA of the source of the application being promoted which is recorded in the PipelineActivity. Defaults to the commit recorded by an earlier promotion in the PipelineActivity of the pipeline")
+	cmd.Flags().StringArrayVarP(&options.AppURLs, "app-url", "", nil, "The URL of the application used in issue comments instead of discovering it. Use 'env=URL' to specify the URL for a particular environment")
+	cmd.Flags().StringArrayVarP(&options.ServiceNames, optionServiceName, "", nil, "The name of the service to discover the URL of the application from before trying the application and release names. Can be specified multiple times")
+	cmd.Flags().StringVarP(&options.Platform, optionPlatform, "", "", fmt.Sprintf("The platform of the environment clusters used to discover the URL of the application. One of %s. OpenShift Routes are looked up if the platform is %s or the cluster serves the Route API", strings.Join(promotePlatforms, ", "), OPENSHIFT))
+	cmd.Flags().StringVarP(&options.ServiceSelector, optionServiceSelector, "", "", "The label selector of the services to discover the URL of the application from if no --"+optionServiceName+" has a URL")
 
 	options.addPromoteOptions(cmd)
 	return cmd
@@ -130,18 +799,118 @@ func NewCmdPromote(f Factory, out io.Writer, errOut io.Writer) *cobra.Command {
 
 func (options *PromoteOptions) addPromoteOptions(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&options.Application, optionApplication, "a", "", "The Application to promote")
-	cmd.Flags().StringVarP(&options.Version, "version", "v", "", "The Version to promote")
-	cmd.Flags().StringVarP(&options.LocalHelmRepoName, "helm-repo-name", "r", kube.LocalHelmRepoName, "The name of the helm repository that contains the app")
-	cmd.Flags().StringVarP(&options.HelmRepositoryURL, "helm-repo-url", "u", helm.DefaultHelmRepositoryURL, "The Helm Repository URL to use for the App")
+	cmd.Flags().StringVarP(&options.ChartName, "chart-name", "", "", "The name of the helm chart to promote if it differs from the application name. Defaults to the application name")
+	cmd.Flags().StringVarP(&options.Version, optionVersion, "v", "", "The Version to promote. Can be a semantic version range such as '^1.2.0' or '~1.4' to promote the highest matching version")
+	cmd.Flags().StringVarP(&options.LocalHelmRepoName, optionHelmRepoName, "r", kube.LocalHelmRepoName, "The name of the helm repository that contains the app")
+	cmd.Flags().StringVarP(&options.HelmRepositoryURL, optionHelmRepositoryURL, "u", helm.DefaultHelmRepositoryURL, "The Helm Repository URL to use for the App")
+	cmd.Flags().StringVarP(&options.ChartPath, optionChartPath, "", "", "The directory of a local chart to install directly via helm rather than a chart from the helm repositories. The version is the version in its Chart.yaml. Not supported for environments promoted via a Pull Request")
 	cmd.Flags().StringVarP(&options.ReleaseName, "release", "", "", "The name of the helm release")
 	cmd.Flags().StringVarP(&options.Timeout, optionTimeout, "t", "1h", "The timeout to wait for the promotion to succeed in the underlying Environment. The command fails if the timeout is exceeded or the promotion does not complete")
+	cmd.Flags().StringVarP(&options.TimeoutAction, optionTimeoutAction, "", timeoutActionFail, fmt.Sprintf("The action taken on the promotion Pull Request if it has not merged before the --%s. Possible values: %s. 'leave' leaves the Pull Request open without failing the PipelineActivity", optionTimeout, strings.Join(timeoutActionValues, ", ")))
+	cmd.Flags().StringVarP(&options.LogFormat, optionLogFormat, "", logFormatText, fmt.Sprintf("The format of the progress messages of the promotion. Possible values: %s. 'json' logs each message as a JSON line with the event, app, env, version, prURL and status", strings.Join(logFormatValues, ", ")))
 	cmd.Flags().StringVarP(&options.PullRequestPollTime, optionPullRequestPollTime, "", "20s", "Poll time when waiting for a Pull Request to merge")
+	cmd.Flags().StringVarP(&options.KubeContext, optionKubeContext, "", "", "The kube context of the cluster of the environment. The namespace is created, the application URL is discovered and the helm upgrade is run in the cluster of the context rather than the current cluster")
+	cmd.Flags().StringVarP(&options.PostPullRequestDelay, optionPostPRDelay, "", "", "The time to wait after creating the promotion Pull Request before polling its status. Defaults to "+waitAfterPullRequestCreated.String()+". Specify 0 to poll straight away")
+	cmd.Flags().StringVarP(&options.HelmTimeout, optionHelmTimeout, "", "", "The timeout of the helm upgrade when promoting directly via helm rather than via a Pull Request. Defaults to --"+optionTimeout)
+	cmd.Flags().StringVarP(&options.PullRequestCreateTimeout, optionPullRequestCreate, "", "", "The timeout to wait for the promotion Pull Request to become reviewable, which is when the status of its last commit is reported. Defaults to --"+optionTimeout)
+	cmd.Flags().StringVarP(&options.PullRequestMergeTimeout, optionPullRequestMerge, "", "", "The timeout to wait for a reviewable promotion Pull Request to merge and for the status checks of its merge commit to pass. Defaults to --"+optionTimeout)
+	cmd.Flags().StringVarP(&options.PollBackoffMax, optionPollBackoffMax, "", "", "The maximum poll time when waiting for a Pull Request to merge. If specified the poll time doubles from --"+optionPullRequestPollTime+" up to this maximum while the state of the Pull Request does not change")
 	cmd.Flags().BoolVarP(&options.NoHelmUpdate, "no-helm-update", "", false, "Allows the 'helm repo update' command if you are sure your local helm cache is up to date with the version you wish to promote")
+	cmd.Flags().StringVarP(&options.HelmUpdateTTL, optionHelmUpdateTTL, "", "", "Skips the 'helm repo update' command if the helm repositories were updated by a promotion within this duration such as '10m'. Ignored if --no-helm-update is specified")
 	cmd.Flags().BoolVarP(&options.NoMergePullRequest, "no-merge", "", false, "Disables automatic merge of promote Pull Requests")
 }
 
 // Run implements this command
 func (o *PromoteOptions) Run() error {
+	return o.RunWithContext(context.Background())
+}
+
+// RunWithContext performs the promotions until they complete or the context is cancelled. A cancelled promotion is
+// recorded as failed in its PipelineActivity
+func (o *PromoteOptions) RunWithContext(ctx context.Context) error {
+	start := time.Now()
+	err := o.run(ctx)
+	if o.MetricsPushgatewayURL != "" && !o.Validate && !o.DryRun {
+		o.pushMetrics(time.Since(start), err)
+	}
+	if err == nil {
+		err = o.skippedError()
+	}
+	return err
+}
+
+// skippedError returns a PromotionSkippedError if the user declined any of the promotions performed by Run
+func (o *PromoteOptions) skippedError() error {
+	if o.DryRun {
+		return nil
+	}
+	envNames := []string{}
+	for _, result := range o.Results {
+		if result.Status == PromoteResultSkipped && util.StringArrayIndex(envNames, result.Environment) < 0 {
+			envNames = append(envNames, result.Environment)
+		}
+	}
+	if len(envNames) == 0 {
+		return nil
+	}
+	return &PromotionSkippedError{Environments: envNames}
+}
+
+func (o *PromoteOptions) run(ctx context.Context) error {
+	o.resetResults()
+	o.helmRepoUpdate = &helmRepoUpdate{}
+	defer o.publishResults()
+
+	if o.Validate {
+		return o.validatePromoteConfig(".")
+	}
+	if o.Output != "" {
+		if o.Output != "json" && o.Output != "yaml" {
+			return util.InvalidOption(optionOutput, o.Output, []string{"json", "yaml"})
+		}
+		// lets keep standard output for the result
+		restoreLog := log.SetOutput(o.Err)
+		defer restoreLog()
+	}
+	if o.LogFormat != "" && util.StringArrayIndex(logFormatValues, o.LogFormat) < 0 {
+		return util.InvalidOption(optionLogFormat, o.LogFormat, logFormatValues)
+	}
+	o.applyEnvironmentVariableDefaults()
+	helmRepoName, err := normalizeHelmRepoName(o.LocalHelmRepoName)
+	if err != nil {
+		return err
+	}
+	o.LocalHelmRepoName = helmRepoName
+
+	if o.NoActivity && o.RequireActivity {
+		return fmt.Errorf("Cannot specify --%s with --require-activity", optionNoActivity)
+	}
+	if o.NoActivity && o.RequireApproval {
+		return fmt.Errorf("Cannot specify --%s with --require-approval as the approval is recorded on the PipelineActivity", optionNoActivity)
+	}
+	err = validateReleaseNotesURL(o.ReleaseNotesURL)
+	if err != nil {
+		return err
+	}
+	if o.VersionFromGitTag && o.Manifest != "" {
+		return fmt.Errorf("Cannot specify --%s with --%s", optionVersionFromGitTag, optionManifest)
+	}
+	if o.Manifest != "" {
+		if o.ChartPath != "" {
+			return fmt.Errorf("Cannot specify --%s with --%s", optionChartPath, optionManifest)
+		}
+		return o.PromoteManifest(ctx, o.Manifest)
+	}
+	err = o.resolveChartPath()
+	if err != nil {
+		return err
+	}
+
+	// the durations are parsed before expanding any application patterns so the --helm-update-ttl is honoured
+	err = o.parseDurations()
+	if err != nil {
+		return err
+	}
 	app := o.Application
 	if app == "" {
 		args := o.Args
@@ -152,256 +921,2643 @@ func (o *PromoteOptions) Run() error {
 				return err
 			}
 		} else {
-			app = args[0]
+			apps, err := parseApplicationVersions(args, o.Version)
+			if err != nil {
+				return err
+			}
+			apps, err = o.expandApplicationPatterns(ctx, apps)
+			if err != nil {
+				return err
+			}
+			if len(apps) == 1 {
+				app = apps[0].Name
+				o.Version = apps[0].Version
+			} else {
+				o.applications = apps
+				app = applicationNames(apps)
+			}
 		}
 	}
 	o.Application = app
+	err = o.applyPromoteConfig(".")
+	if err != nil {
+		return err
+	}
+	if len(o.applications) > 1 {
+		if o.ChartName != "" || o.ReleaseName != "" {
+			return fmt.Errorf("Cannot specify --chart-name or --release when promoting multiple applications")
+		}
+		if o.Rollback || o.AllAutomatic || o.PrintPlanThenConfirm {
+			return fmt.Errorf("Cannot specify --%s, --all-auto or --print-plan-then-confirm when promoting multiple applications", optionRollback)
+		}
+	}
 
-	if o.PullRequestPollTime != "" {
-		duration, err := time.ParseDuration(o.PullRequestPollTime)
+	if o.Rollback && o.Version != "" {
+		return fmt.Errorf("Cannot specify --%s with --%s as the version is the previously promoted version", optionVersion, optionRollback)
+	}
+	if o.VersionFromGitTag {
+		if o.Version != "" {
+			return fmt.Errorf("Cannot specify --%s with --%s", optionVersion, optionVersionFromGitTag)
+		}
+		if o.Rollback || len(o.applications) > 1 {
+			return fmt.Errorf("Cannot specify --%s with --%s or when promoting multiple applications", optionVersionFromGitTag, optionRollback)
+		}
+		version, err := o.findVersionFromGitTag()
 		if err != nil {
-			return fmt.Errorf("Invalid duration format %s for option --%s: %s", o.PullRequestPollTime, optionPullRequestPollTime, err)
+			return err
 		}
-		o.PullRequestPollDuration = &duration
+		o.Version = version
 	}
-	if o.Timeout != "" {
-		duration, err := time.ParseDuration(o.Timeout)
+	if o.FromPreview != "" {
+		if o.Version != "" || o.VersionFromGitTag {
+			return fmt.Errorf("Cannot specify --%s with --%s or --%s", optionFromPreview, optionVersion, optionVersionFromGitTag)
+		}
+		if o.Rollback || len(o.applications) > 1 {
+			return fmt.Errorf("Cannot specify --%s with --%s or when promoting multiple applications", optionFromPreview, optionRollback)
+		}
+		version, err := o.findVersionFromPreview(o.FromPreview)
 		if err != nil {
-			return fmt.Errorf("Invalid duration format %s for option --%s: %s", o.Timeout, optionTimeout, err)
+			return err
 		}
-		o.TimeoutDuration = &duration
+		o.Version = version
 	}
-
-	targetNS, env, err := o.GetTargetNamespace(o.Namespace, o.Environment)
+	if o.Rollback && o.AllAutomatic {
+		return fmt.Errorf("Cannot specify --all-auto with --%s", optionRollback)
+	}
+	err = validateDigestVersions(o.Version, o.applications)
 	if err != nil {
 		return err
 	}
-	apisClient, err := o.Factory.CreateApiExtensionsClient()
+	err = o.resolveCommitSHA()
 	if err != nil {
 		return err
 	}
-	err = kube.RegisterEnvironmentCRD(apisClient)
+	o.resolvePromotedBy()
+	if o.NamespaceSelector != "" && o.AllAutomatic {
+		return fmt.Errorf("Cannot specify --all-auto with --%s", optionNamespaceSelector)
+	}
+	if o.EnvironmentSelector != "" && !o.AllAutomatic {
+		return fmt.Errorf("The --%s can only be specified with --all-auto", optionEnvironmentSelector)
+	}
+	if o.Platform != "" && util.StringArrayIndex(promotePlatforms, o.Platform) < 0 {
+		return util.InvalidOption(optionPlatform, o.Platform, promotePlatforms)
+	}
+	if o.ServiceSelector != "" {
+		_, err := labels.Parse(o.ServiceSelector)
+		if err != nil {
+			return fmt.Errorf("Invalid --%s %s: %s", optionServiceSelector, o.ServiceSelector, err)
+		}
+	}
+	if o.CanaryWeight < 0 || o.CanaryWeight > 100 {
+		return fmt.Errorf("The --%s must be between 1 and 100 but was %d", optionCanaryWeight, o.CanaryWeight)
+	}
+	if o.CanaryWeight > 0 && o.CanaryPromote {
+		return fmt.Errorf("Cannot specify --%s with --%s", optionCanaryWeight, optionCanaryPromote)
+	}
+	for _, value := range o.SetValues {
+		_, _, err := parseSetValue(value)
+		if err != nil {
+			return err
+		}
+	}
+	if o.MaxRebaseAttempts < 0 {
+		return fmt.Errorf("The --max-rebase-attempts must not be negative but was %d", o.MaxRebaseAttempts)
+	}
+	if o.ProviderRetries < 0 {
+		return fmt.Errorf("The --%s must not be negative but was %d", optionProviderRetries, o.ProviderRetries)
+	}
+	if o.NoWait && o.WaitForReady {
+		return fmt.Errorf("Cannot specify --%s with --%s", optionWaitForReady, optionNoWait)
+	}
+	if o.Rollback && (o.CanaryWeight > 0 || o.CanaryPromote) {
+		return fmt.Errorf("Cannot specify --%s or --%s with --%s", optionCanaryWeight, optionCanaryPromote, optionRollback)
+	}
+	if o.MergePolicy != "" && util.StringArrayIndex(v1.MergePolicyKindValues, o.MergePolicy) < 0 {
+		return util.InvalidOption(optionMergePolicy, o.MergePolicy, v1.MergePolicyKindValues)
+	}
+	if o.MergeMethod != "" && util.StringArrayIndex(gits.MergeMethods, o.MergeMethod) < 0 {
+		return util.InvalidOption(optionMergeMethod, o.MergeMethod, gits.MergeMethods)
+	}
+	if o.TimeoutAction != "" && util.StringArrayIndex(timeoutActionValues, o.TimeoutAction) < 0 {
+		return util.InvalidOption(optionTimeoutAction, o.TimeoutAction, timeoutActionValues)
+	}
+	err = o.validatePullRequestTemplates()
 	if err != nil {
 		return err
 	}
-	err = kube.RegisterPipelineActivityCRD(apisClient)
+	err = o.validatePullRequestReviewers()
 	if err != nil {
 		return err
 	}
-	err = kube.RegisterGitServiceCRD(apisClient)
+	err = o.validateValuesFile()
 	if err != nil {
 		return err
 	}
-	err = kube.RegisterUserCRD(apisClient)
+	err = o.loadEnvironmentValuesConfig()
 	if err != nil {
 		return err
 	}
-
-	jxClient, ns, err := o.JXClient()
+	err = o.validateEnvironmentRepo()
 	if err != nil {
 		return err
 	}
-	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
-
-	releaseName := o.ReleaseName
-	if releaseName == "" {
-		releaseName = targetNS + "-" + app
-		o.ReleaseName = releaseName
-	}
 
-	if o.AllAutomatic {
-		return o.PromoteAllAutomatic()
+	err = o.useKubeContext()
+	if err != nil {
+		return err
 	}
-	if env == nil {
-		if o.Environment == "" {
-			return util.MissingOption(optionEnvironment)
-		}
-		return fmt.Errorf("Could not find an Environment called %s", o.Environment)
+	targetNS, env, err := o.GetTargetNamespace(o.Namespace, o.Environment)
+	if err != nil {
+		return err
 	}
-	releaseInfo, err := o.Promote(targetNS, env, true)
-	err = o.WaitForPromotion(targetNS, env, releaseInfo)
+	env, err = o.overrideEnvironmentRepo(env)
 	if err != nil {
 		return err
 	}
+	if !o.DryRun {
+		err = o.registerPromoteCRDs()
+		if err != nil {
+			return err
+		}
+	}
+
+	jxClient, ns, err := o.JXClient()
+	if err != nil {
+		return err
+	}
+	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+
+	if len(o.applications) > 1 {
+		if env == nil {
+			return fmt.Errorf("Could not find an Environment called %s", o.Environment)
+		}
+		releaseInfo, err := o.promoteApplications(ctx, targetNS, env)
+		if o.Output != "" {
+			outputErr := o.printOutput(targetNS, env, releaseInfo, err)
+			if err == nil {
+				err = outputErr
+			}
+		}
+		return err
+	}
+
+	releaseName := o.ReleaseName
+	if releaseName == "" {
+		releaseName = targetNS + "-" + app
+		o.ReleaseName = releaseName
+	}
+
+	if o.PrintPlanThenConfirm {
+		plan, confirmed, err := o.printPlanThenConfirm(ctx)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			if len(plan) == 0 {
+				return nil
+			}
+			o.infoEvent(env, promoteEvent{Event: "plan-not-confirmed"}, "The promotion plan was not confirmed so nothing was promoted\n")
+			envNames := []string{}
+			for _, p := range plan {
+				envNames = append(envNames, p.Environment)
+			}
+			return &PromotionSkippedError{Environments: envNames}
+		}
+	}
+
+	if o.AllAutomatic {
+		return o.promoteAllAutomatic(ctx)
+	}
+	if env == nil {
+		if o.Environment == "" {
+			return util.MissingOption(optionEnvironment)
+		}
+		return fmt.Errorf("Could not find an Environment called %s", o.Environment)
+	}
+	releaseInfo, err := o.Promote(ctx, targetNS, env, !o.PrintPlanThenConfirm)
+	if err == nil {
+		err = o.WaitForPromotion(ctx, targetNS, env, releaseInfo)
+	}
+	o.recordResult(targetNS, env, releaseInfo, err)
+	if o.Output != "" {
+		outputErr := o.printOutput(targetNS, env, releaseInfo, err)
+		if err == nil {
+			err = outputErr
+		}
+	}
+	return err
+}
+
+// parseDurations parses the --timeout and --pull-request-poll-time options
+func (o *PromoteOptions) parseDurations() error {
+	if o.PullRequestPollTime != "" {
+		duration, err := time.ParseDuration(o.PullRequestPollTime)
+		if err != nil {
+			return fmt.Errorf("Invalid duration format %s for option --%s: %s", o.PullRequestPollTime, optionPullRequestPollTime, err)
+		}
+		o.PullRequestPollDuration = &duration
+	}
+	if o.Timeout != "" {
+		duration, err := time.ParseDuration(o.Timeout)
+		if err != nil {
+			return fmt.Errorf("Invalid duration format %s for option --%s: %s", o.Timeout, optionTimeout, err)
+		}
+		o.TimeoutDuration = &duration
+	}
+	if o.PullRequestCreateTimeout != "" {
+		duration, err := time.ParseDuration(o.PullRequestCreateTimeout)
+		if err != nil {
+			return fmt.Errorf("Invalid duration format %s for option --%s: %s", o.PullRequestCreateTimeout, optionPullRequestCreate, err)
+		}
+		o.PullRequestCreateTimeoutDuration = &duration
+	}
+	if o.PullRequestMergeTimeout != "" {
+		duration, err := time.ParseDuration(o.PullRequestMergeTimeout)
+		if err != nil {
+			return fmt.Errorf("Invalid duration format %s for option --%s: %s", o.PullRequestMergeTimeout, optionPullRequestMerge, err)
+		}
+		o.PullRequestMergeTimeoutDuration = &duration
+	}
+	if o.PollBackoffMax != "" {
+		duration, err := time.ParseDuration(o.PollBackoffMax)
+		if err != nil {
+			return fmt.Errorf("Invalid duration format %s for option --%s: %s", o.PollBackoffMax, optionPollBackoffMax, err)
+		}
+		o.PollBackoffMaxDuration = &duration
+	}
+	if o.HelmTimeout != "" {
+		duration, err := time.ParseDuration(o.HelmTimeout)
+		if err != nil {
+			return fmt.Errorf("Invalid duration format %s for option --%s: %s", o.HelmTimeout, optionHelmTimeout, err)
+		}
+		if duration < time.Second {
+			return fmt.Errorf("The --%s must be at least 1s but was %s", optionHelmTimeout, o.HelmTimeout)
+		}
+		o.HelmTimeoutDuration = &duration
+	}
+	if o.PostPullRequestDelay != "" {
+		duration, err := time.ParseDuration(o.PostPullRequestDelay)
+		if err != nil {
+			return fmt.Errorf("Invalid duration format %s for option --%s: %s", o.PostPullRequestDelay, optionPostPRDelay, err)
+		}
+		if duration < 0 {
+			return fmt.Errorf("The --%s must not be negative but was %s", optionPostPRDelay, o.PostPullRequestDelay)
+		}
+		o.PostPullRequestDelayDuration = &duration
+	}
+	if o.HelmUpdateTTL != "" {
+		duration, err := time.ParseDuration(o.HelmUpdateTTL)
+		if err != nil {
+			return fmt.Errorf("Invalid duration format %s for option --%s: %s", o.HelmUpdateTTL, optionHelmUpdateTTL, err)
+		}
+		if duration < 0 {
+			return fmt.Errorf("The --%s must not be negative but was %s", optionHelmUpdateTTL, o.HelmUpdateTTL)
+		}
+		o.HelmUpdateTTLDuration = &duration
+	}
+	return nil
+}
+
+// postPullRequestDelay returns the time to wait after creating the promotion Pull Request before polling its status
+func (o *PromoteOptions) postPullRequestDelay() time.Duration {
+	if o.PostPullRequestDelayDuration != nil {
+		return *o.PostPullRequestDelayDuration
+	}
+	return waitAfterPullRequestCreated
+}
+
+// helmTimeout returns the timeout in seconds of the helm upgrade of the release from the --helm-timeout falling back
+// to the --timeout so that a stuck upgrade does not outlive the promotion. Returns nil if there is no timeout
+func (o *PromoteOptions) helmTimeout() *int {
+	duration := o.HelmTimeoutDuration
+	if duration == nil {
+		duration = o.TimeoutDuration
+	}
+	if duration == nil || *duration <= 0 {
+		return nil
+	}
+	seconds := int(duration.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &seconds
+}
+
+// LoadPromoteManifest loads the promotions listed in the given manifest file
+func (o *PromoteOptions) LoadPromoteManifest(fileName string) ([]PromoteManifestEntry, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load the promotion manifest %s due to %s", fileName, err)
+	}
+	entries := []PromoteManifestEntry{}
+	err = yaml.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal the promotion manifest %s due to %s", fileName, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("The promotion manifest %s does not contain any promotions", fileName)
+	}
+	for i, entry := range entries {
+		if entry.App == "" {
+			return nil, fmt.Errorf("The promotion %d in the manifest %s has no app", i+1, fileName)
+		}
+		if entry.Env == "" {
+			return nil, fmt.Errorf("The promotion of app %s in the manifest %s has no env", entry.App, fileName)
+		}
+	}
+	return entries, nil
+}
+
+// PromoteManifest performs the promotions listed in the given manifest file in order. All of the environments are
+// validated before anything is promoted
+func (o *PromoteOptions) PromoteManifest(ctx context.Context, fileName string) error {
+	if o.Application != "" || len(o.Args) > 0 || o.Environment != "" || o.Version != "" {
+		return fmt.Errorf("Cannot specify an application, --%s or --%s with --%s as they are specified in the manifest", optionEnvironment, optionVersion, optionManifest)
+	}
+	if o.AllAutomatic || o.Rollback || o.PrintPlanThenConfirm {
+		return fmt.Errorf("Cannot specify --all-auto, --%s or --print-plan-then-confirm with --%s", optionRollback, optionManifest)
+	}
+	entries, err := o.LoadPromoteManifest(fileName)
+	if err != nil {
+		return err
+	}
+	err = o.parseDurations()
+	if err != nil {
+		return err
+	}
+	kubeClient, currentNs, err := o.KubeClient()
+	if err != nil {
+		return err
+	}
+	team, _, err := kube.GetDevNamespace(kubeClient, currentNs)
+	if err != nil {
+		return err
+	}
+	jxClient, ns, err := o.JXClient()
+	if err != nil {
+		return err
+	}
+	envs, envNames, err := kube.GetEnvironments(jxClient, team)
+	if err != nil {
+		return err
+	}
+	unknownEnvs := []string{}
+	for _, entry := range entries {
+		if envs[entry.Env] == nil && util.StringArrayIndex(unknownEnvs, entry.Env) < 0 {
+			unknownEnvs = append(unknownEnvs, entry.Env)
+		}
+	}
+	if len(unknownEnvs) > 0 {
+		return fmt.Errorf("The promotion manifest %s refers to unknown environments %s. Available environments: %s", fileName, strings.Join(unknownEnvs, ", "), strings.Join(envNames, ", "))
+	}
+	if !o.DryRun {
+		err = o.registerPromoteCRDs()
+		if err != nil {
+			return err
+		}
+	}
+	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+
+	for _, entry := range entries {
+		env := envs[entry.Env]
+		targetNS, err := kube.DiscoverEnvironmentNamespace(kubeClient, env)
+		if err != nil {
+			return err
+		}
+		if targetNS == "" {
+			return fmt.Errorf("No namespace for environment %s", env.Name)
+		}
+		appOptions := o.forApplication(applicationVersion{
+			Name:    entry.App,
+			Version: entry.Version,
+		})
+		appOptions.Environment = env.Name
+		err = appOptions.promoteAndWait(ctx, targetNS, env)
+		if err != nil {
+			return fmt.Errorf("Failed to promote app %s to environment %s: %s", entry.App, env.Name, err)
+		}
+	}
+	return nil
+}
+
+// printOutput prints the result of the promotion to standard output in the --output format
+func (o *PromoteOptions) printOutput(targetNS string, env *v1.Environment, releaseInfo *ReleaseInfo, promoteErr error) error {
+	result := o.promoteOutput(targetNS, env, releaseInfo, promoteErr)
+	var data []byte
+	var err error
+	if o.Output == "json" {
+		data, err = json.MarshalIndent(result, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = yaml.Marshal(result)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = o.Out.Write(data)
 	return err
 }
 
-func (o *PromoteOptions) PromoteAllAutomatic() error {
-	kubeClient, currentNs, err := o.KubeClient()
-	if err != nil {
-		return err
+// promoteOutput returns the result of the promotion from the release information gathered while promoting
+func (o *PromoteOptions) promoteOutput(targetNS string, env *v1.Environment, releaseInfo *ReleaseInfo, promoteErr error) *PromoteOutput {
+	result := o.promoteResult(targetNS, env, releaseInfo, promoteErr)
+	answer := &PromoteOutput{
+		Application:    result.Application,
+		Version:        result.Version,
+		Namespace:      result.Namespace,
+		Environment:    result.Environment,
+		ReleaseName:    result.ReleaseName,
+		PullRequestURL: result.PullRequestURL,
+		MergeCommitSHA: result.MergeCommitSHA,
+		Status:         string(v1.ActivityStatusTypeSucceeded),
+	}
+	if promoteErr != nil {
+		answer.Status = string(v1.ActivityStatusTypeFailed)
+		answer.Error = promoteErr.Error()
+	}
+	return answer
+}
+
+// promoteResult returns the outcome of the promotion from the release information gathered while promoting
+func (o *PromoteOptions) promoteResult(targetNS string, env *v1.Environment, releaseInfo *ReleaseInfo, promoteErr error) PromoteResult {
+	result := PromoteResult{
+		Application: o.Application,
+		Version:     o.Version,
+		Namespace:   targetNS,
+		Status:      PromoteResultSucceeded,
+		Error:       promoteErr,
+	}
+	if env != nil {
+		result.Environment = env.Name
+	}
+	merged := true
+	if releaseInfo != nil {
+		result.ReleaseName = releaseInfo.ReleaseName
+		if releaseInfo.Version != "" {
+			result.Version = releaseInfo.Version
+		}
+		pullRequestInfo := releaseInfo.PullRequestInfo
+		if pullRequestInfo != nil && pullRequestInfo.PullRequest != nil {
+			pr := pullRequestInfo.PullRequest
+			result.PullRequestURL = pr.URL
+			if pr.MergeCommitSHA != nil {
+				result.MergeCommitSHA = *pr.MergeCommitSHA
+			}
+			merged = pullRequestMerged(pr)
+		}
+	}
+	switch {
+	case promoteErr != nil:
+		result.Status = PromoteResultFailed
+	case o.DryRun || (releaseInfo != nil && releaseInfo.Skipped):
+		result.Status = PromoteResultSkipped
+	case o.NoWait || !merged:
+		result.Status = PromoteResultPending
+	}
+	return result
+}
+
+// resetResults starts collecting the results of the promotions performed by the options and their copies
+func (o *PromoteOptions) resetResults() {
+	o.results = &promoteResults{}
+	o.Results = nil
+}
+
+// publishResults exposes the results collected since resetResults was called as the Results of the options
+func (o *PromoteOptions) publishResults() {
+	if o.results == nil {
+		return
+	}
+	o.results.lock.Lock()
+	defer o.results.lock.Unlock()
+	o.Results = append([]PromoteResult{}, o.results.results...)
+}
+
+// recordResult records the outcome of the promotion of the application to the environment
+func (o *PromoteOptions) recordResult(targetNS string, env *v1.Environment, releaseInfo *ReleaseInfo, promoteErr error) {
+	if o.results == nil {
+		o.resetResults()
+	}
+	result := o.promoteResult(targetNS, env, releaseInfo, promoteErr)
+	o.results.lock.Lock()
+	defer o.results.lock.Unlock()
+	o.results.results = append(o.results.results, result)
+}
+
+// applyPromoteConfig defaults the environments, the --pr-reviewer and the --required-status-context options from the
+// promotion configuration of the app in the given directory. The options take precedence over the configuration. A
+// single environment is promoted to as if specified via --env whereas several are promoted to via --all-auto --only-env
+func (o *PromoteOptions) applyPromoteConfig(dir string) error {
+	promoteConfig, fileName, exists, err := config.LoadPromoteConfig(dir)
+	if err != nil || !exists {
+		return err
+	}
+	if len(o.PullRequestReviewers) == 0 {
+		o.PullRequestReviewers = promoteConfig.Reviewers
+	}
+	if len(o.RequiredStatusContexts) == 0 {
+		o.RequiredStatusContexts = promoteConfig.StatusContexts
+	}
+	environments := promoteConfig.Environments
+	if len(environments) == 0 || o.Environment != "" || o.Namespace != "" || o.NamespaceSelector != "" || o.AllAutomatic {
+		return nil
+	}
+	if len(environments) == 1 {
+		o.Environment = environments[0]
+	} else {
+		o.AllAutomatic = true
+		if len(o.OnlyEnvironments) == 0 {
+			o.OnlyEnvironments = environments
+		}
+	}
+	o.infoEvent(nil, promoteEvent{Event: "promote-config"}, "Promoting to the environments %s of %s\n", util.ColorInfo(strings.Join(environments, ", ")), fileName)
+	return nil
+}
+
+// validatePromoteConfig validates the promotion configuration of the app in the given directory against the
+// environments of the team and reports any problems without promoting
+func (o *PromoteOptions) validatePromoteConfig(dir string) error {
+	promoteConfig, fileName, exists, err := config.LoadPromoteConfig(dir)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("No promotion configuration found at %s", fileName)
+	}
+	jxClient, ns, err := o.JXClientAndDevNamespace()
+	if err != nil {
+		return err
+	}
+	envNames, err := kube.GetEnvironmentNames(jxClient, ns)
+	if err != nil {
+		return err
+	}
+	problems := promoteConfig.Validate(envNames)
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			log.Errorf("%s: %s\n", fileName, problem)
+		}
+		return fmt.Errorf("Found %d problems in %s", len(problems), fileName)
+	}
+	log.Infof("The promotion configuration %s is valid\n", util.ColorInfo(fileName))
+	return nil
+}
+
+// printPlanThenConfirm prints the promotion plan and asks the user to confirm it unless in batch mode. The version
+// resolved by the plan is used for the promotion so that the confirmed plan is what gets promoted. Returns the plan
+// and whether it was confirmed
+func (o *PromoteOptions) printPlanThenConfirm(ctx context.Context) ([]PlannedPromotion, bool, error) {
+	plan, err := o.PromotionPlan(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(plan) == 0 {
+		o.infoEvent(nil, promoteEvent{Event: "no-environments"}, "There are no environments to promote to\n")
+		return plan, false, nil
+	}
+	table := o.CreateTable()
+	table.AddRow("ENVIRONMENT", "NAMESPACE", "VERSION", "RELEASE", "VIA")
+	for _, p := range plan {
+		via := "helm"
+		if p.ViaPullRequest {
+			via = "Pull Request"
+		}
+		table.AddRow(p.Environment, p.Namespace, p.Version, p.ReleaseName, via)
+	}
+	table.Render()
+
+	if o.Version == "" {
+		o.Version = plan[0].Version
+	}
+	if o.BatchMode {
+		return plan, true, nil
+	}
+	confirmed, err := confirmPromotionPlan(fmt.Sprintf("Do you wish to promote %s to the %d environment(s) in the plan? :", o.Application, len(plan)))
+	return plan, confirmed, err
+}
+
+// registerPromoteCRDs registers the custom resources used by a promotion
+func (o *PromoteOptions) registerPromoteCRDs() error {
+	apisClient, err := o.CreateApiExtensionsClient()
+	if err != nil {
+		return err
+	}
+	err = kube.RegisterEnvironmentCRD(apisClient)
+	if err != nil {
+		return err
+	}
+	err = kube.RegisterPipelineActivityCRD(apisClient)
+	if err != nil {
+		return err
+	}
+	err = kube.RegisterGitServiceCRD(apisClient)
+	if err != nil {
+		return err
+	}
+	return kube.RegisterUserCRD(apisClient)
+}
+
+// applyEnvironmentVariableDefaults defaults any options which were not specified explicitly from the JX_PROMOTE_*
+// environment variables. The environment and version are not defaulted for a --manifest which specifies them
+func (o *PromoteOptions) applyEnvironmentVariableDefaults() {
+	if o.Manifest == "" {
+		o.defaultFromEnvironmentVariable(&o.Environment, optionEnvironment, envVarPromoteEnvironment)
+		o.defaultFromEnvironmentVariable(&o.Version, optionVersion, envVarPromoteVersion)
+	}
+	o.defaultFromEnvironmentVariable(&o.Timeout, optionTimeout, envVarPromoteTimeout)
+	o.defaultFromEnvironmentVariable(&o.HelmRepositoryURL, optionHelmRepositoryURL, envVarPromoteHelmRepositoryURL)
+}
+
+// defaultFromEnvironmentVariable sets the option to the value of the environment variable unless the option was
+// specified explicitly. Without a command any non empty value is treated as explicitly specified
+func (o *PromoteOptions) defaultFromEnvironmentVariable(value *string, flag string, envVar string) {
+	envValue := os.Getenv(envVar)
+	if envValue == "" {
+		return
+	}
+	if o.Cmd != nil {
+		f := o.Cmd.Flags().Lookup(flag)
+		if f != nil && f.Changed {
+			return
+		}
+	} else if *value != "" {
+		return
+	}
+	*value = envValue
+}
+
+// PromoteAllAutomatic promotes the application to all of the automatic permanent environments exposing the outcome of
+// each promotion as the Results
+func (o *PromoteOptions) PromoteAllAutomatic(ctx context.Context) error {
+	o.resetResults()
+	defer o.publishResults()
+	o.helmRepoUpdate = &helmRepoUpdate{}
+	o.resolvePromotedBy()
+	return o.promoteAllAutomatic(ctx)
+}
+
+// promoteAllAutomatic promotes the application to all of the automatic permanent environments recording the results
+// in the results collected by the caller
+func (o *PromoteOptions) promoteAllAutomatic(ctx context.Context) error {
+	kubeClient, currentNs, err := o.KubeClient()
+	if err != nil {
+		return err
+	}
+	team, _, err := kube.GetDevNamespace(kubeClient, currentNs)
+	if err != nil {
+		return err
+	}
+	jxClient, _, err := o.JXClient()
+	if err != nil {
+		return err
+	}
+	selector, err := o.environmentSelector()
+	if err != nil {
+		return err
+	}
+	envs, err := jxClient.JenkinsV1().Environments(team).List(metav1.ListOptions{LabelSelector: o.EnvironmentSelector})
+	if err != nil {
+		o.warnEvent(nil, promoteEvent{Event: "no-environments"}, "No Environments found: %s\n", err)
+		return nil
+	}
+	if len(envs.Items) == 0 && selector != nil {
+		o.warnEvent(nil, promoteEvent{Event: "no-environments"}, "No Environments in team %s match the --%s %s so there is nothing to promote to\n", team, optionEnvironmentSelector, o.EnvironmentSelector)
+		return nil
+	}
+	environments, skipped, unknown := o.skipEnvironments(envs.Items)
+	for _, name := range unknown {
+		o.warnEvent(nil, promoteEvent{Event: "skip-environment-not-found"}, "Cannot skip environment %s as there is no environment called %s in team %s\n", name, name, team)
+	}
+	if len(skipped) > 0 {
+		o.infoEvent(nil, promoteEvent{Event: "environments-skipped"}, "Skipping environments %s\n", util.ColorInfo(strings.Join(skipped, ", ")))
+	}
+	if len(environments) == 0 {
+		if len(skipped) > 0 {
+			o.warnEvent(nil, promoteEvent{Event: "no-environments"}, "All of the Environments in team %s are skipped so there is nothing to promote to\n", team)
+			return nil
+		}
+		o.warnEvent(nil, promoteEvent{Event: "no-environments"}, "No Environments have been created yet in team %s. Please create some via 'jx create env'\n", team)
+		return nil
+	}
+	kube.SortEnvironments(environments)
+	environments, err = o.onlyEnvironments(envs.Items, environments)
+	if err != nil {
+		return err
+	}
+	environments, err = o.orderEnvironments(envs.Items, environments)
+	if err != nil {
+		return err
+	}
+
+	for _, env := range environments {
+		kind := env.Spec.Kind
+		if env.Spec.PromotionStrategy == v1.PromotionStrategyTypeAutomatic && kind.IsPermanent() {
+			ns, err := kube.DiscoverEnvironmentNamespace(kubeClient, &env)
+			if err != nil {
+				return err
+			}
+			if ns == "" {
+				return fmt.Errorf("No namespace for environment %s", env.Name)
+			}
+			if ctx.Err() != nil {
+				return &promoteCancelledError{fmt.Sprintf("Cancelled before promoting to environment %s", env.Name)}
+			}
+			err = o.promoteAndWait(ctx, ns, &env)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// environmentSelector returns the label selector of the environments specified by the --env-selector option or nil if
+// all environments are selected
+func (o *PromoteOptions) environmentSelector() (labels.Selector, error) {
+	if o.EnvironmentSelector == "" {
+		return nil, nil
+	}
+	selector, err := labels.Parse(o.EnvironmentSelector)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid --%s %s: %s", optionEnvironmentSelector, o.EnvironmentSelector, err)
+	}
+	return selector, nil
+}
+
+// skipEnvironments returns the environments which are not skipped by the --skip-env option along with the names of
+// the skipped environments and the names of any skipped environments which do not exist
+func (o *PromoteOptions) skipEnvironments(environments []v1.Environment) ([]v1.Environment, []string, []string) {
+	if len(o.SkipEnvironments) == 0 {
+		return environments, nil, nil
+	}
+	answer := []v1.Environment{}
+	skipped := []string{}
+	for _, env := range environments {
+		if util.StringArrayIndex(o.SkipEnvironments, env.Name) >= 0 {
+			skipped = append(skipped, env.Name)
+			continue
+		}
+		answer = append(answer, env)
+	}
+	unknown := []string{}
+	for _, name := range o.SkipEnvironments {
+		if util.StringArrayIndex(skipped, name) < 0 && util.StringArrayIndex(unknown, name) < 0 {
+			unknown = append(unknown, name)
+		}
+	}
+	return answer, skipped, unknown
+}
+
+// onlyEnvironments returns the environments named by the --only-env option. Returns an error if a named environment
+// does not exist or is not an automatic permanent environment
+func (o *PromoteOptions) onlyEnvironments(all []v1.Environment, environments []v1.Environment) ([]v1.Environment, error) {
+	if len(o.OnlyEnvironments) == 0 {
+		return environments, nil
+	}
+	names := []string{}
+	for _, env := range all {
+		names = append(names, env.Name)
+	}
+	problems := []string{}
+	for _, name := range o.OnlyEnvironments {
+		idx := util.StringArrayIndex(names, name)
+		if idx < 0 {
+			problems = append(problems, fmt.Sprintf("there is no environment called %s", name))
+			continue
+		}
+		env := all[idx]
+		if env.Spec.PromotionStrategy != v1.PromotionStrategyTypeAutomatic || !env.Spec.Kind.IsPermanent() {
+			problems = append(problems, fmt.Sprintf("environment %s is not an automatic permanent environment", name))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("Invalid --only-env %s: %s. Available environments: %s", strings.Join(o.OnlyEnvironments, ","), strings.Join(problems, ", "), strings.Join(names, ", "))
+	}
+	answer := []v1.Environment{}
+	for _, env := range environments {
+		if util.StringArrayIndex(o.OnlyEnvironments, env.Name) >= 0 {
+			answer = append(answer, env)
+		}
+	}
+	return answer, nil
+}
+
+// orderEnvironments returns the sorted environments reordered so that the environments named by the --env-order
+// option come first in the given order followed by the other environments. Returns an error if a named environment
+// does not exist
+func (o *PromoteOptions) orderEnvironments(all []v1.Environment, environments []v1.Environment) ([]v1.Environment, error) {
+	if len(o.EnvironmentOrder) == 0 {
+		return environments, nil
+	}
+	names := []string{}
+	for _, env := range all {
+		names = append(names, env.Name)
+	}
+	unknown := []string{}
+	for _, name := range o.EnvironmentOrder {
+		if util.StringArrayIndex(names, name) < 0 {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("Invalid --%s %s: there is no environment called %s. Available environments: %s", optionEnvironmentOrder, strings.Join(o.EnvironmentOrder, ","), strings.Join(unknown, ", "), strings.Join(names, ", "))
+	}
+	answer := []v1.Environment{}
+	for _, name := range o.EnvironmentOrder {
+		for _, env := range environments {
+			if env.Name == name && !environmentsContain(answer, name) {
+				answer = append(answer, env)
+			}
+		}
+	}
+	for _, env := range environments {
+		if !environmentsContain(answer, env.Name) {
+			answer = append(answer, env)
+		}
+	}
+	return answer, nil
+}
+
+// environmentsContain returns true if there is an environment with the given name
+func environmentsContain(environments []v1.Environment, name string) bool {
+	for _, env := range environments {
+		if env.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// promoteAndWait promotes the application to the environment and waits for the promotion to complete while holding
+// the lock for the application so that concurrent promotions of the same application within this process are
+// serialized while promotions of different applications run concurrently
+func (o *PromoteOptions) promoteAndWait(ctx context.Context, ns string, env *v1.Environment) error {
+	app := o.Application
+	appPromotionLocks.Lock(app)
+	defer appPromotionLocks.Unlock(app)
+
+	releaseInfo, err := o.Promote(ctx, ns, env, false)
+	if err == nil {
+		err = o.WaitForPromotion(ctx, ns, env, releaseInfo)
+	}
+	o.recordResult(ns, env, releaseInfo, err)
+	return err
+}
+
+// PromotionPlan returns the ordered promotions the current options would perform without modifying any resources
+func (o *PromoteOptions) PromotionPlan(ctx context.Context) ([]PlannedPromotion, error) {
+	app := o.Application
+	if app == "" {
+		return nil, util.MissingOption(optionApplication)
+	}
+	kubeClient, currentNs, err := o.KubeClient()
+	if err != nil {
+		return nil, err
+	}
+	team, _, err := kube.GetDevNamespace(kubeClient, currentNs)
+	if err != nil {
+		return nil, err
+	}
+	jxClient, _, err := o.JXClient()
+	if err != nil {
+		return nil, err
+	}
+	m, envNames, err := kube.GetEnvironments(jxClient, team)
+	if err != nil {
+		return nil, err
+	}
+
+	environments := []*v1.Environment{}
+	if o.AllAutomatic {
+		selector, err := o.environmentSelector()
+		if err != nil {
+			return nil, err
+		}
+		list := []v1.Environment{}
+		for _, env := range m {
+			if selector != nil && !selector.Matches(labels.Set(env.Labels)) {
+				continue
+			}
+			if env.Spec.PromotionStrategy == v1.PromotionStrategyTypeAutomatic && env.Spec.Kind.IsPermanent() {
+				list = append(list, *env)
+			}
+		}
+		list, _, _ = o.skipEnvironments(list)
+		kube.SortEnvironments(list)
+		all := []v1.Environment{}
+		for _, name := range envNames {
+			env := m[name]
+			if selector == nil || selector.Matches(labels.Set(env.Labels)) {
+				all = append(all, *env)
+			}
+		}
+		list, err = o.onlyEnvironments(all, list)
+		if err != nil {
+			return nil, err
+		}
+		list, err = o.orderEnvironments(all, list)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list {
+			environments = append(environments, &list[i])
+		}
+	} else if o.Environment != "" {
+		env, err := kube.FindEnvironmentByNameOrLabel(m, o.Environment)
+		if err != nil {
+			return nil, err
+		}
+		if env == nil {
+			return nil, util.InvalidOption(optionEnvironment, o.Environment, envNames)
+		}
+		environments = append(environments, env)
+	} else if o.Namespace == "" {
+		return nil, util.MissingOption(optionEnvironment)
+	}
+
+	// the plan must not modify the options or the helm repositories so the version is resolved without updating them
+	version, err := o.resolveVersion(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if version == "" {
+		version, err = o.findLatestVersion(o.chartName())
+		if err != nil {
+			return nil, o.staleHelmCacheHint(err)
+		}
+	}
+
+	plan := []PlannedPromotion{}
+	addPromotion := func(envName string, ns string, viaPullRequest bool) {
+		releaseName := o.ReleaseName
+		if releaseName == "" {
+			releaseName = ns + "-" + app
+		}
+		plan = append(plan, PlannedPromotion{
+			Environment:    envName,
+			Namespace:      ns,
+			Version:        version,
+			ReleaseName:    releaseName,
+			ViaPullRequest: viaPullRequest,
+		})
+	}
+	if len(environments) == 0 && o.Namespace != "" {
+		addPromotion("", o.Namespace, false)
+	}
+	for _, env := range environments {
+		ns, err := kube.DiscoverEnvironmentNamespace(kubeClient, env)
+		if err != nil {
+			return nil, err
+		}
+		if ns == "" {
+			return nil, fmt.Errorf("No namespace for environment %s", env.Name)
+		}
+		addPromotion(env.Name, ns, env.Spec.Source.URL != "" && env.Spec.Kind.IsPermanent())
+	}
+	return plan, nil
+}
+
+// automaticPromotionConfirmed returns true if the promotion to the automatic environment should go ahead. The --confirm
+// option takes precedence, then --batch-mode, otherwise the user is asked which fails if there is no terminal
+func (o *PromoteOptions) automaticPromotionConfirmed(env *v1.Environment) (bool, error) {
+	if o.Confirm {
+		o.infoEvent(env, promoteEvent{Event: "promotion-confirmed"}, "Promoting to environment %s as --%s was specified\n", env.Name, optionConfirm)
+		return true, nil
+	}
+	if o.BatchMode {
+		o.infoEvent(env, promoteEvent{Event: "promotion-confirmed"}, "Promoting to environment %s as --batch-mode was specified\n", env.Name)
+		return true, nil
+	}
+	if !stdinIsTerminal() {
+		return false, fmt.Errorf("Cannot ask to confirm the promotion to the automatic environment %s as there is no terminal. Specify --%s or --batch-mode to promote without asking", env.Name, optionConfirm)
+	}
+	return confirmAutomaticPromotion("Do you wish to promote anyway? :")
+}
+
+func (o *PromoteOptions) Promote(ctx context.Context, targetNS string, env *v1.Environment, warnIfAuto bool) (*ReleaseInfo, error) {
+	app := o.Application
+	if ctx.Err() != nil {
+		return nil, &promoteCancelledError{fmt.Sprintf("Cancelled before promoting %s to namespace %s", app, targetNS)}
+	}
+	if app == "" {
+		o.warnEvent(env, promoteEvent{Event: "app-not-found"}, "No application name could be detected so cannot promote via Helm. If the detection of the helm chart name is not working consider adding it with the --%s argument on the 'jx promomote' command\n", optionApplication)
+		return nil, nil
+	}
+	err := o.resolveVersionRange(ctx)
+	if err != nil {
+		return nil, err
+	}
+	o.environmentValues = nil
+	if env != nil {
+		o.environmentValues = o.environmentValuesConfig[env.Name]
+	}
+	version := o.Version
+	info := util.ColorInfo
+	cluster := o.kubeContextDescription()
+	if o.Rollback {
+		o.infoEvent(env, promoteEvent{Event: "rollback-started"}, "Rolling back app %s in namespace %s%s to its previous version\n", info(app), info(targetNS), cluster)
+	} else if version == "" {
+		o.infoEvent(env, promoteEvent{Event: "promote-started"}, "Promoting latest version of app %s to namespace %s%s\n", info(app), info(targetNS), cluster)
+	} else {
+		o.infoEvent(env, promoteEvent{Event: "promote-started"}, "Promoting app %s version %s to namespace %s%s\n", info(app), info(version), info(targetNS), cluster)
+	}
+	fullAppName := o.fullAppName()
+	if o.ChartPath != "" {
+		fullAppName = o.ChartPath
+	}
+	releaseName := o.ReleaseName
+	if releaseName == "" {
+		releaseName = targetNS + "-" + app
+		o.ReleaseName = releaseName
+	}
+	releaseInfo := &ReleaseInfo{
+		ReleaseName:  releaseName,
+		FullAppName:  fullAppName,
+		Version:      version,
+		CanaryWeight: o.CanaryWeight,
+		CommitSHA:    o.CommitSHA,
+		PromotedBy:   o.PromotedBy,
+		StartTime:    time.Now(),
+	}
+	if o.CanaryWeight > 0 {
+		o.infoEvent(env, promoteEvent{Event: "canary-rollout"}, "Rolling out %s as a canary receiving %d%% of the traffic\n", util.ColorInfo(app), o.CanaryWeight)
+	}
+
+	if env != nil && env.Spec.PromotionPolicy.IsRestricted() && !o.Rollback {
+		if version == "" {
+			chart := o.chartName()
+			err := o.retryOnChartNotFound(ctx, chart, func() error {
+				var err error
+				version, err = o.findLatestVersion(chart)
+				return err
+			})
+			if err != nil {
+				return releaseInfo, err
+			}
+			o.infoEvent(env, promoteEvent{Event: "version-resolved", Version: version}, "Resolved the latest version of app %s as %s to check the promotion policy\n", info(app), info(version))
+			o.Version = version
+			releaseInfo.Version = version
+		}
+		err := o.checkPromotionPolicy(env, version)
+		if err != nil {
+			return releaseInfo, err
+		}
+	}
+
+	err = validateEnvironmentSource(env)
+	if err != nil {
+		return releaseInfo, err
+	}
+	if o.ChartPath != "" && env != nil && env.Spec.Source.URL != "" && env.Spec.Kind.IsPermanent() {
+		return releaseInfo, fmt.Errorf("Cannot promote the local chart %s to environment %s as it is promoted via a Pull Request on %s which can only reference charts in helm repositories. Release the chart to a helm repository or use --%s", o.ChartPath, env.Name, env.Spec.Source.URL, optionVersion)
+	}
+
+	if o.DryRun {
+		return releaseInfo, o.logDryRun(ctx, targetNS, env, releaseInfo)
+	}
+
+	if warnIfAuto && env != nil && env.Spec.PromotionStrategy == v1.PromotionStrategyTypeAutomatic {
+		o.warnEvent(env, promoteEvent{Event: "automatic-environment"}, "WARNING: The Environment %s is setup to promote automatically as part of the CI/CD Pipelines.\n\n", env.Name)
+
+		confirmed, err := o.automaticPromotionConfirmed(env)
+		if err != nil {
+			return releaseInfo, err
+		}
+		if !confirmed {
+			releaseInfo.Skipped = true
+			return releaseInfo, nil
+		}
+	}
+	promoteKey := o.createActivityKey(env, releaseInfo)
+	err = o.validateActivityKey(promoteKey)
+	if err != nil {
+		return releaseInfo, err
+	}
+	if releaseInfo.CommitSHA == "" {
+		releaseInfo.CommitSHA = o.activityCommitSHA(promoteKey)
+		if releaseInfo.CommitSHA == "" {
+			o.infoEvent(env, promoteEvent{Event: "commit-sha-not-found"}, "Not recording the git commit of app %s as no --%s was specified and the PipelineActivity has none\n", app, optionCommitSHA)
+		}
+	}
+	err = o.waitForApproval(ctx, env, promoteKey)
+	if err != nil {
+		return releaseInfo, err
+	}
+	if env != nil {
+		source := &env.Spec.Source
+		if source.URL != "" && env.Spec.Kind.IsPermanent() {
+			if o.ValuesFile != "" {
+				o.warnEvent(env, promoteEvent{Event: "values-file-ignored"}, "Ignoring the --%s file %s as environment %s is promoted via a Pull Request\n", optionValues, o.ValuesFile, env.Name)
+			}
+			err := o.PromoteViaPullRequest(ctx, env, releaseInfo)
+			if err == nil && releaseInfo.AlreadyDeployed {
+				return releaseInfo, promoteKey.OnPromoteUpdate(o.Activities, alreadyDeployedPromotionUpdate)
+			}
+			if err == nil {
+				startPromotePR := func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromotePullRequestStep) error {
+					kube.StartPromotionPullRequest(a, s, ps, p)
+					pr := releaseInfo.PullRequestInfo
+					if pr != nil && pr.PullRequest != nil && p.PullRequestURL == "" {
+						p.PullRequestURL = pr.PullRequest.URL
+					}
+					recordPromoteVersions(a, ps, releaseInfo)
+					return nil
+				}
+				err = promoteKey.OnPromotePullRequest(o.Activities, startPromotePR)
+				delay := o.postPullRequestDelay()
+				if o.noWaitReason() == "" && delay > 0 {
+					// lets sleep a little before we try poll for the PR status
+					if sleepContext(ctx, delay) != nil {
+						return releaseInfo, &promoteCancelledError{fmt.Sprintf("Cancelled waiting to poll the status of the Pull Request to promote %s to environment %s", app, env.Name)}
+					}
+				}
+			}
+			return releaseInfo, err
+		}
+	}
+	if o.Rollback {
+		return releaseInfo, fmt.Errorf("Cannot roll back app %s in namespace %s as --%s is only supported for environments with a GitOps source repository", app, targetNS, optionRollback)
+	}
+	if helm.IsDigestVersion(version) {
+		return releaseInfo, fmt.Errorf("Cannot promote app %s version %s to namespace %s as versions pinned by digest are only supported for environments with a GitOps source repository", app, version, targetNS)
+	}
+	err = o.verifyHelmConfigured()
+	if err != nil {
+		return releaseInfo, err
+	}
+
+	// lets do a helm update to ensure we can find the latest version
+	if o.ChartPath == "" {
+		err = o.updateHelmRepos(ctx, env)
+		if err != nil {
+			return releaseInfo, err
+		}
+	}
+
+	releaseInfo.PreviousVersion = o.findDeployedVersion(targetNS, releaseName)
+	releaseInfo.ValuesFile = o.ValuesFile
+	startPromote := func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromoteUpdateStep) error {
+		kube.StartPromotionUpdate(a, s, ps, p)
+		recordPromoteVersions(a, ps, releaseInfo)
+		return nil
+	}
+	promoteKey.OnPromoteUpdate(o.Activities, startPromote)
+
+	if o.ValidateManifests {
+		err = o.validateManifests(fullAppName, releaseName, targetNS, version)
+		if err != nil {
+			promoteKey.OnPromoteUpdate(o.Activities, kube.FailedPromotionUpdate)
+			return releaseInfo, err
+		}
+	}
+
+	notifier := o.createNotifier(env, releaseInfo)
+	err = o.upgradeRelease(ctx, fullAppName, releaseName, targetNS, version)
+	if err == nil && o.NoWait {
+		// leave the promotion in progress as the rollout of the release has not been observed
+		o.infoEvent(env, promoteEvent{Event: "helm-upgrade-not-waiting"}, "Not waiting for the helm upgrade of %s in namespace %s to complete as --%s was specified\n", releaseName, targetNS, optionNoWait)
+		return releaseInfo, nil
+	}
+	if err == nil && o.WaitForReady {
+		err = o.waitForReleaseReady(ctx, targetNS, releaseName)
+		if err != nil {
+			notifier.failure(fmt.Sprintf("Failed to promote %s to namespace %s due to %s", app, targetNS, err))
+			o.notifyCompletion(targetNS, env, releaseInfo, err)
+			promoteKey.OnPromoteUpdate(o.Activities, failedPromotionUpdate(err))
+			return releaseInfo, err
+		}
+	}
+	if err == nil {
+		notifier.success(fmt.Sprintf("Promoted %s to namespace %s", app, targetNS))
+		err = o.commentOnIssues(targetNS, env, promoteKey)
+		if err != nil {
+			if o.RequireIssues {
+				o.notifyCompletion(targetNS, env, releaseInfo, err)
+				promoteKey.OnPromoteUpdate(o.Activities, kube.FailedPromotionUpdate)
+				return releaseInfo, err
+			}
+			o.warnEvent(env, promoteEvent{Event: "issue-comment-failed"}, "Failed to comment on issues for release %s: %s\n", releaseName, err)
+		}
+		o.notifyCompletion(targetNS, env, releaseInfo, nil)
+		err = promoteKey.OnPromoteUpdate(o.Activities, kube.CompletePromotionUpdate)
+	} else {
+		notifier.failure(fmt.Sprintf("Failed to promote %s to namespace %s due to %s", app, targetNS, err))
+		o.notifyCompletion(targetNS, env, releaseInfo, err)
+		err = promoteKey.OnPromoteUpdate(o.Activities, failedPromotionUpdate(err))
+	}
+	return releaseInfo, err
+}
+
+func (o *PromoteOptions) PromoteViaPullRequest(ctx context.Context, env *v1.Environment, releaseInfo *ReleaseInfo) error {
+	version := o.Version
+	versionName := version
+	if versionName == "" {
+		versionName = "latest"
+	}
+	app := o.Application
+
+	branchNameText := "promote-" + app + "-" + versionName
+
+	title := app + " to " + versionName
+	message := fmt.Sprintf("Promote %s to version %s", app, versionName)
+
+	modifyRequirementsFn := o.createModifyRequirementsFn(ctx, version, releaseInfo)
+	if len(o.applications) > 1 {
+		versions := applicationVersions(o.applications)
+		if len(versions) > 1 {
+			versionName = "versions"
+		}
+		branchNameText = "promote-" + strings.Join(applicationNameList(o.applications), "-") + "-" + versionName
+		title = applicationNames(o.applications) + " to " + versionName
+		message = fmt.Sprintf("Promote %s to version %s", applicationNames(o.applications), versionName)
+		if len(versions) > 1 {
+			message = "Promote " + applicationNamesAndVersions(o.applications)
+		}
+		modifyRequirementsFn = o.createModifyApplicationsRequirementsFn(ctx, releaseInfo)
+	}
+	if o.Rollback {
+		branchNameText = "rollback-" + app
+		title = "rollback " + app
+		message = fmt.Sprintf("Roll back %s to its previous version", app)
+		modifyRequirementsFn = o.createRollbackRequirementsFn(env, releaseInfo)
+	}
+	modifyValuesFn := o.createModifyValuesFn()
+	if len(o.applications) > 1 {
+		modifyValuesFn = o.createModifyApplicationsValuesFn()
+	}
+	// lets not create a Pull Request which only sets the versions the environment already has
+	if !o.AllowNoop && !o.Rollback && modifyValuesFn == nil && releaseInfo.PullRequestInfo == nil {
+		modifyRequirementsFn = skipNoopRequirementsFn(modifyRequirementsFn)
+	}
+	promoteKey := o.releasePromoteKey(env, releaseInfo)
+	title, message, err := o.renderPullRequestTemplates(env, promoteKey, versionName, releaseInfo.CommitSHA, title, message)
+	if err != nil {
+		return err
+	}
+	err = o.validateMergeMethod(env)
+	if err != nil {
+		return err
+	}
+	err = o.verifyPullRequestVersions(ctx)
+	if err != nil {
+		return err
+	}
+	existing := releaseInfo.PullRequestInfo
+	if existing == nil {
+		// lets update the Pull Request of a previous attempt at this promotion rather than create a duplicate
+		existing, err = o.findEnvironmentPullRequest(env, branchNameText, o.EnvBranch, title, message)
+		if err != nil {
+			o.warnEvent(env, promoteEvent{Event: "pr-not-found"}, "Failed to find an existing Pull Request for the promotion of %s to %s so creating a new one: %s\n", app, env.Name, err)
+		} else if existing != nil {
+			o.infoEvent(env, promoteEvent{Event: "pr-updating", PRURL: existing.PullRequest.URL}, "Updating the existing Pull Request %s\n", util.ColorInfo(existing.PullRequest.URL))
+		}
+	}
+	info, err := o.createEnvironmentPullRequest(env, modifyRequirementsFn, modifyValuesFn, branchNameText, o.EnvBranch, title, message, existing)
+	if _, noop := err.(*promoteNoopError); noop {
+		deployed := fmt.Sprintf("version %s of app %s", releaseInfo.Version, app)
+		if len(o.applications) > 1 {
+			deployed = "apps " + applicationNamesAndVersions(o.applications)
+		}
+		o.infoEvent(env, promoteEvent{Event: "promote-noop", Version: releaseInfo.Version}, "Environment %s already at %s so not creating a Pull Request. Specify --%s to create it anyway\n",
+			util.ColorInfo(env.Name), util.ColorInfo(deployed), optionAllowNoop)
+		releaseInfo.AlreadyDeployed = true
+		return nil
+	}
+	releaseInfo.PullRequestInfo = info
+	if err == nil && info == nil {
+		o.warnEvent(env, promoteEvent{Event: "pr-no-changes"}, "%s\n", "No changes made to the GitOps Environment source code. Code must be up to date!")
+	}
+	if err == nil && existing == nil && info != nil {
+		o.infoEvent(env, promoteEvent{Event: "pr-created", PRURL: info.PullRequest.URL}, "Created Pull Request: %s\n\n", util.ColorInfo(info.PullRequest.URL))
+		if releaseInfo.Version != "" {
+			versionName = releaseInfo.Version
+		}
+		err = o.labelPullRequest(env, promoteKey, info, versionName)
+		if err != nil {
+			o.warnEvent(env, promoteEvent{Event: "pr-label-failed", PRURL: info.PullRequest.URL}, "Failed to add labels to the Pull Request %s: %s\n", info.PullRequest.URL, err)
+		}
+		err = o.requestPullRequestReviewers(info)
+		if err != nil {
+			o.warnEvent(env, promoteEvent{Event: "pr-review-request-failed", PRURL: info.PullRequest.URL}, "Failed to request the review of the Pull Request %s by %s: %s\n", info.PullRequest.URL, strings.Join(o.PullRequestReviewers, ", "), err)
+		}
+		if o.CommentOnPullRequestOpen {
+			err = o.commentOnPendingPromotedIssues(env.Spec.Namespace, env, info.PullRequest.URL)
+			if err != nil {
+				o.warnEvent(env, promoteEvent{Event: "issue-comment-failed", PRURL: info.PullRequest.URL}, "Failed to comment on the issues promoted by the Pull Request %s: %s\n", info.PullRequest.URL, err)
+			}
+		}
+		return nil
+	}
+	return err
+}
+
+// skipNoopRequirementsFn wraps the function which modifies the environment requirements so that it returns a
+// promoteNoopError if the function does not change the requirements
+func skipNoopRequirementsFn(fn ModifyRequirementsFn) ModifyRequirementsFn {
+	return func(requirements *helm.Requirements) error {
+		before, err := yaml.Marshal(requirements)
+		if err != nil {
+			return err
+		}
+		err = fn(requirements)
+		if err != nil {
+			return err
+		}
+		after, err := yaml.Marshal(requirements)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(before, after) {
+			return &promoteNoopError{}
+		}
+		return nil
+	}
+}
+
+// validateMergeMethod returns an error if the git provider of the environment repository does not support the
+// --merge-method so that the promotion fails before the Pull Request is created rather than when it is merged
+func (o *PromoteOptions) validateMergeMethod(env *v1.Environment) error {
+	if o.MergeMethod == "" || o.NoMergePullRequest {
+		return nil
+	}
+	gitInfo, err := gits.ParseGitURL(env.Spec.Source.URL)
+	if err != nil {
+		return err
+	}
+	gitKind, err := o.GitServerKind(gitInfo)
+	if err != nil {
+		return err
+	}
+	supported := gits.SupportedMergeMethods(gitKind)
+	if util.StringArrayIndex(supported, o.MergeMethod) < 0 {
+		return fmt.Errorf("The --%s %s is not supported by the %s git provider of the environment %s repository %s. Supported methods: %s", optionMergeMethod, o.MergeMethod, gitKind, env.Name, env.Spec.Source.URL, strings.Join(supported, ", "))
+	}
+	return nil
+}
+
+// validatePullRequestTemplates returns an error if the --pr-title-template, --pr-body-template or
+// --issue-comment-template templates do not parse
+func (o *PromoteOptions) validatePullRequestTemplates() error {
+	_, err := parsePullRequestTemplate(optionPullRequestTitle, o.PullRequestTitleTemplate)
+	if err != nil {
+		return err
+	}
+	_, err = parsePullRequestTemplate(optionPullRequestBody, o.PullRequestBodyTemplate)
+	if err != nil {
+		return err
+	}
+	_, err = parsePullRequestTemplate(optionIssueComment, o.IssueCommentTemplate)
+	return err
+}
+
+// validatePullRequestReviewers returns an error if a --pr-reviewer is not a valid user name or 'org/team' name
+func (o *PromoteOptions) validatePullRequestReviewers() error {
+	promoteConfig := &config.PromoteConfig{
+		Reviewers: o.PullRequestReviewers,
+	}
+	problems := promoteConfig.Validate(nil)
+	if len(problems) > 0 {
+		return fmt.Errorf("Invalid --%s: %s", optionPullRequestReviewer, utilerrors.NewAggregate(problems).Error())
+	}
+	return nil
+}
+
+func parsePullRequestTemplate(option string, text string) (*template.Template, error) {
+	tmpl, err := template.New(option).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid --%s template %s: %s", option, text, err)
+	}
+	return tmpl, nil
+}
+
+// renderPullRequestTemplates renders the title and body of the promotion Pull Request from the --pr-title-template
+// and --pr-body-template templates falling back to the given defaults if no template is specified
+func (o *PromoteOptions) renderPullRequestTemplates(env *v1.Environment, promoteKey *kube.PromoteStepActivityKey, version string, commitSHA string, title string, body string) (string, string, error) {
+	if o.PullRequestTitleTemplate == "" && o.PullRequestBodyTemplate == "" {
+		return title, body, nil
+	}
+	data := &PullRequestTemplateData{
+		App:             o.Application,
+		Version:         version,
+		Environment:     env.Name,
+		ReleaseNotesURL: promoteKey.ReleaseNotesURL,
+		CommitSHA:       commitSHA,
+		Reviewers:       o.PullRequestReviewers,
+		GitInfo:         o.GitInfo,
+		url: func() string {
+			url, _, err := o.applicationURL(env)
+			if err != nil {
+				o.warnEvent(env, promoteEvent{Event: "app-url-not-found"}, "Could not find the URL of %s for the Pull Request templates: %s\n", o.Application, err)
+			}
+			return url
+		},
+	}
+	render := func(option string, text string, defaultValue string) (string, error) {
+		if text == "" {
+			return defaultValue, nil
+		}
+		tmpl, err := parsePullRequestTemplate(option, text)
+		if err != nil {
+			return "", err
+		}
+		var buffer bytes.Buffer
+		err = tmpl.Execute(&buffer, data)
+		if err != nil {
+			return "", fmt.Errorf("Failed to render the --%s template %s: %s", option, text, err)
+		}
+		return buffer.String(), nil
+	}
+	title, err := render(optionPullRequestTitle, o.PullRequestTitleTemplate, title)
+	if err != nil {
+		return "", "", err
+	}
+	if strings.TrimSpace(title) == "" {
+		return "", "", fmt.Errorf("The --%s template %s rendered an empty Pull Request title", optionPullRequestTitle, o.PullRequestTitleTemplate)
+	}
+	body, err = render(optionPullRequestBody, o.PullRequestBodyTemplate, body)
+	if err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(title), body, nil
+}
+
+// labelPullRequest adds the --pr-label-template, --pr-label and default labels to the promotion Pull Request
+func (o *PromoteOptions) labelPullRequest(env *v1.Environment, promoteKey *kube.PromoteStepActivityKey, pullRequestInfo *ReleasePullRequestInfo, version string) error {
+	labels, err := o.pullRequestLabels(env, promoteKey, version)
+	if err != nil || len(labels) == 0 {
+		return err
+	}
+	return pullRequestInfo.GitProvider.AddLabelsToPullRequest(pullRequestInfo.PullRequest, labels)
+}
+
+// requestPullRequestReviewers requests the review of the promotion Pull Request by the --pr-reviewer users and teams
+func (o *PromoteOptions) requestPullRequestReviewers(pullRequestInfo *ReleasePullRequestInfo) error {
+	if len(o.PullRequestReviewers) == 0 {
+		return nil
+	}
+	return pullRequestInfo.GitProvider.RequestReviewers(pullRequestInfo.PullRequest, o.PullRequestReviewers)
+}
+
+// pullRequestLabels renders the labels of the promotion Pull Request from the --pr-label-template templates followed
+// by the --pr-label labels and the default labels unless --no-default-pr-labels is specified
+func (o *PromoteOptions) pullRequestLabels(env *v1.Environment, promoteKey *kube.PromoteStepActivityKey, version string) ([]string, error) {
+	data := &PullRequestLabelData{
+		App:         o.Application,
+		Environment: env.Name,
+		Version:     version,
+		Pipeline:    promoteKey.Pipeline,
+		Build:       promoteKey.Build,
+	}
+	labels := []string{}
+	for _, text := range o.PullRequestLabelTemplates {
+		tmpl, err := template.New("label").Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Pull Request label template %s: %s", text, err)
+		}
+		var buffer bytes.Buffer
+		err = tmpl.Execute(&buffer, data)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to render the Pull Request label template %s: %s", text, err)
+		}
+		label := strings.TrimSpace(buffer.String())
+		if label != "" && !strings.HasSuffix(label, ":") && util.StringArrayIndex(labels, label) < 0 {
+			labels = append(labels, label)
+		}
+	}
+	for _, text := range o.PullRequestLabels {
+		label := strings.TrimSpace(text)
+		if label != "" && util.StringArrayIndex(labels, label) < 0 {
+			labels = append(labels, label)
+		}
+	}
+	if !o.NoDefaultPullRequestLabels {
+		for _, label := range defaultPullRequestLabels(env) {
+			if util.StringArrayIndex(labels, label) < 0 {
+				labels = append(labels, label)
+			}
+		}
+	}
+	return labels, nil
+}
+
+// defaultPullRequestLabels returns the labels added to the promotion Pull Request to the environment unless
+// --no-default-pr-labels is specified
+func defaultPullRequestLabels(env *v1.Environment) []string {
+	return []string{environmentPullRequestLabelPrefix + env.Name}
+}
+
+// validateManifests renders the manifests of the chart and validates them before they are applied to the namespace
+func (o *PromoteOptions) validateManifests(fullAppName string, releaseName string, targetNS string, version string) error {
+	tmpDir, err := ioutil.TempDir("", "jx-promote-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	chartsDir := filepath.Join(tmpDir, "charts")
+	outputDir := filepath.Join(tmpDir, "manifests")
+	for _, dir := range []string{chartsDir, outputDir} {
+		err = os.MkdirAll(dir, util.DefaultWritePermissions)
+		if err != nil {
+			return err
+		}
+	}
+
+	chartDir := o.ChartPath
+	if chartDir == "" {
+		var chartVersion *string
+		if version != "" {
+			chartVersion = &version
+		}
+		err = o.Helm().FetchChart(fullAppName, chartVersion, true, chartsDir)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch the chart %s to validate its manifests: %s", fullAppName, err)
+		}
+		chartDir = filepath.Join(chartsDir, o.chartName())
+	}
+	err = o.Helm().Template(chartDir, releaseName, targetNS, outputDir, o.helmSetValues(), o.helmValueFiles())
+	if err != nil {
+		return fmt.Errorf("Failed to render the manifests of chart %s: %s", fullAppName, err)
+	}
+	err = validateRenderedManifests(outputDir)
+	if err != nil {
+		return err
+	}
+
+	validator := o.ManifestValidator
+	if validator == "" {
+		validator = defaultManifestValidator
+	}
+	args := []string{"-strict", "-summary"}
+	if o.KubernetesVersion != "" {
+		args = append(args, "-kubernetes-version", o.KubernetesVersion)
+	}
+	args = append(args, outputDir)
+	output, err := runManifestValidator(validator, args...)
+	if err != nil {
+		return fmt.Errorf("The manifests of chart %s are not valid: %s\n%s", fullAppName, err, output)
+	}
+	o.infoEvent(nil, promoteEvent{Event: "manifests-validated"}, "Validated the manifests of chart %s\n", util.ColorInfo(fullAppName))
+	return nil
+}
+
+// validateRenderedManifests verifies that each YAML document rendered into the directory is a Kubernetes resource
+func validateRenderedManifests(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(path)
+		if info.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(dir, path)
+		for _, document := range strings.Split(string(data), "\n---") {
+			resource := map[string]interface{}{}
+			err = yaml.Unmarshal([]byte(document), &resource)
+			if err != nil {
+				return fmt.Errorf("Invalid YAML in rendered manifest %s: %s", name, err)
+			}
+			if len(resource) == 0 {
+				continue
+			}
+			for _, field := range []string{"apiVersion", "kind"} {
+				if value, ok := resource[field].(string); !ok || value == "" {
+					return fmt.Errorf("The rendered manifest %s is missing the %s field", name, field)
+				}
+			}
+			metadata, _ := resource["metadata"].(map[string]interface{})
+			if value, ok := metadata["name"].(string); !ok || value == "" {
+				return fmt.Errorf("The rendered manifest %s is missing the metadata.name field", name)
+			}
+		}
+		return nil
+	})
+}
+
+// logDryRun logs the promotion which would be performed resolving the latest version if no version is specified
+func (o *PromoteOptions) logDryRun(ctx context.Context, targetNS string, env *v1.Environment, releaseInfo *ReleaseInfo) error {
+	info := util.ColorInfo
+	version := releaseInfo.Version
+	if o.Rollback {
+		version = "previous"
+	} else if version == "" {
+		chart := o.chartName()
+		err := o.retryOnChartNotFound(ctx, chart, func() error {
+			var err error
+			version, err = o.findLatestVersion(chart)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		releaseInfo.Version = version
+	}
+	releaseInfo.PullRequestInfo = nil
+	method := "upgrading the helm release directly"
+	envName := ""
+	if env != nil {
+		envName = env.Name
+		if env.Spec.Source.URL != "" && env.Spec.Kind.IsPermanent() {
+			method = "creating a Pull Request on " + env.Spec.Source.URL
+		}
+	}
+	o.infoEvent(env, promoteEvent{Event: "dry-run"}, "Dry run: would promote %s version %s as release %s to environment %s in namespace %s by %s\n",
+		info(releaseInfo.FullAppName), info(version), info(releaseInfo.ReleaseName), info(envName), info(targetNS), method)
+	return nil
+}
+
+// createModifyRequirementsFn returns the function which updates the environment requirements to the promoted version
+// of the chart; resolving the latest version of the chart if no version is specified. If a releaseInfo is given the
+// promoted version and the version it replaces are recorded on it
+func (o *PromoteOptions) createModifyRequirementsFn(ctx context.Context, version string, releaseInfo *ReleaseInfo) ModifyRequirementsFn {
+	chart := o.chartName()
+	return func(requirements *helm.Requirements) error {
+		var err error
+		if version == "" {
+			err = o.retryOnChartNotFound(ctx, chart, func() error {
+				version, err = o.findLatestVersion(chart)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+		previousVersion := o.findRequirementsVersion(requirements)
+		err = o.checkDowngrade(previousVersion, version)
+		if err != nil {
+			return err
+		}
+		o.setRequirementsVersion(requirements, version)
+		if releaseInfo != nil {
+			releaseInfo.Version = version
+			if releaseInfo.PreviousVersion == "" && previousVersion != version {
+				releaseInfo.PreviousVersion = previousVersion
+			}
+		}
+		return nil
+	}
+}
+
+// checkDowngrade returns an error if the version is lower than the version currently in the environment requirements
+// unless --force is specified. The check is skipped with a warning if either version is not a semantic version
+func (o *PromoteOptions) checkDowngrade(currentVersion string, version string) error {
+	if currentVersion == "" || currentVersion == version {
+		return nil
+	}
+	current, err := semver.Parse(currentVersion)
+	if err != nil {
+		o.warnEvent(nil, promoteEvent{Event: "downgrade-not-checked"}, "Not checking whether version %s of app %s is a downgrade as the current version %s is not a semantic version\n", version, o.Application, currentVersion)
+		return nil
+	}
+	promoted, err := semver.Parse(version)
+	if err != nil {
+		o.warnEvent(nil, promoteEvent{Event: "downgrade-not-checked"}, "Not checking whether version %s of app %s is a downgrade as it is not a semantic version\n", version, o.Application)
+		return nil
+	}
+	if promoted.GTE(current) {
+		return nil
+	}
+	if o.Force {
+		o.warnEvent(nil, promoteEvent{Event: "downgrade"}, "Downgrading app %s from version %s to %s as --%s was specified\n", o.Application, currentVersion, version, optionForce)
+		return nil
+	}
+	return fmt.Errorf("Cannot promote app %s version %s as it is lower than the version %s in the environment. Specify --%s to downgrade or --%s to roll back", o.Application, version, currentVersion, optionForce, optionRollback)
+}
+
+// promoteApplications promotes multiple applications to the environment. The applications are promoted via a single
+// Pull Request for a GitOps environment otherwise (or for a dry run) each application is promoted in turn
+func (o *PromoteOptions) promoteApplications(ctx context.Context, targetNS string, env *v1.Environment) (*ReleaseInfo, error) {
+	if o.DryRun || env.Spec.Source.URL == "" || !env.Spec.Kind.IsPermanent() {
+		var releaseInfo *ReleaseInfo
+		for _, app := range o.applications {
+			appOptions := o.forApplication(app)
+			var err error
+			releaseInfo, err = appOptions.Promote(ctx, targetNS, env, true)
+			if err == nil {
+				err = appOptions.WaitForPromotion(ctx, targetNS, env, releaseInfo)
+			}
+			appOptions.recordResult(targetNS, env, releaseInfo, err)
+			if err != nil {
+				return releaseInfo, err
+			}
+		}
+		return releaseInfo, nil
+	}
+	releaseInfo := &ReleaseInfo{
+		Version:      o.Version,
+		CanaryWeight: o.CanaryWeight,
+		CommitSHA:    o.CommitSHA,
+		PromotedBy:   o.PromotedBy,
+		StartTime:    time.Now(),
+	}
+	o.infoEvent(env, promoteEvent{Event: "promoting-apps"}, "Promoting apps %s to namespace %s via a single Pull Request\n", util.ColorInfo(o.Application), util.ColorInfo(targetNS))
+	err := o.PromoteViaPullRequest(ctx, env, releaseInfo)
+	if err == nil {
+		err = o.WaitForPromotion(ctx, targetNS, env, releaseInfo)
+	}
+	// the release information is shared by the applications so lets record the version of each application
+	appReleaseInfo := *releaseInfo
+	appReleaseInfo.Version = ""
+	for _, app := range o.applications {
+		o.forApplication(app).recordResult(targetNS, env, &appReleaseInfo, err)
+	}
+	return releaseInfo, err
+}
+
+// forApplication returns a copy of the options which promotes the given application
+func (o *PromoteOptions) forApplication(app applicationVersion) *PromoteOptions {
+	answer := *o
+	answer.Application = app.Name
+	answer.Version = app.Version
+	answer.ReleaseName = ""
+	answer.releaseResource = nil
+	answer.applications = nil
+	return &answer
+}
+
+// createModifyApplicationsRequirementsFn returns the function which updates the environment requirements to the
+// promoted versions of all of the applications, resolving the latest versions of the applications without a version
+func (o *PromoteOptions) createModifyApplicationsRequirementsFn(ctx context.Context, releaseInfo *ReleaseInfo) ModifyRequirementsFn {
+	return func(requirements *helm.Requirements) error {
+		for i, app := range o.applications {
+			appOptions := o.forApplication(app)
+			err := appOptions.resolveVersionRange(ctx)
+			if err != nil {
+				return err
+			}
+			appReleaseInfo := &ReleaseInfo{}
+			err = appOptions.createModifyRequirementsFn(ctx, appOptions.Version, appReleaseInfo)(requirements)
+			if err != nil {
+				return err
+			}
+			o.applications[i].Version = appReleaseInfo.Version
+		}
+		versions := applicationVersions(o.applications)
+		if len(versions) == 1 {
+			releaseInfo.Version = versions[0]
+		}
+		return nil
+	}
+}
+
+// createModifyApplicationsValuesFn returns the function which updates the values of all of the applications in the
+// environment or nil if the promotion does not modify any values
+func (o *PromoteOptions) createModifyApplicationsValuesFn() ModifyValuesFn {
+	fns := []ModifyValuesFn{}
+	for _, app := range o.applications {
+		fn := o.forApplication(app).createModifyValuesFn()
+		if fn != nil {
+			fns = append(fns, fn)
+		}
+	}
+	if len(fns) == 0 {
+		return nil
+	}
+	return func(values map[string]interface{}) error {
+		for _, fn := range fns {
+			err := fn(values)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// commentOnPromotedIssues comments on the issues of each of the promoted applications
+func (o *PromoteOptions) commentOnPromotedIssues(ns string, env *v1.Environment, promoteKey *kube.PromoteStepActivityKey) error {
+	if len(o.applications) <= 1 {
+		return o.commentOnIssues(ns, env, promoteKey)
+	}
+	for _, app := range o.applications {
+		err := o.forApplication(app).commentOnIssues(ns, env, promoteKey)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commentOnPendingPromotedIssues comments on the issues of each of the promoted applications that the fix is pending
+// deployment via the promotion Pull Request
+func (o *PromoteOptions) commentOnPendingPromotedIssues(ns string, env *v1.Environment, pullRequestURL string) error {
+	if len(o.applications) <= 1 {
+		return o.commentOnPendingIssues(ns, env, pullRequestURL)
+	}
+	for _, app := range o.applications {
+		err := o.forApplication(app).commentOnPendingIssues(ns, env, pullRequestURL)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseApplicationVersions parses the applications to promote which are either an application name or 'name@version'
+// to promote a different version of each application
+func parseApplicationVersions(args []string, version string) ([]applicationVersion, error) {
+	apps := []applicationVersion{}
+	for _, arg := range args {
+		app := applicationVersion{
+			Name:    arg,
+			Version: version,
+		}
+		// the version may be an OCI reference pinned by digest which also contains '@'
+		idx := strings.Index(arg, "@")
+		if idx >= 0 {
+			app.Name = arg[0:idx]
+			app.Version = arg[idx+1:]
+			if app.Name == "" || app.Version == "" {
+				return nil, fmt.Errorf("Invalid application %s. Use the application name or 'name@version'", arg)
+			}
+			if version != "" && app.Version != version {
+				return nil, fmt.Errorf("Version %s of application %s conflicts with --%s %s. Use either --%s for all of the applications or 'name@version' for each application", app.Version, app.Name, optionVersion, version, optionVersion)
+			}
+		}
+		apps = append(apps, app)
+	}
+	return apps, nil
+}
+
+// validateDigestVersions returns an error if the version or the version of any of the applications is pinned by an
+// invalid digest
+func validateDigestVersions(version string, apps []applicationVersion) error {
+	versions := []string{version}
+	for _, app := range apps {
+		versions = append(versions, app.Version)
+	}
+	for _, v := range versions {
+		if helm.IsDigestVersion(v) {
+			err := helm.ValidateDigestVersion(v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isApplicationPattern returns true if the application name is a glob pattern such as 'payments-*'
+func isApplicationPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// expandApplicationPatterns replaces any application whose name is a glob pattern with the applications of the charts
+// in the helm repositories which match the pattern. Each matching application is promoted at the version of the pattern
+func (o *PromoteOptions) expandApplicationPatterns(ctx context.Context, apps []applicationVersion) ([]applicationVersion, error) {
+	// applications named explicitly are not added again by a pattern so that their version takes precedence
+	names := []string{}
+	for _, app := range apps {
+		if !isApplicationPattern(app.Name) {
+			names = append(names, app.Name)
+		}
+	}
+	expanded := []applicationVersion{}
+	for _, app := range apps {
+		if !isApplicationPattern(app.Name) {
+			expanded = append(expanded, app)
+			continue
+		}
+		charts, err := o.findChartsMatching(ctx, app.Name)
+		if err != nil {
+			return nil, err
+		}
+		if len(charts) == 0 {
+			return nil, fmt.Errorf("The application pattern %s does not match any charts in the helm repositories", app.Name)
+		}
+		o.infoEvent(nil, promoteEvent{Event: "application-pattern"}, "Application pattern %s matches %s\n", util.ColorInfo(app.Name), util.ColorInfo(strings.Join(charts, ", ")))
+		for _, chart := range charts {
+			if util.StringArrayIndex(names, chart) < 0 {
+				names = append(names, chart)
+				expanded = append(expanded, applicationVersion{Name: chart, Version: app.Version})
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// findChartsMatching returns the sorted names of the charts in the helm repository which match the glob pattern
+func (o *PromoteOptions) findChartsMatching(ctx context.Context, pattern string) ([]string, error) {
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid application pattern %s: %s", pattern, err)
+	}
+	err = o.updateHelmRepos(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// lets search for the literal prefix of the pattern and match the pattern against the results
+	filter := pattern[0:strings.IndexAny(pattern, "*?[")]
+	if o.LocalHelmRepoName != "" {
+		filter = o.LocalHelmRepoName + "/" + filter
+	}
+	results, err := o.Helm().SearchCharts(filter)
+	if err != nil {
+		return nil, err
+	}
+	charts := []string{}
+	for _, result := range results {
+		name := result
+		if o.LocalHelmRepoName != "" {
+			if !strings.HasPrefix(result, o.LocalHelmRepoName+"/") {
+				continue
+			}
+			name = strings.TrimPrefix(result, o.LocalHelmRepoName+"/")
+		}
+		matched, err := path.Match(pattern, name)
+		if err == nil && matched && util.StringArrayIndex(charts, name) < 0 {
+			charts = append(charts, name)
+		}
+	}
+	sort.Strings(charts)
+	return charts, nil
+}
+
+func applicationNameList(apps []applicationVersion) []string {
+	names := []string{}
+	for _, app := range apps {
+		names = append(names, app.Name)
+	}
+	return names
+}
+
+// applicationNames returns the comma separated names of the applications
+func applicationNames(apps []applicationVersion) string {
+	return strings.Join(applicationNameList(apps), ", ")
+}
+
+// applicationNamesAndVersions returns the comma separated names and versions of the applications
+func applicationNamesAndVersions(apps []applicationVersion) string {
+	texts := []string{}
+	for _, app := range apps {
+		version := app.Version
+		if version == "" {
+			version = "latest"
+		}
+		texts = append(texts, app.Name+" to version "+version)
+	}
+	return strings.Join(texts, ", ")
+}
+
+// applicationVersions returns the distinct versions of the applications
+func applicationVersions(apps []applicationVersion) []string {
+	versions := []string{}
+	for _, app := range apps {
+		if util.StringArrayIndex(versions, app.Version) < 0 {
+			versions = append(versions, app.Version)
+		}
+	}
+	return versions
+}
+
+// createRollbackRequirementsFn returns the function which updates the environment requirements to the version of the
+// app promoted before the version currently in the requirements
+func (o *PromoteOptions) createRollbackRequirementsFn(env *v1.Environment, releaseInfo *ReleaseInfo) ModifyRequirementsFn {
+	return func(requirements *helm.Requirements) error {
+		currentVersion := o.findRequirementsVersion(requirements)
+		if currentVersion == "" {
+			return fmt.Errorf("Cannot roll back %s as it is not deployed in environment %s", o.Application, env.Name)
+		}
+		version, err := o.findRollbackVersion(env, currentVersion)
+		if err != nil {
+			return err
+		}
+		o.infoEvent(env, promoteEvent{Event: "rollback"}, "Rolling back %s in environment %s from version %s to version %s\n", util.ColorInfo(o.Application), util.ColorInfo(env.Name), util.ColorInfo(currentVersion), util.ColorInfo(version))
+		o.setRequirementsVersion(requirements, version)
+		releaseInfo.Version = version
+		releaseInfo.PreviousVersion = currentVersion
+		return nil
+	}
+}
+
+// findRollbackVersion returns the version of the app which was successfully promoted to the environment before the
+// most recent promotion of the current version based on the history in the PipelineActivity resources
+func (o *PromoteOptions) findRollbackVersion(env *v1.Environment, currentVersion string) (string, error) {
+	app := o.Application
+	if o.Activities == nil {
+		return "", fmt.Errorf("Cannot roll back %s as there is no PipelineActivity history to find its previous version", app)
+	}
+	list, err := o.Activities.List(metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	promotions := []promotionRecord{}
+	for _, a := range list.Items {
+		if !activityMatchesApp(&a, app) {
+			continue
+		}
+		for _, step := range a.Spec.Steps {
+			ps := step.Promote
+			if ps == nil || ps.Environment != env.Name || ps.Status != v1.ActivityStatusTypeSucceeded {
+				continue
+			}
+			version := ps.Version
+			if version == "" {
+				version = a.Spec.Version
+			}
+			if version == "" {
+				continue
+			}
+			promotions = append(promotions, promotionRecord{Version: version, Timestamp: promoteStepTimestamp(&a, ps)})
+		}
+	}
+	sort.Slice(promotions, func(i, j int) bool {
+		return promotions[i].Timestamp.After(promotions[j].Timestamp)
+	})
+	current := -1
+	for i, p := range promotions {
+		if p.Version == currentVersion {
+			current = i
+			break
+		}
+	}
+	if current < 0 {
+		return "", fmt.Errorf("Cannot roll back %s as the promotion of its current version %s to environment %s is not recorded in the PipelineActivity history", app, currentVersion, env.Name)
+	}
+	for _, p := range promotions[current+1:] {
+		if p.Version != currentVersion {
+			return p.Version, nil
+		}
+	}
+	return "", fmt.Errorf("Cannot roll back %s as no version was promoted to environment %s before the current version %s", app, env.Name, currentVersion)
+}
+
+// promotionRecord is a successful promotion of a version found in the PipelineActivity history
+type promotionRecord struct {
+	Version   string
+	Timestamp time.Time
+}
+
+// activityMatchesApp returns true if the PipelineActivity is for a pipeline of the given app
+func activityMatchesApp(a *v1.PipelineActivity, app string) bool {
+	if a.Spec.GitRepository != "" {
+		return a.Spec.GitRepository == app
+	}
+	paths := strings.Split(a.Spec.Pipeline, "/")
+	return len(paths) > 1 && paths[1] == app
+}
+
+// activityApplication returns the name of the app of the pipeline of the PipelineActivity
+func activityApplication(a *v1.PipelineActivity) string {
+	if a.Spec.GitRepository != "" {
+		return a.Spec.GitRepository
+	}
+	paths := strings.Split(a.Spec.Pipeline, "/")
+	if len(paths) > 1 {
+		return paths[1]
+	}
+	return a.Spec.Pipeline
+}
+
+// promoteStepTimestamp returns when the promote step completed, or started if it has not completed yet
+func promoteStepTimestamp(a *v1.PipelineActivity, ps *v1.PromoteActivityStep) time.Time {
+	if ps.CompletedTimestamp != nil {
+		return ps.CompletedTimestamp.Time
 	}
-	team, _, err := kube.GetDevNamespace(kubeClient, currentNs)
+	if ps.StartedTimestamp != nil {
+		return ps.StartedTimestamp.Time
+	}
+	return a.CreationTimestamp.Time
+}
+
+// findRequirementsVersion returns the version of the app in the environment requirements
+func (o *PromoteOptions) findRequirementsVersion(requirements *helm.Requirements) string {
+	app := o.Application
+	chart := o.chartName()
+	if chart == app {
+		return requirements.FindAppVersion(app)
+	}
+	return requirements.FindAliasedAppVersion(chart, app)
+}
+
+// setRequirementsVersion sets the version of the app in the environment requirements
+func (o *PromoteOptions) setRequirementsVersion(requirements *helm.Requirements, version string) {
+	app := o.Application
+	chart := o.chartName()
+	if chart == app {
+		requirements.SetAppVersion(app, version, o.HelmRepositoryURL)
+	} else {
+		requirements.SetAliasedAppVersion(chart, app, version, o.HelmRepositoryURL)
+	}
+}
+
+// waitForReleaseReady waits for the Deployments and StatefulSets of the release to have all of their replicas ready
+// or for the --timeout to elapse
+func (o *PromoteOptions) waitForReleaseReady(ctx context.Context, ns string, releaseName string) error {
+	kubeClient, err := o.targetKubeClient()
 	if err != nil {
 		return err
 	}
-	jxClient, _, err := o.JXClient()
+	var end time.Time
+	if o.TimeoutDuration != nil {
+		end = time.Now().Add(*o.TimeoutDuration)
+	}
+	logged := map[string]bool{}
+	for {
+		notReady, found, err := kube.GetReleaseWorkloadsNotReady(kubeClient, ns, releaseName)
+		if err != nil {
+			return err
+		}
+		if !found {
+			o.warnEvent(nil, promoteEvent{Event: "release-not-waiting"}, "No Deployments or StatefulSets found for release %s in namespace %s so not waiting for them to be ready\n", releaseName, ns)
+			return nil
+		}
+		if len(notReady) == 0 {
+			o.infoEvent(nil, promoteEvent{Event: "release-ready"}, "Release %s is ready in namespace %s\n", util.ColorInfo(releaseName), util.ColorInfo(ns))
+			return nil
+		}
+		for _, message := range notReady {
+			if !logged[message] {
+				logged[message] = true
+				o.infoEvent(nil, promoteEvent{Event: "release-not-ready"}, "Waiting for release %s to be ready: %s\n", util.ColorInfo(releaseName), message)
+			}
+		}
+		if !end.IsZero() && time.Now().After(end) {
+			return fmt.Errorf("Timed out waiting for release %s to be ready in namespace %s after %s: %s", releaseName, ns, o.TimeoutDuration.String(), strings.Join(notReady, ", "))
+		}
+		if sleepContext(ctx, waitForReadyPollTime) != nil {
+			return &promoteCancelledError{fmt.Sprintf("Cancelled waiting for release %s to be ready in namespace %s", releaseName, ns)}
+		}
+	}
+}
+
+// findDeployedVersion returns the version of the release currently running in the given namespace or an empty string
+// if it cannot be found
+func (o *PromoteOptions) findDeployedVersion(ns string, releaseName string) string {
+	kubeClient, err := o.targetKubeClient()
 	if err != nil {
-		return err
+		return ""
 	}
-	envs, err := jxClient.JenkinsV1().Environments(team).List(metav1.ListOptions{})
+	deployments, err := kubeClient.AppsV1beta1().Deployments(ns).List(metav1.ListOptions{
+		LabelSelector: "release=" + releaseName,
+	})
+	if err == nil {
+		for _, d := range deployments.Items {
+			version := kube.GetVersion(&d.ObjectMeta)
+			if version != "" {
+				return version
+			}
+		}
+	}
+	deployment, err := kubeClient.AppsV1beta1().Deployments(ns).Get(releaseName, metav1.GetOptions{})
 	if err != nil {
-		log.Warnf("No Environments found: %s/n", err)
-		return nil
+		return ""
 	}
-	environments := envs.Items
-	if len(environments) == 0 {
-		log.Warnf("No Environments have been created yet in team %s. Please create some via 'jx create env'\n", team)
-		return nil
+	return kube.GetVersion(&deployment.ObjectMeta)
+}
+
+// recordPromoteVersions records the promoted version, the version it replaces, whether it is a canary, the values
+// file passed to helm and the source commit on the promote step
+func recordPromoteVersions(a *v1.PipelineActivity, ps *v1.PromoteActivityStep, releaseInfo *ReleaseInfo) {
+	version := releaseInfo.Version
+	if version != "" {
+		ps.Version = version
+		if a.Spec.Version == "" {
+			a.Spec.Version = version
+		}
 	}
-	kube.SortEnvironments(environments)
+	if releaseInfo.PreviousVersion != "" && ps.PreviousVersion == "" {
+		ps.PreviousVersion = releaseInfo.PreviousVersion
+	}
+	if releaseInfo.CanaryWeight > 0 {
+		ps.Canary = true
+		ps.CanaryWeight = releaseInfo.CanaryWeight
+	}
+	if releaseInfo.ValuesFile != "" {
+		ps.ValuesFile = releaseInfo.ValuesFile
+	}
+	if releaseInfo.CommitSHA != "" {
+		ps.CommitSHA = releaseInfo.CommitSHA
+	}
+	if releaseInfo.PromotedBy != "" {
+		ps.PromotedBy = releaseInfo.PromotedBy
+	}
+}
 
-	for _, env := range environments {
-		kind := env.Spec.Kind
-		if env.Spec.PromotionStrategy == v1.PromotionStrategyTypeAutomatic && kind.IsPermanent() {
-			ns := env.Spec.Namespace
-			if ns == "" {
-				return fmt.Errorf("No namespace for environment %s", env.Name)
+// createModifyValuesFn returns the function which updates the app values in the environment or nil if the promotion
+// does not modify any values
+func (o *PromoteOptions) createModifyValuesFn() ModifyValuesFn {
+	envValues := o.environmentValues
+	if !o.ForceRollout && o.CanaryWeight <= 0 && !o.CanaryPromote && len(o.SetValues) == 0 && envValues == nil {
+		return nil
+	}
+	app := o.Application
+	nonce := rolloutNonce()
+	return func(values map[string]interface{}) error {
+		if envValues != nil {
+			if len(envValues.values) > 0 {
+				appValues, ok := values[app].(map[string]interface{})
+				if !ok {
+					appValues = map[string]interface{}{}
+					values[app] = appValues
+				}
+				helm.MergeValues(appValues, envValues.values)
 			}
-			releaseInfo, err := o.Promote(ns, &env, false)
-			if err != nil {
-				return err
+			for _, text := range envValues.Set {
+				key, value, err := parseSetValue(text)
+				if err != nil {
+					return err
+				}
+				helm.SetValue(values, app+"."+key, value)
 			}
-			err = o.WaitForPromotion(ns, &env, releaseInfo)
+		}
+		if o.ForceRollout {
+			helm.SetValue(values, app+"."+forceRolloutValue, nonce)
+		}
+		if o.CanaryWeight > 0 {
+			helm.SetValue(values, app+"."+canaryEnabledValue, true)
+			helm.SetValue(values, app+"."+canaryWeightValue, o.CanaryWeight)
+		} else if o.CanaryPromote {
+			helm.SetValue(values, app+"."+canaryEnabledValue, false)
+			helm.SetValue(values, app+"."+canaryWeightValue, 100)
+		}
+		for _, text := range o.SetValues {
+			key, value, err := parseSetValue(text)
 			if err != nil {
 				return err
 			}
+			helm.SetValue(values, app+"."+key, value)
 		}
+		return nil
 	}
-	return nil
 }
 
-func (o *PromoteOptions) Promote(targetNS string, env *v1.Environment, warnIfAuto bool) (*ReleaseInfo, error) {
-	app := o.Application
-	if app == "" {
-		log.Warnf("No application name could be detected so cannot promote via Helm. If the detection of the helm chart name is not working consider adding it with the --%s argument on the 'jx promomote' command\n", optionApplication)
-		return nil, nil
+// helmSetValues returns the values to pass to the helm upgrade when promoting directly via helm
+func (o *PromoteOptions) helmSetValues() []string {
+	values := []string{}
+	if o.ForceRollout {
+		values = append(values, forceRolloutValue+"="+rolloutNonce())
 	}
-	version := o.Version
-	info := util.ColorInfo
-	if version == "" {
-		log.Infof("Promoting latest version of app %s to namespace %s\n", info(app), info(targetNS))
-	} else {
-		log.Infof("Promoting app %s version %s to namespace %s\n", info(app), info(version), info(targetNS))
+	if o.CanaryWeight > 0 {
+		values = append(values, canaryEnabledValue+"=true", canaryWeightValue+"="+strconv.Itoa(o.CanaryWeight))
+	} else if o.CanaryPromote {
+		values = append(values, canaryEnabledValue+"=false", canaryWeightValue+"=100")
 	}
-	fullAppName := app
-	if o.LocalHelmRepoName != "" {
-		fullAppName = o.LocalHelmRepoName + "/" + app
+	if o.environmentValues != nil {
+		values = append(values, o.environmentValues.Set...)
 	}
-	releaseName := o.ReleaseName
-	if releaseName == "" {
-		releaseName = targetNS + "-" + app
-		o.ReleaseName = releaseName
+	return append(values, o.SetValues...)
+}
+
+// helmValueFiles returns the values files to pass to the helm upgrade when promoting directly via helm
+func (o *PromoteOptions) helmValueFiles() []string {
+	var files []string
+	if o.environmentValues != nil && o.environmentValues.ValuesFile != "" {
+		files = append(files, o.environmentValues.ValuesFile)
 	}
-	releaseInfo := &ReleaseInfo{
-		ReleaseName: releaseName,
-		FullAppName: fullAppName,
-		Version:     version,
+	if o.ValuesFile != "" {
+		files = append(files, o.ValuesFile)
 	}
+	return files
+}
 
-	if warnIfAuto && env != nil && env.Spec.PromotionStrategy == v1.PromotionStrategyTypeAutomatic {
-		log.Infof("%s", util.ColorWarning(fmt.Sprintf("WARNING: The Environment %s is setup to promote automatically as part of the CI/CD Pipelines.\n\n", env.Name)))
+// validateValuesFile checks that the --values file can be read and resolves it to an absolute path so that it can be
+// recorded on the PipelineActivity
+func (o *PromoteOptions) validateValuesFile() error {
+	if o.ValuesFile == "" {
+		return nil
+	}
+	fileName, err := filepath.Abs(o.ValuesFile)
+	if err != nil {
+		return err
+	}
+	exists, err := util.FileExists(fileName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("The --%s file %s does not exist", optionValues, fileName)
+	}
+	_, err = ioutil.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("Failed to read the --%s file %s: %s", optionValues, fileName, err)
+	}
+	o.ValuesFile = fileName
+	return nil
+}
 
-		confirm := &survey.Confirm{
-			Message: "Do you wish to promote anyway? :",
-			Default: false,
+// loadEnvironmentValuesConfig loads the chart value overrides of each environment from the --env-values-config file.
+// The values files are resolved relative to the directory of the config file
+func (o *PromoteOptions) loadEnvironmentValuesConfig() error {
+	o.environmentValuesConfig = nil
+	if o.EnvironmentValuesConfig == "" {
+		return nil
+	}
+	fileName := o.EnvironmentValuesConfig
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("Failed to load the --%s file %s due to %s", optionEnvValuesConfig, fileName, err)
+	}
+	config := map[string]*PromoteEnvironmentValues{}
+	err = yaml.Unmarshal(data, &config)
+	if err != nil {
+		return fmt.Errorf("Failed to unmarshal the --%s file %s due to %s", optionEnvValuesConfig, fileName, err)
+	}
+	dir := filepath.Dir(fileName)
+	for envName, envValues := range config {
+		if envValues == nil {
+			delete(config, envName)
+			continue
 		}
-		flag := false
-		err := survey.AskOne(confirm, &flag, nil)
+		for _, text := range envValues.Set {
+			_, _, err := parseSetValue(text)
+			if err != nil {
+				return fmt.Errorf("Invalid value of environment %s in the --%s file %s: %s", envName, optionEnvValuesConfig, fileName, err)
+			}
+		}
+		if envValues.ValuesFile == "" {
+			continue
+		}
+		valuesFile := envValues.ValuesFile
+		if !filepath.IsAbs(valuesFile) {
+			valuesFile = filepath.Join(dir, valuesFile)
+		}
+		valuesFile, err = filepath.Abs(valuesFile)
 		if err != nil {
-			return releaseInfo, err
+			return err
 		}
-		if !flag {
-			return releaseInfo, nil
+		exists, err := util.FileExists(valuesFile)
+		if err != nil {
+			return err
 		}
-	}
-	promoteKey := o.createPromoteKey(env)
-	if env != nil {
-		source := &env.Spec.Source
-		if source.URL != "" && env.Spec.Kind.IsPermanent() {
-			err := o.PromoteViaPullRequest(env, releaseInfo)
-			if err == nil {
-				startPromotePR := func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromotePullRequestStep) error {
-					kube.StartPromotionPullRequest(a, s, ps, p)
-					pr := releaseInfo.PullRequestInfo
-					if pr != nil && pr.PullRequest != nil && p.PullRequestURL == "" {
-						p.PullRequestURL = pr.PullRequest.URL
-					}
-					if version != "" && a.Spec.Version == "" {
-						a.Spec.Version = version
-					}
-					return nil
-				}
-				err = promoteKey.OnPromotePullRequest(o.Activities, startPromotePR)
-				// lets sleep a little before we try poll for the PR status
-				time.Sleep(waitAfterPullRequestCreated)
-			}
-			return releaseInfo, err
+		if !exists {
+			return fmt.Errorf("The values file %s of environment %s in the --%s file %s does not exist", valuesFile, envName, optionEnvValuesConfig, fileName)
+		}
+		envValues.ValuesFile = valuesFile
+		envValues.values, err = helm.LoadValuesFile(valuesFile)
+		if err != nil {
+			return fmt.Errorf("Failed to load the values file %s of environment %s in the --%s file %s due to %s", valuesFile, envName, optionEnvValuesConfig, fileName, err)
 		}
 	}
-	err := o.verifyHelmConfigured()
+	o.environmentValuesConfig = config
+	return nil
+}
+
+// parseSetValue parses a 'key=value' chart value override into the dotted path of the value and the value converting
+// booleans and integers in the same way as 'helm --set'
+func parseSetValue(text string) (string, interface{}, error) {
+	idx := strings.Index(text, "=")
+	if idx <= 0 {
+		return "", nil, fmt.Errorf("Invalid --%s value '%s'. Expected 'key=value'", optionSet, text)
+	}
+	key := text[0:idx]
+	value := text[idx+1:]
+	if strings.HasPrefix(key, ".") || strings.HasSuffix(key, ".") || strings.Contains(key, "..") {
+		return "", nil, fmt.Errorf("Invalid --%s key '%s' in '%s'", optionSet, key, text)
+	}
+	switch value {
+	case "true":
+		return key, true, nil
+	case "false":
+		return key, false, nil
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return key, i, nil
+	}
+	return key, value, nil
+}
+
+// chartName returns the name of the helm chart to promote which defaults to the application name
+func (o *PromoteOptions) chartName() string {
+	if o.ChartName != "" {
+		return o.ChartName
+	}
+	return o.Application
+}
+
+// normalizeHelmRepoName trims the whitespace and any slashes around the --helm-repo-name returning an error if it is
+// not a name which helm can use as the prefix of a chart name
+func normalizeHelmRepoName(name string) (string, error) {
+	answer := strings.Trim(strings.TrimSpace(name), "/")
+	if answer == "" {
+		return "", nil
+	}
+	if !helmRepoNameRegex.MatchString(answer) {
+		return "", fmt.Errorf("Invalid --%s '%s': a helm repository name must start with a letter or digit and only contain letters, digits, '.', '_' or '-'", optionHelmRepoName, name)
+	}
+	return answer, nil
+}
+
+// validateEnvironmentSource returns an error if the permanent environment is configured to be promoted via a git
+// source repository but does not have a usable source URL so that it is not quietly promoted via helm instead
+func validateEnvironmentSource(env *v1.Environment) error {
+	if env == nil || !env.Spec.Kind.IsPermanent() {
+		return nil
+	}
+	source := &env.Spec.Source
+	if source.URL == "" && source.Kind != v1.EnvironmentRepositoryTypeGit && source.Ref == "" {
+		return nil
+	}
+	gitURL := strings.TrimSpace(source.URL)
+	if gitURL == "" {
+		return fmt.Errorf("Environment %s is configured to be promoted via git but has no source URL. Please configure the git repository of the environment via: jx edit env %s --git-url <url>", env.Name, env.Name)
+	}
+	_, err := gits.ParseGitURL(gitURL)
 	if err != nil {
-		return releaseInfo, err
+		return fmt.Errorf("Environment %s has an invalid source URL %s: %s. Please configure the git repository of the environment via: jx edit env %s --git-url <url>", env.Name, source.URL, err, env.Name)
 	}
+	return nil
+}
 
-	// lets do a helm update to ensure we can find the latest version
-	if !o.NoHelmUpdate {
-		log.Info("Updating the helm repositories to ensure we can find the latest versions...")
-		err = o.Helm().UpdateRepo()
-		if err != nil {
-			return releaseInfo, err
-		}
+// resolveChartPath validates the --chart-path and uses the name and version in its Chart.yaml as the application and
+// version being promoted
+func (o *PromoteOptions) resolveChartPath() error {
+	if o.ChartPath == "" {
+		return nil
+	}
+	if o.AllAutomatic || o.Rollback || len(o.Args) > 1 {
+		return fmt.Errorf("Cannot specify --%s with --all-auto, --%s or when promoting multiple applications", optionChartPath, optionRollback)
+	}
+	if o.VersionFromGitTag || o.FromPreview != "" {
+		return fmt.Errorf("Cannot specify --%s with --%s or --%s as the version is the version of the chart", optionChartPath, optionVersionFromGitTag, optionFromPreview)
+	}
+	chartPath := strings.TrimSpace(o.ChartPath)
+	chartFile := filepath.Join(chartPath, "Chart.yaml")
+	exists, err := util.FileExists(chartFile)
+	if err != nil {
+		return err
 	}
+	if !exists {
+		return fmt.Errorf("The --%s %s is not the directory of a chart as it has no Chart.yaml", optionChartPath, o.ChartPath)
+	}
+	name, version, err := helm.LoadChartNameAndVersion(chartFile)
+	if err != nil {
+		return fmt.Errorf("Failed to load %s: %s", chartFile, err)
+	}
+	if o.Version != "" && o.Version != version {
+		return fmt.Errorf("The --%s %s does not match the version %s of the chart in --%s %s", optionVersion, o.Version, version, optionChartPath, o.ChartPath)
+	}
+	if o.Application == "" && len(o.Args) == 0 {
+		o.Application = name
+	}
+	o.ChartPath = chartPath
+	o.Version = version
+	o.infoEvent(nil, promoteEvent{Event: "local-chart"}, "Promoting the local chart %s version %s\n", util.ColorInfo(chartPath), util.ColorInfo(version))
+	return nil
+}
 
-	startPromote := func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromoteUpdateStep) error {
-		kube.StartPromotionUpdate(a, s, ps, p)
-		if version != "" && a.Spec.Version == "" {
-			a.Spec.Version = version
-		}
+// validateReleaseNotesURL returns an error if the --release-notes-url is specified but is not an absolute http or https URL
+func validateReleaseNotesURL(notesURL string) error {
+	if notesURL == "" {
 		return nil
 	}
-	promoteKey.OnPromoteUpdate(o.Activities, startPromote)
+	u, err := url.Parse(notesURL)
+	if err != nil {
+		return fmt.Errorf("Invalid --%s %s: %s", optionReleaseNotesURL, notesURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Invalid --%s %s: expected an absolute http or https URL such as https://github.com/myorg/myapp/releases/tag/v1.2.3", optionReleaseNotesURL, notesURL)
+	}
+	return nil
+}
 
-	err = o.Helm().UpgradeChart(fullAppName, releaseName, targetNS, &version, true, nil, false, true, nil, nil)
-	if err == nil {
-		err = o.commentOnIssues(targetNS, env, promoteKey)
-		if err != nil {
-			log.Warnf("Failed to comment on issues for release %s: %s\n", releaseName, err)
-		}
-		err = promoteKey.OnPromoteUpdate(o.Activities, kube.CompletePromotionUpdate)
+// validateEnvironmentRepo validates the --environment-repo and checks that there are git credentials for its server
+// so that the promotion fails before anything is changed
+func (o *PromoteOptions) validateEnvironmentRepo() error {
+	if o.EnvironmentRepo == "" {
+		return nil
+	}
+	if o.AllAutomatic {
+		return fmt.Errorf("Cannot specify --%s with --all-auto", optionEnvironmentRepo)
+	}
+	gitURL := strings.TrimSpace(o.EnvironmentRepo)
+	gitInfo, err := gits.ParseGitURL(gitURL)
+	if err != nil {
+		return fmt.Errorf("Invalid --%s %s: %s", optionEnvironmentRepo, o.EnvironmentRepo, err)
+	}
+	if gitInfo.Organisation == "" || gitInfo.Name == "" {
+		return fmt.Errorf("Invalid --%s %s: expected the URL of a git repository such as https://github.com/myorg/environment-staging.git", optionEnvironmentRepo, o.EnvironmentRepo)
+	}
+	authConfigSvc, err := o.CreateGitAuthConfigService()
+	if err != nil {
+		return err
+	}
+	serverURL := gitInfo.HostURL()
+	if len(authConfigSvc.Config().FindUserAuths(serverURL)) == 0 {
+		return fmt.Errorf("No git credentials found for the server %s of --%s %s. Please add them via: jx create git token -n <name> %s", serverURL, optionEnvironmentRepo, gitURL, serverURL)
+	}
+	o.EnvironmentRepo = gitURL
+	return nil
+}
+
+// overrideEnvironmentRepo returns a copy of the environment which is promoted via a Pull Request on the
+// --environment-repo rather than the source repository of the environment
+func (o *PromoteOptions) overrideEnvironmentRepo(env *v1.Environment) (*v1.Environment, error) {
+	if o.EnvironmentRepo == "" {
+		return env, nil
+	}
+	if env == nil || !env.Spec.Kind.IsPermanent() {
+		return env, fmt.Errorf("The --%s can only be specified when promoting to a permanent environment with --%s", optionEnvironmentRepo, optionEnvironment)
+	}
+	answer := env.DeepCopy()
+	answer.Spec.Source.URL = o.EnvironmentRepo
+	if env.Spec.Source.URL == "" {
+		o.infoEvent(env, promoteEvent{Event: "environment-repo-override"}, "Promoting to environment %s via a Pull Request on %s\n", env.Name, util.ColorInfo(o.EnvironmentRepo))
 	} else {
-		err = promoteKey.OnPromoteUpdate(o.Activities, kube.FailedPromotionUpdate)
+		o.infoEvent(env, promoteEvent{Event: "environment-repo-override"}, "Promoting to environment %s via a Pull Request on %s rather than %s\n", env.Name, util.ColorInfo(o.EnvironmentRepo), util.ColorInfo(env.Spec.Source.URL))
 	}
-	return releaseInfo, err
+	return answer, nil
 }
 
-func (o *PromoteOptions) PromoteViaPullRequest(env *v1.Environment, releaseInfo *ReleaseInfo) error {
-	version := o.Version
-	versionName := version
-	if versionName == "" {
-		versionName = "latest"
+// resolveCommitSHA validates the --commit-sha. The commit is not defaulted from the current directory as it may not be
+// the source of the promoted version, see activityCommitSHA
+func (o *PromoteOptions) resolveCommitSHA() error {
+	if o.CommitSHA == "" {
+		return nil
 	}
-	app := o.Application
+	sha := strings.ToLower(strings.TrimSpace(o.CommitSHA))
+	if !commitSHARegex.MatchString(sha) {
+		return fmt.Errorf("Invalid --%s '%s': expected between 7 and 64 hexadecimal characters", optionCommitSHA, o.CommitSHA)
+	}
+	o.CommitSHA = sha
+	return nil
+}
 
-	branchNameText := "promote-" + app + "-" + versionName
+// activityCommitSHA returns the git commit recorded by an earlier promotion in the PipelineActivity of the pipeline
+// which built the version or an empty string if there is none
+func (o *PromoteOptions) activityCommitSHA(promoteKey *kube.PromoteStepActivityKey) string {
+	if !promoteKey.IsValid() || o.Activities == nil {
+		return ""
+	}
+	activity, err := o.Activities.Get(promoteKey.Name, metav1.GetOptions{})
+	if err != nil || activity == nil {
+		return ""
+	}
+	for _, step := range activity.Spec.Steps {
+		if step.Promote != nil && step.Promote.CommitSHA != "" {
+			return step.Promote.CommitSHA
+		}
+	}
+	return ""
+}
 
-	title := app + " to " + versionName
-	message := fmt.Sprintf("Promote %s to version %s", app, versionName)
+// resolvePromotedBy defaults the --promoted-by to the git user email falling back to $USER
+func (o *PromoteOptions) resolvePromotedBy() {
+	o.PromotedBy = strings.TrimSpace(o.PromotedBy)
+	if o.PromotedBy != "" {
+		return
+	}
+	email, err := o.Git().Email("")
+	if err == nil && strings.TrimSpace(email) != "" {
+		o.PromotedBy = strings.TrimSpace(email)
+		return
+	}
+	o.PromotedBy = os.Getenv("USER")
+}
 
-	modifyRequirementsFn := func(requirements *helm.Requirements) error {
-		var err error
-		if version == "" {
-			version, err = o.findLatestVersion(app)
-			if err != nil {
-				return err
-			}
+// fullAppName returns the name of the chart of the application prefixed by the --helm-repo-name if there is one
+func (o *PromoteOptions) fullAppName() string {
+	chart := o.chartName()
+	if o.LocalHelmRepoName == "" {
+		return chart
+	}
+	return o.LocalHelmRepoName + "/" + chart
+}
+
+// useKubeContext validates the --kube-context and configures helm to use the cluster of the context
+func (o *PromoteOptions) useKubeContext() error {
+	if o.KubeContext == "" {
+		return nil
+	}
+	_, err := o.targetKubeClient()
+	if err != nil {
+		return err
+	}
+	o.Helm().SetKubeContext(o.KubeContext)
+	o.infoEvent(nil, promoteEvent{Event: "kube-context"}, "Promoting to the cluster of kube context %s\n", util.ColorInfo(o.KubeContext))
+	return nil
+}
+
+// targetKubeClient returns the kubernetes client of the cluster of the --kube-context or of the current cluster if no
+// context is specified
+func (o *PromoteOptions) targetKubeClient() (kubernetes.Interface, error) {
+	if o.KubeContext == "" {
+		kubeClient, _, err := o.KubeClient()
+		return kubeClient, err
+	}
+	if o.contextKubeClient == nil {
+		kubeClient, err := createKubeContextClient(o.KubeContext)
+		if err != nil {
+			return nil, err
 		}
-		requirements.SetAppVersion(app, version, o.HelmRepositoryURL)
-		return nil
+		o.contextKubeClient = kubeClient
 	}
-	info, err := o.createEnvironmentPullRequest(env, modifyRequirementsFn, branchNameText, title, message, releaseInfo.PullRequestInfo)
-	releaseInfo.PullRequestInfo = info
-	return err
+	return o.contextKubeClient, nil
+}
+
+// kubeContextDescription returns the description of the --kube-context appended to log messages or an empty string if
+// the current cluster is used
+func (o *PromoteOptions) kubeContextDescription() string {
+	if o.KubeContext == "" {
+		return ""
+	}
+	return " in the cluster of kube context " + util.ColorInfo(o.KubeContext)
 }
 
 func (o *PromoteOptions) GetTargetNamespace(ns string, env string) (string, *v1.Environment, error) {
@@ -429,24 +3585,53 @@ func (o *PromoteOptions) GetTargetNamespace(ns string, env string) (string, *v1.
 
 	var envResource *v1.Environment
 	targetNS := currentNs
-	if env != "" {
-		envResource = m[env]
+	if o.NamespaceSelector != "" {
+		if env != "" || ns != "" {
+			return "", nil, fmt.Errorf("Cannot specify --%s with --namespace or --%s", optionNamespaceSelector, optionEnvironment)
+		}
+		_, err = labels.Parse(o.NamespaceSelector)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid --%s %s: %s", optionNamespaceSelector, o.NamespaceSelector, err)
+		}
+		targetKubeClient, err := o.targetKubeClient()
+		if err != nil {
+			return "", nil, err
+		}
+		targetNS, err = kube.FindNamespaceBySelector(targetKubeClient, o.NamespaceSelector, "--"+optionNamespaceSelector)
+		if err != nil {
+			return "", nil, err
+		}
+		o.infoEvent(nil, promoteEvent{Event: "namespace-selected"}, "Using namespace %s matching --%s %s\n", util.ColorInfo(targetNS), optionNamespaceSelector, util.ColorInfo(o.NamespaceSelector))
+	} else if env != "" {
+		envResource, err = kube.FindEnvironmentByNameOrLabel(m, env)
+		if err != nil {
+			return "", nil, err
+		}
 		if envResource == nil {
 			return "", nil, util.InvalidOption(optionEnvironment, env, envNames)
 		}
-		targetNS = envResource.Spec.Namespace
+		targetNS, err = kube.DiscoverEnvironmentNamespace(kubeClient, envResource)
+		if err != nil {
+			return "", nil, err
+		}
 		if targetNS == "" {
-			return "", nil, fmt.Errorf("Environment %s does not have a namspace associated with it!", env)
+			return "", nil, fmt.Errorf("Environment %s does not have a namspace associated with it!", envResource.Name)
 		}
 	} else if ns != "" {
 		targetNS = ns
 	}
 
-	labels := map[string]string{}
-	annotations := map[string]string{}
-	err = kube.EnsureNamespaceCreated(kubeClient, targetNS, labels, annotations)
-	if err != nil {
-		return "", nil, err
+	if !o.DryRun {
+		targetKubeClient, err := o.targetKubeClient()
+		if err != nil {
+			return "", nil, err
+		}
+		labels := map[string]string{}
+		annotations := map[string]string{}
+		err = kube.EnsureNamespaceCreated(targetKubeClient, targetNS, labels, annotations)
+		if err != nil {
+			return "", nil, err
+		}
 	}
 	return targetNS, envResource, nil
 }
@@ -476,224 +3661,688 @@ func (o *PromoteOptions) DiscoverAppName() (string, error) {
 	return answer, nil
 }
 
-func (o *PromoteOptions) WaitForPromotion(ns string, env *v1.Environment, releaseInfo *ReleaseInfo) error {
-	if o.TimeoutDuration == nil {
-		log.Infof("No --%s option specified on the 'jx promote' command so not waiting for the promotion to succeed\n", optionTimeout)
+// noWaitReason returns why the promotion is not waited for or an empty string if it is waited for
+func (o *PromoteOptions) noWaitReason() string {
+	switch {
+	case o.NoWait:
+		return fmt.Sprintf("--%s was specified", optionNoWait)
+	case o.TimeoutDuration == nil:
+		return fmt.Sprintf("no --%s was specified", optionTimeout)
+	case o.PullRequestPollDuration == nil:
+		return fmt.Sprintf("no --%s was specified", optionPullRequestPollTime)
+	}
+	return ""
+}
+
+func (o *PromoteOptions) WaitForPromotion(ctx context.Context, ns string, env *v1.Environment, releaseInfo *ReleaseInfo) error {
+	pullRequestInfo := releaseInfo.PullRequestInfo
+	if pullRequestInfo == nil {
 		return nil
 	}
-	if o.PullRequestPollDuration == nil {
-		log.Infof("No --%s option specified on the 'jx promote' command so not waiting for the promotion to succeed\n", optionPullRequestPollTime)
+	reason := o.noWaitReason()
+	if reason != "" {
+		if pullRequestInfo.PullRequest != nil {
+			o.infoEvent(env, promoteEvent{Event: "not-waiting", PRURL: pullRequestInfo.PullRequest.URL}, "Not waiting for the promotion Pull Request %s to complete as %s\n", pullRequestInfo.PullRequest.URL, reason)
+		} else {
+			o.infoEvent(env, promoteEvent{Event: "not-waiting"}, "Not waiting for the promotion to complete as %s\n", reason)
+		}
 		return nil
 	}
 	duration := *o.TimeoutDuration
 	end := time.Now().Add(duration)
 
-	pullRequestInfo := releaseInfo.PullRequestInfo
-	if pullRequestInfo != nil {
-		promoteKey := o.createPromoteKey(env)
+	promoteKey := o.createActivityKey(env, releaseInfo)
+	notifier := o.createNotifier(env, releaseInfo)
 
-		err := o.waitForGitOpsPullRequest(ns, env, releaseInfo, end, duration, promoteKey)
-		if err != nil {
-			// TODO based on if the PR completed or not fail the PR or the Promote?
-			promoteKey.OnPromotePullRequest(o.Activities, kube.FailedPromotionPullRequest)
+	err := o.waitForGitOpsPullRequest(ctx, ns, env, releaseInfo, end, duration, promoteKey, notifier)
+	o.notifyCompletion(ns, env, releaseInfo, err)
+	if err != nil {
+		notifier.failure(err.Error())
+		pullRequestInfo = releaseInfo.PullRequestInfo
+		merged := pullRequestInfo != nil && pullRequestMerged(pullRequestInfo.PullRequest)
+		if _, timedOut := err.(*pullRequestTimeoutError); timedOut && !merged && pullRequestInfo != nil && pullRequestInfo.PullRequest != nil {
+			return o.onPullRequestTimeout(pullRequestInfo, promoteKey, err)
+		}
+		if _, cancelled := err.(*promoteCancelledError); cancelled {
+			o.warnEvent(env, promoteEvent{Event: "cancelled"}, "%s\n", err)
+			if merged {
+				promoteKey.OnPromoteUpdate(o.Activities, cancelledPromotionUpdate)
+			} else {
+				promoteKey.OnPromotePullRequest(o.Activities, cancelledPromotionPullRequest)
+			}
 			return err
 		}
+		// once the Pull Request has merged it is the update of the environment which failed
+		if merged {
+			promoteKey.OnPromoteUpdate(o.Activities, kube.FailedPromotionUpdate)
+		} else {
+			promoteKey.OnPromotePullRequest(o.Activities, kube.FailedPromotionPullRequest)
+		}
+		return err
 	}
 	return nil
 }
 
-func (o *PromoteOptions) waitForGitOpsPullRequest(ns string, env *v1.Environment, releaseInfo *ReleaseInfo, end time.Time, duration time.Duration, promoteKey *kube.PromoteStepActivityKey) error {
-	pullRequestInfo := releaseInfo.PullRequestInfo
-	logMergeFailure := false
-	logNoMergeCommitSha := false
-	logHasMergeSha := false
-	logMergeStatusError := false
-	logNoMergeStatuses := false
-	urlStatusMap := map[string]string{}
-	urlStatusTargetURLMap := map[string]string{}
-
-	if pullRequestInfo != nil {
-		for {
-			pr := pullRequestInfo.PullRequest
-			gitProvider := pullRequestInfo.GitProvider
-			err := gitProvider.UpdatePullRequestStatus(pr)
-			if err != nil {
-				return fmt.Errorf("Failed to query the Pull Request status for %s %s", pr.URL, err)
+// cancelledPromotionPullRequest marks the Pull Request step of a cancelled promotion as failed
+func cancelledPromotionPullRequest(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromotePullRequestStep) error {
+	kube.FailedPromotionPullRequest(a, s, ps, p)
+	p.Description = "cancelled"
+	return nil
+}
+
+// failedPromotionUpdate returns the function to mark the update step of a promotion which failed with the given error
+func failedPromotionUpdate(err error) kube.PromoteUpdateFn {
+	if isPromoteCancelled(err) {
+		return cancelledPromotionUpdate
+	}
+	return kube.FailedPromotionUpdate
+}
+
+// cancelledPromotionUpdate marks the update step of a cancelled promotion as failed
+func cancelledPromotionUpdate(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromoteUpdateStep) error {
+	kube.FailedPromotionUpdate(a, s, ps, p)
+	p.Description = "cancelled"
+	return nil
+}
+
+// alreadyDeployedPromotionUpdate marks the update step of a promotion to an environment which already has the
+// promoted version as succeeded
+func alreadyDeployedPromotionUpdate(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromoteUpdateStep) error {
+	kube.CompletePromotionUpdate(a, s, ps, p)
+	p.Description = "already deployed"
+	return nil
+}
+
+// sleepContext waits for the given duration returning the error of the context if it is cancelled first
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// signalContext returns a context which is cancelled when the process is interrupted or terminated so that the
+// promotion can record the cancellation before exiting. A second signal terminates the process straight away
+func (o *PromoteOptions) signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			o.warnEvent(nil, promoteEvent{Event: "cancelling"}, "Received %s so cancelling the promotion\n", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
+}
+
+// PromoteCompletionEvent is the JSON payload posted to the --completion-webhook when a promotion succeeds or fails
+type PromoteCompletionEvent struct {
+	App            string `json:"app"`
+	Version        string `json:"version"`
+	Environment    string `json:"env"`
+	Namespace      string `json:"namespace"`
+	Status         string `json:"status"`
+	PullRequestURL string `json:"prURL,omitempty"`
+	MergeSHA       string `json:"mergeSHA,omitempty"`
+	// Duration is the number of seconds the promotion took
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// notifyCompletion posts the completion of the promotion to the --completion-webhook if one is specified. Failures
+// to post are only logged so that they do not fail the promotion
+func (o *PromoteOptions) notifyCompletion(ns string, env *v1.Environment, releaseInfo *ReleaseInfo, promoteErr error) {
+	if o.CompletionWebhookURL == "" {
+		return
+	}
+	event := o.createCompletionEvent(ns, env, releaseInfo, promoteErr)
+	err := postCompletionWebhook(o.CompletionWebhookURL, o.CompletionWebhookSecret, event)
+	if err != nil {
+		o.warnEvent(env, promoteEvent{Event: "completion-webhook-failed"}, "%s\n", err)
+	}
+}
+
+// createCompletionEvent creates the payload describing the completed promotion
+func (o *PromoteOptions) createCompletionEvent(ns string, env *v1.Environment, releaseInfo *ReleaseInfo, promoteErr error) *PromoteCompletionEvent {
+	event := &PromoteCompletionEvent{
+		App:       o.Application,
+		Version:   o.Version,
+		Namespace: ns,
+		Status:    string(v1.ActivityStatusTypeSucceeded),
+	}
+	if env != nil {
+		event.Environment = env.Name
+	}
+	if promoteErr != nil {
+		event.Status = string(v1.ActivityStatusTypeFailed)
+		event.Error = promoteErr.Error()
+	}
+	if releaseInfo != nil {
+		if releaseInfo.Version != "" {
+			event.Version = releaseInfo.Version
+		}
+		if !releaseInfo.StartTime.IsZero() {
+			event.Duration = time.Since(releaseInfo.StartTime).Seconds()
+		}
+		if releaseInfo.PullRequestInfo != nil && releaseInfo.PullRequestInfo.PullRequest != nil {
+			pr := releaseInfo.PullRequestInfo.PullRequest
+			event.PullRequestURL = pr.URL
+			if pr.MergeCommitSHA != nil {
+				event.MergeSHA = *pr.MergeCommitSHA
 			}
+		}
+	}
+	return event
+}
 
-			if pr.Merged != nil && *pr.Merged {
-				if pr.MergeCommitSHA == nil {
-					if !logNoMergeCommitSha {
-						logNoMergeCommitSha = true
-						log.Infof("Pull Request %s is merged but waiting for Merge SHA\n", util.ColorInfo(pr.URL))
-					}
-				} else {
-					mergeSha := *pr.MergeCommitSHA
-					if !logHasMergeSha {
-						logHasMergeSha = true
-						log.Infof("Pull Request %s is merged at sha %s\n", util.ColorInfo(pr.URL), util.ColorInfo(mergeSha))
-
-						mergedPR := func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromotePullRequestStep) error {
-							kube.CompletePromotionPullRequest(a, s, ps, p)
-							p.MergeCommitSHA = mergeSha
-							return nil
-						}
-						promoteKey.OnPromotePullRequest(o.Activities, mergedPR)
-					}
+// postCompletionWebhook posts the completion event to the webhook signing it with the secret if one is specified
+func postCompletionWebhook(webhookURL string, secret string, event *PromoteCompletionEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("Failed to create the request to the completion webhook due to %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(completionWebhookSignatureHeader, completionWebhookSignature(secret, data))
+	}
+	client := http.Client{
+		Timeout: completionWebhookTimeout,
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Failed to post to the completion webhook due to %s", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("Failed to post to the completion webhook due to response %d: %s", res.StatusCode, string(body))
+	}
+	return nil
+}
 
-					promoteKey.OnPromoteUpdate(o.Activities, kube.StartPromotionUpdate)
-
-					statuses, err := gitProvider.ListCommitStatus(pr.Owner, pr.Repo, mergeSha)
-					if err != nil {
-						if !logMergeStatusError {
-							logMergeStatusError = true
-							log.Warnf("Failed to query merge status of repo %s/%s with merge sha %s due to: %s\n", pr.Owner, pr.Repo, mergeSha, err)
-						}
-					} else {
-						if len(statuses) == 0 {
-							if !logNoMergeStatuses {
-								logNoMergeStatuses = true
-								log.Infof("Merge commit has not yet any statuses on repo %s/%s merge sha %s\n", pr.Owner, pr.Repo, mergeSha)
-							}
-						} else {
-							for _, status := range statuses {
-								if status.IsFailed() {
-									log.Warnf("merge status: %s URL: %s description: %s\n",
-										status.State, status.TargetURL, status.Description)
-									return fmt.Errorf("Status: %s URL: %s description: %s\n",
-										status.State, status.TargetURL, status.Description)
-								}
-								url := status.URL
-								state := status.State
-								if urlStatusMap[url] == "" || urlStatusMap[url] != gitStatusSuccess {
-									if urlStatusMap[url] != state {
-										urlStatusMap[url] = state
-										urlStatusTargetURLMap[url] = status.TargetURL
-										log.Infof("merge status: %s for URL %s with target: %s description: %s\n",
-											util.ColorInfo(state), util.ColorInfo(status.URL), util.ColorInfo(status.TargetURL), util.ColorInfo(status.Description))
-									}
-								}
-							}
-							prStatuses := []v1.GitStatus{}
-							keys := util.SortedMapKeys(urlStatusMap)
-							for _, url := range keys {
-								state := urlStatusMap[url]
-								targetURL := urlStatusTargetURLMap[url]
-								if targetURL == "" {
-									targetURL = url
-								}
-								prStatuses = append(prStatuses, v1.GitStatus{
-									URL:    targetURL,
-									Status: state,
-								})
-							}
-							updateStatuses := func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromoteUpdateStep) error {
-								p.Statuses = prStatuses
-								return nil
-							}
-							promoteKey.OnPromoteUpdate(o.Activities, updateStatuses)
-
-							succeeded := true
-							for _, v := range urlStatusMap {
-								if v != gitStatusSuccess {
-									succeeded = false
-								}
-							}
-							if succeeded {
-								log.Infoln("Merge status checks all passed so the promotion worked!")
-								err = o.commentOnIssues(ns, env, promoteKey)
-								if err == nil {
-									err = promoteKey.OnPromoteUpdate(o.Activities, kube.CompletePromotionUpdate)
-								}
-								return err
-							}
-						}
-					}
-				}
-			} else {
-				if pr.IsClosed() {
-					log.Warnf("Pull Request %s is closed\n", util.ColorInfo(pr.URL))
-					return fmt.Errorf("Promotion failed as Pull Request %s is closed without merging", pr.URL)
+// pushMetrics pushes the duration and outcome of the promotion to the --metrics-pushgateway. Failures to push are only
+// logged so that they do not fail the promotion
+func (o *PromoteOptions) pushMetrics(duration time.Duration, promoteErr error) {
+	app := o.Application
+	if len(o.applications) > 1 {
+		app = strings.Join(applicationNameList(o.applications), ",")
+	}
+	env := o.Environment
+	if env == "" && o.AllAutomatic {
+		env = "all-auto"
+	} else if env == "" && o.Manifest != "" {
+		env = optionManifest
+	} else if env == "" {
+		env = o.Namespace
+	}
+	if app == "" {
+		app = "unknown"
+	}
+	if env == "" {
+		env = "unknown"
+	}
+	status := "succeeded"
+	if promoteErr != nil {
+		status = "failed"
+	}
+	err := pushPromoteMetrics(o.MetricsPushgatewayURL, app, env, buildPromoteMetrics(app, env, status, duration))
+	if err != nil {
+		o.warnEvent(nil, promoteEvent{Event: "metrics-push-failed", Env: env}, "%s\n", err)
+	}
+}
+
+// buildPromoteMetrics returns the promotion metrics in the Prometheus text exposition format
+func buildPromoteMetrics(app string, env string, status string, duration time.Duration) string {
+	labels := fmt.Sprintf(`app="%s",env="%s",status="%s"`, escapeMetricLabel(app), escapeMetricLabel(env), escapeMetricLabel(status))
+	var buffer bytes.Buffer
+	buffer.WriteString("# HELP jx_promote_duration_seconds The number of seconds the promotion took\n")
+	buffer.WriteString("# TYPE jx_promote_duration_seconds gauge\n")
+	buffer.WriteString(fmt.Sprintf("jx_promote_duration_seconds{%s} %s\n", labels, strconv.FormatFloat(duration.Seconds(), 'f', -1, 64)))
+	buffer.WriteString("# HELP jx_promote_total The number of promotions\n")
+	buffer.WriteString("# TYPE jx_promote_total counter\n")
+	buffer.WriteString(fmt.Sprintf("jx_promote_total{%s} 1\n", labels))
+	return buffer.String()
+}
+
+// escapeMetricLabel escapes the value of a label in the Prometheus text exposition format
+func escapeMetricLabel(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	return strings.Replace(value, "\n", `\n`, -1)
+}
+
+// pushPromoteMetrics pushes the metrics to the Pushgateway grouped by the app and environment
+func pushPromoteMetrics(gatewayURL string, app string, env string, metrics string) error {
+	pushURL := strings.TrimSuffix(gatewayURL, "/") + "/metrics/job/jx_promote/app/" + url.PathEscape(app) + "/env/" + url.PathEscape(env)
+	client := http.Client{
+		Timeout: metricsPushTimeout,
+	}
+	res, err := client.Post(pushURL, "text/plain; version=0.0.4", strings.NewReader(metrics))
+	if err != nil {
+		return fmt.Errorf("Failed to push the promotion metrics to %s due to %s", gatewayURL, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("Failed to push the promotion metrics to %s due to response %d: %s", gatewayURL, res.StatusCode, string(body))
+	}
+	return nil
+}
+
+// completionWebhookSignature returns the HMAC SHA256 signature of the payload in the same format as GitHub webhooks
+func completionWebhookSignature(secret string, data []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(data)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// createNotifier creates the notifier for the promotion to the given environment
+func (o *PromoteOptions) createNotifier(env *v1.Environment, releaseInfo *ReleaseInfo) *promoteNotifier {
+	notify := o.Notify
+	if o.SlackWebhookURL != "" {
+		notifySlack := o.createSlackNotifyFn(releaseInfo)
+		notify = notifySlack
+		if o.Notify != nil {
+			notify = func(env *v1.Environment, kind PromoteNotificationKind, message string) error {
+				err := o.Notify(env, kind, message)
+				if err != nil {
+					o.warnEvent(env, promoteEvent{Event: "notification-failed"}, "Failed to send the promotion %s notification: %s\n", kind, err)
 				}
+				return notifySlack(env, kind, message)
+			}
+		}
+	}
+	return &promoteNotifier{
+		options:          o,
+		notify:           notify,
+		env:              env,
+		firstFailureOnly: o.NotifyOnFirstFailureOnly,
+	}
+}
+
+// slackWebhookMessage is the message posted to a Slack incoming webhook
+type slackWebhookMessage struct {
+	Attachments []slack.Attachment `json:"attachments"`
+}
+
+// createSlackNotifyFn creates the function which posts the promotion notifications to the Slack incoming webhook
+func (o *PromoteOptions) createSlackNotifyFn(releaseInfo *ReleaseInfo) PromoteNotifyFn {
+	return func(env *v1.Environment, kind PromoteNotificationKind, message string) error {
+		return postSlackWebhook(o.SlackWebhookURL, o.createSlackMessage(env, releaseInfo, kind, message))
+	}
+}
+
+// createSlackMessage creates the Slack message describing the promotion of the application to the environment
+func (o *PromoteOptions) createSlackMessage(env *v1.Environment, releaseInfo *ReleaseInfo, kind PromoteNotificationKind, message string) *slackWebhookMessage {
+	version := o.Version
+	prURL := ""
+	if releaseInfo != nil {
+		if releaseInfo.Version != "" {
+			version = releaseInfo.Version
+		}
+		if releaseInfo.PullRequestInfo != nil && releaseInfo.PullRequestInfo.PullRequest != nil {
+			prURL = releaseInfo.PullRequestInfo.PullRequest.URL
+		}
+	}
+	if version == "" {
+		version = "latest"
+	}
+	envName := ""
+	if env != nil {
+		envName = env.Name
+	}
+	color := "good"
+	title := fmt.Sprintf("Promoted %s %s to %s", o.Application, version, envName)
+	if kind == PromoteNotificationFailure {
+		color = "danger"
+		title = fmt.Sprintf("Failed to promote %s %s to %s", o.Application, version, envName)
+	}
+	fields := []slack.AttachmentField{
+		{
+			Title: "Application",
+			Value: o.Application,
+			Short: true,
+		},
+		{
+			Title: "Version",
+			Value: version,
+			Short: true,
+		},
+		{
+			Title: "Environment",
+			Value: envName,
+			Short: true,
+		},
+	}
+	if prURL != "" {
+		fields = append(fields, slack.AttachmentField{
+			Title: "Pull Request",
+			Value: prURL,
+			Short: true,
+		})
+	}
+	return &slackWebhookMessage{
+		Attachments: []slack.Attachment{
+			{
+				Color:     color,
+				Fallback:  title,
+				Title:     title,
+				TitleLink: prURL,
+				Text:      message,
+				Fields:    fields,
+			},
+		},
+	}
+}
+
+// postSlackWebhook posts the message to the Slack incoming webhook
+func postSlackWebhook(webhookURL string, message *slackWebhookMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	client := http.Client{
+		Timeout: slackWebhookTimeout,
+	}
+	res, err := client.Post(webhookURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("Failed to post to the Slack webhook due to %s", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("Failed to post to the Slack webhook due to response %d: %s", res.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (o *PromoteOptions) findLatestVersion(app string) (string, error) {
+	versions, err := o.Helm().SearchChartVersions(app)
+	if err != nil {
+		return "", err
+	}
+	versionRange := o.versionRange()
+	if versionRange != nil && len(versions) > 0 {
+		return findLatestVersionInRange(app, o.Version, versionRange, versions, o.ExcludePrereleases)
+	}
+
+	var maxSemVer *semver.Version
+	maxString := ""
+	excluded := false
+	for _, version := range versions {
+		sv, err := semver.Parse(version)
+		if err != nil {
+			o.warnEvent(nil, promoteEvent{Event: "invalid-version"}, "Invalid semantic version: %s %s\n", version, err)
+			if maxString == "" || strings.Compare(version, maxString) > 0 {
+				maxString = version
+			}
+		} else if o.ExcludePrereleases && len(sv.Pre) > 0 {
+			excluded = true
+			continue
+		} else {
+			if maxSemVer == nil || maxSemVer.Compare(sv) < 0 {
+				maxSemVer = &sv
+			}
+		}
+	}
+
+	if maxSemVer != nil {
+		return maxSemVer.String(), nil
+	}
+	if maxString == "" {
+		if excluded {
+			// the chart was found so updating the helm repositories and retrying would not help
+			return "", fmt.Errorf("no non-prerelease version of %s found as --exclude-prereleases was specified. Available versions: %s", app, strings.Join(versions, ", "))
+		}
+		return "", &chartNotFoundError{chart: app}
+	}
+	return maxString, nil
+}
+
+// findVersionFromGitTag returns the version of the highest semantic version git tag of the current directory
+func (o *PromoteOptions) findVersionFromGitTag() (string, error) {
+	tags, err := o.Git().Tags("")
+	if err != nil {
+		return "", fmt.Errorf("Failed to list the git tags of the current directory: %s", err)
+	}
+	version, tag := findLatestSemVerTag(tags, o.ExcludePrereleases)
+	if version == "" {
+		return "", fmt.Errorf("No semantic version git tag such as 1.2.3 or v1.2.3 found in the current directory for --%s", optionVersionFromGitTag)
+	}
+	o.infoEvent(nil, promoteEvent{Event: "version-resolved"}, "Promoting version %s of git tag %s\n", util.ColorInfo(version), util.ColorInfo(tag))
+	return version, nil
+}
+
+// findVersionFromPreview returns the version of the application deployed in the preview environment found from the
+// release metadata in the namespace of the preview environment
+func (o *PromoteOptions) findVersionFromPreview(previewName string) (string, error) {
+	kubeClient, currentNs, err := o.KubeClient()
+	if err != nil {
+		return "", err
+	}
+	team, _, err := kube.GetDevNamespace(kubeClient, currentNs)
+	if err != nil {
+		return "", err
+	}
+	jxClient, _, err := o.JXClient()
+	if err != nil {
+		return "", err
+	}
+	envs, _, err := kube.GetEnvironments(jxClient, team)
+	if err != nil {
+		return "", err
+	}
+	previewNames := []string{}
+	for name, env := range envs {
+		if kube.IsPreviewEnvironment(env) {
+			previewNames = append(previewNames, name)
+		}
+	}
+	sort.Strings(previewNames)
+	env := envs[previewName]
+	if !kube.IsPreviewEnvironment(env) {
+		return "", util.InvalidOption(optionFromPreview, previewName, previewNames)
+	}
+	ns := env.Spec.Namespace
+	if ns == "" {
+		return "", fmt.Errorf("The preview environment %s has no namespace", previewName)
+	}
+	release, err := findPreviewRelease(jxClient.JenkinsV1().Releases(ns), o.Application)
+	if err != nil {
+		return "", err
+	}
+	if release == nil || release.Spec.Version == "" {
+		return "", fmt.Errorf("No release of application %s found in namespace %s of preview environment %s", o.Application, ns, previewName)
+	}
+	o.infoEvent(nil, promoteEvent{Event: "version-resolved"}, "Promoting version %s of application %s deployed in preview environment %s\n", util.ColorInfo(release.Spec.Version), util.ColorInfo(o.Application), util.ColorInfo(previewName))
+	return release.Spec.Version, nil
+}
+
+// findPreviewRelease returns the most recently created release of the application or nil if there is none
+func findPreviewRelease(releases typev1.ReleaseInterface, app string) (*v1.Release, error) {
+	list, err := releases.List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	prefix := kube.ToValidNameWithDots(app) + "-"
+	var answer *v1.Release
+	for i := range list.Items {
+		release := &list.Items[i]
+		if release.Spec.Name != app && (release.Spec.Name != "" || !strings.HasPrefix(release.Name, prefix)) {
+			continue
+		}
+		if answer == nil || answer.CreationTimestamp.Before(&release.CreationTimestamp) {
+			answer = release
+		}
+	}
+	return answer, nil
+}
+
+// findLatestSemVerTag returns the highest semantic version of the git tags without any 'v' prefix along with its tag
+// or blank strings if no tag is a semantic version
+func findLatestSemVerTag(tags []string, excludePrereleases bool) (string, string) {
+	var maxSemVer *semver.Version
+	maxTag := ""
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		sv, err := semver.Parse(strings.TrimPrefix(tag, "v"))
+		if err != nil || (excludePrereleases && len(sv.Pre) > 0) {
+			continue
+		}
+		if maxSemVer == nil || maxSemVer.Compare(sv) < 0 {
+			maxSemVer = &sv
+			maxTag = tag
+		}
+	}
+	if maxSemVer == nil {
+		return "", ""
+	}
+	return maxSemVer.String(), maxTag
+}
+
+// findLatestVersionInRange returns the highest of the given chart versions which satisfies the version range
+func findLatestVersionInRange(app string, rangeText string, versionRange *msemver.Constraints, versions []string, excludePrereleases bool) (string, error) {
+	var maxVersion *msemver.Version
+	for _, version := range versions {
+		sv, err := msemver.NewVersion(version)
+		if err != nil || (excludePrereleases && sv.Prerelease() != "") {
+			continue
+		}
+		if versionRange.Check(sv) && (maxVersion == nil || maxVersion.LessThan(sv)) {
+			maxVersion = sv
+		}
+	}
+	if maxVersion == nil {
+		return "", fmt.Errorf("no version of chart %s satisfies the version range %s. Available versions: %s", app, rangeText, strings.Join(versions, ", "))
+	}
+	return maxVersion.Original(), nil
+}
+
+// versionRange returns the semantic version range given as the version to promote or nil if no version or an exact
+// version is specified
+func (o *PromoteOptions) versionRange() *msemver.Constraints {
+	version := o.Version
+	if version == "" {
+		return nil
+	}
+	if helm.IsDigestVersion(version) {
+		return nil
+	}
+	_, err := semver.Parse(version)
+	if err == nil {
+		return nil
+	}
+	versionRange, err := msemver.NewConstraint(version)
+	if err != nil {
+		return nil
+	}
+	return versionRange
+}
 
-				// lets try merge if the status is good
-				status, err := gitProvider.PullRequestLastCommitStatus(pr)
+// verifyPullRequestVersions checks that the versions written to the environment by the promotion Pull Request are
+// available in the helm repositories so that the Pull Request can deploy them
+func (o *PromoteOptions) verifyPullRequestVersions(ctx context.Context) error {
+	if o.Rollback {
+		return nil
+	}
+	if len(o.applications) > 1 {
+		for _, app := range o.applications {
+			appOptions := o.forApplication(app)
+			if appOptions.Version != "" {
+				err := appOptions.verifyChartVersion(ctx, appOptions.chartName(), appOptions.Version)
 				if err != nil {
-					log.Warnf("Failed to query the Pull Request last commit status for %s ref %s %s\n", pr.URL, pr.LastCommitSha, err)
-					//return fmt.Errorf("Failed to query the Pull Request last commit status for %s ref %s %s", pr.URL, pr.LastCommitSha, err)
-				} else if status == "in-progress" {
-					log.Infoln("The build for the Pull Request last commit is currently in progress.")
-				} else {
-					if status == "success" {
-						if !o.NoMergePullRequest {
-							err = gitProvider.MergePullRequest(pr, "jx promote automatically merged promotion PR")
-							if err != nil {
-								if !logMergeFailure {
-									logMergeFailure = true
-									log.Warnf("Failed to merge the Pull Request %s due to %s maybe I don't have karma?\n", pr.URL, err)
-								}
-							}
-						}
-					} else if status == "error" || status == "failure" {
-						return fmt.Errorf("Pull request %s last commit has status %s for ref %s", pr.URL, status, pr.LastCommitSha)
-					}
+					return err
 				}
 			}
-			if pr.Mergeable != nil && !*pr.Mergeable {
-				log.Infoln("Rebasing PullRequest due to conflict")
-
-				err = o.PromoteViaPullRequest(env, releaseInfo)
-				pullRequestInfo = releaseInfo.PullRequestInfo
-			}
-
-			if time.Now().After(end) {
-				return fmt.Errorf("Timed out waiting for pull request %s to merge. Waited %s", pr.URL, duration.String())
-			}
-			time.Sleep(*o.PullRequestPollDuration)
 		}
+		return nil
 	}
-	return nil
+	if o.Version == "" {
+		// the latest version is resolved from the helm repositories
+		return nil
+	}
+	return o.verifyChartVersion(ctx, o.chartName(), o.Version)
 }
 
-func (o *PromoteOptions) findLatestVersion(app string) (string, error) {
-	versions, err := o.Helm().SearchChartVersions(app)
-	if err != nil {
-		return "", err
+// verifyChartVersion checks that the version of the chart is available in the helm repositories
+func (o *PromoteOptions) verifyChartVersion(ctx context.Context, chart string, version string) error {
+	if helm.IsDigestVersion(version) {
+		// the helm repositories only list the semantic versions of the charts
+		return nil
 	}
-
-	var maxSemVer *semver.Version
-	maxString := ""
-	for _, version := range versions {
-		sv, err := semver.Parse(version)
+	return o.retryOnChartNotFound(ctx, chart, func() error {
+		versions, err := o.Helm().SearchChartVersions(chart)
 		if err != nil {
-			log.Warnf("Invalid semantic version: %s %s\n", version, err)
-			if maxString == "" || strings.Compare(version, maxString) > 0 {
-				maxString = version
-			}
-		} else {
-			if maxSemVer == nil || maxSemVer.Compare(sv) > 0 {
-				maxSemVer = &sv
-			}
+			return err
+		}
+		if len(versions) == 0 {
+			return &chartNotFoundError{chart: chart}
+		}
+		if util.StringArrayIndex(versions, version) < 0 {
+			return &chartVersionNotFoundError{chart: chart, version: version, versions: versions}
 		}
+		return nil
+	})
+}
+
+// resolveVersionRange replaces a semantic version range given as the version to promote with the highest version of
+// the chart which satisfies it
+func (o *PromoteOptions) resolveVersionRange(ctx context.Context) error {
+	version, err := o.resolveVersion(ctx, true)
+	if err != nil {
+		return err
 	}
+	o.Version = version
+	return nil
+}
 
-	if maxSemVer != nil {
-		return maxSemVer.String(), nil
+// resolveVersion returns the version to promote with a semantic version range resolved as the highest version of the
+// chart which satisfies it. If updateRepos is true the lookup of the chart is retried, updating the helm repositories,
+// while the chart cannot be found
+func (o *PromoteOptions) resolveVersion(ctx context.Context, updateRepos bool) (string, error) {
+	if o.versionRange() == nil {
+		return o.Version, nil
 	}
-	if maxString == "" {
-		return "", fmt.Errorf("Could not find a version of app %s in the helm repositories", app)
+	chart := o.chartName()
+	version := ""
+	find := func() error {
+		var err error
+		version, err = o.findLatestVersion(chart)
+		return err
 	}
-	return maxString, nil
+	var err error
+	if updateRepos {
+		err = o.retryOnChartNotFound(ctx, chart, find)
+	} else {
+		err = o.staleHelmCacheHint(find())
+	}
+	if err != nil {
+		return "", err
+	}
+	o.infoEvent(nil, promoteEvent{Event: "version-resolved"}, "Resolved the version range %s of app %s as %s\n", util.ColorInfo(o.Version), util.ColorInfo(o.Application), util.ColorInfo(version))
+	return version, nil
 }
 
 func (o *PromoteOptions) verifyHelmConfigured() error {
-	helmHomeDir := filepath.Join(util.HomeDir(), ".helm")
-	exists, err := util.FileExists(helmHomeDir)
+	helmHome := helmHomeDir()
+	exists, err := util.FileExists(helmHome)
 	if err != nil {
 		return err
 	}
 	if !exists {
-		log.Warnf("No helm home dir at %s so lets initialise helm client\n", helmHomeDir)
+		o.warnEvent(nil, promoteEvent{Event: "helm-init"}, "No helm home dir at %s so lets initialise helm client\n", helmHome)
 
 		err = o.helmInit("")
 		if err != nil {
@@ -710,6 +4359,24 @@ func (o *PromoteOptions) verifyHelmConfigured() error {
 	return o.registerLocalHelmRepo(o.LocalHelmRepoName, ns)
 }
 
+// createActivityKey returns the key of the PipelineActivity which records the promotion to the given environment or
+// nil if --no-activity is specified. All the updates of the PipelineActivity are skipped for a nil key
+func (o *PromoteOptions) createActivityKey(env *v1.Environment, releaseInfo *ReleaseInfo) *kube.PromoteStepActivityKey {
+	if o.NoActivity {
+		return nil
+	}
+	return o.releasePromoteKey(env, releaseInfo)
+}
+
+// releasePromoteKey returns the key of the promotion of the release creating it the first time it is needed so that
+// the steps of the promotion share it
+func (o *PromoteOptions) releasePromoteKey(env *v1.Environment, releaseInfo *ReleaseInfo) *kube.PromoteStepActivityKey {
+	if releaseInfo.promoteKey == nil {
+		releaseInfo.promoteKey = o.createPromoteKey(env)
+	}
+	return releaseInfo.promoteKey
+}
+
 func (o *PromoteOptions) createPromoteKey(env *v1.Environment) *kube.PromoteStepActivityKey {
 	pipeline := os.Getenv("JOB_NAME")
 	build := os.Getenv("BUILD_NUMBER")
@@ -730,8 +4397,11 @@ func (o *PromoteOptions) createPromoteKey(env *v1.Environment) *kube.PromoteStep
 	if o.releaseResource != nil {
 		releaseNotesURL = o.releaseResource.Spec.ReleaseNotesURL
 	}
+	if o.ReleaseNotesURL != "" {
+		releaseNotesURL = o.ReleaseNotesURL
+	}
 	if err != nil {
-		log.Warnf("Could not discover the git repository info %s\n", err)
+		o.warnEvent(env, promoteEvent{Event: "git-info-not-found"}, "Could not discover the git repository info %s\n", err)
 	} else {
 		o.GitInfo = gitInfo
 	}
@@ -740,7 +4410,7 @@ func (o *PromoteOptions) createPromoteKey(env *v1.Environment) *kube.PromoteStep
 			// lets default the pipeline name from the git repo
 			branch, err := o.Git().Branch(".")
 			if err != nil {
-				log.Warnf("Could not find the branch name: %s\n", err)
+				o.warnEvent(env, promoteEvent{Event: "branch-not-found"}, "Could not find the branch name: %s\n", err)
 			}
 			if branch == "" {
 				branch = "master"
@@ -750,7 +4420,7 @@ func (o *PromoteOptions) createPromoteKey(env *v1.Environment) *kube.PromoteStep
 				// lets validate and determine the current active pipeline branch
 				p, b, err := o.getLatestPipelineBuild(pipeline)
 				if err != nil {
-					log.Warnf("Failed to try detect the current Jenkins pipeline for %s due to %s\n", pipeline, err)
+					o.warnEvent(env, promoteEvent{Event: "pipeline-not-found"}, "Failed to try detect the current Jenkins pipeline for %s due to %s\n", pipeline, err)
 					pipeline = ""
 				} else {
 					pipeline = p
@@ -758,12 +4428,12 @@ func (o *PromoteOptions) createPromoteKey(env *v1.Environment) *kube.PromoteStep
 				}
 			}
 		}
-		if pipeline == "" {
+		if pipeline == "" && !o.NoActivity {
 			// lets try find
-			log.Warnf("No $JOB_NAME environment variable found so cannot record promotion activities into the PipelineActivity resources in kubernetes\n")
+			o.warnEvent(env, promoteEvent{Event: "activity-not-recorded"}, "No $JOB_NAME environment variable found so cannot record promotion activities into the PipelineActivity resources in kubernetes\n")
 		}
-	} else if build == "" {
-		log.Warnf("No $BUILD_NUMBER environment variablefound so cannot record promotion activities into the PipelineActivity resources in kubernetes\n")
+	} else if build == "" && !o.NoActivity {
+		o.warnEvent(env, promoteEvent{Event: "activity-not-recorded"}, "No $BUILD_NUMBER environment variablefound so cannot record promotion activities into the PipelineActivity resources in kubernetes\n")
 	}
 	name := pipeline
 	if build != "" {
@@ -791,7 +4461,7 @@ func (o *PromoteOptions) createPromoteKey(env *v1.Environment) *kube.PromoteStep
 		}
 	}
 	name = kube.ToValidName(name)
-	log.Infof("Using pipeline: %s build: %s\n", util.ColorInfo(pipeline), util.ColorInfo("#"+build))
+	o.infoEvent(env, promoteEvent{Event: "pipeline"}, "Using pipeline: %s build: %s\n", util.ColorInfo(pipeline), util.ColorInfo("#"+build))
 	return &kube.PromoteStepActivityKey{
 		PipelineActivityKey: kube.PipelineActivityKey{
 			Name:            name,
@@ -806,9 +4476,24 @@ func (o *PromoteOptions) createPromoteKey(env *v1.Environment) *kube.PromoteStep
 	}
 }
 
+// validateActivityKey returns an error if --require-activity is specified but the promotion cannot be recorded in a
+// PipelineActivity as its pipeline or build is unknown
+func (o *PromoteOptions) validateActivityKey(key *kube.PromoteStepActivityKey) error {
+	if !o.RequireActivity || key == nil {
+		return nil
+	}
+	if key.Pipeline == "" {
+		return fmt.Errorf("Cannot record the promotion in a PipelineActivity as no pipeline could be found. Please set the $JOB_NAME environment variable")
+	}
+	if key.Build == "" {
+		return fmt.Errorf("Cannot record the promotion in a PipelineActivity for pipeline %s as no build could be found. Please set the $BUILD_NUMBER environment variable", key.Pipeline)
+	}
+	return nil
+}
+
 // getLatestPipelineBuild for the given pipeline name lets try find the Jenkins Pipeline and the latest build
 func (o *PromoteOptions) getLatestPipelineBuild(pipeline string) (string, string, error) {
-	log.Infof("pipeline %s\n", pipeline)
+	o.infoEvent(nil, promoteEvent{Event: "pipeline"}, "pipeline %s\n", pipeline)
 	build := ""
 	jenkins, err := o.JenkinsClient()
 	if err != nil {
@@ -832,7 +4517,7 @@ func (o *PromoteOptions) getJenkinsURL() string {
 	}
 	url, err := o.GetJenkinsURL()
 	if err != nil {
-		log.Warnf("Could not find Jenkins URL %s", err)
+		o.warnEvent(nil, promoteEvent{Event: "jenkins-url-not-found"}, "Could not find Jenkins URL %s\n", err)
 	} else {
 		o.jenkinsURL = url
 	}
@@ -841,27 +4526,64 @@ func (o *PromoteOptions) getJenkinsURL() string {
 
 // commentOnIssues comments on any issues for a release that the fix is available in the given environment
 func (o *PromoteOptions) commentOnIssues(targetNS string, environment *v1.Environment, promoteKey *kube.PromoteStepActivityKey) error {
+	return o.commentOnReleaseIssues(targetNS, environment, promoteKey, "")
+}
+
+// commentOnPendingIssues comments on any issues for a release that the fix is pending deployment to the given
+// environment via the promotion Pull Request
+func (o *PromoteOptions) commentOnPendingIssues(targetNS string, environment *v1.Environment, pullRequestURL string) error {
+	return o.commentOnReleaseIssues(targetNS, environment, nil, pullRequestURL)
+}
+
+// commentOnReleaseIssues comments on the closed issues of the release that the fix is deployed to the given
+// environment or, if the URL of the promotion Pull Request is specified, that the deployment is pending
+func (o *PromoteOptions) commentOnReleaseIssues(targetNS string, environment *v1.Environment, promoteKey *kube.PromoteStepActivityKey, pullRequestURL string) error {
+	pending := pullRequestURL != ""
 	ens := environment.Spec.Namespace
 	envName := environment.Spec.Label
 	app := o.Application
 	version := o.Version
 	if ens == "" {
-		log.Warnf("Environment %s has no namespace\n", envName)
+		o.warnEvent(environment, promoteEvent{Event: "issue-comment-skipped"}, "Environment %s has no namespace\n", envName)
 		return nil
 	}
 	if app == "" {
-		log.Warnf("No application name so cannot comment on issues that they are now in %s\n", envName)
+		o.warnEvent(environment, promoteEvent{Event: "issue-comment-skipped"}, "No application name so cannot comment on issues that they are now in %s\n", envName)
 		return nil
 	}
 	if version == "" {
-		log.Warnf("No version name so cannot comment on issues that they are now in %s\n", envName)
+		o.warnEvent(environment, promoteEvent{Event: "issue-comment-skipped"}, "No version name so cannot comment on issues that they are now in %s\n", envName)
 		return nil
 	}
 	gitInfo := o.GitInfo
 	if gitInfo == nil {
-		log.Warnf("No GitInfo discovered so cannot comment on issues that they are now in %s\n", envName)
+		o.warnEvent(environment, promoteEvent{Event: "issue-comment-skipped"}, "No GitInfo discovered so cannot comment on issues that they are now in %s\n", envName)
 		return nil
 	}
+	releaseName := kube.ToValidNameWithDots(app + "-" + version)
+	jxClient, _, err := o.JXClient()
+	if err != nil {
+		return err
+	}
+	release, err := jxClient.JenkinsV1().Releases(ens).Get(releaseName, metav1.GetOptions{})
+	if err != nil {
+		release = nil
+	}
+	if pending {
+		// the release is only deployed to the environment when the Pull Request merges
+		if release == nil {
+			release = o.findPromotedRelease(releaseName)
+		}
+		if release == nil || !hasClosedIssues(release) {
+			return nil
+		}
+	} else {
+		err = o.checkReleaseIssues(releaseName, release)
+		if err != nil {
+			return err
+		}
+	}
+
 	authConfigSvc, err := o.CreateGitAuthConfigService()
 	if err != nil {
 		return err
@@ -871,30 +4593,369 @@ func (o *PromoteOptions) commentOnIssues(targetNS string, environment *v1.Enviro
 		return err
 	}
 
-	provider, err := gitInfo.PickOrCreateProvider(authConfigSvc, "user name to comment on issues", o.BatchMode, gitKind, o.Git())
+	provider, err := o.createIssueCommentProvider(authConfigSvc, gitInfo, gitKind)
 	if err != nil {
 		return err
 	}
 
-	releaseName := kube.ToValidNameWithDots(app + "-" + version)
+	url := ""
+	available := ""
+	if !pending {
+		url, available, err = o.applicationURL(environment)
+		if err != nil {
+			return err
+		}
+		if url != "" {
+			o.infoEvent(environment, promoteEvent{Event: "app-url"}, "Application is available at: %s\n", util.ColorInfo(url))
+		}
+
+		// lets try update the PipelineActivity
+		if url != "" && promoteKey != nil && promoteKey.ApplicationURL == "" {
+			promoteKey.ApplicationURL = url
+		}
+	}
+
+	if release != nil {
+		o.releaseResource = release
+
+		releaseNotesURL := release.Spec.ReleaseNotesURL
+		if o.ReleaseNotesURL != "" {
+			releaseNotesURL = o.ReleaseNotesURL
+		}
+		versionMessage := version
+		if releaseNotesURL != "" {
+			versionMessage = "[" + version + "](" + releaseNotesURL + ")"
+		}
+		comment := issueDeployedComment(envName, versionMessage, available)
+		if o.IssueCommentTemplate != "" {
+			comment, err = o.renderIssueComment(envName, version, releaseNotesURL, url)
+			if err != nil {
+				return err
+			}
+		}
+		status := "is now in " + util.ColorInfo(envName)
+		if pending {
+			comment = issuePendingComment(envName, versionMessage, pullRequestURL)
+			status = "is pending deployment to " + util.ColorInfo(envName)
+		}
+		issues := o.issuesSincePreviousVersion(ens, environment, release)
+		commented, errs := o.commentOnClosedIssues(environment, provider, gitInfo, issues, comment, status)
+		if len(errs) > 0 {
+			o.warnEvent(environment, promoteEvent{Event: "issue-comment-failed"}, "Commented on %d of the %d closed issues of release %s:\n", commented, commented+len(errs), releaseName)
+			for _, err := range errs {
+				o.warnEvent(environment, promoteEvent{Event: "issue-comment-failed"}, "  %s\n", err)
+			}
+		} else if commented > 0 {
+			o.infoEvent(environment, promoteEvent{Event: "issues-commented"}, "Commented on the %d closed issues of release %s\n", commented, releaseName)
+		}
+	}
+	return nil
+}
+
+// issuesSincePreviousVersion returns the issues of the release which are not issues of the release of the
+// --comment-since-version or of the version previously promoted to the environment so that the issues of earlier
+// releases are not commented on again. Returns all of the issues of the release if the previous release is not known
+func (o *PromoteOptions) issuesSincePreviousVersion(ns string, environment *v1.Environment, release *v1.Release) []v1.IssueSummary {
+	issues := release.Spec.Issues
+	previousVersion := o.CommentSinceVersion
+	if previousVersion == "" {
+		previousVersion = o.previouslyPromotedVersion(environment.Name)
+	}
+	if previousVersion == "" || previousVersion == o.Version {
+		return issues
+	}
+	previousReleaseName := kube.ToValidNameWithDots(o.Application + "-" + previousVersion)
+	var previous *v1.Release
+	jxClient, _, err := o.JXClient()
+	if err == nil {
+		previous, err = jxClient.JenkinsV1().Releases(ns).Get(previousReleaseName, metav1.GetOptions{})
+		if err != nil {
+			previous = o.findPromotedRelease(previousReleaseName)
+		}
+	}
+	if previous == nil {
+		o.warnEvent(environment, promoteEvent{Event: "previous-release-not-found"}, "Could not find release %s of the previous version %s so commenting on all of the closed issues of the release\n", previousReleaseName, previousVersion)
+		return issues
+	}
+	o.infoEvent(environment, promoteEvent{Event: "issues-since-version"}, "Only commenting on the issues closed since version %s\n", util.ColorInfo(previousVersion))
+	return issuesNotIn(issues, previous.Spec.Issues)
+}
+
+// previouslyPromotedVersion returns the version of the application which was last successfully promoted to the
+// environment before the current version found in the PipelineActivity history or "" if there is none
+func (o *PromoteOptions) previouslyPromotedVersion(envName string) string {
+	if o.Activities == nil {
+		return ""
+	}
+	history := &PromoteHistoryOptions{
+		Application: o.Application,
+		Environment: envName,
+		Activities:  o.Activities,
+	}
+	entries, err := history.History()
+	if err != nil {
+		o.warnEvent(nil, promoteEvent{Event: "previous-version-not-found"}, "Could not find the version previously promoted to environment %s: %s\n", envName, err)
+		return ""
+	}
+	for _, entry := range entries {
+		if entry.Status == v1.ActivityStatusTypeSucceeded && entry.Version != "" && entry.Version != o.Version {
+			return entry.Version
+		}
+	}
+	return ""
+}
+
+// issuesNotIn returns the issues which are not in the other issues comparing issues by their URL or, if they have no
+// URL, by their ID
+func issuesNotIn(issues []v1.IssueSummary, others []v1.IssueSummary) []v1.IssueSummary {
+	issueKey := func(issue *v1.IssueSummary) string {
+		if issue.URL != "" {
+			return issue.URL
+		}
+		return issue.ID
+	}
+	found := map[string]bool{}
+	for i := range others {
+		found[issueKey(&others[i])] = true
+	}
+	answer := []v1.IssueSummary{}
+	for i := range issues {
+		if !found[issueKey(&issues[i])] {
+			answer = append(answer, issues[i])
+		}
+	}
+	return answer
+}
+
+// commentOnClosedIssues adds the comment to each of the closed issues of the git repository. Each issue is commented on
+// independently so that a failure does not stop the remaining issues being commented on. Returns the number of issues
+// commented on and the reasons the other closed issues could not be commented on
+func (o *PromoteOptions) commentOnClosedIssues(env *v1.Environment, provider gits.GitProvider, gitInfo *gits.GitRepositoryInfo, issues []v1.IssueSummary, comment string, status string) (int, []error) {
+	commented := 0
+	errs := []error{}
+	for i := range issues {
+		issue := &issues[i]
+		if !issue.IsClosed() {
+			continue
+		}
+		o.infoEvent(env, promoteEvent{Event: "issue-comment"}, "Commenting that issue %s %s\n", util.ColorInfo(issue.URL), status)
+		number, err := strconv.Atoi(issue.ID)
+		if err != nil || number <= 0 {
+			errs = append(errs, fmt.Errorf("Could not parse issue id '%s' for URL %s", issue.ID, issue.URL))
+			continue
+		}
+		err = provider.CreateIssueComment(gitInfo.Organisation, gitInfo.Name, number, comment)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to add comment to issue %s: %s", issue.URL, err))
+			continue
+		}
+		commented++
+	}
+	return commented, errs
+}
+
+// issueDeployedComment returns the comment added to an issue when its fix is deployed to the environment
+func issueDeployedComment(envName string, versionMessage string, available string) string {
+	return fmt.Sprintf(":white_check_mark: the fix for this issue is now deployed to **%s** in version %s %s", envName, versionMessage, available)
+}
+
+// renderIssueComment renders the comment added to the closed issues of the release from the --issue-comment-template
+func (o *PromoteOptions) renderIssueComment(envName string, version string, releaseNotesURL string, url string) (string, error) {
+	tmpl, err := parsePullRequestTemplate(optionIssueComment, o.IssueCommentTemplate)
+	if err != nil {
+		return "", err
+	}
+	data := &IssueCommentTemplateData{
+		Environment:     envName,
+		Version:         version,
+		ReleaseNotesURL: releaseNotesURL,
+		URL:             url,
+	}
+	var buffer bytes.Buffer
+	err = tmpl.Execute(&buffer, data)
+	if err != nil {
+		return "", fmt.Errorf("Failed to render the --%s template %s: %s", optionIssueComment, o.IssueCommentTemplate, err)
+	}
+	return buffer.String(), nil
+}
+
+// issuePendingComment returns the comment added to an issue when the Pull Request which promotes its fix to the
+// environment is created
+func issuePendingComment(envName string, versionMessage string, pullRequestURL string) string {
+	return fmt.Sprintf(":hourglass: the fix for this issue is pending deployment to **%s** in version %s via Pull Request %s", envName, versionMessage, pullRequestURL)
+}
+
+// findPromotedRelease returns the release deployed to any of the environments of the team or nil if it cannot be found
+func (o *PromoteOptions) findPromotedRelease(releaseName string) *v1.Release {
+	kubeClient, currentNs, err := o.KubeClient()
+	if err != nil {
+		return nil
+	}
+	team, _, err := kube.GetDevNamespace(kubeClient, currentNs)
+	if err != nil {
+		return nil
+	}
 	jxClient, _, err := o.JXClient()
+	if err != nil {
+		return nil
+	}
+	envs, names, err := kube.GetEnvironments(jxClient, team)
+	if err != nil {
+		return nil
+	}
+	for _, name := range names {
+		ns := envs[name].Spec.Namespace
+		if ns == "" {
+			continue
+		}
+		release, err := jxClient.JenkinsV1().Releases(ns).Get(releaseName, metav1.GetOptions{})
+		if err == nil {
+			return release
+		}
+	}
+	return nil
+}
+
+// hasClosedIssues returns true if the release references any closed issues
+func hasClosedIssues(release *v1.Release) bool {
+	for _, issue := range release.Spec.Issues {
+		if issue.IsClosed() {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPromotionPolicy returns an error if the promotion policy of the environment does not allow the version
+func (o *PromoteOptions) checkPromotionPolicy(env *v1.Environment, version string) error {
+	app := o.Application
+	policy := &env.Spec.PromotionPolicy
+	if !policy.AllowsVersion(version) {
+		return fmt.Errorf("Version %s of %s is not allowed in environment %s which only allows versions: %s", version, app, env.Name, strings.Join(policy.AllowedVersions, ", "))
+	}
+	if len(policy.RequiredEnvironments) == 0 {
+		return nil
+	}
+	kubeClient, currentNs, err := o.KubeClient()
 	if err != nil {
 		return err
 	}
-	kubeClient, _, err := o.KubeClient()
+	team, _, err := kube.GetDevNamespace(kubeClient, currentNs)
 	if err != nil {
 		return err
 	}
-	appNames := []string{app, o.ReleaseName, ens + "-" + app}
+	jxClient, _, err := o.JXClient()
+	if err != nil {
+		return err
+	}
+	envs, _, err := kube.GetEnvironments(jxClient, team)
+	if err != nil {
+		return err
+	}
+	releaseName := kube.ToValidNameWithDots(app + "-" + version)
+	for _, name := range policy.RequiredEnvironments {
+		requiredEnv := envs[name]
+		if requiredEnv == nil {
+			return fmt.Errorf("The promotion policy of environment %s requires the unknown environment %s", env.Name, name)
+		}
+		ns, err := kube.DiscoverEnvironmentNamespace(kubeClient, requiredEnv)
+		if err != nil {
+			return err
+		}
+		_, err = jxClient.JenkinsV1().Releases(ns).Get(releaseName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("Version %s of %s must be promoted to environment %s before environment %s", version, app, name, env.Name)
+		}
+	}
+	return nil
+}
+
+// createIssueCommentProvider creates the git provider used to comment on issues which uses the API token of the
+// --comment-as user if specified otherwise the user is picked from the git auth configuration
+func (o *PromoteOptions) createIssueCommentProvider(authConfigSvc auth.AuthConfigService, gitInfo *gits.GitRepositoryInfo, gitKind string) (gits.GitProvider, error) {
+	if o.CommentAs == "" {
+		return gitInfo.PickOrCreateProvider(authConfigSvc, "user name to comment on issues", o.BatchMode, gitKind, o.Git())
+	}
+	authConfig := authConfigSvc.Config()
+	server := authConfig.GetOrCreateServer(gitInfo.HostURLWithoutUser())
+	if server.Kind == "" {
+		server.Kind = gitKind
+	}
+	userAuth := authConfig.FindUserAuth(server.URL, o.CommentAs)
+	if userAuth == nil {
+		return nil, fmt.Errorf("No API token found for user %s on git server %s to comment on issues. Try 'jx create git token %s'", o.CommentAs, server.URL, o.CommentAs)
+	}
+	return gitInfo.CreateProviderForUser(server, userAuth, gitKind, o.Git())
+}
+
+// checkReleaseIssues returns an error if the --require-issues option is specified and the release does not reference
+// any issues
+func (o *PromoteOptions) checkReleaseIssues(releaseName string, release *v1.Release) error {
+	if !o.RequireIssues {
+		return nil
+	}
+	if release == nil {
+		return fmt.Errorf("Could not find the release %s to verify it references issues as required by --%s", releaseName, optionRequireIssues)
+	}
+	if len(release.Spec.Issues) == 0 {
+		return fmt.Errorf("The release %s does not reference any issues as required by --%s", releaseName, optionRequireIssues)
+	}
+	return nil
+}
+
+// applicationURL returns the URL of the application in the given environment along with the markdown text describing
+// where it is available. An --app-url option takes precedence over any discovered service or ingress. All the lookups
+// of the application URL use it so that the promotion log, the comments and the templates agree on the URL
+func (o *PromoteOptions) applicationURL(environment *v1.Environment) (string, string, error) {
+	url := o.appURLOverride(environment.Name)
+	if url != "" {
+		return url, fmt.Sprintf(" and available [here](%s)", url), nil
+	}
+	kubeClient, err := o.targetKubeClient()
+	if err != nil {
+		return "", "", err
+	}
+	url, available := o.discoverApplicationURL(kubeClient, environment)
+	return url, available, nil
+}
+
+// discoverApplicationURL returns the URL of the application discovered from its service, ingress or route in the
+// given environment along with the markdown text describing where it is available
+func (o *PromoteOptions) discoverApplicationURL(kubeClient kubernetes.Interface, environment *v1.Environment) (string, string) {
 	url := ""
-	for _, n := range appNames {
-		url, err = kube.FindServiceURL(kubeClient, ens, n)
+	app := o.Application
+	ens := environment.Spec.Namespace
+	for _, n := range o.ServiceNames {
+		url, _ = kube.FindServiceURL(kubeClient, ens, n)
 		if url != "" {
+			o.infoEvent(environment, promoteEvent{Event: "app-url-found"}, "Found the URL of %s via the --%s %s\n", app, optionServiceName, util.ColorInfo(n))
 			break
 		}
 	}
+	if url == "" && o.ServiceSelector != "" {
+		name := ""
+		url, name = o.findServiceURLBySelector(kubeClient, ens)
+		if url != "" {
+			o.infoEvent(environment, promoteEvent{Event: "app-url-found"}, "Found the URL of %s via the service %s matching the --%s %s\n", app, util.ColorInfo(name), optionServiceSelector, o.ServiceSelector)
+		}
+	}
+	appNames := []string{app, o.ReleaseName, ens + "-" + app}
+	if url == "" {
+		for _, n := range appNames {
+			url, _ = kube.FindServiceURL(kubeClient, ens, n)
+			if url != "" {
+				o.infoEvent(environment, promoteEvent{Event: "app-url-found"}, "Found the URL of %s via the service %s\n", app, util.ColorInfo(n))
+				break
+			}
+		}
+	}
 	if url == "" {
-		log.Warnf("Could not find the service URL in namespace %s for names %s\n", ens, strings.Join(appNames, ", "))
+		names := append(append([]string{}, o.ServiceNames...), appNames...)
+		if o.ServiceSelector != "" {
+			o.warnEvent(environment, promoteEvent{Event: "app-url-not-found"}, "Could not find the service URL in namespace %s for names %s or selector %s\n", ens, strings.Join(names, ", "), o.ServiceSelector)
+		} else {
+			o.warnEvent(environment, promoteEvent{Event: "app-url-not-found"}, "Could not find the service URL in namespace %s for names %s\n", ens, strings.Join(names, ", "))
+		}
 	}
 	available := ""
 	if url != "" {
@@ -912,47 +4973,77 @@ func (o *PromoteOptions) commentOnIssues(targetNS string, environment *v1.Enviro
 				if hostname != "" {
 					available = fmt.Sprintf(" and available at %s", hostname)
 					url = hostname
+					o.infoEvent(environment, promoteEvent{Event: "app-url-found"}, "Found the URL of %s via the ingress %s\n", app, util.ColorInfo(ing.Name))
 				}
 			}
 		}
 	}
+	if available == "" && o.useOpenShiftRoutes(kubeClient) {
+		for _, n := range []string{app, o.ReleaseName} {
+			if n == "" {
+				continue
+			}
+			routeURL, err := findRouteURL(kubeClient, ens, n)
+			if err == nil && routeURL != "" {
+				url = routeURL
+				available = fmt.Sprintf(" and available [here](%s)", url)
+				o.infoEvent(environment, promoteEvent{Event: "app-url-found"}, "Found the URL of %s via the route %s\n", app, util.ColorInfo(n))
+				break
+			}
+		}
+	}
+	return url, available
+}
 
-	// lets try update the PipelineActivity
-	if url != "" && promoteKey.ApplicationURL == "" {
-		promoteKey.ApplicationURL = url
-		log.Infof("Application is available at: %s\n", util.ColorInfo(url))
+// useOpenShiftRoutes returns true if the URL of the application can be discovered from its OpenShift Route as the
+// --platform is openshift or the cluster serves the Route API
+func (o *PromoteOptions) useOpenShiftRoutes(kubeClient kubernetes.Interface) bool {
+	switch o.Platform {
+	case OPENSHIFT:
+		return true
+	case KUBERNETES:
+		return false
+	default:
+		return kube.HasOpenShiftRoutes(kubeClient)
 	}
+}
 
-	release, err := jxClient.JenkinsV1().Releases(ens).Get(releaseName, metav1.GetOptions{})
-	if err == nil && release != nil {
-		o.releaseResource = release
-		issues := release.Spec.Issues
+// findServiceURLBySelector returns the URL of the first service in the namespace matching the --service-selector
+// which has a URL along with the name of the service
+func (o *PromoteOptions) findServiceURLBySelector(kubeClient kubernetes.Interface, ns string) (string, string) {
+	services, err := kubeClient.CoreV1().Services(ns).List(metav1.ListOptions{
+		LabelSelector: o.ServiceSelector,
+	})
+	if err != nil {
+		o.warnEvent(nil, promoteEvent{Event: "service-selector-failed"}, "Failed to find the services in namespace %s matching the --%s %s: %s\n", ns, optionServiceSelector, o.ServiceSelector, err)
+		return "", ""
+	}
+	names := []string{}
+	for _, svc := range services.Items {
+		names = append(names, svc.Name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		url, _ := kube.FindServiceURL(kubeClient, ns, name)
+		if url != "" {
+			return url, name
+		}
+	}
+	return "", ""
+}
 
-		versionMessage := version
-		if release.Spec.ReleaseNotesURL != "" {
-			versionMessage = "[" + version + "](" + release.Spec.ReleaseNotesURL + ")"
-		}
-		for _, issue := range issues {
-			if issue.IsClosed() {
-				log.Infof("Commenting that issue %s is now in %s\n", util.ColorInfo(issue.URL), util.ColorInfo(envName))
-
-				comment := fmt.Sprintf(":white_check_mark: the fix for this issue is now deployed to **%s** in version %s %s", envName, versionMessage, available)
-				id := issue.ID
-				if id != "" {
-					number, err := strconv.Atoi(id)
-					if err != nil {
-						log.Warnf("Could not parse issue id %s for URL %s\n", id, issue.URL)
-					} else {
-						if number > 0 {
-							err = provider.CreateIssueComment(gitInfo.Organisation, gitInfo.Name, number, comment)
-							if err != nil {
-								log.Warnf("Failed to add comment to issue %s: %s", issue.URL, err)
-							}
-						}
-					}
-				}
+// appURLOverride returns the application URL specified via the --app-url option for the given environment
+func (o *PromoteOptions) appURLOverride(envName string) string {
+	answer := ""
+	for _, value := range o.AppURLs {
+		idx := strings.Index(value, "=")
+		if idx > 0 && !strings.Contains(value[0:idx], "://") {
+			if value[0:idx] == envName {
+				return value[idx+1:]
 			}
+		} else if answer == "" {
+			answer = value
 		}
 	}
-	return nil
+	return answer
 }
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/kube"
+	"github.com/jenkins-x/jx/pkg/util"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// isProtectedEnvironment returns true if promotions to the environment require approval when using --require-approval
+func isProtectedEnvironment(env *v1.Environment) bool {
+	return env != nil && env.Labels[kube.LabelProtected] == "true"
+}
+
+// waitForApproval blocks the promotion to a protected environment until a user approves it by annotating the
+// PipelineActivity of the promotion if the --require-approval option is specified. The wait is recorded on the update
+// step of the promotion so that it shows up in 'jx get activity'
+func (o *PromoteOptions) waitForApproval(ctx context.Context, env *v1.Environment, promoteKey *kube.PromoteStepActivityKey) error {
+	if !o.RequireApproval || !isProtectedEnvironment(env) {
+		return nil
+	}
+	annotation := kube.AnnotationPromoteApprovedByPrefix + env.Name
+	approver, err := o.findApprover(promoteKey, annotation)
+	if err != nil {
+		return err
+	}
+	if approver == "" {
+		if !promoteKey.IsValid() || o.Activities == nil {
+			return fmt.Errorf("Approval required to promote %s to the protected environment %s but there is no PipelineActivity to approve. Please specify $JOB_NAME and $BUILD_NUMBER", o.Application, env.Name)
+		}
+		if o.BatchMode {
+			return fmt.Errorf("Approval required to promote %s to the protected environment %s. To approve it run: kubectl annotate pipelineactivity %s %s=<user>", o.Application, env.Name, promoteKey.Name, annotation)
+		}
+		err = promoteKey.OnPromoteUpdate(o.Activities, kube.WaitingForApprovalPromotionUpdate)
+		if err != nil {
+			return err
+		}
+		o.infoEvent(env, promoteEvent{Event: "waiting-for-approval"}, "Waiting for the approval to promote %s to the protected environment %s. To approve it run:\n\n\tkubectl annotate pipelineactivity %s %s=<user>\n\n", util.ColorInfo(o.Application), util.ColorInfo(env.Name), promoteKey.Name, annotation)
+
+		pollTime := defaultApprovalPollTime
+		if o.PullRequestPollDuration != nil {
+			pollTime = *o.PullRequestPollDuration
+		}
+		var end time.Time
+		if o.TimeoutDuration != nil {
+			end = time.Now().Add(*o.TimeoutDuration)
+		}
+		for approver == "" {
+			if !end.IsZero() && time.Now().After(end) {
+				promoteKey.OnPromoteUpdate(o.Activities, kube.FailedPromotionUpdate)
+				return fmt.Errorf("Timed out waiting for the approval to promote %s to the protected environment %s. Waited %s", o.Application, env.Name, o.TimeoutDuration.String())
+			}
+			if sleepContext(ctx, pollTime) != nil {
+				promoteKey.OnPromoteUpdate(o.Activities, cancelledPromotionUpdate)
+				return &promoteCancelledError{fmt.Sprintf("Cancelled waiting for the approval to promote %s to the protected environment %s", o.Application, env.Name)}
+			}
+			approver, err = o.findApprover(promoteKey, annotation)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	o.infoEvent(env, promoteEvent{Event: "approved"}, "The promotion of %s to environment %s was approved by %s\n", util.ColorInfo(o.Application), util.ColorInfo(env.Name), util.ColorInfo(approver))
+	approved := func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromoteUpdateStep) error {
+		if p.Status == v1.ActivityStatusTypeWaitingForApproval {
+			p.Status = v1.ActivityStatusTypeNone
+		}
+		p.Description = "approved by " + approver
+		return nil
+	}
+	return promoteKey.OnPromoteUpdate(o.Activities, approved)
+}
+
+// findApprover returns the user who approved the promotion from the annotation of the PipelineActivity or a blank
+// string if the promotion is not approved yet
+func (o *PromoteOptions) findApprover(promoteKey *kube.PromoteStepActivityKey, annotation string) (string, error) {
+	if !promoteKey.IsValid() || o.Activities == nil {
+		return "", nil
+	}
+	activity, err := o.Activities.Get(promoteKey.Name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(activity.Annotations[annotation]), nil
+}
+
+// deploymentGateApproved returns true if the protection rules of the GitHub Environment specified via the
+// --github-environment option have approved the deployment of the Pull Request. The deployment of the last commit of
+// the Pull Request is created the first time the gate is checked
+func (o *PromoteOptions) deploymentGateApproved(pullRequestInfo *ReleasePullRequestInfo) (bool, error) {
+	environment := o.GitHubEnvironment
+	if environment == "" {
+		return true, nil
+	}
+	pr := pullRequestInfo.PullRequest
+	gitProvider := pullRequestInfo.GitProvider
+	if pullRequestInfo.Deployment == nil {
+		ref := pr.LastCommitSha
+		if ref == "" && pr.HeadRef != nil {
+			ref = *pr.HeadRef
+		}
+		deployment, err := gitProvider.CreateDeployment(pr.Owner, pr.Repo, ref, environment)
+		if err != nil {
+			return false, fmt.Errorf("Failed to create a deployment of %s to GitHub environment %s: %s", pr.URL, environment, err)
+		}
+		pullRequestInfo.Deployment = deployment
+	}
+	state, err := gitProvider.DeploymentStatus(pr.Owner, pr.Repo, pullRequestInfo.Deployment.ID)
+	if err != nil {
+		return false, fmt.Errorf("Failed to query the deployment status for GitHub environment %s: %s", environment, err)
+	}
+	switch state {
+	case "success", "in_progress":
+		return true, nil
+	case "failure", "error", "inactive":
+		return false, fmt.Errorf("The deployment of %s to GitHub environment %s was not approved and has state %s", pr.URL, environment, state)
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ghodss/yaml"
+	"github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/helm"
+	"github.com/jenkins-x/jx/pkg/jx/cmd/templates"
+	"github.com/jenkins-x/jx/pkg/kube"
+	"github.com/jenkins-x/jx/pkg/log"
+	"github.com/jenkins-x/jx/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// PromoteDiffOptions containers the CLI options
+type PromoteDiffOptions struct {
+	PromoteOptions
+}
+
+// RequirementsChange is a change to a dependency of the environment requirements which a promotion would make
+type RequirementsChange struct {
+	Name          string
+	OldVersion    string
+	NewVersion    string
+	OldRepository string
+	NewRepository string
+}
+
+var (
+	promote_diff_long = templates.LongDesc(`
+		Displays the changes a promotion would make to the requirements of the environment git repository without creating a Pull Request.
+
+		The requirements are modified in memory in the same way as the promotion Pull Request so the environment git repository is not changed.
+`)
+
+	promote_diff_example = templates.Examples(`
+		# Display the changes promoting version 1.2.3 of myapp to production would make
+		jx promote diff myapp --version 1.2.3 --env production
+
+		# Display the changes promoting the latest versions of several applications to staging would make
+		jx promote diff svc-a svc-b@2.0.1 --env staging
+	`)
+)
+
+// NewCmdPromoteDiff creates the new command for: jx promote diff
+func NewCmdPromoteDiff(f Factory, out io.Writer, errOut io.Writer) *cobra.Command {
+	options := &PromoteDiffOptions{
+		PromoteOptions: PromoteOptions{
+			CommonOptions: CommonOptions{
+				Factory: f,
+				Out:     out,
+				Err:     errOut,
+			},
+		},
+	}
+	cmd := &cobra.Command{
+		Use:     "diff [application]...",
+		Short:   "Displays the changes a promotion would make to the environment requirements",
+		Long:    promote_diff_long,
+		Example: promote_diff_example,
+		Run: func(cmd *cobra.Command, args []string) {
+			options.Cmd = cmd
+			options.Args = args
+			err := options.Run()
+			CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&options.Application, optionApplication, "a", "", "The Application to promote")
+	cmd.Flags().StringVarP(&options.Environment, optionEnvironment, "e", "", "The Environment to promote to")
+	cmd.Flags().StringVarP(&options.Version, optionVersion, "v", "", "The Version to promote. Can be a semantic version range such as '^1.2.0' or '~1.4'. Defaults to the latest version")
+	cmd.Flags().StringVarP(&options.ChartName, "chart-name", "", "", "The name of the helm chart to promote if it differs from the application name. Defaults to the application name")
+	cmd.Flags().StringVarP(&options.LocalHelmRepoName, optionHelmRepoName, "r", kube.LocalHelmRepoName, "The name of the helm repository that contains the app")
+	cmd.Flags().StringVarP(&options.HelmRepositoryURL, optionHelmRepositoryURL, "u", helm.DefaultHelmRepositoryURL, "The Helm Repository URL to use for the App")
+	cmd.Flags().StringVarP(&options.EnvBranch, "env-branch", "", "", "The branch of the environment git repository to compare against. Defaults to the ref of the environment source or the default branch of the repository")
+	cmd.Flags().BoolVarP(&options.ExcludePrereleases, "exclude-prereleases", "", false, "Ignores prerelease versions such as 2.0.0-rc.1 when resolving the latest version to promote")
+	cmd.Flags().BoolVarP(&options.NoHelmUpdate, "no-helm-update", "", false, "Allows the 'helm repo update' command if you are sure your local helm cache is up to date with the version you wish to promote")
+	return cmd
+}
+
+// Run implements this command
+func (o *PromoteDiffOptions) Run() error {
+	ctx := context.Background()
+	o.applyEnvironmentVariableDefaults()
+	if o.Environment == "" {
+		return util.MissingOption(optionEnvironment)
+	}
+	helmRepoName, err := normalizeHelmRepoName(o.LocalHelmRepoName)
+	if err != nil {
+		return err
+	}
+	o.LocalHelmRepoName = helmRepoName
+
+	app := o.Application
+	if app == "" {
+		if len(o.Args) == 0 {
+			app, err = o.DiscoverAppName()
+			if err != nil {
+				return err
+			}
+		} else {
+			apps, err := parseApplicationVersions(o.Args, o.Version)
+			if err != nil {
+				return err
+			}
+			apps, err = o.expandApplicationPatterns(ctx, apps)
+			if err != nil {
+				return err
+			}
+			if len(apps) == 1 {
+				app = apps[0].Name
+				o.Version = apps[0].Version
+			} else {
+				o.applications = apps
+				app = applicationNames(apps)
+			}
+		}
+	}
+	o.Application = app
+
+	// the diff does not change anything so lets not create the namespace of the environment
+	o.DryRun = true
+	_, env, err := o.GetTargetNamespace("", o.Environment)
+	if err != nil {
+		return err
+	}
+	if env == nil || env.Spec.Source.URL == "" || !env.Spec.Kind.IsPermanent() {
+		return fmt.Errorf("Environment %s is not promoted via a Pull Request on an environment git repository", o.Environment)
+	}
+	changes, err := o.Diff(ctx, env)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		log.Infof("Promoting %s would not change the requirements of environment %s\n", util.ColorInfo(app), util.ColorInfo(env.Name))
+		return nil
+	}
+	log.Infof("Promoting %s would change the requirements of environment %s:\n\n", util.ColorInfo(app), util.ColorInfo(env.Name))
+	for _, change := range changes {
+		fmt.Fprintln(o.Out, change.String())
+	}
+	return nil
+}
+
+// Diff returns the changes to the requirements of the environment git repository which promoting would make. The
+// requirements are modified in memory by the same function as the promotion Pull Request
+func (o *PromoteDiffOptions) Diff(ctx context.Context, env *v1.Environment) ([]RequirementsChange, error) {
+	err := o.updateHelmRepos(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	modifyRequirementsFn := o.createModifyApplicationsRequirementsFn(ctx, &ReleaseInfo{})
+	if len(o.applications) <= 1 {
+		err = o.resolveVersionRange(ctx)
+		if err != nil {
+			return nil, err
+		}
+		modifyRequirementsFn = o.createModifyRequirementsFn(ctx, o.Version, nil)
+	}
+	dir, _, err := o.cloneEnvironmentRepository(env, o.EnvBranch)
+	if err != nil {
+		return nil, err
+	}
+	requirementsFile, err := helm.FindRequirementsFileName(dir)
+	if err != nil {
+		return nil, err
+	}
+	requirements, err := helm.LoadRequirementsFile(requirementsFile)
+	if err != nil {
+		return nil, err
+	}
+	modified, err := copyRequirements(requirements)
+	if err != nil {
+		return nil, err
+	}
+	err = modifyRequirementsFn(modified)
+	if err != nil {
+		return nil, err
+	}
+	return diffRequirements(requirements, modified), nil
+}
+
+// String returns the change as a line of a diff; prefixed with '+' for an added dependency, '-' for a removed
+// dependency or '~' for a dependency whose version or repository changed
+func (c *RequirementsChange) String() string {
+	switch {
+	case c.OldVersion == "" && c.OldRepository == "":
+		return fmt.Sprintf("+ %s %s (%s)", c.Name, c.NewVersion, c.NewRepository)
+	case c.NewVersion == "" && c.NewRepository == "":
+		return fmt.Sprintf("- %s %s (%s)", c.Name, c.OldVersion, c.OldRepository)
+	}
+	answer := fmt.Sprintf("~ %s %s -> %s", c.Name, c.OldVersion, c.NewVersion)
+	if c.OldRepository != c.NewRepository {
+		answer += fmt.Sprintf(" (repository %s -> %s)", c.OldRepository, c.NewRepository)
+	}
+	return answer
+}
+
+// copyRequirements returns a deep copy of the requirements so they can be modified without changing the original
+func copyRequirements(requirements *helm.Requirements) (*helm.Requirements, error) {
+	data, err := yaml.Marshal(requirements)
+	if err != nil {
+		return nil, err
+	}
+	answer := &helm.Requirements{}
+	err = yaml.Unmarshal(data, answer)
+	return answer, err
+}
+
+// diffRequirements returns the dependencies which are added, changed or removed by the modified requirements. The
+// dependencies are matched by their alias or name
+func diffRequirements(requirements *helm.Requirements, modified *helm.Requirements) []RequirementsChange {
+	changes := []RequirementsChange{}
+	old := map[string]*helm.Dependency{}
+	for _, dep := range requirements.Dependencies {
+		old[dependencyKey(dep)] = dep
+	}
+	names := map[string]bool{}
+	for _, dep := range modified.Dependencies {
+		name := dependencyKey(dep)
+		names[name] = true
+		change := RequirementsChange{
+			Name:          name,
+			NewVersion:    dep.Version,
+			NewRepository: dep.Repository,
+		}
+		previous := old[name]
+		if previous != nil {
+			if previous.Version == dep.Version && previous.Repository == dep.Repository {
+				continue
+			}
+			change.OldVersion = previous.Version
+			change.OldRepository = previous.Repository
+		}
+		changes = append(changes, change)
+	}
+	for _, dep := range requirements.Dependencies {
+		name := dependencyKey(dep)
+		if !names[name] {
+			changes = append(changes, RequirementsChange{
+				Name:          name,
+				OldVersion:    dep.Version,
+				OldRepository: dep.Repository,
+			})
+		}
+	}
+	return changes
+}
+
+// dependencyKey returns the alias of the dependency or its name if it has no alias
+func dependencyKey(dep *helm.Dependency) string {
+	if dep.Alias != "" {
+		return dep.Alias
+	}
+	return dep.Name
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/jenkins-x/jx/pkg/helm"
+	"github.com/jenkins-x/jx/pkg/kube"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestPromoteDiff(t *testing.T) {
+	f := newPromoteTestFixture(t)
+	defer f.cleanup()
+	requirementsFile := f.stagingRequirements(f.jxHome(), &helm.Requirements{
+		Dependencies: []*helm.Dependency{
+			{Name: "myapp", Version: "1.2.0", Repository: "http://chartmuseum"},
+			{Name: "other", Version: "2.0.0", Repository: "http://chartmuseum"},
+		},
+	})
+	original, err := ioutil.ReadFile(requirementsFile)
+	assert.NoError(t, err)
+
+	staging := kube.NewPermanentEnvironment("staging")
+	staging.Spec.Source.URL = "https://github.com/jstrachan/environment-staging.git"
+	test := kube.NewPermanentEnvironment("test")
+
+	helmer := &promoteTestHelmer{
+		versions: map[string][]string{
+			"myapp":  {"1.2.0", "1.3.0"},
+			"newapp": {"0.1.0"},
+		},
+	}
+	newOptions := func(args ...string) (*PromoteDiffOptions, *bytes.Buffer) {
+		out := &bytes.Buffer{}
+		o := &PromoteDiffOptions{}
+		o.Args = args
+		o.Environment = "staging"
+		o.HelmRepositoryURL = "http://chartmuseum"
+		o.NoHelmUpdate = true
+		ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging, test}, &gits.GitFake{}, helmer)
+		o.Out = out
+		return o, out
+	}
+
+	// the latest version is resolved
+	o, out := newOptions("myapp")
+	assert.NoError(t, o.Run())
+	assert.Equal(t, "~ myapp 1.2.0 -> 1.3.0\n", out.String())
+
+	o, out = newOptions("myapp@1.3.0", "newapp@0.1.0")
+	assert.NoError(t, o.Run())
+	assert.Equal(t, "~ myapp 1.2.0 -> 1.3.0\n+ newapp 0.1.0 (http://chartmuseum)\n", out.String())
+
+	o, out = newOptions("myapp@1.2.0")
+	assert.NoError(t, o.Run())
+	assert.Empty(t, out.String())
+
+	// the environment git repository is not changed
+	data, err := ioutil.ReadFile(requirementsFile)
+	assert.NoError(t, err)
+	assert.Equal(t, string(original), string(data))
+
+	o, _ = newOptions("myapp")
+	o.Environment = "test"
+	err = o.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not promoted via a Pull Request")
+	}
+
+	o, _ = newOptions("myapp")
+	o.Environment = ""
+	assert.Error(t, o.Run())
+}
+
+func TestDiffRequirements(t *testing.T) {
+	requirements := &helm.Requirements{
+		Dependencies: []*helm.Dependency{
+			{Name: "myapp", Version: "1.2.0", Repository: "http://chartmuseum"},
+			{Name: "shared-chart", Alias: "svc-a", Version: "1.0.0", Repository: "http://chartmuseum"},
+			{Name: "removed", Version: "0.9.0", Repository: "http://chartmuseum"},
+		},
+	}
+	modified, err := copyRequirements(requirements)
+	assert.NoError(t, err)
+	modified.Dependencies[0].Repository = "http://other-chartmuseum"
+	modified.Dependencies[1].Version = "1.1.0"
+	modified.Dependencies = append(modified.Dependencies[0:2], &helm.Dependency{Name: "added", Version: "0.1.0", Repository: "http://chartmuseum"})
+	assert.Equal(t, "1.2.0", requirements.Dependencies[0].Version, "the copy does not share the dependencies")
+	assert.Equal(t, "http://chartmuseum", requirements.Dependencies[0].Repository)
+
+	lines := []string{}
+	for _, change := range diffRequirements(requirements, modified) {
+		lines = append(lines, change.String())
+	}
+	assert.Equal(t, []string{
+		"~ myapp 1.2.0 -> 1.2.0 (repository http://chartmuseum -> http://other-chartmuseum)",
+		"~ svc-a 1.0.0 -> 1.1.0",
+		"+ added 0.1.0 (http://chartmuseum)",
+		"- removed 0.9.0 (http://chartmuseum)",
+	}, lines)
+	assert.Empty(t, diffRequirements(requirements, requirements))
+}
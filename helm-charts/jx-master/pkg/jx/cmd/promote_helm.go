@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/util"
+)
+
+// chartNotFoundError indicates that no version of a chart could be found in the helm repositories
+type chartNotFoundError struct {
+	chart string
+}
+
+func (e *chartNotFoundError) Error() string {
+	return fmt.Sprintf("Could not find a version of app %s in the helm repositories", e.chart)
+}
+
+// chartVersionNotFoundError indicates that a version of a chart could not be found in the helm repositories
+type chartVersionNotFoundError struct {
+	chart    string
+	version  string
+	versions []string
+}
+
+func (e *chartVersionNotFoundError) Error() string {
+	return fmt.Sprintf("Could not find version %s of app %s in the helm repositories. Available versions: %s", e.version, e.chart, strings.Join(e.versions, ", "))
+}
+
+// isChartNotFound returns true if the error indicates the chart or chart version is not in the helm repositories.
+// Other failures of helm such as a missing namespace or release are not retried
+func isChartNotFound(err error) bool {
+	if _, ok := err.(*chartNotFoundError); ok {
+		return true
+	}
+	if _, ok := err.(*chartVersionNotFoundError); ok {
+		return true
+	}
+	message := err.Error()
+	for _, helmMessage := range helmChartNotFoundMessages {
+		if strings.Contains(message, helmMessage) {
+			return true
+		}
+	}
+	return false
+}
+
+// helmRepoUpdate ensures the helm repositories are updated at most once by the promotions performed by a single call
+// of Run or PromoteAllAutomatic
+type helmRepoUpdate struct {
+	once sync.Once
+	err  error
+}
+
+// retryOnChartNotFound invokes the function retrying with an exponential backoff while it fails as the chart cannot be
+// found. A freshly released chart may not be in the helm repository index yet so the repositories are updated before
+// each retry unless --no-helm-update is specified
+func (o *PromoteOptions) retryOnChartNotFound(ctx context.Context, chart string, fn func() error) error {
+	backoff := o.ChartRetryBackoff
+	for i := 0; ; i++ {
+		err := fn()
+		if err == nil || !isChartNotFound(err) {
+			return err
+		}
+		if i >= o.ChartRetries {
+			return o.staleHelmCacheHint(err)
+		}
+		o.infoEvent(nil, promoteEvent{Event: "chart-not-found-retry"}, "Chart %s not found in the helm repositories so retrying in %s\n", util.ColorInfo(chart), backoff.String())
+		if sleepContext(ctx, backoff) != nil {
+			return &promoteCancelledError{fmt.Sprintf("Cancelled retrying to find chart %s", chart)}
+		}
+		backoff *= 2
+		if !o.NoHelmUpdate {
+			err = o.runHelmRepoUpdate(ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// upgradeRelease upgrades the helm release to the version of the chart retrying while the chart version has not been
+// indexed by the helm repositories yet
+func (o *PromoteOptions) upgradeRelease(ctx context.Context, fullAppName string, releaseName string, targetNS string, version string) error {
+	return o.retryOnChartNotFound(ctx, fullAppName, func() error {
+		return o.Helm().UpgradeChart(fullAppName, releaseName, targetNS, &version, true, o.helmTimeout(), false, !o.NoWait, o.helmSetValues(), o.helmValueFiles())
+	})
+}
+
+// staleHelmCacheHint adds a hint to the error that the chart could not be found as the local helm repository cache may
+// be out of date if --no-helm-update is specified
+func (o *PromoteOptions) staleHelmCacheHint(err error) error {
+	if err == nil || !o.NoHelmUpdate || !isChartNotFound(err) {
+		return err
+	}
+	return fmt.Errorf("%s\nThe local helm repository cache may be out of date as --no-helm-update was specified. Please try again without --no-helm-update", strings.TrimSpace(err.Error()))
+}
+
+// updateHelmRepos updates the helm repositories unless --no-helm-update is specified. The repositories are updated at
+// most once by the promotions of a single run and are not updated if they were updated within the --helm-update-ttl
+func (o *PromoteOptions) updateHelmRepos(ctx context.Context, env *v1.Environment) error {
+	if o.NoHelmUpdate {
+		return nil
+	}
+	if o.helmRepoUpdate == nil {
+		o.helmRepoUpdate = &helmRepoUpdate{}
+	}
+	o.helmRepoUpdate.once.Do(func() {
+		if o.HelmUpdateTTLDuration != nil && *o.HelmUpdateTTLDuration > 0 {
+			updated, ok := lastHelmRepoUpdate()
+			if ok && time.Since(updated) < *o.HelmUpdateTTLDuration {
+				o.infoEvent(env, promoteEvent{Event: "helm-repo-update-skipped"}, "Not updating the helm repositories as they were updated at %s within the --%s of %s\n",
+					util.ColorInfo(updated.Local().Format(time.RFC3339)), optionHelmUpdateTTL, o.HelmUpdateTTLDuration.String())
+				return
+			}
+		}
+		o.infoEvent(env, promoteEvent{Event: "helm-repo-update"}, "Updating the helm repositories to ensure we can find the latest versions...")
+		o.helmRepoUpdate.err = o.runHelmRepoUpdate(ctx)
+	})
+	return o.helmRepoUpdate.err
+}
+
+// runHelmRepoUpdate updates the helm repositories and records when they were updated for the --helm-update-ttl. Returns
+// straight away if the context is cancelled leaving the update to complete in the background
+func (o *PromoteOptions) runHelmRepoUpdate(ctx context.Context) error {
+	// the update cannot be interrupted so lets stop waiting for it if the promotion is cancelled
+	done := make(chan error, 1)
+	go func() {
+		done <- o.Helm().UpdateRepo()
+	}()
+	select {
+	case <-ctx.Done():
+		return &promoteCancelledError{"Cancelled updating the helm repositories"}
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+	}
+	fileName := helmRepoUpdateFile()
+	err := ioutil.WriteFile(fileName, []byte(time.Now().UTC().Format(time.RFC3339)), util.DefaultWritePermissions)
+	if err != nil {
+		o.warnEvent(nil, promoteEvent{Event: "helm-repo-update-not-recorded"}, "Failed to record the time of the helm repository update in %s: %s\n", fileName, err)
+	}
+	return nil
+}
+
+// lastHelmRepoUpdate returns when the helm repositories were last updated by a promotion if it is known
+func lastHelmRepoUpdate() (time.Time, bool) {
+	data, err := ioutil.ReadFile(helmRepoUpdateFile())
+	if err != nil {
+		return time.Time{}, false
+	}
+	updated, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return updated, true
+}
+
+// helmRepoUpdateFile returns the timestamp file in the helm home dir which records when the helm repositories were
+// last updated by a promotion
+func helmRepoUpdateFile() string {
+	return filepath.Join(helmHomeDir(), helmRepoUpdateFileName)
+}
+
+// helmHomeDir returns the helm home dir which defaults to ~/.helm
+func helmHomeDir() string {
+	dir := os.Getenv("HELM_HOME")
+	if dir == "" {
+		dir = filepath.Join(util.HomeDir(), ".helm")
+	}
+	return dir
+}
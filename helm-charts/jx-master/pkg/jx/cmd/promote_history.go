@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	typev1 "github.com/jenkins-x/jx/pkg/client/clientset/versioned/typed/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/jx/cmd/templates"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PromoteHistoryOptions containers the CLI options
+type PromoteHistoryOptions struct {
+	CommonOptions
+
+	Application string
+	Environment string
+	Limit       int
+
+	Activities typev1.PipelineActivityInterface
+}
+
+// PromoteHistoryEntry is a promotion of a version of an app to an environment found in the PipelineActivity history
+type PromoteHistoryEntry struct {
+	Application    string
+	Environment    string
+	Version        string
+	Status         v1.ActivityStatusType
+	PullRequestURL string
+	MergeCommitSHA string
+	Timestamp      time.Time
+}
+
+const (
+	promoteHistoryTimeFormat = "2006-01-02 15:04:05"
+)
+
+var (
+	promote_history_long = templates.LongDesc(`
+		Displays the history of the promotions of applications to environments recorded in the PipelineActivity resources, newest first.
+`)
+
+	promote_history_example = templates.Examples(`
+		# List the promotions of all applications
+		jx promote history
+
+		# List the last 10 promotions of myapp to production
+		jx promote history --app myapp --env production --limit 10
+	`)
+)
+
+// NewCmdPromoteHistory creates the new command for: jx promote history
+func NewCmdPromoteHistory(f Factory, out io.Writer, errOut io.Writer) *cobra.Command {
+	options := &PromoteHistoryOptions{
+		CommonOptions: CommonOptions{
+			Factory: f,
+			Out:     out,
+			Err:     errOut,
+		},
+	}
+	cmd := &cobra.Command{
+		Use:     "history",
+		Short:   "Displays the history of promotions",
+		Long:    promote_history_long,
+		Example: promote_history_example,
+		Run: func(cmd *cobra.Command, args []string) {
+			options.Cmd = cmd
+			options.Args = args
+			err := options.Run()
+			CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&options.Application, optionApplication, "a", "", "Only lists the promotions of the given application")
+	cmd.Flags().StringVarP(&options.Environment, optionEnvironment, "e", "", "Only lists the promotions to the given environment")
+	cmd.Flags().IntVarP(&options.Limit, "limit", "l", 0, "The maximum number of promotions to list. Lists all of the promotions if 0")
+	return cmd
+}
+
+// Run implements this command
+func (o *PromoteHistoryOptions) Run() error {
+	if o.Limit < 0 {
+		return fmt.Errorf("The --limit must not be negative but was %d", o.Limit)
+	}
+	if o.Activities == nil {
+		jxClient, ns, err := o.JXClient()
+		if err != nil {
+			return err
+		}
+		o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+	}
+	entries, err := o.History()
+	if err != nil {
+		return err
+	}
+	table := o.CreateTable()
+	table.AddRow("APPLICATION", "ENVIRONMENT", "VERSION", "STATUS", "PULL REQUEST", "MERGE SHA", "PROMOTED")
+	for _, e := range entries {
+		table.AddRow(e.Application, e.Environment, e.Version, string(e.Status), e.PullRequestURL, e.MergeCommitSHA, e.Timestamp.Local().Format(promoteHistoryTimeFormat))
+	}
+	table.Render()
+	return nil
+}
+
+// History returns the promotions matching the --app and --env options sorted newest first and capped by --limit
+func (o *PromoteHistoryOptions) History() ([]PromoteHistoryEntry, error) {
+	list, err := o.Activities.List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	entries := []PromoteHistoryEntry{}
+	for i := range list.Items {
+		a := &list.Items[i]
+		if o.Application != "" && !activityMatchesApp(a, o.Application) {
+			continue
+		}
+		for _, step := range a.Spec.Steps {
+			ps := step.Promote
+			if ps == nil || (o.Environment != "" && ps.Environment != o.Environment) {
+				continue
+			}
+			entry := PromoteHistoryEntry{
+				Application: activityApplication(a),
+				Environment: ps.Environment,
+				Version:     ps.Version,
+				Status:      ps.Status,
+				Timestamp:   promoteStepTimestamp(a, ps),
+			}
+			if entry.Version == "" {
+				entry.Version = a.Spec.Version
+			}
+			if ps.PullRequest != nil {
+				entry.PullRequestURL = ps.PullRequest.PullRequestURL
+				entry.MergeCommitSHA = ps.PullRequest.MergeCommitSHA
+			}
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+	if o.Limit > 0 && len(entries) > o.Limit {
+		entries = entries[0:o.Limit]
+	}
+	return entries, nil
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestPromoteHistory(t *testing.T) {
+	now := time.Now()
+	merged := newPromoteTestActivity("a5", "myapp", "production", "1.2.0", v1.ActivityStatusTypeSucceeded, now.Add(-1*time.Hour))
+	merged.Spec.Steps[0].Promote.PullRequest = &v1.PromotePullRequestStep{
+		PullRequestURL: "https://github.com/jstrachan/environment-production/pull/7",
+		MergeCommitSHA: "abc123",
+	}
+	activities := []runtime.Object{
+		newPromoteTestActivity("a1", "myapp", "staging", "1.0.0", v1.ActivityStatusTypeSucceeded, now.Add(-5*time.Hour)),
+		newPromoteTestActivity("a2", "myapp", "production", "1.1.0", v1.ActivityStatusTypeFailed, now.Add(-4*time.Hour)),
+		newPromoteTestActivity("a3", "other", "production", "2.0.0", v1.ActivityStatusTypeSucceeded, now.Add(-3*time.Hour)),
+		newPromoteTestActivity("a4", "myapp", "staging", "1.2.0", v1.ActivityStatusTypeSucceeded, now.Add(-2*time.Hour)),
+		merged,
+	}
+	o := &PromoteHistoryOptions{}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, activities, &gits.GitFake{}, &promoteTestHelmer{})
+
+	versions := func() []string {
+		entries, err := o.History()
+		assert.NoError(t, err)
+		answer := []string{}
+		for _, e := range entries {
+			answer = append(answer, e.Application+"/"+e.Environment+"@"+e.Version)
+		}
+		return answer
+	}
+
+	out := &bytes.Buffer{}
+	o.Out = out
+	assert.NoError(t, o.Run())
+	assert.Contains(t, out.String(), "MERGE SHA")
+	assert.Contains(t, out.String(), "https://github.com/jstrachan/environment-production/pull/7")
+	assert.Contains(t, out.String(), "abc123")
+
+	assert.Equal(t, []string{"myapp/production@1.2.0", "myapp/staging@1.2.0", "other/production@2.0.0", "myapp/production@1.1.0", "myapp/staging@1.0.0"}, versions())
+
+	o.Application = "myapp"
+	assert.Equal(t, []string{"myapp/production@1.2.0", "myapp/staging@1.2.0", "myapp/production@1.1.0", "myapp/staging@1.0.0"}, versions())
+
+	o.Environment = "production"
+	assert.Equal(t, []string{"myapp/production@1.2.0", "myapp/production@1.1.0"}, versions())
+
+	o.Environment = ""
+	o.Limit = 2
+	assert.Equal(t, []string{"myapp/production@1.2.0", "myapp/staging@1.2.0"}, versions())
+
+	o.Limit = -1
+	assert.Error(t, o.Run())
+}
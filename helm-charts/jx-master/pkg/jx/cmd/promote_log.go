@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/log"
+)
+
+const (
+	optionLogFormat = "log-format"
+
+	// logFormatText logs the progress of the promotion as colorized text
+	logFormatText = "text"
+	// logFormatJSON logs the progress of the promotion as JSON lines
+	logFormatJSON = "json"
+
+	promoteEventLevelInfo = "info"
+	promoteEventLevelWarn = "warn"
+)
+
+var (
+	// logFormatValues the formats the progress of the promotion can be logged in
+	logFormatValues = []string{logFormatText, logFormatJSON}
+
+	// ansiColorRegex matches the escape sequences used to colorize the text log messages
+	ansiColorRegex = regexp.MustCompile("\x1b\\[[0-9;]*m")
+)
+
+// promoteEvent is the progress of a promotion which is logged as text or, with --log-format json, as a JSON line so
+// that both formats are rendered from the same event data
+type promoteEvent struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Event   string `json:"event"`
+	App     string `json:"app,omitempty"`
+	Env     string `json:"env,omitempty"`
+	Version string `json:"version,omitempty"`
+	PRURL   string `json:"prURL,omitempty"`
+	Status  string `json:"status,omitempty"`
+	Message string `json:"message"`
+}
+
+// infoEvent logs the progress of the promotion to the environment. The app and version default to those being promoted
+func (o *PromoteOptions) infoEvent(env *v1.Environment, event promoteEvent, format string, args ...interface{}) {
+	o.logEvent(promoteEventLevelInfo, env, event, format, args...)
+}
+
+// warnEvent logs a problem with the promotion to the environment. The app and version default to those being promoted
+func (o *PromoteOptions) warnEvent(env *v1.Environment, event promoteEvent, format string, args ...interface{}) {
+	o.logEvent(promoteEventLevelWarn, env, event, format, args...)
+}
+
+func (o *PromoteOptions) logEvent(level string, env *v1.Environment, event promoteEvent, format string, args ...interface{}) {
+	if o.LogFormat != logFormatJSON {
+		if level == promoteEventLevelWarn {
+			log.Warnf(format, args...)
+		} else {
+			log.Infof(format, args...)
+		}
+		return
+	}
+	event.Time = time.Now().UTC().Format(time.RFC3339)
+	event.Level = level
+	if event.App == "" {
+		event.App = o.Application
+	}
+	if event.Version == "" {
+		event.Version = o.Version
+	}
+	if event.Env == "" && env != nil {
+		event.Env = env.Name
+	}
+	event.Message = strings.TrimSpace(ansiColorRegex.ReplaceAllString(fmt.Sprintf(format, args...), ""))
+	data, err := json.Marshal(&event)
+	if err != nil {
+		log.Warnf("Failed to marshal the %s event to JSON: %s\n", event.Event, err)
+		return
+	}
+	log.Info(string(data) + "\n")
+}
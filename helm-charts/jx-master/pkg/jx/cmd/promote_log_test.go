@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/helm"
+	"github.com/jenkins-x/jx/pkg/kube"
+	"github.com/jenkins-x/jx/pkg/log"
+	"github.com/jenkins-x/jx/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestPromoteLogFormat(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	o := &PromoteOptions{
+		Application: "myapp",
+		Version:     "1.2.0",
+	}
+	prURL := "https://github.com/jstrachan/environment-staging/pull/1"
+
+	logOut := &bytes.Buffer{}
+	restoreLog := log.SetOutput(logOut)
+	defer restoreLog()
+
+	// text messages are logged as before
+	o.infoEvent(staging, promoteEvent{Event: "pr-merge-sha", PRURL: prURL}, "Pull Request %s is merged at sha %s\n", prURL, "abc123")
+	assert.Equal(t, "Pull Request "+prURL+" is merged at sha abc123\n", logOut.String())
+
+	logOut.Reset()
+	o.LogFormat = logFormatJSON
+	o.infoEvent(staging, promoteEvent{Event: "pr-merge-sha", PRURL: prURL}, "Pull Request %s is merged at sha %s\n", util.ColorInfo(prURL), util.ColorInfo("abc123"))
+	o.warnEvent(nil, promoteEvent{Event: "merge-status", Status: "failure", Version: "1.1.0"}, "merge status: %s\n", "failure")
+	lines := strings.Split(strings.TrimSpace(logOut.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		event := promoteEvent{}
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &event))
+		assert.NotEmpty(t, event.Time)
+		event.Time = ""
+		assert.Equal(t, promoteEvent{
+			Level:   "info",
+			Event:   "pr-merge-sha",
+			App:     "myapp",
+			Env:     "staging",
+			Version: "1.2.0",
+			PRURL:   prURL,
+			Message: "Pull Request " + prURL + " is merged at sha abc123",
+		}, event)
+
+		event = promoteEvent{}
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+		assert.Equal(t, "warn", event.Level)
+		assert.Equal(t, "", event.Env)
+		assert.Equal(t, "1.1.0", event.Version)
+		assert.Equal(t, "failure", event.Status)
+		assert.Equal(t, "merge status: failure", event.Message)
+	}
+
+	o.LogFormat = "xml"
+	err := o.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "xml")
+	}
+}
+
+func TestPromoteLogFormatJSONLines(t *testing.T) {
+	f := newPromoteTestFixture(t)
+	defer f.cleanup()
+	f.stagingRequirements(f.jxHome(), &helm.Requirements{
+		Dependencies: []*helm.Dependency{
+			{Name: "myapp", Version: "1.2.0", Repository: "http://chartmuseum"},
+		},
+	})
+
+	staging := kube.NewPermanentEnvironment("staging")
+	staging.Spec.Source.URL = "https://github.com/jstrachan/environment-staging.git"
+	timeout := 20 * time.Millisecond
+	pollTime := time.Millisecond
+	o := &PromoteOptions{
+		Application:             "myapp",
+		Version:                 "1.2.0",
+		HelmRepositoryURL:       "http://chartmuseum",
+		LogFormat:               logFormatJSON,
+		ChartRetries:            2,
+		ChartRetryBackoff:       time.Millisecond,
+		MergeRetries:            1,
+		MergeRetryInterval:      time.Millisecond,
+		TimeoutAction:           timeoutActionClose,
+		TimeoutDuration:         &timeout,
+		PullRequestPollDuration: &pollTime,
+	}
+	helmer := &promoteTestHelmer{
+		versions: map[string][]string{
+			"myapp": {"1.2.0"},
+		},
+		missingSearches: 1,
+	}
+	f.configure(o, nil, []runtime.Object{staging}, nil, helmer)
+	jxClient, ns, err := o.JXClient()
+	assert.NoError(t, err)
+	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+
+	logOut := &bytes.Buffer{}
+	restoreLog := log.SetOutput(logOut)
+	defer restoreLog()
+
+	// the environment already has the version so no Pull Request is created
+	_, err = o.Promote(context.Background(), staging.Spec.Namespace, staging, false)
+	assert.NoError(t, err)
+
+	// the chart is not found until the helm repositories are updated
+	helmer.searched = nil
+	assert.NoError(t, o.createModifyRequirementsFn(context.Background(), "", nil)(&helm.Requirements{}))
+
+	// the Pull Request has no commits so the promotion times out and the Pull Request is closed
+	releaseInfo := &ReleaseInfo{
+		PullRequestInfo: newPromoteTestPullRequest(nil),
+	}
+	err = o.WaitForPromotion(context.Background(), staging.Spec.Namespace, staging, releaseInfo)
+	assert.Error(t, err)
+
+	// merging the Pull Request fails once it no longer exists
+	info := newPromoteTestPullRequest(nil)
+	notifier := o.createNotifier(staging, releaseInfo)
+	assert.NoError(t, o.mergePullRequest(context.Background(), info, notifier))
+	assert.Error(t, o.mergePullRequest(context.Background(), info, notifier))
+
+	output := strings.TrimSpace(logOut.String())
+	assert.NotEmpty(t, output)
+	for _, line := range strings.Split(output, "\n") {
+		event := promoteEvent{}
+		if assert.NoError(t, json.Unmarshal([]byte(line), &event), "the log line is not JSON: %s", line) {
+			assert.NotEmpty(t, event.Event, line)
+		}
+	}
+	for _, event := range []string{"promote-noop", "chart-not-found-retry", "pr-closing", "pr-merge-failed", "pr-merge-retry"} {
+		assert.Contains(t, output, `"event":"`+event+`"`)
+	}
+}
@@ -0,0 +1,560 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/jenkins-x/jx/pkg/kube"
+	"github.com/jenkins-x/jx/pkg/util"
+)
+
+// pullRequestTimeoutError indicates that the promotion Pull Request did not merge and pass its status checks before
+// the timeout
+type pullRequestTimeoutError struct {
+	message string
+}
+
+func (e *pullRequestTimeoutError) Error() string {
+	return e.message
+}
+
+// onPullRequestTimeout applies the --timeout-action to the promotion Pull Request which did not merge before the
+// timeout and returns the timeout error
+func (o *PromoteOptions) onPullRequestTimeout(pullRequestInfo *ReleasePullRequestInfo, promoteKey *kube.PromoteStepActivityKey, err error) error {
+	pr := pullRequestInfo.PullRequest
+	switch o.TimeoutAction {
+	case timeoutActionLeave:
+		o.warnEvent(nil, promoteEvent{Event: "pr-left-open", PRURL: pr.URL}, "Leaving the Pull Request %s open as the promotion timed out\n", pr.URL)
+		leftOpen := func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromotePullRequestStep) error {
+			p.Description = "left open as the promotion timed out"
+			return nil
+		}
+		promoteKey.OnPromotePullRequest(o.Activities, leftOpen)
+	case timeoutActionClose:
+		o.infoEvent(nil, promoteEvent{Event: "pr-closing", PRURL: pr.URL}, "Closing the Pull Request %s as the promotion timed out\n", util.ColorInfo(pr.URL))
+		closeErr := pullRequestInfo.GitProvider.ClosePullRequest(pr)
+		if closeErr != nil {
+			o.warnEvent(nil, promoteEvent{Event: "pr-close-failed", PRURL: pr.URL}, "Failed to close the Pull Request %s due to %s\n", pr.URL, closeErr)
+		}
+		closed := func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromotePullRequestStep) error {
+			kube.FailedPromotionPullRequest(a, s, ps, p)
+			if closeErr == nil {
+				p.Description = "closed as the promotion timed out"
+			}
+			return nil
+		}
+		promoteKey.OnPromotePullRequest(o.Activities, closed)
+	default:
+		promoteKey.OnPromotePullRequest(o.Activities, kube.FailedPromotionPullRequest)
+	}
+	return err
+}
+
+func (o *PromoteOptions) waitForGitOpsPullRequest(ctx context.Context, ns string, env *v1.Environment, releaseInfo *ReleaseInfo, end time.Time, duration time.Duration, promoteKey *kube.PromoteStepActivityKey, notifier *promoteNotifier) error {
+	pullRequestInfo := releaseInfo.PullRequestInfo
+	logNoMergeCommitSha := false
+	logHasMergeSha := false
+	logMergeStatusError := false
+	logNoMergeStatuses := false
+	logPullRequestStatusError := false
+	logWaitingForApproval := false
+	logPendingStatus := false
+	logNotRebasing := false
+	rebaseAttempts := 0
+	urlStatusMap := map[string]string{}
+	mergePolicy := o.mergePolicy(env)
+
+	// the create phase lasts until the Pull Request is reviewable and is followed by the merge phase
+	reviewable := false
+	createEnd, createDuration := pullRequestPhaseDeadline(o.PullRequestCreateTimeoutDuration, end, duration)
+	mergeEnd, mergeDuration := end, duration
+
+	pollTime := *o.PullRequestPollDuration
+	lastState := ""
+	queried := false
+	queryRetries := 0
+
+	if pullRequestInfo != nil {
+		statusKind := o.commitStatusKind(env, pullRequestInfo.GitProvider)
+		for {
+			commitStatus := ""
+			pr := pullRequestInfo.PullRequest
+			gitProvider := pullRequestInfo.GitProvider
+			err := o.retryProviderQuery(ctx, env, "query the Pull Request status for "+pr.URL, func() error {
+				return gitProvider.UpdatePullRequestStatus(pr)
+			})
+			if isPromoteCancelled(err) {
+				return err
+			}
+			if err != nil {
+				// the new Pull Request may not be queryable yet if there was no --post-pr-delay
+				if !queried && queryRetries < pullRequestQueryRetries {
+					queryRetries++
+					o.warnEvent(env, promoteEvent{Event: "pr-status-retry", PRURL: pr.URL}, "Failed to query the Pull Request status for %s so retrying: %s\n", pr.URL, err)
+					if sleepContext(ctx, pullRequestQueryRetryTime) != nil {
+						return &promoteCancelledError{fmt.Sprintf("Cancelled waiting for pull request %s", pr.URL)}
+					}
+					continue
+				}
+				return fmt.Errorf("Failed to query the Pull Request status for %s %s", pr.URL, err)
+			}
+			queried = true
+
+			merged := pr.Merged != nil && *pr.Merged
+			if !reviewable && merged {
+				reviewable = true
+				mergeEnd, mergeDuration = pullRequestPhaseDeadline(o.PullRequestMergeTimeoutDuration, end, duration)
+			}
+			if merged {
+				if pr.MergeCommitSHA == nil {
+					if !logNoMergeCommitSha {
+						logNoMergeCommitSha = true
+						o.infoEvent(env, promoteEvent{Event: "pr-merged", PRURL: pr.URL}, "Pull Request %s is merged but waiting for Merge SHA\n", util.ColorInfo(pr.URL))
+					}
+				} else {
+					mergeSha := *pr.MergeCommitSHA
+					if !logHasMergeSha {
+						logHasMergeSha = true
+						o.infoEvent(env, promoteEvent{Event: "pr-merge-sha", PRURL: pr.URL}, "Pull Request %s is merged at sha %s\n", util.ColorInfo(pr.URL), util.ColorInfo(mergeSha))
+
+						mergedPR := func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromotePullRequestStep) error {
+							kube.CompletePromotionPullRequest(a, s, ps, p)
+							p.MergeCommitSHA = mergeSha
+							return nil
+						}
+						promoteKey.OnPromotePullRequest(o.Activities, mergedPR)
+					}
+
+					promoteKey.OnPromoteUpdate(o.Activities, kube.StartPromotionUpdate)
+
+					var statuses []*gits.GitRepoStatus
+					err := o.retryProviderQuery(ctx, env, "query the merge status of "+pr.URL, func() error {
+						var err error
+						statuses, err = gitProvider.ListCommitStatus(pr.Owner, pr.Repo, mergeSha)
+						return err
+					})
+					if isPromoteCancelled(err) {
+						return err
+					}
+					normalizeCommitStatuses(statusKind, statuses)
+					if err != nil {
+						if !logMergeStatusError {
+							logMergeStatusError = true
+							o.warnEvent(env, promoteEvent{Event: "merge-status-error", PRURL: pr.URL}, "Failed to query merge status of repo %s/%s with merge sha %s due to: %s\n", pr.Owner, pr.Repo, mergeSha, err)
+						}
+						notifier.failure(fmt.Sprintf("Failed to query merge status of repo %s/%s with merge sha %s due to: %s", pr.Owner, pr.Repo, mergeSha, err))
+					} else {
+						if len(statuses) == 0 && !logNoMergeStatuses {
+							logNoMergeStatuses = true
+							o.infoEvent(env, promoteEvent{Event: "merge-status-pending", PRURL: pr.URL}, "Merge commit has not yet any statuses on repo %s/%s merge sha %s\n", pr.Owner, pr.Repo, mergeSha)
+						}
+						for _, status := range statuses {
+							if status.IsFailed() {
+								o.warnEvent(env, promoteEvent{Event: "merge-status", PRURL: pr.URL, Status: status.State}, "merge status: %s URL: %s description: %s\n",
+									status.State, status.TargetURL, status.Description)
+								continue
+							}
+							url := status.URL
+							state := status.State
+							if urlStatusMap[url] == "" || urlStatusMap[url] != gitStatusSuccess {
+								if urlStatusMap[url] != state {
+									urlStatusMap[url] = state
+									o.infoEvent(env, promoteEvent{Event: "merge-status", PRURL: pr.URL, Status: state}, "merge status: %s for URL %s with target: %s description: %s\n",
+										util.ColorInfo(state), util.ColorInfo(status.URL), util.ColorInfo(status.TargetURL), util.ColorInfo(status.Description))
+								}
+							}
+						}
+						state, reason := pullRequestState(pr, "", statuses)
+						if state == promoteStateFailed {
+							return fmt.Errorf("Promotion via Pull Request %s failed as %s", pr.URL, reason)
+						}
+						if len(statuses) > 0 {
+							promoteKey.OnPromoteUpdate(o.Activities, updateGitStatuses(gitStatusesOfRef(statuses)))
+						}
+						if state == promoteStateSucceeded {
+							o.infoEvent(env, promoteEvent{Event: "merge-status-passed", PRURL: pr.URL, Status: gitStatusSuccess}, "Merge status checks all passed so the promotion worked!\n")
+							err = o.commentOnPromotedIssues(ns, env, promoteKey)
+							if err == nil {
+								err = promoteKey.OnPromoteUpdate(o.Activities, kube.CompletePromotionUpdate)
+							}
+							if err == nil {
+								notifier.success(fmt.Sprintf("Promoted %s to %s via Pull Request %s", o.Application, env.Name, pr.URL))
+							}
+							return err
+						}
+					}
+				}
+			} else {
+				// a closed Pull Request fails the promotion whatever the status of its last commit
+				if state, reason := pullRequestState(pr, "", nil); state == promoteStateFailed {
+					o.warnEvent(env, promoteEvent{Event: "pr-closed", PRURL: pr.URL}, "Pull Request %s is closed\n", util.ColorInfo(pr.URL))
+					return fmt.Errorf("Promotion via Pull Request %s failed as %s", pr.URL, reason)
+				}
+
+				// lets record the CI progress of the Pull Request on its step while waiting for it to merge so that the
+				// update step only holds the statuses of the merge commit
+				if pr.LastCommitSha != "" {
+					var headStatuses []*gits.GitRepoStatus
+					err := o.retryProviderQuery(ctx, env, "query the commit statuses of "+pr.URL, func() error {
+						var err error
+						headStatuses, err = gitProvider.ListCommitStatus(pr.Owner, pr.Repo, pr.LastCommitSha)
+						return err
+					})
+					if isPromoteCancelled(err) {
+						return err
+					}
+					normalizeCommitStatuses(statusKind, headStatuses)
+					if err != nil {
+						if !logPullRequestStatusError {
+							logPullRequestStatusError = true
+							o.warnEvent(env, promoteEvent{Event: "pr-status-error", PRURL: pr.URL}, "Failed to query the commit statuses of Pull Request %s ref %s due to: %s\n", pr.URL, pr.LastCommitSha, err)
+						}
+					} else if len(headStatuses) > 0 {
+						promoteKey.OnPromotePullRequest(o.Activities, updatePullRequestGitStatuses(gitStatusesOfRef(headStatuses)))
+					}
+				}
+
+				// lets try merge if the status is good
+				var status string
+				err := o.retryProviderQuery(ctx, env, "query the last commit status of "+pr.URL, func() error {
+					var err error
+					status, err = gitProvider.PullRequestLastCommitStatus(pr)
+					return err
+				})
+				if isPromoteCancelled(err) {
+					return err
+				}
+				status = gits.NormalizeCommitStatus(statusKind, status)
+				commitStatus = status
+				if !reviewable && (err == nil || mergePolicy.Kind == v1.MergePolicyKindImmediate) {
+					reviewable = true
+					mergeEnd, mergeDuration = pullRequestPhaseDeadline(o.PullRequestMergeTimeoutDuration, end, duration)
+				}
+				if err != nil && mergePolicy.Kind != v1.MergePolicyKindImmediate {
+					o.warnEvent(env, promoteEvent{Event: "pr-status-error", PRURL: pr.URL}, "Failed to query the Pull Request last commit status for %s ref %s %s\n", pr.URL, pr.LastCommitSha, err)
+					notifier.failure(fmt.Sprintf("Failed to query the Pull Request last commit status for %s ref %s %s", pr.URL, pr.LastCommitSha, err))
+					//return fmt.Errorf("Failed to query the Pull Request last commit status for %s ref %s %s", pr.URL, pr.LastCommitSha, err)
+				} else if state, reason := pullRequestState(pr, status, nil); state == promoteStateFailed {
+					return fmt.Errorf("Promotion via Pull Request %s failed as %s", pr.URL, reason)
+				} else {
+					switch status {
+					case gits.CommitStateInProgress:
+						o.infoEvent(env, promoteEvent{Event: "pr-status", PRURL: pr.URL, Status: status}, "The build for the Pull Request last commit is currently in progress.\n")
+					case gits.CommitStatePending:
+						// the checks have not started yet so lets keep waiting for them
+						if !logPendingStatus {
+							logPendingStatus = true
+							o.infoEvent(env, promoteEvent{Event: "pr-status", PRURL: pr.URL, Status: status}, "The build for the Pull Request last commit has not started yet.\n")
+						}
+					}
+					if !o.NoMergePullRequest {
+						ready, err := o.readyToMerge(pullRequestInfo, mergePolicy, status)
+						if err != nil {
+							return err
+						}
+						if !ready {
+							if status == gitStatusSuccess && !logWaitingForApproval {
+								logWaitingForApproval = true
+								o.infoEvent(env, promoteEvent{Event: "pr-waiting-for-approval", PRURL: pr.URL, Status: status}, "Waiting for the approval of Pull Request %s required by environment %s\n", util.ColorInfo(pr.URL), util.ColorInfo(env.Name))
+							}
+						} else {
+							err = o.mergePullRequest(ctx, pullRequestInfo, notifier)
+							if err != nil {
+								return err
+							}
+						}
+					}
+				}
+			}
+			if pr.Mergeable != nil && !*pr.Mergeable {
+				if !o.AutoRebase {
+					if !logNotRebasing {
+						logNotRebasing = true
+						o.warnEvent(env, promoteEvent{Event: "pr-conflict", PRURL: pr.URL}, "Pull Request %s has conflicts but is not rebased as --%s is disabled\n", pr.URL, optionAutoRebase)
+					}
+				} else {
+					if rebaseAttempts >= o.MaxRebaseAttempts {
+						return fmt.Errorf("Pull Request %s still has conflicts after %d rebase attempts", pr.URL, rebaseAttempts)
+					}
+					rebaseAttempts++
+					o.infoEvent(env, promoteEvent{Event: "pr-rebase", PRURL: pr.URL}, "Rebasing Pull Request %s due to conflict, attempt %d of %d\n", util.ColorInfo(pr.URL), rebaseAttempts, o.MaxRebaseAttempts)
+
+					err = o.PromoteViaPullRequest(ctx, env, releaseInfo)
+					if err != nil {
+						o.warnEvent(env, promoteEvent{Event: "pr-rebase-failed", PRURL: pr.URL}, "Failed to rebase Pull Request %s due to %s\n", pr.URL, err)
+						releaseInfo.PullRequestInfo = pullRequestInfo
+					} else {
+						pullRequestInfo = releaseInfo.PullRequestInfo
+						reviewable = false
+						createEnd, createDuration = pullRequestPhaseDeadline(o.PullRequestCreateTimeoutDuration, end, duration)
+					}
+				}
+			}
+
+			if !reviewable && time.Now().After(createEnd) {
+				return &pullRequestTimeoutError{fmt.Sprintf("Timed out in the create phase waiting for pull request %s to become reviewable. Waited %s", pr.URL, createDuration.String())}
+			}
+			if reviewable && time.Now().After(mergeEnd) {
+				return &pullRequestTimeoutError{fmt.Sprintf("Timed out in the merge phase waiting for pull request %s to merge and pass its status checks. Waited %s", pr.URL, mergeDuration.String())}
+			}
+			state := pullRequestPollState(pr, commitStatus, urlStatusMap)
+			pollTime = o.nextPollTime(pollTime, state != lastState)
+			lastState = state
+			if sleepContext(ctx, pollTime) != nil {
+				return &promoteCancelledError{fmt.Sprintf("Cancelled waiting for pull request %s to merge and pass its status checks", pr.URL)}
+			}
+		}
+	}
+	return nil
+}
+
+// pullRequestMerged returns true if the Pull Request has been merged
+func pullRequestMerged(pr *gits.GitPullRequest) bool {
+	return pr != nil && pr.Merged != nil && *pr.Merged
+}
+
+// commitStatusKind returns the kind of the git server of the environment which is used to normalize the commit statuses
+// of its Pull Requests, falling back to the kind of the git provider
+func (o *PromoteOptions) commitStatusKind(env *v1.Environment, gitProvider gits.GitProvider) string {
+	if env != nil && env.Spec.Source.URL != "" {
+		gitInfo, err := gits.ParseGitURL(env.Spec.Source.URL)
+		if err == nil {
+			kind, err := o.GitServerKind(gitInfo)
+			if err == nil && kind != "" {
+				return kind
+			}
+		}
+	}
+	return gitProvider.Kind()
+}
+
+// normalizeCommitStatuses maps the states of the given commit statuses onto the canonical commit states
+func normalizeCommitStatuses(kind string, statuses []*gits.GitRepoStatus) {
+	for _, status := range statuses {
+		if status != nil {
+			status.State = gits.NormalizeCommitStatus(kind, status.State)
+		}
+	}
+}
+
+// gitStatusesOfRef returns the activity statuses of the commit statuses of a git ref sorted by URL. Only the first
+// status of each URL is used as the git providers list the newest status first
+func gitStatusesOfRef(statuses []*gits.GitRepoStatus) []v1.GitStatus {
+	urlStatusMap := map[string]string{}
+	urlTargetURLMap := map[string]string{}
+	for _, status := range statuses {
+		if status == nil {
+			continue
+		}
+		if _, ok := urlStatusMap[status.URL]; ok {
+			continue
+		}
+		urlStatusMap[status.URL] = status.State
+		urlTargetURLMap[status.URL] = status.TargetURL
+	}
+	answer := []v1.GitStatus{}
+	for _, url := range util.SortedMapKeys(urlStatusMap) {
+		targetURL := urlTargetURLMap[url]
+		if targetURL == "" {
+			targetURL = url
+		}
+		answer = append(answer, v1.GitStatus{
+			URL:    targetURL,
+			Status: urlStatusMap[url],
+		})
+	}
+	return answer
+}
+
+// updateGitStatuses returns a function which records the given git statuses on the promote update step of the activity
+func updateGitStatuses(statuses []v1.GitStatus) kube.PromoteUpdateFn {
+	return func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromoteUpdateStep) error {
+		p.Statuses = statuses
+		return nil
+	}
+}
+
+// updatePullRequestGitStatuses returns a function which records the given git statuses of the last commit of the Pull
+// Request on the promote Pull Request step of the activity
+func updatePullRequestGitStatuses(statuses []v1.GitStatus) kube.PromotePullRequestFn {
+	return func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromotePullRequestStep) error {
+		p.Statuses = statuses
+		return nil
+	}
+}
+
+// pullRequestPollState returns a description of the state of the Pull Request used to detect when it changes between
+// polls
+func pullRequestPollState(pr *gits.GitPullRequest, commitStatus string, urlStatusMap map[string]string) string {
+	merged := pr.Merged != nil && *pr.Merged
+	mergeable := ""
+	if pr.Mergeable != nil {
+		mergeable = strconv.FormatBool(*pr.Mergeable)
+	}
+	mergeSha := ""
+	if pr.MergeCommitSHA != nil {
+		mergeSha = *pr.MergeCommitSHA
+	}
+	statuses := []string{}
+	for _, url := range util.SortedMapKeys(urlStatusMap) {
+		statuses = append(statuses, url+"="+urlStatusMap[url])
+	}
+	return fmt.Sprintf("%s merged=%t mergeable=%s mergeSha=%s status=%s statuses=%s", pr.LastCommitSha, merged, mergeable, mergeSha, commitStatus, strings.Join(statuses, ","))
+}
+
+// nextPollTime returns the time to wait before polling the Pull Request again. If --poll-backoff-max is specified the
+// poll time doubles up to the maximum while the Pull Request is unchanged and goes back to --pull-request-poll-time when
+// it changes
+func (o *PromoteOptions) nextPollTime(pollTime time.Duration, changed bool) time.Duration {
+	base := *o.PullRequestPollDuration
+	if o.PollBackoffMaxDuration == nil || changed {
+		return base
+	}
+	next := pollTime * 2
+	if next > *o.PollBackoffMaxDuration {
+		next = *o.PollBackoffMaxDuration
+	}
+	if next < base {
+		next = base
+	}
+	return next
+}
+
+// pullRequestPhaseDeadline returns the deadline and duration of a phase of waiting for the promotion Pull Request which
+// starts now, falling back to the deadline of the whole promotion if the phase has no timeout
+func pullRequestPhaseDeadline(timeout *time.Duration, end time.Time, duration time.Duration) (time.Time, time.Duration) {
+	if timeout == nil {
+		return end, duration
+	}
+	return time.Now().Add(*timeout), *timeout
+}
+
+// mergePullRequest merges the promotion Pull Request retrying up to --merge-retries times if the merge fails.
+// Returns the last error if the Pull Request could not be merged, unless it has conflicts which are resolved by
+// rebasing the Pull Request
+func (o *PromoteOptions) mergePullRequest(ctx context.Context, pullRequestInfo *ReleasePullRequestInfo, notifier *promoteNotifier) error {
+	pr := pullRequestInfo.PullRequest
+	for i := 0; ; i++ {
+		err := pullRequestInfo.GitProvider.MergePullRequestUsingMethod(pr, "jx promote automatically merged promotion PR", o.MergeMethod)
+		if err == nil {
+			return nil
+		}
+		o.warnEvent(nil, promoteEvent{Event: "pr-merge-failed", PRURL: pr.URL}, "Failed to merge the Pull Request %s due to %s maybe I don't have karma?\n", pr.URL, err)
+		notifier.failure(fmt.Sprintf("Failed to merge the Pull Request %s due to %s", pr.URL, err))
+		if pr.Mergeable != nil && !*pr.Mergeable {
+			return nil
+		}
+		if i >= o.MergeRetries {
+			return fmt.Errorf("Failed to merge the Pull Request %s after %d attempts due to %s", pr.URL, i+1, err)
+		}
+		o.infoEvent(nil, promoteEvent{Event: "pr-merge-retry", PRURL: pr.URL}, "Retrying to merge the Pull Request %s in %s\n", util.ColorInfo(pr.URL), o.MergeRetryInterval.String())
+		if sleepContext(ctx, o.MergeRetryInterval) != nil {
+			return &promoteCancelledError{fmt.Sprintf("Cancelled retrying to merge the Pull Request %s", pr.URL)}
+		}
+	}
+}
+
+// mergePolicy returns the merge policy of the environment overridden by the --merge-policy and --required-approvals
+// options
+func (o *PromoteOptions) mergePolicy(env *v1.Environment) v1.MergePolicy {
+	policy := v1.MergePolicy{}
+	if env != nil {
+		policy = env.Spec.MergePolicy
+	}
+	if o.MergePolicy != "" {
+		policy.Kind = v1.MergePolicyKind(o.MergePolicy)
+	}
+	if o.RequiredApprovals > 0 {
+		policy.RequiredApprovals = int32(o.RequiredApprovals)
+	}
+	if policy.Kind == "" {
+		policy.Kind = v1.MergePolicyKindCISuccess
+	}
+	if policy.Kind == v1.MergePolicyKindApproved && policy.RequiredApprovals <= 0 {
+		policy.RequiredApprovals = 1
+	}
+	return policy
+}
+
+// readyToMerge returns true if the merge policy allows the promotion Pull Request to be merged given the status of
+// its last commit
+func (o *PromoteOptions) readyToMerge(pullRequestInfo *ReleasePullRequestInfo, policy v1.MergePolicy, status string) (bool, error) {
+	if policy.Kind != v1.MergePolicyKindImmediate && status != gitStatusSuccess {
+		return false, nil
+	}
+	if policy.Kind == v1.MergePolicyKindApproved {
+		pr := pullRequestInfo.PullRequest
+		approvers, err := pullRequestInfo.GitProvider.PullRequestApprovers(pr)
+		if err != nil {
+			return false, fmt.Errorf("Failed to query the approvals of Pull Request %s: %s", pr.URL, err)
+		}
+		if len(approvers) < int(policy.RequiredApprovals) {
+			return false, nil
+		}
+	}
+	passed, err := o.requiredStatusContextsPassed(pullRequestInfo)
+	if err != nil || !passed {
+		return false, err
+	}
+	return o.deploymentGateApproved(pullRequestInfo)
+}
+
+// requiredStatusContextsPassed returns true if the last commit of the Pull Request has a successful commit status for
+// each of the --required-status-context contexts. Returns an error if one of the contexts failed as the Pull Request
+// can then never be merged
+func (o *PromoteOptions) requiredStatusContextsPassed(pullRequestInfo *ReleasePullRequestInfo) (bool, error) {
+	if len(o.RequiredStatusContexts) == 0 {
+		return true, nil
+	}
+	pr := pullRequestInfo.PullRequest
+	gitProvider := pullRequestInfo.GitProvider
+	statuses, err := gitProvider.ListCommitStatus(pr.Owner, pr.Repo, pr.LastCommitSha)
+	if err != nil {
+		return false, fmt.Errorf("Failed to query the commit statuses of Pull Request %s: %s", pr.URL, err)
+	}
+	normalizeCommitStatuses(gitProvider.Kind(), statuses)
+	// the git providers list the newest status of each context first
+	contextStates := map[string]string{}
+	for _, status := range statuses {
+		if status == nil {
+			continue
+		}
+		if _, ok := contextStates[status.Context]; !ok {
+			contextStates[status.Context] = status.State
+		}
+	}
+	for _, context := range o.RequiredStatusContexts {
+		switch contextStates[context] {
+		case gitStatusSuccess:
+			continue
+		case gits.CommitStateError, gits.CommitStateFailure:
+			return false, fmt.Errorf("The required status context %s of Pull Request %s has state %s", context, pr.URL, contextStates[context])
+		default:
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// retryProviderQuery invokes the query of the git provider retrying up to --provider-retries times with an
+// exponential backoff while it fails with a transient error. Other errors such as a missing Pull Request are returned
+// straight away
+func (o *PromoteOptions) retryProviderQuery(ctx context.Context, env *v1.Environment, description string, query func() error) error {
+	backoff := providerRetryBackoff
+	for i := 0; ; i++ {
+		err := query()
+		if err == nil || i >= o.ProviderRetries || !gits.IsTransientError(err) {
+			return err
+		}
+		o.warnEvent(env, promoteEvent{Event: "provider-retry"}, "Failed to %s due to a transient error so retrying in %s, attempt %d of %d: %s\n", description, backoff.String(), i+1, o.ProviderRetries, err)
+		if sleepContext(ctx, backoff) != nil {
+			return &promoteCancelledError{fmt.Sprintf("Cancelled retrying to %s", description)}
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	typev1 "github.com/jenkins-x/jx/pkg/client/clientset/versioned/typed/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/jenkins-x/jx/pkg/jx/cmd/templates"
+	"github.com/jenkins-x/jx/pkg/log"
+	"github.com/jenkins-x/jx/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// PromoteStatusOptions containers the CLI options
+type PromoteStatusOptions struct {
+	CommonOptions
+
+	Application string
+	Environment string
+
+	Activities  typev1.PipelineActivityInterface
+	GitProvider gits.GitProvider
+}
+
+const (
+	promoteStatePending   = "pending"
+	promoteStateDeploying = "merged-and-deploying"
+	promoteStateSucceeded = "succeeded"
+	promoteStateFailed    = "failed"
+
+	// PromotePendingExitCode is the exit code of jx promote status when the promotion Pull Request has not merged yet
+	PromotePendingExitCode = 4
+	// PromoteDeployingExitCode is the exit code of jx promote status when the promotion Pull Request has merged but
+	// the status checks of its merge commit have not all passed yet
+	PromoteDeployingExitCode = 5
+)
+
+var (
+	promote_status_long = templates.LongDesc(`
+		Displays the status of the most recent promotion of an application to an environment.
+
+		The Pull Request of the promotion is queried on the git provider so that a promotion run with --no-wait can be checked later on.
+		The exit code is 0 if the promotion succeeded, 1 if it failed, 4 if the Pull Request is pending and 5 if the Pull Request has merged but is still being deployed.
+`)
+
+	promote_status_example = templates.Examples(`
+		# Display the status of the promotion of myapp to production
+		jx promote status --app myapp --env production
+	`)
+)
+
+// PromoteStatusError indicates that the promotion has not succeeded. Its exit code reflects the state of the promotion
+type PromoteStatusError struct {
+	State   string
+	Message string
+}
+
+func (e *PromoteStatusError) Error() string {
+	return e.Message
+}
+
+// ExitCode returns the exit code of jx promote status for the state of the promotion
+func (e *PromoteStatusError) ExitCode() int {
+	switch e.State {
+	case promoteStatePending:
+		return PromotePendingExitCode
+	case promoteStateDeploying:
+		return PromoteDeployingExitCode
+	default:
+		return DefaultErrorExitCode
+	}
+}
+
+// NewCmdPromoteStatus creates the new command for: jx promote status
+func NewCmdPromoteStatus(f Factory, out io.Writer, errOut io.Writer) *cobra.Command {
+	options := &PromoteStatusOptions{
+		CommonOptions: CommonOptions{
+			Factory: f,
+			Out:     out,
+			Err:     errOut,
+		},
+	}
+	cmd := &cobra.Command{
+		Use:     "status",
+		Short:   "Displays the status of a promotion",
+		Long:    promote_status_long,
+		Example: promote_status_example,
+		Run: func(cmd *cobra.Command, args []string) {
+			options.Cmd = cmd
+			options.Args = args
+			err := options.Run()
+			CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&options.Application, optionApplication, "a", "", "The application which was promoted")
+	cmd.Flags().StringVarP(&options.Environment, optionEnvironment, "e", "", "The environment the application was promoted to")
+	return cmd
+}
+
+// Run implements this command
+func (o *PromoteStatusOptions) Run() error {
+	if o.Application == "" {
+		return util.MissingOption(optionApplication)
+	}
+	if o.Environment == "" {
+		return util.MissingOption(optionEnvironment)
+	}
+	if o.Activities == nil {
+		jxClient, ns, err := o.JXClient()
+		if err != nil {
+			return err
+		}
+		o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+	}
+	history := &PromoteHistoryOptions{
+		CommonOptions: o.CommonOptions,
+		Application:   o.Application,
+		Environment:   o.Environment,
+		Limit:         1,
+		Activities:    o.Activities,
+	}
+	entries, err := history.History()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("No promotion of application %s to environment %s found", o.Application, o.Environment)
+	}
+	entry := entries[0]
+	state, reason, err := o.promotionState(&entry)
+	if err != nil {
+		return err
+	}
+	message := fmt.Sprintf("Promotion of %s version %s to %s", entry.Application, entry.Version, entry.Environment)
+	if entry.PullRequestURL != "" {
+		message += " via Pull Request " + entry.PullRequestURL
+	}
+	message += " is " + state
+	if reason != "" {
+		message += ": " + reason
+	}
+	if state == promoteStateSucceeded {
+		log.Infof("%s\n", util.ColorInfo(message))
+		return nil
+	}
+	return &PromoteStatusError{State: state, Message: message}
+}
+
+// promotionState returns the state of the promotion. The state of a promotion via a Pull Request which has not
+// completed yet is queried on the git provider as the promotion may not be waiting for the Pull Request
+func (o *PromoteStatusOptions) promotionState(entry *PromoteHistoryEntry) (string, string, error) {
+	switch entry.Status {
+	case v1.ActivityStatusTypeSucceeded:
+		return promoteStateSucceeded, "", nil
+	case v1.ActivityStatusTypeFailed, v1.ActivityStatusTypeError:
+		return promoteStateFailed, "", nil
+	}
+	if entry.PullRequestURL == "" {
+		return promoteStatePending, "", nil
+	}
+	repoURL, number, err := parsePullRequestURL(entry.PullRequestURL)
+	if err != nil {
+		return "", "", err
+	}
+	gitInfo, err := gits.ParseGitURL(repoURL)
+	if err != nil {
+		return "", "", err
+	}
+	gitProvider := o.GitProvider
+	if gitProvider == nil {
+		gitProvider, err = o.gitProviderForURL(repoURL, "user name to query the promotion Pull Request")
+		if err != nil {
+			return "", "", err
+		}
+	}
+	pr, err := gitProvider.GetPullRequest(gitInfo.Organisation, gitInfo, number)
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to find the Pull Request %s %s", entry.PullRequestURL, err)
+	}
+	err = gitProvider.UpdatePullRequestStatus(pr)
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to query the Pull Request status for %s %s", entry.PullRequestURL, err)
+	}
+	if pr.MergeCommitSHA == nil && entry.MergeCommitSHA != "" {
+		pr.MergeCommitSHA = &entry.MergeCommitSHA
+	}
+	return pullRequestPromotionState(gitProvider, pr)
+}
+
+// pullRequestPromotionState returns the state of a promotion via the given Pull Request along with the reason for it
+// querying the statuses it depends on
+func pullRequestPromotionState(gitProvider gits.GitProvider, pr *gits.GitPullRequest) (string, string, error) {
+	statusKind := gitProvider.Kind()
+	status := ""
+	var statuses []*gits.GitRepoStatus
+	if !pullRequestMerged(pr) {
+		if !pr.IsClosed() {
+			var err error
+			status, err = gitProvider.PullRequestLastCommitStatus(pr)
+			if err != nil {
+				return "", "", fmt.Errorf("Failed to query the status of the last commit of Pull Request %s due to: %s", pr.URL, err)
+			}
+			status = gits.NormalizeCommitStatus(statusKind, status)
+		}
+	} else if pr.MergeCommitSHA != nil {
+		mergeSha := *pr.MergeCommitSHA
+		var err error
+		statuses, err = gitProvider.ListCommitStatus(pr.Owner, pr.Repo, mergeSha)
+		if err != nil {
+			return "", "", fmt.Errorf("Failed to query merge status of repo %s/%s with merge sha %s due to: %s", pr.Owner, pr.Repo, mergeSha, err)
+		}
+		normalizeCommitStatuses(statusKind, statuses)
+	}
+	state, reason := pullRequestState(pr, status, statuses)
+	return state, reason, nil
+}
+
+// pullRequestState returns the state of a promotion via the Pull Request along with the reason for it. The normalized
+// status of the last commit decides the state until the Pull Request merges and the statuses of its merge commit
+// afterwards. It is used by both jx promote status and waitForGitOpsPullRequest so that they agree on the outcome
+func pullRequestState(pr *gits.GitPullRequest, lastCommitStatus string, mergeStatuses []*gits.GitRepoStatus) (string, string) {
+	if !pullRequestMerged(pr) {
+		if pr.IsClosed() {
+			return promoteStateFailed, "the Pull Request is closed without merging"
+		}
+		if lastCommitStatus == gits.CommitStateError || lastCommitStatus == gits.CommitStateFailure {
+			return promoteStateFailed, fmt.Sprintf("the last commit has status %s for ref %s", lastCommitStatus, pr.LastCommitSha)
+		}
+		return promoteStatePending, fmt.Sprintf("the last commit has status %s", lastCommitStatus)
+	}
+	if pr.MergeCommitSHA == nil {
+		return promoteStateDeploying, "waiting for the merge SHA"
+	}
+	for _, status := range mergeStatuses {
+		if status != nil && status.IsFailed() {
+			return promoteStateFailed, fmt.Sprintf("merge status %s URL: %s description: %s", status.State, status.TargetURL, status.Description)
+		}
+	}
+	gitStatuses := gitStatusesOfRef(mergeStatuses)
+	if len(gitStatuses) == 0 {
+		return promoteStateDeploying, fmt.Sprintf("the merge sha %s has no statuses yet", *pr.MergeCommitSHA)
+	}
+	for _, status := range gitStatuses {
+		if status.Status != gitStatusSuccess {
+			return promoteStateDeploying, fmt.Sprintf("merge status %s for %s", status.Status, status.URL)
+		}
+	}
+	return promoteStateSucceeded, ""
+}
+
+// parsePullRequestURL returns the URL of the git repository and the number of the given Pull Request URL
+func parsePullRequestURL(prURL string) (string, int, error) {
+	u := strings.TrimSuffix(prURL, "/")
+	idx := strings.LastIndex(u, "/")
+	if idx < 0 {
+		return "", 0, fmt.Errorf("Invalid Pull Request URL %s", prURL)
+	}
+	number, err := strconv.Atoi(u[idx+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("Invalid Pull Request URL %s as it does not end with a number", prURL)
+	}
+	u = u[0:idx]
+	for _, suffix := range []string{"/-/merge_requests", "/merge_requests", "/pull-requests", "/pulls", "/pull"} {
+		if strings.HasSuffix(u, suffix) {
+			return strings.TrimSuffix(u, suffix), number, nil
+		}
+	}
+	return "", 0, fmt.Errorf("Invalid Pull Request URL %s", prURL)
+}
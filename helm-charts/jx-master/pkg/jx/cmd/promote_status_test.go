@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestPromoteStatus(t *testing.T) {
+	now := time.Now()
+	inFlight := newPromoteTestActivity("a2", "myapp", "production", "1.2.0", v1.ActivityStatusTypeRunning, now.Add(-1*time.Hour))
+	inFlight.Spec.Steps[0].Promote.PullRequest = &v1.PromotePullRequestStep{
+		PullRequestURL: "https://github.com/jstrachan/environment-production/pull/1",
+	}
+	activities := []runtime.Object{
+		newPromoteTestActivity("a1", "myapp", "staging", "1.2.0", v1.ActivityStatusTypeSucceeded, now.Add(-2*time.Hour)),
+		inFlight,
+	}
+	info := newPromoteTestPullRequest(nil)
+	provider := info.GitProvider.(*gits.FakeProvider)
+	repo := provider.Repositories["jstrachan"][0]
+	pr := info.PullRequest
+
+	o := &PromoteStatusOptions{
+		Application: "myapp",
+		GitProvider: provider,
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, activities, &gits.GitFake{}, &promoteTestHelmer{})
+
+	exitCode := func() int {
+		err := o.Run()
+		if err == nil {
+			return 0
+		}
+		if statusErr, ok := err.(*PromoteStatusError); ok {
+			return statusErr.ExitCode()
+		}
+		t.Logf("unexpected error: %s", err)
+		return -1
+	}
+
+	assert.Error(t, o.Run(), "the --env is required")
+
+	o.Environment = "test"
+	err := o.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "No promotion of application myapp to environment test found")
+	}
+
+	// the promotion was completed without a Pull Request
+	o.Environment = "staging"
+	assert.Equal(t, 0, exitCode())
+
+	// the status of the Pull Request cannot be queried
+	o.Environment = "production"
+	repo.PullRequests[1].Commits = nil
+	err = o.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Failed to query the status of the last commit of Pull Request")
+		_, ok := err.(*PromoteStatusError)
+		assert.False(t, ok, "the status is not reported as pending")
+	}
+
+	// the Pull Request has not merged yet
+	repo.PullRequests[1].Commits = []*gits.FakeCommit{
+		{
+			Commit: &gits.GitCommit{SHA: "abc123"},
+			Status: gits.CommitStatusPending,
+		},
+	}
+	assert.Equal(t, PromotePendingExitCode, exitCode())
+
+	repo.PullRequests[1].Commits[0].Status = gits.CommitStatusFailure
+	assert.Equal(t, DefaultErrorExitCode, exitCode())
+
+	// the Pull Request has merged but the merge commit is still being deployed
+	merged := true
+	mergeSha := "def456"
+	pr.Merged = &merged
+	pr.MergeCommitSHA = &mergeSha
+	assert.Equal(t, PromoteDeployingExitCode, exitCode())
+
+	repo.Commits = []*gits.FakeCommit{
+		{
+			Commit: &gits.GitCommit{SHA: mergeSha, URL: "https://ci/deploy"},
+			Status: gits.CommitStatusPending,
+		},
+	}
+	assert.Equal(t, PromoteDeployingExitCode, exitCode())
+
+	repo.Commits[0].Status = gits.CommitSatusSuccess
+	assert.Equal(t, 0, exitCode())
+
+	repo.Commits[0].Status = gits.CommitStatusFailure
+	assert.Equal(t, DefaultErrorExitCode, exitCode())
+
+	// the Pull Request was closed without merging
+	merged = false
+	closedAt := now
+	pr.ClosedAt = &closedAt
+	err = o.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "closed without merging")
+	}
+}
+
+func TestPullRequestState(t *testing.T) {
+	mergeSha := "def456"
+	closedAt := time.Now()
+	newPR := func(merged bool, closed bool) *gits.GitPullRequest {
+		pr := &gits.GitPullRequest{
+			URL:           "https://github.com/jstrachan/environment-production/pull/1",
+			LastCommitSha: "abc123",
+			Merged:        &merged,
+		}
+		if closed {
+			pr.ClosedAt = &closedAt
+		}
+		if merged {
+			pr.MergeCommitSHA = &mergeSha
+		}
+		return pr
+	}
+	testCases := []struct {
+		name             string
+		pr               *gits.GitPullRequest
+		lastCommitStatus string
+		mergeStatuses    []*gits.GitRepoStatus
+		expected         string
+	}{
+		{
+			name:             "open with pending checks",
+			pr:               newPR(false, false),
+			lastCommitStatus: gits.CommitStatePending,
+			expected:         promoteStatePending,
+		},
+		{
+			name:             "open with failed checks",
+			pr:               newPR(false, false),
+			lastCommitStatus: gits.CommitStateFailure,
+			expected:         promoteStateFailed,
+		},
+		{
+			name:             "closed without merging",
+			pr:               newPR(false, true),
+			lastCommitStatus: gitStatusSuccess,
+			expected:         promoteStateFailed,
+		},
+		{
+			name:     "merged without merge statuses",
+			pr:       newPR(true, true),
+			expected: promoteStateDeploying,
+		},
+		{
+			name: "merged with a pending merge status",
+			pr:   newPR(true, true),
+			mergeStatuses: []*gits.GitRepoStatus{
+				{URL: "https://ci/build", State: gitStatusSuccess},
+				{URL: "https://ci/deploy", State: gits.CommitStatePending},
+			},
+			expected: promoteStateDeploying,
+		},
+		{
+			name: "merged with a failed merge status",
+			pr:   newPR(true, true),
+			mergeStatuses: []*gits.GitRepoStatus{
+				{URL: "https://ci/build", State: gitStatusSuccess},
+				{URL: "https://ci/deploy", State: gits.CommitStateFailure},
+			},
+			expected: promoteStateFailed,
+		},
+		{
+			name: "merged with successful merge statuses",
+			pr:   newPR(true, true),
+			mergeStatuses: []*gits.GitRepoStatus{
+				{URL: "https://ci/build", State: gitStatusSuccess},
+				{URL: "https://ci/deploy", State: gitStatusSuccess},
+			},
+			expected: promoteStateSucceeded,
+		},
+	}
+	for _, tc := range testCases {
+		state, _ := pullRequestState(tc.pr, tc.lastCommitStatus, tc.mergeStatuses)
+		assert.Equal(t, tc.expected, state, tc.name)
+	}
+}
+
+func TestParsePullRequestURL(t *testing.T) {
+	testCases := []struct {
+		url     string
+		repoURL string
+		number  int
+	}{
+		{"https://github.com/jstrachan/environment-production/pull/7", "https://github.com/jstrachan/environment-production", 7},
+		{"https://gitlab.com/jstrachan/environment-production/merge_requests/12/", "https://gitlab.com/jstrachan/environment-production", 12},
+		{"https://gitlab.com/jstrachan/environment-production/-/merge_requests/3", "https://gitlab.com/jstrachan/environment-production", 3},
+		{"https://bitbucket.org/jstrachan/environment-production/pull-requests/4", "https://bitbucket.org/jstrachan/environment-production", 4},
+	}
+	for _, tc := range testCases {
+		repoURL, number, err := parsePullRequestURL(tc.url)
+		assert.NoError(t, err, tc.url)
+		assert.Equal(t, tc.repoURL, repoURL, tc.url)
+		assert.Equal(t, tc.number, number, tc.url)
+	}
+
+	for _, url := range []string{"https://github.com/jstrachan/environment-production", "https://github.com/jstrachan/environment-production/issues/1"} {
+		_, _, err := parsePullRequestURL(url)
+		assert.Error(t, err, url)
+	}
+}
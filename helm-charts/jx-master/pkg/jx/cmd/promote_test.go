@@ -0,0 +1,4673 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/auth"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/jenkins-x/jx/pkg/helm"
+	"github.com/jenkins-x/jx/pkg/kube"
+	"github.com/jenkins-x/jx/pkg/log"
+	"github.com/jenkins-x/jx/pkg/util"
+	"github.com/stretchr/testify/assert"
+	appsv1beta1 "k8s.io/api/apps/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+// promoteTestHelmer fakes the helm repository queries and upgrades made by a promotion
+type promoteTestHelmer struct {
+	helm.Helmer
+
+	versions  map[string][]string
+	searched  []string
+	manifests map[string]string
+	fetched   []string
+
+	// missingSearches is the number of searches before the chart versions are found
+	missingSearches int
+	updates         int
+	upgrades        []string
+	timeouts        []*int
+	kubeContext     string
+
+	// upgradeErrors are the errors returned by the upgrades in turn
+	upgradeErrors []error
+
+	// updateRepo blocks the updates of the repositories until it is closed
+	updateRepo chan struct{}
+	// searching is called with the chart on each search of the versions of a chart
+	searching func(chart string)
+}
+
+func (h *promoteTestHelmer) SetKubeContext(context string) {
+	h.kubeContext = context
+}
+
+func (h *promoteTestHelmer) UpgradeChart(chart string, releaseName string, ns string, version *string, install bool, timeout *int, force bool, wait bool, values []string, valueFiles []string) error {
+	h.upgrades = append(h.upgrades, chart)
+	h.timeouts = append(h.timeouts, timeout)
+	if len(h.upgradeErrors) > 0 {
+		err := h.upgradeErrors[0]
+		h.upgradeErrors = h.upgradeErrors[1:]
+		return err
+	}
+	return nil
+}
+
+func (h *promoteTestHelmer) UpdateRepo() error {
+	if h.updateRepo != nil {
+		<-h.updateRepo
+	}
+	h.updates++
+	return nil
+}
+
+func (h *promoteTestHelmer) SearchChartVersions(chart string) ([]string, error) {
+	if h.searching != nil {
+		h.searching(chart)
+	}
+	h.searched = append(h.searched, chart)
+	if len(h.searched) <= h.missingSearches {
+		return []string{}, nil
+	}
+	versions, ok := h.versions[chart]
+	if !ok {
+		return nil, fmt.Errorf("chart %s not found", chart)
+	}
+	return versions, nil
+}
+
+func (h *promoteTestHelmer) SearchCharts(filter string) ([]string, error) {
+	h.searched = append(h.searched, filter)
+	charts := []string{}
+	for chart := range h.versions {
+		if strings.Contains(chart, filter) {
+			charts = append(charts, chart)
+		}
+	}
+	return charts, nil
+}
+
+func (h *promoteTestHelmer) FetchChart(chart string, version *string, untar bool, untardir string) error {
+	h.fetched = append(h.fetched, chart)
+	return nil
+}
+
+func (h *promoteTestHelmer) Template(chartDir string, releaseName string, ns string, outputDir string, values []string, valueFiles []string) error {
+	for name, manifest := range h.manifests {
+		err := ioutil.WriteFile(filepath.Join(outputDir, name), []byte(manifest), util.DefaultWritePermissions)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// promoteTestFixture sets up the environment and options of a promotion test, restoring the environment when it is
+// cleaned up
+type promoteTestFixture struct {
+	t       *testing.T
+	restore []func()
+}
+
+func newPromoteTestFixture(t *testing.T) *promoteTestFixture {
+	return &promoteTestFixture{t: t}
+}
+
+// setEnv sets the environment variables until the fixture is cleaned up
+func (f *promoteTestFixture) setEnv(envVars map[string]string) *promoteTestFixture {
+	for k, v := range envVars {
+		name := k
+		oldValue, hadValue := os.LookupEnv(name)
+		os.Setenv(name, v)
+		f.restore = append(f.restore, func() {
+			if hadValue {
+				os.Setenv(name, oldValue)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+	return f
+}
+
+// tempDir creates a temporary directory which is removed when the fixture is cleaned up
+func (f *promoteTestFixture) tempDir(prefix string) string {
+	dir, err := ioutil.TempDir("", prefix)
+	if !assert.NoError(f.t, err) {
+		f.t.FailNow()
+	}
+	f.restore = append(f.restore, func() {
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+// jxHome points $JX_HOME at a new temporary directory until the fixture is cleaned up, returning the directory
+func (f *promoteTestFixture) jxHome() string {
+	jxHome := f.tempDir("test-jx-home-")
+	f.setEnv(map[string]string{"JX_HOME": jxHome})
+	return jxHome
+}
+
+// stagingRequirements writes the requirements of the clone of the jstrachan/environment-staging repository in the
+// $JX_HOME directory, returning the requirements file
+func (f *promoteTestFixture) stagingRequirements(jxHome string, requirements *helm.Requirements) string {
+	envDir := filepath.Join(jxHome, "environments", "jstrachan", "environment-staging", "env")
+	assert.NoError(f.t, os.MkdirAll(envDir, util.DefaultWritePermissions))
+	requirementsFile := filepath.Join(envDir, helm.RequirementsFileName)
+	assert.NoError(f.t, helm.SaveRequirementsFile(requirementsFile, requirements))
+	return requirementsFile
+}
+
+// configure sets up the options with fake clients holding the given resources. The gitter and helmer default to fakes
+// which succeed
+func (f *promoteTestFixture) configure(o *PromoteOptions, kubeObjects []runtime.Object, jxObjects []runtime.Object, gitter gits.Gitter, helmer helm.Helmer) *PromoteOptions {
+	if gitter == nil {
+		gitter = &gits.GitFake{}
+	}
+	if helmer == nil {
+		helmer = &promoteTestHelmer{}
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, kubeObjects, jxObjects, gitter, helmer)
+	return o
+}
+
+// cleanup restores the environment in the reverse order it was changed
+func (f *promoteTestFixture) cleanup() {
+	for i := len(f.restore) - 1; i >= 0; i-- {
+		f.restore[i]()
+	}
+}
+
+func TestPromoteChartNameDiffersFromAppName(t *testing.T) {
+	helmer := &promoteTestHelmer{
+		versions: map[string][]string{
+			"shared-chart": {"1.0.0"},
+		},
+	}
+	o := &PromoteOptions{
+		Application:       "myapp",
+		ChartName:         "shared-chart",
+		HelmRepositoryURL: "http://chartmuseum",
+	}
+	o.helm = helmer
+
+	requirements := &helm.Requirements{}
+	err := o.createModifyRequirementsFn(context.Background(), "", nil)(requirements)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"shared-chart"}, helmer.searched)
+
+	if assert.Len(t, requirements.Dependencies, 1) {
+		dep := requirements.Dependencies[0]
+		assert.Equal(t, "shared-chart", dep.Name)
+		assert.Equal(t, "myapp", dep.Alias)
+		assert.Equal(t, "1.0.0", dep.Version)
+		assert.Equal(t, "http://chartmuseum", dep.Repository)
+	}
+
+	// promoting another app using the same chart should not replace the first app
+	o.Application = "otherapp"
+	err = o.createModifyRequirementsFn(context.Background(), "2.0.0", nil)(requirements)
+	assert.NoError(t, err)
+	assert.Len(t, requirements.Dependencies, 2)
+}
+
+func TestPromoteDowngrade(t *testing.T) {
+	testCases := []struct {
+		name    string
+		current string
+		version string
+		force   bool
+		err     bool
+	}{
+		{name: "upgrade", current: "1.2.0", version: "1.10.0"},
+		{name: "same version", current: "1.2.0", version: "1.2.0"},
+		{name: "not in the environment", current: "", version: "1.0.0"},
+		{name: "downgrade", current: "1.10.0", version: "1.2.0", err: true},
+		{name: "prerelease downgrade", current: "1.2.0", version: "1.2.0-rc.1", err: true},
+		{name: "forced downgrade", current: "1.10.0", version: "1.2.0", force: true},
+		{name: "not a semantic version", current: "latest", version: "1.2.0"},
+	}
+	for _, tc := range testCases {
+		o := &PromoteOptions{
+			Application:       "myapp",
+			HelmRepositoryURL: "http://chartmuseum",
+			Force:             tc.force,
+		}
+		requirements := &helm.Requirements{}
+		if tc.current != "" {
+			requirements.SetAppVersion("myapp", tc.current, "http://chartmuseum")
+		}
+		err := o.createModifyRequirementsFn(context.Background(), tc.version, nil)(requirements)
+		if tc.err {
+			if assert.Error(t, err, tc.name) {
+				assert.Contains(t, err.Error(), "--force", tc.name)
+			}
+			assert.Equal(t, tc.current, requirements.Dependencies[0].Version, tc.name)
+		} else {
+			assert.NoError(t, err, tc.name)
+			assert.Equal(t, tc.version, requirements.Dependencies[0].Version, tc.name)
+		}
+	}
+}
+
+func TestPromoteChartNameDefaultsToAppName(t *testing.T) {
+	o := &PromoteOptions{
+		Application:       "myapp",
+		HelmRepositoryURL: "http://chartmuseum",
+	}
+	assert.Equal(t, "myapp", o.chartName())
+
+	requirements := &helm.Requirements{}
+	err := o.createModifyRequirementsFn(context.Background(), "1.2.3", nil)(requirements)
+	assert.NoError(t, err)
+	if assert.Len(t, requirements.Dependencies, 1) {
+		dep := requirements.Dependencies[0]
+		assert.Equal(t, "myapp", dep.Name)
+		assert.Equal(t, "", dep.Alias)
+		assert.Equal(t, "1.2.3", dep.Version)
+	}
+}
+
+func TestPromoteDigestVersion(t *testing.T) {
+	digest := helm.DigestPrefix + strings.Repeat("0f", 32)
+	ociRef := "oci://registry.example.com/charts/myapp@" + digest
+
+	apps, err := parseApplicationVersions([]string{"myapp@" + ociRef, "otherapp@" + digest}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []applicationVersion{{Name: "myapp", Version: ociRef}, {Name: "otherapp", Version: digest}}, apps)
+	assert.NoError(t, validateDigestVersions("", apps))
+	assert.Error(t, validateDigestVersions("sha256:abc", nil))
+
+	helmer := &promoteTestHelmer{
+		versions: map[string][]string{
+			"myapp": {"1.0.0"},
+		},
+	}
+	o := &PromoteOptions{
+		Application:       "myapp",
+		Version:           ociRef,
+		HelmRepositoryURL: "http://chartmuseum",
+	}
+	o.helm = helmer
+	assert.Nil(t, o.versionRange())
+	assert.NoError(t, o.verifyPullRequestVersions(context.Background()))
+
+	// the digest is written to the environment verbatim
+	requirements := &helm.Requirements{}
+	err = o.createModifyRequirementsFn(context.Background(), o.Version, nil)(requirements)
+	assert.NoError(t, err)
+	if assert.Len(t, requirements.Dependencies, 1) {
+		assert.Equal(t, ociRef, requirements.Dependencies[0].Version)
+		assert.Equal(t, "oci://registry.example.com/charts", requirements.Dependencies[0].Repository)
+	}
+	assert.Empty(t, helmer.searched)
+
+	// helm cannot upgrade to a digest
+	staging := kube.NewPermanentEnvironment("staging")
+	staging.Spec.PromotionStrategy = v1.PromotionStrategyTypeManual
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging}, &gits.GitFake{}, helmer)
+	_, err = o.Promote(context.Background(), staging.Spec.Namespace, staging, false)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "digest")
+	}
+}
+
+func TestPromoteTargetNamespaceWithDuplicateEnvironmentLabels(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	stagingEU := kube.NewPermanentEnvironment("staging-eu")
+	stagingEU.Spec.Label = "Staging"
+
+	o := &PromoteOptions{}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging, stagingEU}, &gits.GitFake{}, &promoteTestHelmer{})
+
+	ns, env, err := o.GetTargetNamespace("", "staging-eu")
+	assert.NoError(t, err)
+	assert.Equal(t, "jx-staging-eu", ns)
+	assert.Equal(t, "staging-eu", env.Name)
+
+	_, _, err = o.GetTargetNamespace("", "Staging")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "staging (name and label), staging-eu (label)")
+	}
+
+	// the name of an environment is ambiguous if it is the label of another environment
+	_, _, err = o.GetTargetNamespace("", "staging")
+	assert.Error(t, err)
+}
+
+func TestPromoteTargetNamespaceFromNamespaceSelector(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	staging.Spec.Namespace = ""
+	staging.Annotations = map[string]string{
+		kube.AnnotationNamespaceSelector: "env=staging",
+	}
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "jx-staging-x7k2p",
+			Labels: map[string]string{
+				"env": "staging",
+			},
+		},
+	}
+
+	o := &PromoteOptions{}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, []runtime.Object{ns}, []runtime.Object{staging}, &gits.GitFake{}, &promoteTestHelmer{})
+
+	targetNS, env, err := o.GetTargetNamespace("", "staging")
+	assert.NoError(t, err)
+	assert.Equal(t, "jx-staging-x7k2p", targetNS)
+	assert.Equal(t, "staging", env.Name)
+}
+
+func TestPromoteAppURLOverridesDiscoveredURL(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myapp",
+			Namespace: staging.Spec.Namespace,
+			Annotations: map[string]string{
+				kube.ExposeURLAnnotation: "http://myapp.discovered.com",
+			},
+		},
+	}
+	o := &PromoteOptions{
+		Application: "myapp",
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, []runtime.Object{svc}, []runtime.Object{staging}, &gits.GitFake{}, &promoteTestHelmer{})
+	kubeClient, _, err := o.KubeClient()
+	assert.NoError(t, err)
+
+	url, available := o.discoverApplicationURL(kubeClient, staging)
+	assert.Equal(t, "http://myapp.discovered.com", url)
+	assert.Equal(t, " and available [here](http://myapp.discovered.com)", available)
+	url, _, err = o.applicationURL(staging)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://myapp.discovered.com", url)
+
+	o.AppURLs = []string{"https://myapp.example.com"}
+	url, available, err = o.applicationURL(staging)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://myapp.example.com", url)
+	assert.Equal(t, " and available [here](https://myapp.example.com)", available)
+
+	o.AppURLs = []string{"https://myapp.example.com?a=b", "production=https://myapp.com", "staging=https://staging.myapp.com"}
+	url, _, err = o.applicationURL(staging)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://staging.myapp.com", url)
+	assert.Equal(t, "https://myapp.com", o.appURLOverride("production"))
+	assert.Equal(t, "https://myapp.example.com?a=b", o.appURLOverride("dev"))
+
+	// the Pull Request templates use the same URL as the issue comments
+	o.PullRequestBodyTemplate = "Available at {{.URL}}"
+	_, body, err := o.renderPullRequestTemplates(staging, &kube.PromoteStepActivityKey{}, "1.2.3", "", "myapp to 1.2.3", "Promote myapp to version 1.2.3")
+	assert.NoError(t, err)
+	assert.Equal(t, "Available at https://staging.myapp.com", body)
+	o.IssueCommentTemplate = "Deployed to {{.URL}}"
+	comment, err := o.renderIssueComment(staging.Name, "1.2.3", "", url)
+	assert.NoError(t, err)
+	assert.Equal(t, "Deployed to https://staging.myapp.com", comment)
+}
+
+func TestPromoteServiceNameAndSelector(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	newService := func(name string, url string, labels map[string]string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: staging.Spec.Namespace,
+				Labels:    labels,
+				Annotations: map[string]string{
+					kube.ExposeURLAnnotation: url,
+				},
+			},
+		}
+	}
+	services := []runtime.Object{
+		newService("myapp", "http://myapp.discovered.com", nil),
+		newService("myapp-frontend", "http://frontend.discovered.com", nil),
+		newService("web-b", "http://web-b.discovered.com", map[string]string{"tier": "web"}),
+		newService("web-a", "http://web-a.discovered.com", map[string]string{"tier": "web"}),
+	}
+	o := &PromoteOptions{
+		Application: "myapp",
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, services, []runtime.Object{staging}, &gits.GitFake{}, &promoteTestHelmer{})
+	kubeClient, _, err := o.KubeClient()
+	assert.NoError(t, err)
+
+	// the --service-name is tried before the default names
+	o.ServiceNames = []string{"missing", "myapp-frontend"}
+	url, _ := o.discoverApplicationURL(kubeClient, staging)
+	assert.Equal(t, "http://frontend.discovered.com", url)
+
+	// the --service-selector is tried if no --service-name has a URL
+	o.ServiceNames = []string{"missing"}
+	o.ServiceSelector = "tier=web"
+	url, _ = o.discoverApplicationURL(kubeClient, staging)
+	assert.Equal(t, "http://web-a.discovered.com", url)
+
+	o.ServiceSelector = "tier=db"
+	url, _ = o.discoverApplicationURL(kubeClient, staging)
+	assert.Equal(t, "http://myapp.discovered.com", url)
+
+	o.ServiceSelector = "tier in (web"
+	err = o.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--service-selector")
+	}
+}
+
+func TestPromoteOpenShiftRoutes(t *testing.T) {
+	oldFindRouteURL := findRouteURL
+	defer func() {
+		findRouteURL = oldFindRouteURL
+	}()
+	routes := map[string]string{}
+	findRouteURL = func(kubeClient kubernetes.Interface, ns string, name string) (string, error) {
+		url := routes[ns+"/"+name]
+		if url == "" {
+			return "", fmt.Errorf("routes.route.openshift.io \"%s\" not found", name)
+		}
+		return url, nil
+	}
+
+	staging := kube.NewPermanentEnvironment("staging")
+	o := &PromoteOptions{
+		Application: "myapp",
+		ReleaseName: "jx-staging-myapp",
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging}, &gits.GitFake{}, &promoteTestHelmer{})
+	kubeClient, _, err := o.KubeClient()
+	assert.NoError(t, err)
+
+	// neither an ingress nor a route exists
+	url, available := o.discoverApplicationURL(kubeClient, staging)
+	assert.Equal(t, "", url)
+	assert.Equal(t, "", available)
+
+	// routes are only looked up on OpenShift
+	routes[staging.Spec.Namespace+"/jx-staging-myapp"] = "https://myapp-jx-staging.apps.example.com"
+	url, _ = o.discoverApplicationURL(kubeClient, staging)
+	assert.Equal(t, "", url)
+
+	o.Platform = OPENSHIFT
+	url, available = o.discoverApplicationURL(kubeClient, staging)
+	assert.Equal(t, "https://myapp-jx-staging.apps.example.com", url)
+	assert.Equal(t, " and available [here](https://myapp-jx-staging.apps.example.com)", available)
+
+	// OpenShift is detected from the Route API
+	o.Platform = ""
+	kubeClient.(*kubefake.Clientset).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: kube.OpenShiftRouteGroupVersion,
+			APIResources: []metav1.APIResource{
+				{Name: "routes", Kind: "Route", Namespaced: true},
+			},
+		},
+	}
+	url, _ = o.discoverApplicationURL(kubeClient, staging)
+	assert.Equal(t, "https://myapp-jx-staging.apps.example.com", url)
+
+	o.Platform = KUBERNETES
+	url, _ = o.discoverApplicationURL(kubeClient, staging)
+	assert.Equal(t, "", url)
+
+	o.Platform = "mesos"
+	err = o.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "mesos")
+	}
+}
+
+func TestPromoteForceRolloutInjectsNonce(t *testing.T) {
+	o := &PromoteOptions{
+		Application: "myapp",
+	}
+	assert.Empty(t, o.helmSetValues())
+	assert.Nil(t, o.createModifyValuesFn())
+
+	o.ForceRollout = true
+	values1 := o.helmSetValues()
+	values2 := o.helmSetValues()
+	if assert.Len(t, values1, 1) && assert.Len(t, values2, 1) {
+		assert.Contains(t, values1[0], forceRolloutValue+"=")
+		assert.NotEqual(t, values1[0], values2[0], "the nonce should differ on each promotion")
+	}
+
+	values := map[string]interface{}{
+		"myapp": map[string]interface{}{
+			"replicaCount": 2,
+		},
+	}
+	fn := o.createModifyValuesFn()
+	if assert.NotNil(t, fn) {
+		assert.NoError(t, fn(values))
+		appValues := values["myapp"].(map[string]interface{})
+		assert.Equal(t, 2, appValues["replicaCount"])
+		assert.NotEmpty(t, appValues[forceRolloutValue])
+	}
+}
+
+func newPromoteTestPullRequest(statuses map[string][]string) *ReleasePullRequestInfo {
+	number := 1
+	pr := &gits.GitPullRequest{
+		URL:           "https://github.com/jstrachan/environment-production/pull/1",
+		Owner:         "jstrachan",
+		Repo:          "environment-production",
+		Number:        &number,
+		LastCommitSha: "abc123",
+	}
+	provider := &gits.FakeProvider{
+		Repositories: map[string][]*gits.FakeRepository{
+			pr.Owner: {
+				{
+					GitRepo: &gits.GitRepository{
+						Name: pr.Repo,
+					},
+					PullRequests: map[int]*gits.FakePullRequest{
+						number: {
+							PullRequest: pr,
+						},
+					},
+					DeploymentStatuses: statuses,
+				},
+			},
+		},
+	}
+	return &ReleasePullRequestInfo{
+		GitProvider: provider,
+		PullRequest: pr,
+	}
+}
+
+func TestPromoteDeploymentGateWaitsForApproval(t *testing.T) {
+	o := &PromoteOptions{}
+	info := newPromoteTestPullRequest(map[string][]string{
+		"production": {"waiting", "queued", "success"},
+	})
+
+	approved, err := o.deploymentGateApproved(info)
+	assert.NoError(t, err)
+	assert.True(t, approved, "no GitHub environment so there is no gate")
+	assert.Nil(t, info.Deployment)
+
+	o.GitHubEnvironment = "production"
+	for i := 0; i < 2; i++ {
+		approved, err = o.deploymentGateApproved(info)
+		assert.NoError(t, err)
+		assert.False(t, approved)
+	}
+	if assert.NotNil(t, info.Deployment) {
+		assert.Equal(t, "abc123", info.Deployment.Ref)
+		assert.Equal(t, "production", info.Deployment.Environment)
+	}
+	approved, err = o.deploymentGateApproved(info)
+	assert.NoError(t, err)
+	assert.True(t, approved)
+}
+
+func TestPromoteDeploymentGateRejected(t *testing.T) {
+	o := &PromoteOptions{
+		GitHubEnvironment: "production",
+	}
+	info := newPromoteTestPullRequest(map[string][]string{
+		"production": {"waiting", "failure"},
+	})
+
+	approved, err := o.deploymentGateApproved(info)
+	assert.NoError(t, err)
+	assert.False(t, approved)
+
+	approved, err = o.deploymentGateApproved(info)
+	assert.Error(t, err)
+	assert.False(t, approved)
+}
+
+func TestPromoteMergePolicy(t *testing.T) {
+	testCases := []struct {
+		name      string
+		policy    v1.MergePolicy
+		approvers []string
+		status    string
+		ready     bool
+	}{
+		{
+			name:   "immediate does not wait for CI",
+			policy: v1.MergePolicy{Kind: v1.MergePolicyKindImmediate},
+			status: "pending",
+			ready:  true,
+		},
+		{
+			name:   "CI success waits for CI",
+			policy: v1.MergePolicy{Kind: v1.MergePolicyKindCISuccess},
+			status: "pending",
+		},
+		{
+			name:   "CI success merges when CI succeeds",
+			policy: v1.MergePolicy{Kind: v1.MergePolicyKindCISuccess},
+			status: "success",
+			ready:  true,
+		},
+		{
+			name:   "CI success is the default",
+			status: "success",
+			ready:  true,
+		},
+		{
+			name:      "approved waits for CI",
+			policy:    v1.MergePolicy{Kind: v1.MergePolicyKindApproved},
+			approvers: []string{"jstrachan"},
+			status:    "pending",
+		},
+		{
+			name:   "approved waits for an approval",
+			policy: v1.MergePolicy{Kind: v1.MergePolicyKindApproved},
+			status: "success",
+		},
+		{
+			name:      "approved merges when approved",
+			policy:    v1.MergePolicy{Kind: v1.MergePolicyKindApproved},
+			approvers: []string{"jstrachan"},
+			status:    "success",
+			ready:     true,
+		},
+		{
+			name:      "approved waits for the required approvals",
+			policy:    v1.MergePolicy{Kind: v1.MergePolicyKindApproved, RequiredApprovals: 2},
+			approvers: []string{"jstrachan"},
+			status:    "success",
+		},
+	}
+	for _, tc := range testCases {
+		env := kube.NewPermanentEnvironment("production")
+		env.Spec.MergePolicy = tc.policy
+		info := newPromoteTestPullRequest(nil)
+		repo := info.GitProvider.(*gits.FakeProvider).Repositories["jstrachan"][0]
+		repo.PullRequests[1].Approvers = tc.approvers
+
+		o := &PromoteOptions{}
+		ready, err := o.readyToMerge(info, o.mergePolicy(env), tc.status)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.ready, ready, tc.name)
+	}
+
+	// the options override the policy of the environment
+	env := kube.NewPermanentEnvironment("production")
+	env.Spec.MergePolicy = v1.MergePolicy{Kind: v1.MergePolicyKindApproved, RequiredApprovals: 2}
+	o := &PromoteOptions{
+		RequiredApprovals: 3,
+	}
+	assert.Equal(t, v1.MergePolicy{Kind: v1.MergePolicyKindApproved, RequiredApprovals: 3}, o.mergePolicy(env))
+	o.MergePolicy = string(v1.MergePolicyKindImmediate)
+	assert.Equal(t, v1.MergePolicyKindImmediate, o.mergePolicy(env).Kind)
+}
+
+func TestPromotionPlan(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	staging.Spec.Order = 100
+	staging.Spec.Source.URL = "https://github.com/jstrachan/environment-staging.git"
+	production := kube.NewPermanentEnvironment("production")
+	production.Spec.Order = 200
+	production.Spec.PromotionStrategy = v1.PromotionStrategyTypeManual
+	qa := kube.NewPermanentEnvironment("qa")
+	qa.Spec.Order = 150
+	preview := kube.NewPreviewEnvironment("preview")
+	dev := kube.NewPermanentEnvironment("dev")
+	dev.Spec.Namespace = "jx"
+	dev.Spec.Kind = v1.EnvironmentKindTypeDevelopment
+	dev.Spec.PromotionStrategy = v1.PromotionStrategyTypeNever
+
+	helmer := &promoteTestHelmer{
+		versions: map[string][]string{
+			"myapp": {"1.0.0"},
+		},
+	}
+	o := &PromoteOptions{
+		Application: "myapp",
+		Environment: "production",
+		Version:     "1.2.3",
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{dev, production, staging, qa, preview}, &gits.GitFake{}, helmer)
+
+	plan, err := o.PromotionPlan(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []PlannedPromotion{
+		{
+			Environment: "production",
+			Namespace:   "jx-production",
+			Version:     "1.2.3",
+			ReleaseName: "jx-production-myapp",
+		},
+	}, plan)
+
+	o.Environment = ""
+	o.Version = ""
+	o.AllAutomatic = true
+	plan, err = o.PromotionPlan(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []PlannedPromotion{
+		{
+			Environment:    "staging",
+			Namespace:      "jx-staging",
+			Version:        "1.0.0",
+			ReleaseName:    "jx-staging-myapp",
+			ViaPullRequest: true,
+		},
+		{
+			Environment: "qa",
+			Namespace:   "jx-qa",
+			Version:     "1.0.0",
+			ReleaseName: "jx-qa-myapp",
+		},
+	}, plan)
+
+	// computing the plan must not create the target namespace
+	o.AllAutomatic = false
+	o.Namespace = "jx-custom"
+	plan, err = o.PromotionPlan(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []PlannedPromotion{
+		{
+			Namespace:   "jx-custom",
+			Version:     "1.0.0",
+			ReleaseName: "jx-custom-myapp",
+		},
+	}, plan)
+	kubeClient, _, err := o.KubeClient()
+	assert.NoError(t, err)
+	_, err = kubeClient.CoreV1().Namespaces().Get("jx-custom", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	// a version range is resolved without modifying the options or updating the helm repositories
+	o.Namespace = ""
+	o.Environment = "production"
+	o.Version = "^1.0.0"
+	helmer.versions["myapp"] = []string{"1.0.0", "1.4.0", "2.0.0"}
+	plan, err = o.PromotionPlan(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []PlannedPromotion{
+		{
+			Environment: "production",
+			Namespace:   "jx-production",
+			Version:     "1.4.0",
+			ReleaseName: "jx-production-myapp",
+		},
+	}, plan)
+	assert.Equal(t, "^1.0.0", o.Version)
+
+	o.ChartRetries = 3
+	o.Version = "^3.0.0"
+	_, err = o.PromotionPlan(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, "^3.0.0", o.Version)
+	assert.Equal(t, 0, helmer.updates)
+
+	o.Environment = "unknown"
+	_, err = o.PromotionPlan(context.Background())
+	assert.Error(t, err)
+}
+
+func TestPromoteEnvironmentVariableDefaults(t *testing.T) {
+	envVars := map[string]string{
+		envVarPromoteEnvironment:       "staging",
+		envVarPromoteVersion:           "1.2.3",
+		envVarPromoteTimeout:           "5m",
+		envVarPromoteHelmRepositoryURL: "http://chartmuseum.example.com",
+	}
+	f := newPromoteTestFixture(t).setEnv(envVars)
+	defer f.cleanup()
+
+	cmd := NewCmdPromote(nil, os.Stdout, os.Stderr)
+	o := &PromoteOptions{}
+	o.Cmd = cmd
+	o.Timeout = "1h"
+	o.HelmRepositoryURL = helm.DefaultHelmRepositoryURL
+	o.applyEnvironmentVariableDefaults()
+	assert.Equal(t, "staging", o.Environment)
+	assert.Equal(t, "1.2.3", o.Version)
+	assert.Equal(t, "5m", o.Timeout)
+	assert.Equal(t, "http://chartmuseum.example.com", o.HelmRepositoryURL)
+
+	// explicit flags take precedence over the environment variables
+	err := cmd.Flags().Parse([]string{"--env", "production", "--version", "2.0.0", "--timeout", "1h"})
+	assert.NoError(t, err)
+	o = &PromoteOptions{
+		Environment: "production",
+		Version:     "2.0.0",
+		Timeout:     "1h",
+	}
+	o.Cmd = cmd
+	o.applyEnvironmentVariableDefaults()
+	assert.Equal(t, "production", o.Environment)
+	assert.Equal(t, "2.0.0", o.Version)
+	assert.Equal(t, "1h", o.Timeout)
+	assert.Equal(t, "http://chartmuseum.example.com", o.HelmRepositoryURL)
+
+	// without a command any values already specified take precedence
+	o = &PromoteOptions{
+		Version: "3.0.0",
+	}
+	o.applyEnvironmentVariableDefaults()
+	assert.Equal(t, "staging", o.Environment)
+	assert.Equal(t, "3.0.0", o.Version)
+}
+
+func TestPromoteNotifyOnFirstFailureOnly(t *testing.T) {
+	env := kube.NewPermanentEnvironment("staging")
+
+	for _, firstFailureOnly := range []bool{true, false} {
+		// the Pull Request has no commits so querying its status fails on every poll
+		releaseInfo := &ReleaseInfo{
+			PullRequestInfo: newPromoteTestPullRequest(nil),
+		}
+		notifications := map[PromoteNotificationKind]int{}
+		timeout := 500 * time.Millisecond
+		pollTime := 10 * time.Millisecond
+		o := &PromoteOptions{
+			Application:              "myapp",
+			NotifyOnFirstFailureOnly: firstFailureOnly,
+			TimeoutDuration:          &timeout,
+			PullRequestPollDuration:  &pollTime,
+			Notify: func(e *v1.Environment, kind PromoteNotificationKind, message string) error {
+				assert.Equal(t, env, e)
+				notifications[kind]++
+				return nil
+			},
+		}
+		notifier := o.createNotifier(env, releaseInfo)
+		promoteKey := o.createPromoteKey(env)
+		err := o.waitForGitOpsPullRequest(context.Background(), env.Spec.Namespace, env, releaseInfo, time.Now().Add(timeout), timeout, promoteKey, notifier)
+		assert.Error(t, err)
+		notifier.failure(err.Error())
+
+		if firstFailureOnly {
+			assert.Equal(t, 1, notifications[PromoteNotificationFailure])
+		} else {
+			assert.True(t, notifications[PromoteNotificationFailure] > 2, "expected a notification for every failed poll but got %d", notifications[PromoteNotificationFailure])
+		}
+		assert.Equal(t, 0, notifications[PromoteNotificationSuccess])
+
+		// successes are always notified
+		notifier.success("promoted")
+		assert.Equal(t, 1, notifications[PromoteNotificationSuccess])
+	}
+}
+
+const promoteTestDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: myapp
+spec:
+  replicas: 1
+`
+
+func TestPromoteValidateManifests(t *testing.T) {
+	oldValidator := runManifestValidator
+	defer func() {
+		runManifestValidator = oldValidator
+	}()
+	validatorArgs := []string{}
+	validatorErr := error(nil)
+	runManifestValidator = func(validator string, args ...string) (string, error) {
+		validatorArgs = append([]string{validator}, args[:len(args)-1]...)
+		return "", validatorErr
+	}
+
+	helmer := &promoteTestHelmer{
+		manifests: map[string]string{
+			"deployment.yaml": promoteTestDeployment,
+			"service.yaml":    "# a comment\n---\napiVersion: v1\nkind: Service\nmetadata:\n  name: myapp\n",
+		},
+	}
+	o := &PromoteOptions{
+		Application:       "myapp",
+		KubernetesVersion: "1.11.0",
+	}
+	o.helm = helmer
+
+	err := o.validateManifests("releases/myapp", "jx-staging-myapp", "jx-staging", "1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"releases/myapp"}, helmer.fetched)
+	assert.Equal(t, []string{"kubeconform", "-strict", "-summary", "-kubernetes-version", "1.11.0"}, validatorArgs)
+
+	validatorErr = fmt.Errorf("exit status 1")
+	err = o.validateManifests("releases/myapp", "jx-staging-myapp", "jx-staging", "1.0.0")
+	assert.Error(t, err, "the validator rejected the manifests")
+	validatorErr = nil
+
+	helmer.manifests["configmap.yaml"] = "apiVersion: v1\nmetadata:\n  name: config\n"
+	err = o.validateManifests("releases/myapp", "jx-staging-myapp", "jx-staging", "1.0.0")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "kind")
+	}
+
+	helmer.manifests["configmap.yaml"] = "apiVersion: v1\nkind: [ConfigMap\n"
+	err = o.validateManifests("releases/myapp", "jx-staging-myapp", "jx-staging", "1.0.0")
+	assert.Error(t, err)
+}
+
+func TestPromoteRequireIssues(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	release := &v1.Release{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myapp-1.0.0",
+			Namespace: staging.Spec.Namespace,
+		},
+	}
+	o := &PromoteOptions{
+		Application: "myapp",
+		Version:     "1.0.0",
+		GitInfo: &gits.GitRepositoryInfo{
+			Host:         "github.com",
+			Organisation: "jstrachan",
+			Name:         "myapp",
+		},
+		RequireIssues: true,
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging, release}, &gits.GitFake{}, &promoteTestHelmer{})
+
+	err := o.commentOnIssues(staging.Spec.Namespace, staging, o.createPromoteKey(staging))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not reference any issues")
+	}
+
+	o.Version = "2.0.0"
+	err = o.commentOnIssues(staging.Spec.Namespace, staging, o.createPromoteKey(staging))
+	assert.Error(t, err, "the release could not be found")
+
+	release.Spec.Issues = []v1.IssueSummary{
+		{
+			ID:  "123",
+			URL: "https://github.com/jstrachan/myapp/issues/123",
+		},
+	}
+	assert.NoError(t, o.checkReleaseIssues(release.Name, release))
+
+	o.RequireIssues = false
+	assert.NoError(t, o.checkReleaseIssues(release.Name, nil))
+	assert.NoError(t, o.checkReleaseIssues(release.Name, &v1.Release{}))
+}
+
+func TestPromoteRetriesWhenChartNotFound(t *testing.T) {
+	helmer := &promoteTestHelmer{
+		versions: map[string][]string{
+			"myapp": {"1.0.0"},
+		},
+		missingSearches: 2,
+	}
+	o := &PromoteOptions{
+		Application:       "myapp",
+		ChartRetries:      3,
+		ChartRetryBackoff: time.Millisecond,
+	}
+	o.helm = helmer
+
+	requirements := &helm.Requirements{}
+	err := o.createModifyRequirementsFn(context.Background(), "", nil)(requirements)
+	assert.NoError(t, err)
+	assert.Len(t, helmer.searched, 3)
+	assert.Equal(t, 2, helmer.updates, "the helm repositories should be updated before each retry")
+	if assert.Len(t, requirements.Dependencies, 1) {
+		assert.Equal(t, "1.0.0", requirements.Dependencies[0].Version)
+	}
+
+	// the retries are bounded
+	helmer.searched = nil
+	helmer.updates = 0
+	helmer.missingSearches = 10
+	o.ChartRetries = 2
+	err = o.createModifyRequirementsFn(context.Background(), "", nil)(&helm.Requirements{})
+	assert.Error(t, err)
+	assert.Len(t, helmer.searched, 3)
+
+	// other errors are not retried
+	calls := 0
+	err = o.retryOnChartNotFound(context.Background(), "myapp", func() error {
+		calls++
+		return fmt.Errorf("connection refused")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	// the upgrade is retried while helm cannot find the chart version
+	helmer.updates = 0
+	helmer.upgradeErrors = []error{
+		fmt.Errorf("Error: failed to download \"releases/myapp\" (hint: running `helm repo update` may help)"),
+		fmt.Errorf("Error: chart \"myapp\" matching 1.0.0 not found in releases index. (try 'helm repo update'). no chart version found for myapp-1.0.0"),
+	}
+	err = o.upgradeRelease(context.Background(), "releases/myapp", "jx-staging-myapp", "jx-staging", "1.0.0")
+	assert.NoError(t, err)
+	assert.Len(t, helmer.upgrades, 3)
+	assert.Equal(t, 2, helmer.updates)
+
+	// other failures of the upgrade which are not found errors fail on the first attempt
+	for _, message := range []string{
+		"Error: namespaces \"jx-staging\" not found",
+		"Error: secrets \"jx-staging-myapp-tls\" not found",
+		"Error: UPGRADE FAILED: \"jx-staging-myapp\" has no deployed releases",
+		"Error: context \"prod-cluster\" not found",
+	} {
+		helmer.upgrades = nil
+		helmer.updates = 0
+		helmer.upgradeErrors = []error{fmt.Errorf("%s", message)}
+		err = o.upgradeRelease(context.Background(), "releases/myapp", "jx-staging-myapp", "jx-staging", "1.0.0")
+		if assert.Error(t, err, message) {
+			assert.Equal(t, message, err.Error())
+		}
+		assert.Len(t, helmer.upgrades, 1, message)
+		assert.Equal(t, 0, helmer.updates, message)
+	}
+}
+
+func TestPromoteNoHelmUpdateHint(t *testing.T) {
+	helmer := &promoteTestHelmer{
+		versions: map[string][]string{
+			"myapp": {"1.0.0"},
+		},
+		missingSearches: 10,
+	}
+	o := &PromoteOptions{
+		Application:       "myapp",
+		ChartRetries:      1,
+		ChartRetryBackoff: time.Millisecond,
+	}
+	o.helm = helmer
+
+	err := o.createModifyRequirementsFn(context.Background(), "", nil)(&helm.Requirements{})
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "--no-helm-update")
+	}
+
+	o.NoHelmUpdate = true
+	err = o.createModifyRequirementsFn(context.Background(), "", nil)(&helm.Requirements{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Could not find a version of app myapp")
+		assert.Contains(t, err.Error(), "try again without --no-helm-update")
+	}
+	assert.Equal(t, 1, helmer.updates, "the helm repositories are not updated with --no-helm-update")
+
+	// the version is not in the cache
+	err = o.staleHelmCacheHint(&chartVersionNotFoundError{chart: "myapp", version: "2.0.0", versions: []string{"1.0.0"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Could not find version 2.0.0 of app myapp")
+		assert.Contains(t, err.Error(), "try again without --no-helm-update")
+	}
+
+	// other errors do not suggest the cache is out of date
+	err = o.retryOnChartNotFound(context.Background(), "myapp", func() error {
+		return fmt.Errorf("connection refused")
+	})
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "--no-helm-update")
+	}
+}
+
+func TestPromotePullRequestLabels(t *testing.T) {
+	f := newPromoteTestFixture(t).setEnv(map[string]string{"JOB_NAME": "jstrachan/myapp/master", "BUILD_NUMBER": ""})
+	defer f.cleanup()
+
+	production := kube.NewPermanentEnvironment("production")
+	o := &PromoteOptions{
+		Application:               "myapp",
+		PullRequestLabelTemplates: []string{"app:{{.App}}", "env:{{.Environment}}"},
+	}
+	info := newPromoteTestPullRequest(nil)
+
+	err := o.labelPullRequest(production, o.createPromoteKey(production), info, "1.2.3")
+	assert.NoError(t, err)
+	fakePR := info.GitProvider.(*gits.FakeProvider).Repositories["jstrachan"][0].PullRequests[1]
+	assert.Equal(t, []string{"app:myapp", "env:production", "environment/production"}, fakePR.Labels)
+
+	o.NoDefaultPullRequestLabels = true
+	o.PullRequestLabelTemplates = []string{"promote/{{.App}}-{{.Version}}", "pipeline:{{.Pipeline}}", "build:{{.Build}}", "env:{{.Environment}}"}
+	labels, err := o.pullRequestLabels(production, o.createPromoteKey(production), "1.2.3")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"promote/myapp-1.2.3", "pipeline:jstrachan/myapp/master", "env:production"}, labels, "labels without a value should be ignored")
+
+	// only the environment label is added by default
+	o.NoDefaultPullRequestLabels = false
+	o.PullRequestLabelTemplates = nil
+	labels, err = o.pullRequestLabels(production, o.createPromoteKey(production), "1.2.3")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"environment/production"}, labels)
+
+	// the --pr-label labels are added as they are
+	o.PullRequestLabelTemplates = nil
+	o.PullRequestLabels = []string{"promotion", " ", "{{.App}}", "environment/production"}
+	labels, err = o.pullRequestLabels(production, o.createPromoteKey(production), "1.2.3")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"promotion", "{{.App}}", "environment/production"}, labels)
+
+	o.PullRequestLabelTemplates = []string{"{{.Unknown}}"}
+	_, err = o.pullRequestLabels(production, o.createPromoteKey(production), "1.2.3")
+	assert.Error(t, err)
+}
+
+func TestPromotePullRequestReviewers(t *testing.T) {
+	production := kube.NewPermanentEnvironment("production")
+	o := &PromoteOptions{
+		Application: "myapp",
+	}
+	info := newPromoteTestPullRequest(nil)
+	fakePR := info.GitProvider.(*gits.FakeProvider).Repositories["jstrachan"][0].PullRequests[1]
+
+	assert.NoError(t, o.requestPullRequestReviewers(info))
+	assert.Empty(t, fakePR.Reviewers)
+
+	o.PullRequestReviewers = []string{"jstrachan", "jenkins-x/core"}
+	assert.NoError(t, o.validatePullRequestReviewers())
+	assert.NoError(t, o.requestPullRequestReviewers(info))
+	assert.Equal(t, []string{"jstrachan", "jenkins-x/core"}, fakePR.Reviewers)
+
+	// the reviewers are visible in the Pull Request body even if they cannot be requested
+	o.PullRequestBodyTemplate = "Promote {{.App}}{{range .Reviewers}} @{{.}}{{end}}"
+	_, body, err := o.renderPullRequestTemplates(production, o.createPromoteKey(production), "1.2.3", o.CommitSHA, "myapp to 1.2.3", "Promote myapp to version 1.2.3")
+	assert.NoError(t, err)
+	assert.Equal(t, "Promote myapp @jstrachan @jenkins-x/core", body)
+
+	gitea := &gits.GiteaProvider{}
+	err = o.requestPullRequestReviewers(&ReleasePullRequestInfo{GitProvider: gitea, PullRequest: info.PullRequest})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not supported for gitea")
+	}
+
+	o.PullRequestReviewers = []string{"jstrachan", "-bad"}
+	err = o.validatePullRequestReviewers()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--pr-reviewer")
+		assert.Contains(t, err.Error(), "-bad")
+	}
+}
+
+func TestPromoteRequireActivity(t *testing.T) {
+	f := newPromoteTestFixture(t).setEnv(map[string]string{"JOB_NAME": "jstrachan/myapp/master", "BUILD_NUMBER": "", "BUILD_URL": "https://jenkins/job/3/", "BUILD_LOG_URL": "https://jenkins/job/3/console"})
+	defer f.cleanup()
+
+	production := kube.NewPermanentEnvironment("production")
+	o := &PromoteOptions{
+		Application: "myapp",
+	}
+	key := o.createPromoteKey(production)
+	assert.NoError(t, o.validateActivityKey(key), "the activity is only required with --require-activity")
+
+	o.RequireActivity = true
+	err := o.validateActivityKey(key)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "$BUILD_NUMBER")
+	}
+
+	f.setEnv(map[string]string{"BUILD_NUMBER": "3"})
+	key = o.createPromoteKey(production)
+	assert.NoError(t, o.validateActivityKey(key))
+
+	assert.Error(t, o.validateActivityKey(&kube.PromoteStepActivityKey{}))
+}
+
+func TestPromoteReleaseNotesURL(t *testing.T) {
+	production := kube.NewPermanentEnvironment("production")
+	o := &PromoteOptions{
+		Application: "myapp",
+	}
+	assert.Equal(t, "", o.createPromoteKey(production).ReleaseNotesURL)
+
+	o.releaseResource = &v1.Release{
+		Spec: v1.ReleaseSpec{
+			ReleaseNotesURL: "https://github.com/jstrachan/myapp/releases/tag/v1.2.2",
+		},
+	}
+	assert.Equal(t, "https://github.com/jstrachan/myapp/releases/tag/v1.2.2", o.createPromoteKey(production).ReleaseNotesURL)
+
+	o.ReleaseNotesURL = "https://github.com/jstrachan/myapp/releases/tag/v1.2.3"
+	assert.Equal(t, "https://github.com/jstrachan/myapp/releases/tag/v1.2.3", o.createPromoteKey(production).ReleaseNotesURL)
+
+	assert.NoError(t, validateReleaseNotesURL(""))
+	assert.NoError(t, validateReleaseNotesURL(o.ReleaseNotesURL))
+	for _, notesURL := range []string{"releases/v1.2.3", "ftp://example.com/notes", "https://", "http://%zz"} {
+		err := validateReleaseNotesURL(notesURL)
+		if assert.Error(t, err, notesURL) {
+			assert.Contains(t, err.Error(), "--release-notes-url")
+		}
+	}
+}
+
+func TestPromoteNoActivity(t *testing.T) {
+	f := newPromoteTestFixture(t).setEnv(map[string]string{"JOB_NAME": "jstrachan/myapp/master", "BUILD_NUMBER": "3", "BUILD_URL": "https://jenkins/job/3/", "BUILD_LOG_URL": "https://jenkins/job/3/console"})
+	defer f.cleanup()
+
+	env := kube.NewPermanentEnvironment("staging")
+	timeout := 20 * time.Millisecond
+	pollTime := time.Millisecond
+	o := &PromoteOptions{
+		Application:             "myapp",
+		NoActivity:              true,
+		NoMergePullRequest:      true,
+		TimeoutAction:           timeoutActionClose,
+		TimeoutDuration:         &timeout,
+		PullRequestPollDuration: &pollTime,
+	}
+	f.configure(o, []runtime.Object{}, []runtime.Object{env}, nil, nil)
+	jxClient, ns, err := o.JXClient()
+	assert.NoError(t, err)
+	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+
+	promoteKey := o.createActivityKey(env, &ReleaseInfo{})
+	assert.Nil(t, promoteKey)
+	assert.False(t, promoteKey.IsValid())
+	assert.NoError(t, promoteKey.OnPromoteUpdate(o.Activities, kube.StartPromotionUpdate))
+	assert.NoError(t, promoteKey.OnPromotePullRequest(o.Activities, kube.FailedPromotionPullRequest))
+
+	// the promotion still times out and closes the Pull Request without recording it
+	releaseInfo := &ReleaseInfo{
+		PullRequestInfo: newPromoteTestPullRequest(nil),
+	}
+	err = o.WaitForPromotion(context.Background(), env.Spec.Namespace, env, releaseInfo)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Timed out")
+	}
+	assert.True(t, releaseInfo.PullRequestInfo.PullRequest.IsClosed())
+
+	activities, err := o.Activities.List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, activities.Items)
+
+	o.NoActivity = false
+	assert.NotNil(t, o.createActivityKey(env, &ReleaseInfo{}))
+
+	o.NoActivity = true
+	o.RequireActivity = true
+	err = o.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--no-activity")
+	}
+}
+
+func TestPromoteFindLatestVersion(t *testing.T) {
+	testCases := []struct {
+		name               string
+		versions           []string
+		excludePrereleases bool
+		expected           string
+		err                bool
+	}{
+		{
+			name:     "semantic versions",
+			versions: []string{"1.0.0", "1.2.3", "1.10.0", "1.9.9"},
+			expected: "1.10.0",
+		},
+		{
+			name:     "prerelease of a newer version",
+			versions: []string{"1.0.0", "2.0.0-rc.1", "1.2.3", "1.10.0"},
+			expected: "2.0.0-rc.1",
+		},
+		{
+			name:     "release is newer than its prerelease",
+			versions: []string{"2.0.0-rc.1", "2.0.0", "2.0.0-rc.2"},
+			expected: "2.0.0",
+		},
+		{
+			name:     "prerelease included by default",
+			versions: []string{"1.9.0", "2.0.0-beta.1"},
+			expected: "2.0.0-beta.1",
+		},
+		{
+			name:               "prereleases excluded",
+			versions:           []string{"1.9.0", "2.0.0-beta.1"},
+			excludePrereleases: true,
+			expected:           "1.9.0",
+		},
+		{
+			name:               "non semantic versions are not prereleases",
+			versions:           []string{"2.0.0-beta.1", "latest"},
+			excludePrereleases: true,
+			expected:           "latest",
+		},
+		{
+			name:     "semantic versions take precedence over other versions",
+			versions: []string{"latest", "1.0.0", "zzz", "1.2.3"},
+			expected: "1.2.3",
+		},
+		{
+			name:     "only non semantic versions",
+			versions: []string{"abc", "release-2", "release-10"},
+			expected: "release-2",
+		},
+		{
+			name:     "no versions",
+			versions: []string{},
+			err:      true,
+		},
+		{
+			name:               "only prereleases excluded",
+			versions:           []string{"2.0.0-beta.1"},
+			excludePrereleases: true,
+			err:                true,
+		},
+	}
+	for _, tc := range testCases {
+		o := &PromoteOptions{
+			ExcludePrereleases: tc.excludePrereleases,
+		}
+		o.helm = &promoteTestHelmer{
+			versions: map[string][]string{
+				"myapp": tc.versions,
+			},
+		}
+		version, err := o.findLatestVersion("myapp")
+		if tc.err {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.expected, version, tc.name)
+	}
+
+	// excluding the only prerelease of a chart is not retried as the chart was found
+	helmer := &promoteTestHelmer{
+		versions: map[string][]string{
+			"myapp": {"2.0.0-beta.1"},
+		},
+	}
+	o := &PromoteOptions{
+		ExcludePrereleases: true,
+		ChartRetries:       3,
+	}
+	o.helm = helmer
+	err := o.retryOnChartNotFound(context.Background(), "myapp", func() error {
+		_, err := o.findLatestVersion("myapp")
+		return err
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no non-prerelease version of myapp found")
+		assert.False(t, isChartNotFound(err))
+	}
+	assert.Equal(t, []string{"myapp"}, helmer.searched)
+	assert.Equal(t, 0, helmer.updates)
+}
+
+func TestPromoteVersionRange(t *testing.T) {
+	helmer := &promoteTestHelmer{
+		versions: map[string][]string{
+			"myapp": {"1.1.0", "1.2.0", "1.2.5", "1.4.0", "1.4.7", "1.5.0", "2.0.0", "2.1.0-rc.1", "latest"},
+		},
+	}
+	testCases := []struct {
+		version  string
+		expected string
+		err      bool
+	}{
+		{version: "^1.2.0", expected: "1.5.0"},
+		{version: "~1.4", expected: "1.4.7"},
+		{version: ">=1.2.0, <1.4.0", expected: "1.2.5"},
+		{version: "1.x", expected: "1.5.0"},
+		{version: "1.2.0", expected: "1.2.0"},
+		{version: "latest", expected: "latest"},
+		{version: "^3.0.0", err: true},
+	}
+	for _, tc := range testCases {
+		o := &PromoteOptions{
+			Application: "myapp",
+			Version:     tc.version,
+		}
+		o.helm = helmer
+		err := o.resolveVersionRange(context.Background())
+		if tc.err {
+			if assert.Error(t, err, tc.version) {
+				assert.Contains(t, err.Error(), "1.1.0, 1.2.0, 1.2.5", tc.version)
+			}
+			continue
+		}
+		assert.NoError(t, err, tc.version)
+		assert.Equal(t, tc.expected, o.Version, tc.version)
+	}
+}
+
+// promoteTestGitter fails to clone or update the environment git repository
+type promoteTestGitter struct {
+	gits.GitFake
+}
+
+func (g *promoteTestGitter) Clone(url string, directory string) error {
+	return fmt.Errorf("failed to clone %s", url)
+}
+
+func (g *promoteTestGitter) SetRemoteURL(dir string, name string, gitURL string) error {
+	return fmt.Errorf("failed to clone %s", gitURL)
+}
+
+func TestPromoteRunReturnsPromoteError(t *testing.T) {
+	f := newPromoteTestFixture(t)
+	defer f.cleanup()
+	f.jxHome()
+
+	staging := kube.NewPermanentEnvironment("staging")
+	staging.Spec.Source.URL = "https://github.com/jstrachan/environment-staging.git"
+	staging.Spec.PromotionStrategy = v1.PromotionStrategyTypeManual
+
+	timeout := time.Minute
+	pollTime := time.Millisecond
+	o := &PromoteOptions{
+		Application:             "myapp",
+		Environment:             "staging",
+		Version:                 "1.0.0",
+		TimeoutDuration:         &timeout,
+		PullRequestPollDuration: &pollTime,
+	}
+	helmer := &promoteTestHelmer{
+		versions: map[string][]string{
+			"myapp": {"1.0.0"},
+		},
+	}
+	f.configure(o, nil, []runtime.Object{staging}, &promoteTestGitter{}, helmer)
+
+	err := o.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "failed to clone https://github.com/jstrachan/environment-staging.git")
+	}
+}
+
+func TestPromotePromotionPolicy(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	production := kube.NewPermanentEnvironment("production")
+	production.Spec.PromotionStrategy = v1.PromotionStrategyTypeManual
+	production.Spec.PromotionPolicy = v1.PromotionPolicy{
+		AllowedVersions:      []string{">=1.0.0 <2.0.0", "2.0.0-hotfix"},
+		RequiredEnvironments: []string{"staging"},
+	}
+	newRelease := func(version string) *v1.Release {
+		return &v1.Release{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp-" + version,
+				Namespace: staging.Spec.Namespace,
+			},
+		}
+	}
+
+	o := &PromoteOptions{
+		Application: "myapp",
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging, production, newRelease("1.2.0"), newRelease("2.1.0")}, &gits.GitFake{}, &promoteTestHelmer{})
+
+	assert.True(t, production.Spec.PromotionPolicy.IsRestricted())
+	assert.False(t, staging.Spec.PromotionPolicy.IsRestricted())
+
+	err := o.checkPromotionPolicy(production, "1.2.0")
+	assert.NoError(t, err)
+
+	err = o.checkPromotionPolicy(production, "1.3.0")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "must be promoted to environment staging")
+	}
+
+	err = o.checkPromotionPolicy(production, "2.1.0")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is not allowed in environment production")
+	}
+
+	policy := &v1.PromotionPolicy{
+		AllowedVersions: []string{">=1.0.0 <2.0.0", "2.0.0-hotfix", "latest"},
+	}
+	assert.True(t, policy.AllowsVersion("2.0.0-hotfix"))
+	assert.True(t, policy.AllowsVersion("latest"))
+	assert.False(t, policy.AllowsVersion("nightly"))
+	assert.False(t, policy.AllowsVersion("0.9.0"))
+
+	// the promotion is refused before anything is promoted
+	o.Version = "1.3.0"
+	_, err = o.Promote(context.Background(), production.Spec.Namespace, production, false)
+	assert.Error(t, err)
+}
+
+func TestPromoteDryRun(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	staging.Spec.Source.URL = "https://github.com/jstrachan/environment-staging.git"
+	staging.Spec.PromotionStrategy = v1.PromotionStrategyTypeManual
+	test := kube.NewPermanentEnvironment("test")
+	test.Spec.PromotionStrategy = v1.PromotionStrategyTypeManual
+
+	helmer := &promoteTestHelmer{
+		versions: map[string][]string{
+			"myapp": {"1.0.0", "1.2.0"},
+		},
+	}
+	o := &PromoteOptions{
+		Application:       "myapp",
+		LocalHelmRepoName: "releases",
+		DryRun:            true,
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging, test}, &promoteTestGitter{}, helmer)
+	jxClient, ns, err := o.JXClient()
+	assert.NoError(t, err)
+	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+
+	// the Pull Request path does not clone the environment repository
+	releaseInfo, err := o.Promote(context.Background(), staging.Spec.Namespace, staging, true)
+	assert.NoError(t, err)
+	if assert.NotNil(t, releaseInfo) {
+		assert.Nil(t, releaseInfo.PullRequestInfo)
+		assert.Equal(t, "1.2.0", releaseInfo.Version)
+		assert.Equal(t, "releases/myapp", releaseInfo.FullAppName)
+		assert.Equal(t, "jx-staging-myapp", releaseInfo.ReleaseName)
+	}
+
+	// the direct helm path does not update the repositories or upgrade the release
+	o.ReleaseName = ""
+	o.Version = "1.0.0"
+	releaseInfo, err = o.Promote(context.Background(), test.Spec.Namespace, test, true)
+	assert.NoError(t, err)
+	if assert.NotNil(t, releaseInfo) {
+		assert.Nil(t, releaseInfo.PullRequestInfo)
+		assert.Equal(t, "1.0.0", releaseInfo.Version)
+		assert.Equal(t, "jx-test-myapp", releaseInfo.ReleaseName)
+	}
+	assert.Empty(t, helmer.upgrades)
+	assert.Equal(t, 0, helmer.updates)
+
+	// the dry run logs the namespace which is promoted to rather than the namespace of the environment
+	o.ReleaseName = ""
+	logOut := &bytes.Buffer{}
+	restoreLog := log.SetOutput(logOut)
+	_, err = o.Promote(context.Background(), "jx-staging-canary", staging, true)
+	restoreLog()
+	assert.NoError(t, err)
+	assert.Contains(t, logOut.String(), "to environment staging in namespace jx-staging-canary by creating a Pull Request")
+
+	// multiple applications are not promoted via a single Pull Request
+	o.Version = ""
+	o.applications = []applicationVersion{{Name: "myapp", Version: "1.0.0"}, {Name: "myapp"}}
+	releaseInfo, err = o.promoteApplications(context.Background(), staging.Spec.Namespace, staging)
+	assert.NoError(t, err)
+	if assert.NotNil(t, releaseInfo) {
+		assert.Nil(t, releaseInfo.PullRequestInfo)
+		assert.Equal(t, "1.2.0", releaseInfo.Version)
+	}
+	o.applications = nil
+
+	activities, err := o.Activities.List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, activities.Items)
+
+	// the target namespace is not created
+	kubeClient, _, err := o.KubeClient()
+	assert.NoError(t, err)
+	targetNS, _, err := o.GetTargetNamespace("jx-dry-run", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "jx-dry-run", targetNS)
+	_, err = kubeClient.CoreV1().Namespaces().Get("jx-dry-run", metav1.GetOptions{})
+	assert.Error(t, err)
+}
+
+func TestPromoteAlreadyDeployedVersion(t *testing.T) {
+	f := newPromoteTestFixture(t)
+	defer f.cleanup()
+	requirements := &helm.Requirements{
+		Dependencies: []*helm.Dependency{
+			{Name: "myapp", Version: "1.2.0", Repository: "http://chartmuseum"},
+		},
+	}
+	f.stagingRequirements(f.jxHome(), requirements)
+
+	staging := kube.NewPermanentEnvironment("staging")
+	staging.Spec.Source.URL = "https://github.com/jstrachan/environment-staging.git"
+	o := &PromoteOptions{
+		Application:       "myapp",
+		Version:           "1.2.0",
+		HelmRepositoryURL: "http://chartmuseum",
+	}
+	f.configure(o, nil, []runtime.Object{staging}, nil, &promoteTestHelmer{
+		versions: map[string][]string{
+			"myapp": {"1.2.0"},
+		},
+	})
+
+	releaseInfo := &ReleaseInfo{}
+	err := o.PromoteViaPullRequest(context.Background(), staging, releaseInfo)
+	assert.NoError(t, err)
+	assert.True(t, releaseInfo.AlreadyDeployed)
+	assert.Nil(t, releaseInfo.PullRequestInfo)
+	assert.Equal(t, "1.2.0", releaseInfo.Version)
+
+	// a different version is not a no-op
+	err = skipNoopRequirementsFn(o.createModifyRequirementsFn(context.Background(), "1.3.0", nil))(requirements)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.3.0", requirements.FindAppVersion("myapp"))
+	_, noop := skipNoopRequirementsFn(o.createModifyRequirementsFn(context.Background(), "1.3.0", nil))(requirements).(*promoteNoopError)
+	assert.True(t, noop)
+
+	// the promotion is recorded as succeeded as the environment already has the version
+	ps := &v1.PromoteActivityStep{}
+	update := &v1.PromoteUpdateStep{}
+	assert.NoError(t, alreadyDeployedPromotionUpdate(nil, nil, ps, update))
+	assert.Equal(t, v1.ActivityStatusTypeSucceeded, update.Status)
+	assert.Equal(t, "already deployed", update.Description)
+
+	// --allow-noop does not check the versions in the environment
+	o.AllowNoop = true
+	releaseInfo = &ReleaseInfo{}
+	err = o.PromoteViaPullRequest(context.Background(), staging, releaseInfo)
+	assert.NoError(t, err)
+	assert.False(t, releaseInfo.AlreadyDeployed)
+
+	// the key of the promotion is only created once for the activity and the Pull Request templates
+	o.AllowNoop = false
+	o.PullRequestTitleTemplate = "{{.App}} {{.Version}} {{.ReleaseNotesURL}}"
+	gitter := &promoteKeyTestGitter{}
+	f.configure(o, nil, []runtime.Object{staging}, gitter, &promoteTestHelmer{
+		versions: map[string][]string{
+			"myapp": {"1.2.0"},
+		},
+	})
+	releaseInfo, err = o.Promote(context.Background(), staging.Spec.Namespace, staging, false)
+	assert.NoError(t, err)
+	assert.True(t, releaseInfo.AlreadyDeployed)
+	assert.NoError(t, o.WaitForPromotion(context.Background(), staging.Spec.Namespace, staging, releaseInfo))
+	assert.Equal(t, 1, gitter.infos)
+}
+
+// promoteKeyTestGitter counts the lookups of the git repository info which are made each time a promote key is created
+type promoteKeyTestGitter struct {
+	gits.GitFake
+	infos int
+}
+
+func (g *promoteKeyTestGitter) Info(dir string) (*gits.GitRepositoryInfo, error) {
+	g.infos++
+	return g.GitFake.Info(dir)
+}
+
+func TestPromotePrintPlanThenConfirm(t *testing.T) {
+	f := newPromoteTestFixture(t)
+	defer f.cleanup()
+	f.jxHome()
+
+	oldConfirm := confirmPromotionPlan
+	defer func() {
+		confirmPromotionPlan = oldConfirm
+	}()
+	confirmations := 0
+	confirmed := false
+	confirmPromotionPlan = func(message string) (bool, error) {
+		confirmations++
+		return confirmed, nil
+	}
+
+	staging := kube.NewPermanentEnvironment("staging")
+	staging.Spec.Source.URL = "https://github.com/jstrachan/environment-staging.git"
+
+	for _, batchMode := range []bool{false, true} {
+		for _, confirmed = range []bool{false, true} {
+			confirmations = 0
+			out := &bytes.Buffer{}
+			o := &PromoteOptions{
+				Application:          "myapp",
+				Environment:          "staging",
+				PrintPlanThenConfirm: true,
+			}
+			f.configure(o, nil, []runtime.Object{staging}, &promoteTestGitter{}, &promoteTestHelmer{
+				versions: map[string][]string{
+					"myapp": {"1.0.0"},
+				},
+			})
+			o.Out = out
+			o.BatchMode = batchMode
+
+			// the environment is automatic but there is no per environment prompt once the plan is confirmed
+			err := o.Run()
+			assert.Contains(t, out.String(), "jx-staging-myapp")
+			assert.Contains(t, out.String(), "Pull Request")
+			if batchMode {
+				assert.Equal(t, 0, confirmations, "batch mode should not ask for confirmation")
+			} else {
+				assert.Equal(t, 1, confirmations)
+			}
+			if batchMode || confirmed {
+				// the promotion proceeds and fails to clone the environment repository
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), "failed to clone")
+				}
+				assert.Equal(t, "1.0.0", o.Version)
+			} else {
+				// the declined plan is reported as skipped
+				if assert.Error(t, err) {
+					skipped, ok := err.(*PromotionSkippedError)
+					if assert.True(t, ok, "expected a PromotionSkippedError but got: %s", err) {
+						assert.Equal(t, []string{"staging"}, skipped.Environments)
+						assert.Equal(t, PromoteSkippedExitCode, skipped.ExitCode())
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestPromoteConfirmAutomaticEnvironment(t *testing.T) {
+	oldConfirm := confirmAutomaticPromotion
+	oldIsTerminal := stdinIsTerminal
+	defer func() {
+		confirmAutomaticPromotion = oldConfirm
+		stdinIsTerminal = oldIsTerminal
+	}()
+	confirmations := 0
+	confirmAutomaticPromotion = func(message string) (bool, error) {
+		confirmations++
+		return false, nil
+	}
+	isTerminal := false
+	stdinIsTerminal = func() bool {
+		return isTerminal
+	}
+
+	staging := kube.NewPermanentEnvironment("staging")
+	o := &PromoteOptions{}
+
+	// --confirm takes precedence over --batch-mode and the prompt
+	o.Confirm = true
+	confirmed, err := o.automaticPromotionConfirmed(staging)
+	assert.NoError(t, err)
+	assert.True(t, confirmed)
+
+	o.Confirm = false
+	o.BatchMode = true
+	confirmed, err = o.automaticPromotionConfirmed(staging)
+	assert.NoError(t, err)
+	assert.True(t, confirmed)
+	assert.Equal(t, 0, confirmations)
+
+	// without a terminal the promotion fails rather than waiting for an answer
+	o.BatchMode = false
+	_, err = o.automaticPromotionConfirmed(staging)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--confirm")
+	}
+	assert.Equal(t, 0, confirmations)
+
+	isTerminal = true
+	confirmed, err = o.automaticPromotionConfirmed(staging)
+	assert.NoError(t, err)
+	assert.False(t, confirmed)
+	assert.Equal(t, 1, confirmations)
+
+	// a declined promotion is reported as skipped with its own exit code
+	o = &PromoteOptions{
+		Application: "myapp",
+		Environment: "staging",
+		Version:     "1.0.0",
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging}, &gits.GitFake{}, &promoteTestHelmer{
+		versions: map[string][]string{
+			"myapp": {"1.0.0"},
+		},
+	})
+	o.BatchMode = false
+	err = o.Run()
+	if assert.Error(t, err) {
+		skipped, ok := err.(*PromotionSkippedError)
+		if assert.True(t, ok, "unexpected error %s", err) {
+			assert.Equal(t, []string{"staging"}, skipped.Environments)
+			assert.Equal(t, PromoteSkippedExitCode, skipped.ExitCode())
+		}
+	}
+	if assert.Len(t, o.Results, 1) {
+		assert.Equal(t, PromoteResultSkipped, o.Results[0].Status)
+	}
+	exitCode := 0
+	checkErr("", err, func(msg string, code int) {
+		exitCode = code
+	})
+	assert.Equal(t, PromoteSkippedExitCode, exitCode)
+}
+
+func TestPromoteRecordsPreviousVersion(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	deployment := &appsv1beta1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "jx-staging-myapp",
+			Namespace: staging.Spec.Namespace,
+			Labels: map[string]string{
+				"release": "jx-staging-myapp",
+				"version": "1.2.0",
+			},
+		},
+	}
+	o := &PromoteOptions{
+		Application: "myapp",
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, []runtime.Object{deployment}, []runtime.Object{staging}, &gits.GitFake{}, &promoteTestHelmer{})
+	jxClient, ns, err := o.JXClient()
+	assert.NoError(t, err)
+	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+
+	// the previous version is discovered from the running release
+	assert.Equal(t, "1.2.0", o.findDeployedVersion(staging.Spec.Namespace, "jx-staging-myapp"))
+	assert.Equal(t, "", o.findDeployedVersion(staging.Spec.Namespace, "jx-staging-other"))
+
+	// or from the GitOps requirements
+	requirements := &helm.Requirements{}
+	requirements.SetAppVersion("myapp", "1.2.0", "")
+	releaseInfo := &ReleaseInfo{}
+	err = o.createModifyRequirementsFn(context.Background(), "1.3.0", releaseInfo)(requirements)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.3.0", releaseInfo.Version)
+	assert.Equal(t, "1.2.0", releaseInfo.PreviousVersion)
+
+	promoteKey := &kube.PromoteStepActivityKey{
+		PipelineActivityKey: kube.PipelineActivityKey{
+			Name:     "jstrachan-myapp-master-1",
+			Pipeline: "jstrachan/myapp/master",
+			Build:    "1",
+		},
+		Environment: staging.Name,
+	}
+	err = promoteKey.OnPromotePullRequest(o.Activities, func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromotePullRequestStep) error {
+		kube.StartPromotionPullRequest(a, s, ps, p)
+		recordPromoteVersions(a, ps, releaseInfo)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	activity, err := o.Activities.Get("jstrachan-myapp-master-1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "1.3.0", activity.Spec.Version)
+	var promote *v1.PromoteActivityStep
+	for _, step := range activity.Spec.Steps {
+		if step.Promote != nil {
+			promote = step.Promote
+		}
+	}
+	if assert.NotNil(t, promote) {
+		assert.Equal(t, "1.3.0", promote.Version)
+		assert.Equal(t, "1.2.0", promote.PreviousVersion)
+		assert.Contains(t, describePromoteVersion(promote), "→")
+	}
+
+	// the first install has no previous version
+	releaseInfo = &ReleaseInfo{}
+	err = o.createModifyRequirementsFn(context.Background(), "1.0.0", releaseInfo)(&helm.Requirements{})
+	assert.NoError(t, err)
+	assert.Equal(t, "", releaseInfo.PreviousVersion)
+	assert.NotContains(t, describePromoteVersion(&v1.PromoteActivityStep{Version: "1.0.0"}), "→")
+}
+
+func TestPromotePromotedBy(t *testing.T) {
+	f := newPromoteTestFixture(t).setEnv(map[string]string{"USER": "jenkins"})
+	defer f.cleanup()
+
+	gitter := &gits.GitFake{}
+	o := &PromoteOptions{
+		Application: "myapp",
+	}
+	ConfigureTestOptions(&o.CommonOptions, gitter, &promoteTestHelmer{})
+
+	// defaults to $USER if there is no git user email
+	o.resolvePromotedBy()
+	assert.Equal(t, "jenkins", o.PromotedBy)
+
+	o.PromotedBy = ""
+	gitter.UserInfo.Email = "jstrachan@example.com"
+	o.resolvePromotedBy()
+	assert.Equal(t, "jstrachan@example.com", o.PromotedBy)
+
+	o.PromotedBy = " release-bot "
+	o.resolvePromotedBy()
+	assert.Equal(t, "release-bot", o.PromotedBy)
+
+	jxClient, ns, err := o.JXClient()
+	assert.NoError(t, err)
+	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+	promoteKey := &kube.PromoteStepActivityKey{
+		PipelineActivityKey: kube.PipelineActivityKey{
+			Name:     "jstrachan-myapp-master-1",
+			Pipeline: "jstrachan/myapp/master",
+			Build:    "1",
+		},
+		Environment: "production",
+	}
+	releaseInfo := &ReleaseInfo{Version: "1.2.3", PromotedBy: o.PromotedBy}
+	err = promoteKey.OnPromoteUpdate(o.Activities, func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromoteUpdateStep) error {
+		kube.StartPromotionUpdate(a, s, ps, p)
+		recordPromoteVersions(a, ps, releaseInfo)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	activity, err := o.Activities.Get("jstrachan-myapp-master-1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	var promote *v1.PromoteActivityStep
+	for _, step := range activity.Spec.Steps {
+		if step.Promote != nil {
+			promote = step.Promote
+		}
+	}
+	if assert.NotNil(t, promote) {
+		assert.Equal(t, "release-bot", promote.PromotedBy)
+		assert.Contains(t, describePromotedBy(promote), "release-bot")
+	}
+	assert.Equal(t, "", describePromotedBy(&v1.PromoteActivityStep{}))
+}
+
+func TestPromoteEnvironmentRepo(t *testing.T) {
+	f := newPromoteTestFixture(t)
+	defer f.cleanup()
+	jxHome := f.jxHome()
+
+	authConfigSvc := auth.AuthConfigService{FileName: filepath.Join(jxHome, GitAuthConfigFile)}
+	authConfigSvc.SetConfig(&auth.AuthConfig{
+		Servers: []*auth.AuthServer{
+			{
+				URL:   "https://github.com",
+				Kind:  gits.KindGitHub,
+				Users: []*auth.UserAuth{{Username: "jstrachan", ApiToken: "abc123"}},
+			},
+		},
+	})
+	assert.NoError(t, authConfigSvc.SaveConfig())
+
+	staging := kube.NewPermanentEnvironment("staging")
+	staging.Spec.Source.URL = "https://github.com/jstrachan/environment-staging.git"
+	o := &PromoteOptions{
+		EnvironmentRepo: " https://github.com/jstrachan/environment-staging-overrides.git ",
+	}
+	f.configure(o, nil, []runtime.Object{staging}, nil, nil)
+
+	assert.NoError(t, o.validateEnvironmentRepo())
+	assert.Equal(t, "https://github.com/jstrachan/environment-staging-overrides.git", o.EnvironmentRepo)
+	env, err := o.overrideEnvironmentRepo(staging)
+	assert.NoError(t, err)
+	assert.Equal(t, o.EnvironmentRepo, env.Spec.Source.URL)
+	assert.Equal(t, "https://github.com/jstrachan/environment-staging.git", staging.Spec.Source.URL, "the environment is not modified")
+
+	// the repository must be on a git server with credentials
+	o.EnvironmentRepo = "https://gitlab.com/jstrachan/environment-staging-overrides.git"
+	err = o.validateEnvironmentRepo()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "No git credentials found for the server https://gitlab.com")
+	}
+
+	o.EnvironmentRepo = "not a git url"
+	assert.Error(t, o.validateEnvironmentRepo())
+
+	// only permanent environments can be overridden
+	o.EnvironmentRepo = "https://github.com/jstrachan/environment-staging-overrides.git"
+	_, err = o.overrideEnvironmentRepo(kube.NewPreviewEnvironment("myapp-pr-1"))
+	assert.Error(t, err)
+	_, err = o.overrideEnvironmentRepo(nil)
+	assert.Error(t, err)
+}
+
+func newPromoteTestActivity(name string, app string, env string, version string, status v1.ActivityStatusType, completed time.Time) *v1.PipelineActivity {
+	return &v1.PipelineActivity{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "jx",
+		},
+		Spec: v1.PipelineActivitySpec{
+			Pipeline:      "jstrachan/" + app + "/master",
+			GitRepository: app,
+			Version:       version,
+			Steps: []v1.PipelineActivityStep{
+				{
+					Kind: v1.ActivityStepKindTypePromote,
+					Promote: &v1.PromoteActivityStep{
+						CoreActivityStep: v1.CoreActivityStep{
+							Status:             status,
+							CompletedTimestamp: &metav1.Time{Time: completed},
+						},
+						Environment: env,
+						Version:     version,
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestPromoteRollback(t *testing.T) {
+	production := kube.NewPermanentEnvironment("production")
+	production.Spec.PromotionStrategy = v1.PromotionStrategyTypeManual
+	now := time.Now()
+	activities := []runtime.Object{
+		newPromoteTestActivity("a1", "myapp", "production", "1.0.0", v1.ActivityStatusTypeSucceeded, now.Add(-5*time.Hour)),
+		newPromoteTestActivity("a2", "myapp", "production", "1.1.0", v1.ActivityStatusTypeFailed, now.Add(-4*time.Hour)),
+		newPromoteTestActivity("a3", "myapp", "staging", "1.1.5", v1.ActivityStatusTypeSucceeded, now.Add(-3*time.Hour)),
+		newPromoteTestActivity("a4", "other", "production", "1.1.7", v1.ActivityStatusTypeSucceeded, now.Add(-3*time.Hour)),
+		newPromoteTestActivity("a5", "myapp", "production", "1.2.0", v1.ActivityStatusTypeSucceeded, now.Add(-2*time.Hour)),
+	}
+	o := &PromoteOptions{
+		Application: "myapp",
+		Rollback:    true,
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, append(activities, production), &gits.GitFake{}, &promoteTestHelmer{})
+	jxClient, ns, err := o.JXClient()
+	assert.NoError(t, err)
+	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+
+	requirements := &helm.Requirements{}
+	requirements.SetAppVersion("myapp", "1.2.0", "")
+	releaseInfo := &ReleaseInfo{}
+	err = o.createRollbackRequirementsFn(production, releaseInfo)(requirements)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", requirements.FindAppVersion("myapp"), "the failed promotion of 1.1.0 is skipped")
+	assert.Equal(t, "1.0.0", releaseInfo.Version)
+	assert.Equal(t, "1.2.0", releaseInfo.PreviousVersion)
+
+	// there is nothing before the first version
+	_, err = o.findRollbackVersion(production, "1.0.0")
+	assert.Error(t, err)
+
+	// the promotion of the current version is not in the history so we cannot tell what came before it
+	_, err = o.findRollbackVersion(production, "1.3.0")
+	assert.Error(t, err)
+
+	// the app is not deployed
+	err = o.createRollbackRequirementsFn(production, &ReleaseInfo{})(&helm.Requirements{})
+	assert.Error(t, err)
+
+	// rolling back requires a GitOps environment
+	_, err = o.Promote(context.Background(), production.Spec.Namespace, production, true)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--rollback")
+	}
+}
+
+func TestPromoteCommentOnPullRequestOpen(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	production := kube.NewPermanentEnvironment("production")
+	release := &v1.Release{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myapp-1.0.0",
+			Namespace: staging.Spec.Namespace,
+		},
+		Spec: v1.ReleaseSpec{
+			Issues: []v1.IssueSummary{
+				{
+					ID:    "123",
+					URL:   "https://github.com/jstrachan/myapp/issues/123",
+					State: "open",
+				},
+			},
+		},
+	}
+	o := &PromoteOptions{
+		Application: "myapp",
+		Version:     "1.0.0",
+		GitInfo: &gits.GitRepositoryInfo{
+			Host:         "github.com",
+			Organisation: "jstrachan",
+			Name:         "myapp",
+		},
+		RequireIssues:            true,
+		CommentOnPullRequestOpen: true,
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging, production, release}, &gits.GitFake{}, &promoteTestHelmer{})
+
+	// the release is found in the environment it was previously promoted to
+	found := o.findPromotedRelease(release.Name)
+	if assert.NotNil(t, found) {
+		assert.Equal(t, staging.Spec.Namespace, found.Namespace)
+	}
+	assert.Nil(t, o.findPromotedRelease("myapp-2.0.0"))
+
+	// there are no closed issues to comment on and --require-issues is only checked when the fix is deployed
+	assert.False(t, hasClosedIssues(found))
+	assert.NoError(t, o.commentOnPendingIssues(production.Spec.Namespace, production, "https://github.com/jstrachan/environment-production/pull/1"))
+	release.Spec.Issues[0].State = "closed"
+	assert.True(t, hasClosedIssues(release))
+
+	assert.Equal(t, ":hourglass: the fix for this issue is pending deployment to **production** in version 1.0.0 via Pull Request https://github.com/jstrachan/environment-production/pull/1",
+		issuePendingComment("production", "1.0.0", "https://github.com/jstrachan/environment-production/pull/1"))
+	assert.Equal(t, ":white_check_mark: the fix for this issue is now deployed to **production** in version 1.0.0 ",
+		issueDeployedComment("production", "1.0.0", ""))
+}
+
+func TestPromoteCommentOnClosedIssues(t *testing.T) {
+	gitInfo := &gits.GitRepositoryInfo{
+		Host:         "github.com",
+		Organisation: "jstrachan",
+		Name:         "myapp",
+	}
+	provider := &gits.FakeProvider{
+		Repositories: map[string][]*gits.FakeRepository{
+			"jstrachan": {
+				{
+					GitRepo: &gits.GitRepository{
+						Name: "myapp",
+					},
+					Issues: map[int]*gits.FakeIssue{
+						1: {},
+						2: {},
+						4: {},
+					},
+				},
+			},
+		},
+	}
+	issues := []v1.IssueSummary{
+		{ID: "1", URL: "https://github.com/jstrachan/myapp/issues/1", State: "closed"},
+		{ID: "2", URL: "https://github.com/jstrachan/myapp/issues/2", State: "open"},
+		{ID: "abc", URL: "https://github.com/jstrachan/myapp/issues/abc", State: "closed"},
+		{ID: "3", URL: "https://github.com/jstrachan/myapp/issues/3", State: "closed"},
+		{ID: "4", URL: "https://github.com/jstrachan/myapp/issues/4", State: "fixed"},
+	}
+
+	commented, errs := (&PromoteOptions{}).commentOnClosedIssues(nil, provider, gitInfo, issues, "deployed", "is now in staging")
+	assert.Equal(t, 2, commented)
+	if assert.Len(t, errs, 2) {
+		assert.Contains(t, errs[0].Error(), "Could not parse issue id 'abc'")
+		assert.Contains(t, errs[1].Error(), "issues/3")
+	}
+	fakeIssues := provider.Repositories["jstrachan"][0].Issues
+	assert.Equal(t, "deployed", fakeIssues[1].Comment)
+	assert.Equal(t, "", fakeIssues[2].Comment, "open issues are not commented on")
+	assert.Equal(t, "deployed", fakeIssues[4].Comment, "the issues after a failure are still commented on")
+}
+
+func TestPromoteCommentSinceVersion(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	now := time.Now()
+	issue := func(id string) v1.IssueSummary {
+		return v1.IssueSummary{ID: id, URL: "https://github.com/jstrachan/myapp/issues/" + id, State: "closed"}
+	}
+	newRelease := func(version string, issues ...v1.IssueSummary) *v1.Release {
+		return &v1.Release{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp-" + version,
+				Namespace: staging.Spec.Namespace,
+			},
+			Spec: v1.ReleaseSpec{
+				Version: version,
+				Issues:  issues,
+			},
+		}
+	}
+	release := newRelease("1.2.0", issue("1"), issue("2"), issue("3"))
+	jxObjects := []runtime.Object{
+		staging,
+		newRelease("1.0.0", issue("1")),
+		newRelease("1.1.0", issue("1"), issue("2")),
+		release,
+		newPromoteTestActivity("a1", "myapp", "staging", "1.0.0", v1.ActivityStatusTypeSucceeded, now.Add(-3*time.Hour)),
+		newPromoteTestActivity("a2", "myapp", "staging", "1.1.0", v1.ActivityStatusTypeSucceeded, now.Add(-2*time.Hour)),
+		newPromoteTestActivity("a3", "myapp", "staging", "1.1.5", v1.ActivityStatusTypeFailed, now.Add(-1*time.Hour)),
+		newPromoteTestActivity("a4", "myapp", "staging", "1.2.0", v1.ActivityStatusTypeSucceeded, now),
+	}
+	o := &PromoteOptions{
+		Application: "myapp",
+		Version:     "1.2.0",
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, jxObjects, &gits.GitFake{}, &promoteTestHelmer{})
+	issueIDs := func() []string {
+		answer := []string{}
+		for _, issue := range o.issuesSincePreviousVersion(staging.Spec.Namespace, staging, release) {
+			answer = append(answer, issue.ID)
+		}
+		return answer
+	}
+
+	// without any history all of the issues are commented on
+	assert.Equal(t, []string{"1", "2", "3"}, issueIDs())
+
+	// the last version successfully promoted before the current version is found in the history
+	jxClient, ns, err := o.JXClient()
+	assert.NoError(t, err)
+	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+	assert.Equal(t, "1.1.0", o.previouslyPromotedVersion("staging"))
+	assert.Equal(t, []string{"3"}, issueIDs())
+
+	o.CommentSinceVersion = "1.0.0"
+	assert.Equal(t, []string{"2", "3"}, issueIDs())
+
+	// the release of the previous version cannot be found
+	o.CommentSinceVersion = "0.9.0"
+	assert.Equal(t, []string{"1", "2", "3"}, issueIDs())
+}
+
+func TestPromoteIssueCommentTemplate(t *testing.T) {
+	o := &PromoteOptions{
+		IssueCommentTemplate: "Deployed {{.Version}} to {{.Environment}} at {{.URL}}{{if .ReleaseNotesURL}} see {{.ReleaseNotesURL}}{{end}}",
+	}
+	assert.NoError(t, o.validatePullRequestTemplates())
+	comment, err := o.renderIssueComment("staging", "1.0.0", "https://github.com/jstrachan/myapp/releases/tag/v1.0.0", "http://myapp.jx-staging.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "Deployed 1.0.0 to staging at http://myapp.jx-staging.example.com see https://github.com/jstrachan/myapp/releases/tag/v1.0.0", comment)
+
+	comment, err = o.renderIssueComment("staging", "1.0.0", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "Deployed 1.0.0 to staging at ", comment)
+
+	o.IssueCommentTemplate = "{{.Environment"
+	err = o.validatePullRequestTemplates()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--issue-comment-template")
+	}
+
+	o.IssueCommentTemplate = "{{.Ticket}}"
+	_, err = o.renderIssueComment("staging", "1.0.0", "", "")
+	assert.Error(t, err)
+}
+
+func TestPromoteCommentAs(t *testing.T) {
+	authConfigSvc := auth.AuthConfigService{}
+	authConfigSvc.SetConfig(&auth.AuthConfig{
+		Servers: []*auth.AuthServer{
+			{
+				URL:  "https://github.com",
+				Kind: gits.KindGitHub,
+				Users: []*auth.UserAuth{
+					{
+						Username: "jstrachan",
+						ApiToken: "abc",
+					},
+					{
+						Username: "jenkins-x-bot",
+						ApiToken: "def",
+					},
+				},
+				CurrentUser: "jstrachan",
+			},
+		},
+	})
+	gitInfo := &gits.GitRepositoryInfo{
+		Host:         "github.com",
+		Organisation: "jstrachan",
+		Name:         "myapp",
+	}
+	o := &PromoteOptions{
+		CommentAs: "jenkins-x-bot",
+	}
+	o.BatchMode = true
+	ConfigureTestOptions(&o.CommonOptions, &gits.GitFake{}, &promoteTestHelmer{})
+
+	provider, err := o.createIssueCommentProvider(authConfigSvc, gitInfo, gits.KindGitHub)
+	assert.NoError(t, err)
+	if assert.NotNil(t, provider) {
+		assert.Equal(t, "jenkins-x-bot", provider.UserAuth().Username)
+		assert.Equal(t, "def", provider.UserAuth().ApiToken)
+	}
+
+	o.CommentAs = "unknown"
+	_, err = o.createIssueCommentProvider(authConfigSvc, gitInfo, gits.KindGitHub)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown")
+	}
+}
+
+func TestPromoteValidateConfig(t *testing.T) {
+	f := newPromoteTestFixture(t)
+	defer f.cleanup()
+	dir := f.tempDir("test-promote-validate-")
+	configDir := filepath.Join(dir, config.PromoteConfigDir)
+	assert.NoError(t, os.MkdirAll(configDir, util.DefaultWritePermissions))
+	fileName := filepath.Join(configDir, config.PromoteConfigFileName)
+
+	staging := kube.NewPermanentEnvironment("staging")
+	o := &PromoteOptions{
+		Validate: true,
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging}, &gits.GitFake{}, &promoteTestHelmer{})
+
+	err := o.validatePromoteConfig(dir)
+	assert.Error(t, err, "there is no configuration file")
+
+	err = ioutil.WriteFile(fileName, []byte("environments:\n- staging\nreviewers:\n- jstrachan\n"), util.DefaultWritePermissions)
+	assert.NoError(t, err)
+	assert.NoError(t, o.validatePromoteConfig(dir))
+
+	err = ioutil.WriteFile(fileName, []byte("environments:\n- staging\n- production\nstatusContexts:\n- ''\n"), util.DefaultWritePermissions)
+	assert.NoError(t, err)
+	err = o.validatePromoteConfig(dir)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Found 2 problems")
+	}
+}
+
+func TestPromoteConfigDefaults(t *testing.T) {
+	f := newPromoteTestFixture(t)
+	defer f.cleanup()
+	dir := f.tempDir("test-promote-config-")
+	configDir := filepath.Join(dir, config.PromoteConfigDir)
+	assert.NoError(t, os.MkdirAll(configDir, util.DefaultWritePermissions))
+	fileName := filepath.Join(configDir, config.PromoteConfigFileName)
+
+	// there is no configuration so nothing is defaulted
+	o := &PromoteOptions{}
+	assert.NoError(t, o.applyPromoteConfig(dir))
+	assert.Equal(t, "", o.Environment)
+	assert.Empty(t, o.PullRequestReviewers)
+
+	err := ioutil.WriteFile(fileName, []byte("environments:\n- staging\nreviewers:\n- jstrachan\nstatusContexts:\n- ci/build\n"), util.DefaultWritePermissions)
+	assert.NoError(t, err)
+	assert.NoError(t, o.applyPromoteConfig(dir))
+	assert.Equal(t, "staging", o.Environment)
+	assert.False(t, o.AllAutomatic)
+	assert.Equal(t, []string{"jstrachan"}, o.PullRequestReviewers)
+	assert.Equal(t, []string{"ci/build"}, o.RequiredStatusContexts)
+
+	// the options take precedence over the configuration
+	o = &PromoteOptions{
+		Environment:            "production",
+		PullRequestReviewers:   []string{"myorg/release-team"},
+		RequiredStatusContexts: []string{"ci/e2e"},
+	}
+	assert.NoError(t, o.applyPromoteConfig(dir))
+	assert.Equal(t, "production", o.Environment)
+	assert.Equal(t, []string{"myorg/release-team"}, o.PullRequestReviewers)
+	assert.Equal(t, []string{"ci/e2e"}, o.RequiredStatusContexts)
+
+	// several environments are promoted to via --all-auto --only-env
+	err = ioutil.WriteFile(fileName, []byte("environments:\n- staging\n- production\n"), util.DefaultWritePermissions)
+	assert.NoError(t, err)
+	o = &PromoteOptions{}
+	assert.NoError(t, o.applyPromoteConfig(dir))
+	assert.Equal(t, "", o.Environment)
+	assert.True(t, o.AllAutomatic)
+	assert.Equal(t, []string{"staging", "production"}, o.OnlyEnvironments)
+
+	// the Pull Request is only merged once the required status contexts succeed
+	info := newPromoteTestPullRequest(nil)
+	repo := info.GitProvider.(*gits.FakeProvider).Repositories["jstrachan"][0]
+	repo.Commits = []*gits.FakeCommit{
+		{Commit: &gits.GitCommit{SHA: "abc123"}, Status: gits.CommitSatusSuccess, Context: "ci/lint"},
+	}
+	o = &PromoteOptions{
+		RequiredStatusContexts: []string{"ci/build"},
+	}
+	policy := v1.MergePolicy{Kind: v1.MergePolicyKindImmediate}
+	ready, err := o.readyToMerge(info, policy, gitStatusSuccess)
+	assert.NoError(t, err)
+	assert.False(t, ready, "the required status context has not reported yet")
+
+	repo.Commits = append([]*gits.FakeCommit{
+		{Commit: &gits.GitCommit{SHA: "abc123"}, Status: gits.CommitSatusSuccess, Context: "ci/build"},
+	}, repo.Commits...)
+	ready, err = o.readyToMerge(info, policy, gitStatusSuccess)
+	assert.NoError(t, err)
+	assert.True(t, ready)
+
+	repo.Commits = append([]*gits.FakeCommit{
+		{Commit: &gits.GitCommit{SHA: "abc123"}, Status: gits.CommitStatusFailure, Context: "ci/build"},
+	}, repo.Commits...)
+	_, err = o.readyToMerge(info, policy, gitStatusSuccess)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "ci/build")
+	}
+}
+
+func TestPromoteOutput(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	mergeSha := "def456"
+	releaseInfo := &ReleaseInfo{
+		ReleaseName:     "jx-staging-myapp",
+		Version:         "1.2.0",
+		PullRequestInfo: newPromoteTestPullRequest(nil),
+	}
+	releaseInfo.PullRequestInfo.PullRequest.MergeCommitSHA = &mergeSha
+	out := &bytes.Buffer{}
+	o := &PromoteOptions{
+		Application: "myapp",
+		Output:      "json",
+	}
+	o.Out = out
+
+	err := o.printOutput(staging.Spec.Namespace, staging, releaseInfo, nil)
+	assert.NoError(t, err)
+	result := &PromoteOutput{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), result))
+	assert.Equal(t, &PromoteOutput{
+		Application:    "myapp",
+		Version:        "1.2.0",
+		Namespace:      "jx-staging",
+		Environment:    "staging",
+		ReleaseName:    "jx-staging-myapp",
+		PullRequestURL: "https://github.com/jstrachan/environment-production/pull/1",
+		MergeCommitSHA: "def456",
+		Status:         "Succeeded",
+	}, result)
+
+	out.Reset()
+	o.Output = "yaml"
+	err = o.printOutput(staging.Spec.Namespace, staging, nil, fmt.Errorf("timed out"))
+	assert.NoError(t, err)
+	result = &PromoteOutput{}
+	assert.NoError(t, yaml.Unmarshal(out.Bytes(), result))
+	assert.Equal(t, "Failed", result.Status)
+	assert.Equal(t, "timed out", result.Error)
+
+	// the log messages are not written to standard output
+	logOut := &bytes.Buffer{}
+	restoreLog := log.SetOutput(logOut)
+	log.Infof("Promoting app %s\n", "myapp")
+	restoreLog()
+	assert.Equal(t, "Promoting app myapp\n", logOut.String())
+
+	o.Output = "xml"
+	err = o.Run()
+	assert.Error(t, err)
+}
+
+func TestPromoteResult(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	o := &PromoteOptions{
+		Application: "myapp",
+		Version:     "1.2.0",
+	}
+	releaseInfo := &ReleaseInfo{
+		ReleaseName:     "jx-staging-myapp",
+		PullRequestInfo: newPromoteTestPullRequest(nil),
+	}
+
+	// the Pull Request has not merged
+	result := o.promoteResult(staging.Spec.Namespace, staging, releaseInfo, nil)
+	assert.Equal(t, PromoteResultPending, result.Status)
+	assert.Equal(t, "1.2.0", result.Version)
+	assert.Equal(t, "staging", result.Environment)
+	assert.Equal(t, "https://github.com/jstrachan/environment-production/pull/1", result.PullRequestURL)
+
+	mergeSha := "def456"
+	merged := true
+	releaseInfo.PullRequestInfo.PullRequest.Merged = &merged
+	releaseInfo.PullRequestInfo.PullRequest.MergeCommitSHA = &mergeSha
+	result = o.promoteResult(staging.Spec.Namespace, staging, releaseInfo, nil)
+	assert.Equal(t, PromoteResultSucceeded, result.Status)
+	assert.Equal(t, "def456", result.MergeCommitSHA)
+
+	promoteErr := fmt.Errorf("timed out")
+	result = o.promoteResult(staging.Spec.Namespace, staging, nil, promoteErr)
+	assert.Equal(t, PromoteResultFailed, result.Status)
+	assert.Equal(t, promoteErr, result.Error)
+
+	// copies of the options record their results in the results of the original options
+	o.resetResults()
+	appOptions := o.forApplication(applicationVersion{Name: "other", Version: "2.0.0"})
+	o.recordResult(staging.Spec.Namespace, staging, releaseInfo, nil)
+	appOptions.recordResult(staging.Spec.Namespace, staging, nil, promoteErr)
+	o.publishResults()
+	if assert.Len(t, o.Results, 2) {
+		assert.Equal(t, "myapp", o.Results[0].Application)
+		assert.Equal(t, "other", o.Results[1].Application)
+		assert.Equal(t, PromoteResultFailed, o.Results[1].Status)
+	}
+}
+
+func TestPromoteParseApplicationVersions(t *testing.T) {
+	apps, err := parseApplicationVersions([]string{"svc-a", "svc-b"}, "1.4.0")
+	assert.NoError(t, err)
+	assert.Equal(t, []applicationVersion{{Name: "svc-a", Version: "1.4.0"}, {Name: "svc-b", Version: "1.4.0"}}, apps)
+
+	apps, err = parseApplicationVersions([]string{"svc-a@1.4.0", "svc-b@2.0.1", "svc-c"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []applicationVersion{{Name: "svc-a", Version: "1.4.0"}, {Name: "svc-b", Version: "2.0.1"}, {Name: "svc-c"}}, apps)
+
+	// a version matching --version is fine
+	_, err = parseApplicationVersions([]string{"svc-a@1.4.0", "svc-b"}, "1.4.0")
+	assert.NoError(t, err)
+
+	_, err = parseApplicationVersions([]string{"svc-a@1.4.0", "svc-b@2.0.1"}, "1.4.0")
+	assert.Error(t, err)
+
+	for _, arg := range []string{"svc-a@", "@1.4.0"} {
+		_, err = parseApplicationVersions([]string{arg}, "")
+		assert.Error(t, err, arg)
+	}
+}
+
+func TestPromoteMultipleApplicationsRequirements(t *testing.T) {
+	helmer := &promoteTestHelmer{
+		versions: map[string][]string{
+			"svc-c": {"3.0.0", "3.1.0"},
+		},
+	}
+	o := &PromoteOptions{
+		Application:       "svc-a, svc-b, svc-c",
+		HelmRepositoryURL: "http://chartmuseum",
+		applications: []applicationVersion{
+			{Name: "svc-a", Version: "1.4.0"},
+			{Name: "svc-b", Version: "1.4.0"},
+			{Name: "svc-c"},
+		},
+	}
+	o.helm = helmer
+
+	requirements := &helm.Requirements{
+		Dependencies: []*helm.Dependency{
+			{Name: "svc-a", Version: "1.3.0", Repository: "http://chartmuseum"},
+		},
+	}
+	releaseInfo := &ReleaseInfo{}
+	err := o.createModifyApplicationsRequirementsFn(context.Background(), releaseInfo)(requirements)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.4.0", requirements.FindAppVersion("svc-a"))
+	assert.Equal(t, "1.4.0", requirements.FindAppVersion("svc-b"))
+	assert.Equal(t, "3.1.0", requirements.FindAppVersion("svc-c"))
+	assert.Len(t, requirements.Dependencies, 3)
+	assert.Equal(t, "3.1.0", o.applications[2].Version)
+	assert.Equal(t, "", releaseInfo.Version)
+
+	// multiple applications cannot be combined with --all-auto
+	o = &PromoteOptions{
+		Version:      "1.4.0",
+		Args:         []string{"svc-a@1.4.0", "svc-b"},
+		AllAutomatic: true,
+	}
+	err = o.Run()
+	assert.Error(t, err)
+}
+
+func TestPromoteApplicationPatterns(t *testing.T) {
+	helmer := &promoteTestHelmer{
+		versions: map[string][]string{
+			"releases/payments-api":    {"1.2.0"},
+			"releases/payments-worker": {"1.2.0"},
+			"releases/orders":          {"1.2.0"},
+			"other/payments-ui":        {"1.2.0"},
+		},
+	}
+	o := &PromoteOptions{
+		LocalHelmRepoName: "releases",
+	}
+	o.helm = helmer
+
+	apps, err := o.expandApplicationPatterns(context.Background(), []applicationVersion{
+		{Name: "payments-*", Version: "1.2.0"},
+		{Name: "orders", Version: "1.3.0"},
+		{Name: "payments-api", Version: "1.1.0"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []applicationVersion{
+		{Name: "payments-worker", Version: "1.2.0"},
+		{Name: "orders", Version: "1.3.0"},
+		{Name: "payments-api", Version: "1.1.0"},
+	}, apps)
+	assert.Equal(t, []string{"releases/payments-"}, helmer.searched)
+	assert.Equal(t, 1, helmer.updates)
+
+	// the names of the applications are not expanded
+	apps, err = o.expandApplicationPatterns(context.Background(), []applicationVersion{{Name: "orders"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []applicationVersion{{Name: "orders"}}, apps)
+	assert.Len(t, helmer.searched, 1)
+
+	_, err = o.expandApplicationPatterns(context.Background(), []applicationVersion{{Name: "billing-*"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "The application pattern billing-* does not match any charts")
+	}
+	_, err = o.expandApplicationPatterns(context.Background(), []applicationVersion{{Name: "payments-[a"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Invalid application pattern payments-[a")
+	}
+
+	// a pattern matching a single chart promotes that application
+	o = &PromoteOptions{
+		Args:              []string{"*-worker"},
+		Version:           "1.2.0",
+		LocalHelmRepoName: "releases",
+		NoHelmUpdate:      true,
+		AllAutomatic:      true,
+		Rollback:          true,
+	}
+	o.helm = helmer
+	err = o.Run()
+	assert.Error(t, err)
+	assert.Equal(t, "payments-worker", o.Application)
+}
+
+func TestPromoteSlackNotification(t *testing.T) {
+	messages := []*slackWebhookMessage{}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		message := &slackWebhookMessage{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(message))
+		messages = append(messages, message)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	staging := kube.NewPermanentEnvironment("staging")
+	releaseInfo := &ReleaseInfo{
+		Version:         "1.2.0",
+		PullRequestInfo: newPromoteTestPullRequest(nil),
+	}
+	notifications := 0
+	o := &PromoteOptions{
+		Application:     "myapp",
+		SlackWebhookURL: server.URL,
+		Notify: func(e *v1.Environment, kind PromoteNotificationKind, message string) error {
+			notifications++
+			return nil
+		},
+	}
+	notifier := o.createNotifier(staging, releaseInfo)
+	notifier.success("Promoted myapp")
+	notifier.failure("Pull Request closed")
+
+	assert.Equal(t, 2, notifications)
+	if assert.Len(t, messages, 2) {
+		success := messages[0].Attachments[0]
+		assert.Equal(t, "good", success.Color)
+		assert.Equal(t, "Promoted myapp 1.2.0 to staging", success.Title)
+		assert.Equal(t, "https://github.com/jstrachan/environment-production/pull/1", success.TitleLink)
+		assert.Len(t, success.Fields, 4)
+
+		failure := messages[1].Attachments[0]
+		assert.Equal(t, "danger", failure.Color)
+		assert.Equal(t, "Failed to promote myapp 1.2.0 to staging", failure.Title)
+		assert.Equal(t, "Pull Request closed", failure.Text)
+	}
+
+	// a failure to post to Slack is not fatal
+	status = http.StatusInternalServerError
+	err := postSlackWebhook(server.URL, o.createSlackMessage(staging, releaseInfo, PromoteNotificationSuccess, ""))
+	assert.Error(t, err)
+	notifier.success("Promoted myapp")
+	assert.Equal(t, 3, notifications)
+}
+
+func TestPromoteMergeRetries(t *testing.T) {
+	env := kube.NewPermanentEnvironment("production")
+	failures := 0
+	o := &PromoteOptions{
+		Application:        "myapp",
+		MergeRetries:       2,
+		MergeRetryInterval: time.Millisecond,
+		Notify: func(e *v1.Environment, kind PromoteNotificationKind, message string) error {
+			failures++
+			return nil
+		},
+	}
+
+	info := newPromoteTestPullRequest(nil)
+	err := o.mergePullRequest(context.Background(), info, o.createNotifier(env, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, failures)
+
+	// the Pull Request no longer exists so every merge attempt fails
+	err = o.mergePullRequest(context.Background(), info, o.createNotifier(env, nil))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "after 3 attempts")
+	assert.Equal(t, 3, failures)
+
+	// conflicts are resolved by rebasing rather than retrying the merge
+	failures = 0
+	mergeable := false
+	info.PullRequest.Mergeable = &mergeable
+	err = o.mergePullRequest(context.Background(), info, o.createNotifier(env, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failures)
+}
+
+func TestPromoteManifest(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	staging.Spec.PromotionStrategy = v1.PromotionStrategyTypeManual
+	production := kube.NewPermanentEnvironment("production")
+	production.Spec.PromotionStrategy = v1.PromotionStrategyTypeManual
+
+	f := newPromoteTestFixture(t)
+	defer f.cleanup()
+	dir := f.tempDir("test-promote-manifest-")
+	writeManifest := func(name string, text string) string {
+		fileName := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(fileName, []byte(text), util.DefaultWritePermissions))
+		return fileName
+	}
+
+	o := &PromoteOptions{
+		DryRun: true,
+	}
+	f.configure(o, nil, []runtime.Object{staging, production}, nil, nil)
+
+	trainFileName := writeManifest("train.yaml", `- app: svc-a
+  version: 1.0.0
+  env: staging
+- app: svc-b
+  version: 2.1.0
+  env: production
+`)
+	fileName := trainFileName
+	entries, err := o.LoadPromoteManifest(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, []PromoteManifestEntry{
+		{App: "svc-a", Version: "1.0.0", Env: "staging"},
+		{App: "svc-b", Version: "2.1.0", Env: "production"},
+	}, entries)
+
+	logOut := &bytes.Buffer{}
+	restoreLog := log.SetOutput(logOut)
+	err = o.PromoteManifest(context.Background(), fileName)
+	restoreLog()
+	assert.NoError(t, err)
+	logs := logOut.String()
+	svcA := strings.Index(logs, "Promoting app svc-a version 1.0.0 to namespace jx-staging")
+	svcB := strings.Index(logs, "Promoting app svc-b version 2.1.0 to namespace jx-production")
+	assert.True(t, svcA >= 0 && svcB > svcA, "expected the promotions in the manifest order but got: %s", logs)
+
+	// all of the unknown environments are reported before promoting anything
+	fileName = writeManifest("unknown.yaml", `- app: svc-a
+  env: staging
+- app: svc-b
+  env: qa
+- app: svc-c
+  env: prod
+`)
+	err = o.PromoteManifest(context.Background(), fileName)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown environments qa, prod")
+	}
+
+	fileName = writeManifest("invalid.yaml", `- app: svc-a
+`)
+	_, err = o.LoadPromoteManifest(fileName)
+	assert.Error(t, err)
+
+	o.Environment = "staging"
+	err = o.PromoteManifest(context.Background(), fileName)
+	assert.Error(t, err)
+
+	// the pipeline wide JX_PROMOTE_ENV and JX_PROMOTE_VERSION do not conflict with the manifest
+	f.setEnv(map[string]string{envVarPromoteEnvironment: "production", envVarPromoteVersion: "9.9.9"})
+	o = &PromoteOptions{
+		Manifest: trainFileName,
+		DryRun:   true,
+	}
+	f.configure(o, nil, []runtime.Object{staging, production}, nil, nil)
+	logOut.Reset()
+	restoreLog = log.SetOutput(logOut)
+	err = o.Run()
+	restoreLog()
+	assert.NoError(t, err)
+	assert.Contains(t, logOut.String(), "Promoting app svc-a version 1.0.0 to namespace jx-staging")
+	assert.Equal(t, "", o.Environment)
+	assert.Equal(t, "", o.Version)
+}
+
+func TestPromotePullRequestTemplates(t *testing.T) {
+	f := newPromoteTestFixture(t).setEnv(map[string]string{"JOB_NAME": "jstrachan/myapp/master", "BUILD_NUMBER": "3"})
+	defer f.cleanup()
+
+	production := kube.NewPermanentEnvironment("production")
+	o := &PromoteOptions{
+		Application: "myapp",
+	}
+	gitter := &gits.GitFake{
+		RepoInfo: gits.GitRepositoryInfo{
+			Organisation: "jstrachan",
+			Name:         "myapp",
+		},
+	}
+	ConfigureTestOptions(&o.CommonOptions, gitter, &promoteTestHelmer{})
+	o.releaseResource = &v1.Release{
+		Spec: v1.ReleaseSpec{
+			ReleaseNotesURL: "https://github.com/jstrachan/myapp/releases/tag/v1.2.3",
+		},
+	}
+
+	// the defaults are used without templates
+	title, body, err := o.renderPullRequestTemplates(production, o.createPromoteKey(production), "1.2.3", o.CommitSHA, "myapp to 1.2.3", "Promote myapp to version 1.2.3")
+	assert.NoError(t, err)
+	assert.Equal(t, "myapp to 1.2.3", title)
+	assert.Equal(t, "Promote myapp to version 1.2.3", body)
+
+	o.PullRequestTitleTemplate = "chore(release): {{.App}} {{.Version}} to {{.Environment}}"
+	o.PullRequestBodyTemplate = "Release of {{.GitInfo.Organisation}}/{{.GitInfo.Name}}\n\nChangelog: {{.ReleaseNotesURL}}"
+	title, body, err = o.renderPullRequestTemplates(production, o.createPromoteKey(production), "1.2.3", o.CommitSHA, "myapp to 1.2.3", "Promote myapp to version 1.2.3")
+	assert.NoError(t, err)
+	assert.Equal(t, "chore(release): myapp 1.2.3 to production", title)
+	assert.Equal(t, "Release of jstrachan/myapp\n\nChangelog: https://github.com/jstrachan/myapp/releases/tag/v1.2.3", body)
+	assert.NoError(t, o.validatePullRequestTemplates())
+
+	// only the title is templated
+	o.PullRequestBodyTemplate = ""
+	_, body, err = o.renderPullRequestTemplates(production, o.createPromoteKey(production), "1.2.3", o.CommitSHA, "myapp to 1.2.3", "Promote myapp to version 1.2.3")
+	assert.NoError(t, err)
+	assert.Equal(t, "Promote myapp to version 1.2.3", body)
+
+	o.PullRequestTitleTemplate = "{{.App"
+	assert.Error(t, o.validatePullRequestTemplates())
+
+	o.PullRequestTitleTemplate = "{{.Ticket}}"
+	_, _, err = o.renderPullRequestTemplates(production, o.createPromoteKey(production), "1.2.3", o.CommitSHA, "myapp to 1.2.3", "Promote myapp to version 1.2.3")
+	assert.Error(t, err)
+
+	o.PullRequestTitleTemplate = "{{if false}}x{{end}}"
+	_, _, err = o.renderPullRequestTemplates(production, o.createPromoteKey(production), "1.2.3", o.CommitSHA, "myapp to 1.2.3", "Promote myapp to version 1.2.3")
+	assert.Error(t, err, "an empty title is invalid")
+}
+
+func TestPromoteMergeMethod(t *testing.T) {
+	env := kube.NewPermanentEnvironment("production")
+	env.Spec.Source.URL = "https://github.com/jstrachan/environment-production.git"
+	o := &PromoteOptions{
+		Application: "myapp",
+		MergeMethod: gits.MergeMethodSquash,
+	}
+	ConfigureTestOptions(&o.CommonOptions, &gits.GitFake{}, &promoteTestHelmer{})
+	assert.NoError(t, o.validateMergeMethod(env))
+
+	info := newPromoteTestPullRequest(nil)
+	fakePR := info.GitProvider.(*gits.FakeProvider).Repositories["jstrachan"][0].PullRequests[1]
+	err := o.mergePullRequest(context.Background(), info, o.createNotifier(env, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, gits.MergeMethodSquash, fakePR.MergeMethod)
+
+	// bitbucket cloud cannot rebase so the promotion fails before the Pull Request is created
+	env.Spec.Source.URL = "https://bitbucket.org/jstrachan/environment-production.git"
+	o.MergeMethod = gits.MergeMethodRebase
+	err = o.validateMergeMethod(env)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Supported methods: merge, squash")
+	}
+	err = o.PromoteViaPullRequest(context.Background(), env, &ReleaseInfo{})
+	assert.Error(t, err)
+
+	o.MergeMethod = "fast-forward"
+	err = o.Run()
+	assert.Error(t, err)
+}
+
+func TestPromoteAllAutomaticSkipEnvironments(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	staging.Spec.Order = 100
+	stagingEU := kube.NewPermanentEnvironment("staging-eu")
+	stagingEU.Spec.Order = 100
+	production := kube.NewPermanentEnvironment("production")
+	production.Spec.Order = 200
+
+	o := &PromoteOptions{
+		Application:      "myapp",
+		Version:          "1.2.0",
+		AllAutomatic:     true,
+		DryRun:           true,
+		SkipEnvironments: []string{"staging-eu", "stagin"},
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging, stagingEU, production}, &gits.GitFake{}, &promoteTestHelmer{})
+
+	environments, skipped, unknown := o.skipEnvironments([]v1.Environment{*staging, *stagingEU, *production})
+	assert.Len(t, environments, 2)
+	assert.Equal(t, []string{"staging-eu"}, skipped)
+	assert.Equal(t, []string{"stagin"}, unknown)
+
+	logOut := &bytes.Buffer{}
+	restoreLog := log.SetOutput(logOut)
+	err := o.PromoteAllAutomatic(context.Background())
+	restoreLog()
+	assert.NoError(t, err)
+	logs := logOut.String()
+	assert.Contains(t, logs, "Skipping environments staging-eu")
+	assert.Contains(t, logs, "no environment called stagin")
+	assert.Contains(t, logs, "to namespace jx-staging\n")
+	assert.Contains(t, logs, "to namespace jx-production\n")
+	assert.NotContains(t, logs, "to namespace jx-staging-eu")
+	promoted := []string{}
+	for _, result := range o.Results {
+		assert.Equal(t, PromoteResultSkipped, result.Status, result.Environment)
+		promoted = append(promoted, result.Environment)
+	}
+	assert.Equal(t, []string{"dev", "staging", "production"}, promoted)
+
+	plan, err := o.PromotionPlan(context.Background())
+	assert.NoError(t, err)
+	planned := []string{}
+	for _, p := range plan {
+		planned = append(planned, p.Environment)
+	}
+	assert.Equal(t, []string{"dev", "staging", "production"}, planned, "the test resources include an automatic dev environment")
+
+	// skipping every environment is not the same as having no environments
+	o.SkipEnvironments = []string{"dev", "staging", "staging-eu", "production"}
+	logOut.Reset()
+	restoreLog = log.SetOutput(logOut)
+	err = o.PromoteAllAutomatic(context.Background())
+	restoreLog()
+	assert.NoError(t, err)
+	assert.Contains(t, logOut.String(), "are skipped so there is nothing to promote to")
+}
+
+func TestPromoteAllAutomaticAppLocks(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	production := kube.NewPermanentEnvironment("production")
+	newOptions := func(app string, searching func(chart string)) *PromoteOptions {
+		o := &PromoteOptions{
+			Application:  app,
+			AllAutomatic: true,
+			DryRun:       true,
+		}
+		ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging, production}, &gits.GitFake{}, &promoteTestHelmer{
+			versions:  map[string][]string{app: {"1.0.0"}},
+			searching: searching,
+		})
+		return o
+	}
+	promoteConcurrently := func(options ...*PromoteOptions) []error {
+		errs := make([]error, len(options))
+		var wg sync.WaitGroup
+		for i, o := range options {
+			wg.Add(1)
+			go func(i int, o *PromoteOptions) {
+				defer wg.Done()
+				errs[i] = o.PromoteAllAutomatic(context.Background())
+			}(i, o)
+		}
+		wg.Wait()
+		return errs
+	}
+	restoreLog := log.SetOutput(ioutil.Discard)
+	defer restoreLog()
+
+	// the promotions of different applications overlap as each resolves its version while the other is promoting
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	searching := func(chart string) {
+		started <- chart
+		<-release
+	}
+	done := make(chan []error)
+	go func() {
+		done <- promoteConcurrently(newOptions("myapp", searching), newOptions("otherapp", searching))
+	}()
+	overlapping := []string{}
+	for len(overlapping) < 2 {
+		select {
+		case chart := <-started:
+			overlapping = append(overlapping, chart)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the promotions of different applications did not overlap, only %v started", overlapping)
+		}
+	}
+	close(release)
+	assert.ElementsMatch(t, []string{"myapp", "otherapp"}, overlapping)
+	for _, err := range <-done {
+		assert.NoError(t, err)
+	}
+
+	// the promotions of the same application are serialized
+	var mutex sync.Mutex
+	running := 0
+	maxRunning := 0
+	searches := 0
+	searching = func(chart string) {
+		mutex.Lock()
+		running++
+		searches++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+	}
+	for _, err := range promoteConcurrently(newOptions("myapp", searching), newOptions("myapp", searching)) {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, maxRunning)
+	assert.True(t, searches >= 4, "expected each promotion to resolve the version of each environment but there were %d searches", searches)
+	assert.Equal(t, 0, appPromotionLocks.Len(), "the locks of the applications are removed once they are promoted")
+}
+
+func TestPromoteAllAutomaticOnlyEnvironments(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	staging.Spec.Order = 100
+	canary := kube.NewPermanentEnvironment("canary")
+	canary.Spec.Order = 150
+	production := kube.NewPermanentEnvironment("production")
+	production.Spec.Order = 200
+	manual := kube.NewPermanentEnvironment("manual")
+	manual.Spec.PromotionStrategy = v1.PromotionStrategyTypeManual
+
+	o := &PromoteOptions{
+		Application:      "myapp",
+		Version:          "1.2.0",
+		AllAutomatic:     true,
+		DryRun:           true,
+		OnlyEnvironments: []string{"canary", "staging"},
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging, canary, production, manual}, &gits.GitFake{}, &promoteTestHelmer{})
+
+	logOut := &bytes.Buffer{}
+	restoreLog := log.SetOutput(logOut)
+	err := o.PromoteAllAutomatic(context.Background())
+	restoreLog()
+	assert.NoError(t, err)
+	logs := logOut.String()
+	stagingIdx := strings.Index(logs, "to namespace jx-staging\n")
+	canaryIdx := strings.Index(logs, "to namespace jx-canary\n")
+	assert.True(t, stagingIdx >= 0 && canaryIdx > stagingIdx, "expected staging then canary to be promoted but got: %s", logs)
+	assert.NotContains(t, logs, "jx-production")
+
+	plan, err := o.PromotionPlan(context.Background())
+	assert.NoError(t, err)
+	planned := []string{}
+	for _, p := range plan {
+		planned = append(planned, p.Environment)
+	}
+	assert.Equal(t, []string{"staging", "canary"}, planned)
+
+	for _, names := range [][]string{{"staging", "qa"}, {"manual"}} {
+		o.OnlyEnvironments = names
+		err = o.PromoteAllAutomatic(context.Background())
+		assert.Error(t, err, "%v", names)
+		_, err = o.PromotionPlan(context.Background())
+		assert.Error(t, err, "%v", names)
+	}
+}
+
+func TestPromoteAllAutomaticEnvironmentOrder(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	staging.Spec.Order = 100
+	canary := kube.NewPermanentEnvironment("canary")
+	canary.Spec.Order = 150
+	production := kube.NewPermanentEnvironment("production")
+	production.Spec.Order = 200
+	manual := kube.NewPermanentEnvironment("manual")
+	manual.Spec.PromotionStrategy = v1.PromotionStrategyTypeManual
+
+	o := &PromoteOptions{
+		Application:      "myapp",
+		Version:          "1.2.0",
+		AllAutomatic:     true,
+		DryRun:           true,
+		EnvironmentOrder: []string{"canary", "manual"},
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging, canary, production, manual}, &gits.GitFake{}, &promoteTestHelmer{})
+
+	logOut := &bytes.Buffer{}
+	restoreLog := log.SetOutput(logOut)
+	err := o.PromoteAllAutomatic(context.Background())
+	restoreLog()
+	assert.NoError(t, err)
+	logs := logOut.String()
+	canaryIdx := strings.Index(logs, "to namespace jx-canary\n")
+	stagingIdx := strings.Index(logs, "to namespace jx-staging\n")
+	productionIdx := strings.Index(logs, "to namespace jx-production\n")
+	assert.True(t, canaryIdx >= 0 && stagingIdx > canaryIdx && productionIdx > stagingIdx, "expected canary, staging then production to be promoted but got: %s", logs)
+	assert.NotContains(t, logs, "jx-manual", "listing an environment does not promote to it if it is not automatic")
+
+	plan, err := o.PromotionPlan(context.Background())
+	assert.NoError(t, err)
+	planned := []string{}
+	for _, p := range plan {
+		planned = append(planned, p.Environment)
+	}
+	assert.Equal(t, []string{"canary", "dev", "staging", "production"}, planned)
+
+	o.EnvironmentOrder = []string{"canary", "qa"}
+	err = o.PromoteAllAutomatic(context.Background())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "there is no environment called qa")
+	}
+	_, err = o.PromotionPlan(context.Background())
+	assert.Error(t, err)
+}
+
+func TestPromoteAllAutomaticEnvironmentSelector(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	staging.Spec.Order = 100
+	staging.Labels = map[string]string{"region": "eu"}
+	stagingUS := kube.NewPermanentEnvironment("staging-us")
+	stagingUS.Spec.Order = 100
+	stagingUS.Labels = map[string]string{"region": "us"}
+	production := kube.NewPermanentEnvironment("production")
+	production.Spec.Order = 200
+	production.Labels = map[string]string{"region": "eu"}
+
+	o := &PromoteOptions{
+		Application:         "myapp",
+		Version:             "1.2.0",
+		AllAutomatic:        true,
+		DryRun:              true,
+		EnvironmentSelector: "region=eu",
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging, stagingUS, production}, &gits.GitFake{}, &promoteTestHelmer{})
+
+	logOut := &bytes.Buffer{}
+	restoreLog := log.SetOutput(logOut)
+	err := o.PromoteAllAutomatic(context.Background())
+	restoreLog()
+	assert.NoError(t, err)
+	logs := logOut.String()
+	assert.Contains(t, logs, "to namespace jx-staging\n")
+	assert.Contains(t, logs, "to namespace jx-production\n")
+	assert.NotContains(t, logs, "jx-staging-us")
+
+	plan, err := o.PromotionPlan(context.Background())
+	assert.NoError(t, err)
+	planned := []string{}
+	for _, p := range plan {
+		planned = append(planned, p.Environment)
+	}
+	assert.Equal(t, []string{"staging", "production"}, planned)
+
+	o.EnvironmentSelector = "region=apac"
+	logOut.Reset()
+	restoreLog = log.SetOutput(logOut)
+	err = o.PromoteAllAutomatic(context.Background())
+	restoreLog()
+	assert.NoError(t, err)
+	assert.Contains(t, logOut.String(), "No Environments in team jx match the --env-selector region=apac")
+
+	o.EnvironmentSelector = "region in (eu"
+	err = o.PromoteAllAutomatic(context.Background())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Invalid --env-selector")
+	}
+
+	o.EnvironmentSelector = "region=eu"
+	o.AllAutomatic = false
+	o.Environment = "staging"
+	err = o.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "can only be specified with --all-auto")
+	}
+}
+
+func TestPromoteRequireApproval(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	production := kube.NewPermanentEnvironment("production")
+	production.Labels = map[string]string{kube.LabelProtected: "true"}
+	pollTime := time.Millisecond
+	o := &PromoteOptions{
+		Application:             "myapp",
+		RequireApproval:         true,
+		PullRequestPollDuration: &pollTime,
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, []runtime.Object{}, []runtime.Object{staging, production}, &gits.GitFake{}, &promoteTestHelmer{})
+	jxClient, ns, err := o.JXClient()
+	assert.NoError(t, err)
+	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+
+	promoteKey := &kube.PromoteStepActivityKey{
+		PipelineActivityKey: kube.PipelineActivityKey{
+			Name:     "jstrachan-myapp-master-1",
+			Pipeline: "jstrachan/myapp/master",
+			Build:    "1",
+		},
+		Environment: production.Name,
+	}
+	annotation := kube.AnnotationPromoteApprovedByPrefix + production.Name
+
+	// environments which are not protected do not need an approval
+	assert.NoError(t, o.waitForApproval(context.Background(), staging, promoteKey))
+
+	// batch mode fails fast without an approval
+	o.BatchMode = true
+	err = o.waitForApproval(context.Background(), production, promoteKey)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Approval required")
+		assert.Contains(t, err.Error(), annotation)
+	}
+
+	// waits for the approval and records it on the activity
+	o.BatchMode = false
+	done := make(chan error)
+	go func() {
+		done <- o.waitForApproval(context.Background(), production, promoteKey)
+	}()
+	var activity *v1.PipelineActivity
+	for i := 0; i < 1000; i++ {
+		activity, err = o.Activities.Get(promoteKey.Name, metav1.GetOptions{})
+		if err == nil && promoteUpdateStep(activity) != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.NoError(t, err)
+	if assert.NotNil(t, promoteUpdateStep(activity)) {
+		assert.Equal(t, v1.ActivityStatusTypeWaitingForApproval, promoteUpdateStep(activity).Status)
+		assert.Equal(t, "awaiting approval", promoteUpdateStep(activity).Description)
+	}
+	activity.Annotations = map[string]string{annotation: "jstrachan"}
+	_, err = o.Activities.Update(activity)
+	assert.NoError(t, err)
+	assert.NoError(t, <-done)
+
+	activity, err = o.Activities.Get(promoteKey.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	if assert.NotNil(t, promoteUpdateStep(activity)) {
+		assert.Equal(t, v1.ActivityStatusTypeNone, promoteUpdateStep(activity).Status)
+		assert.Equal(t, "approved by jstrachan", promoteUpdateStep(activity).Description)
+	}
+
+	// an existing approval lets batch mode continue
+	o.BatchMode = true
+	assert.NoError(t, o.waitForApproval(context.Background(), production, promoteKey))
+}
+
+func promotePullRequestStep(activity *v1.PipelineActivity) *v1.PromotePullRequestStep {
+	for _, step := range activity.Spec.Steps {
+		if step.Promote != nil {
+			return step.Promote.PullRequest
+		}
+	}
+	return nil
+}
+
+func promoteUpdateStep(activity *v1.PipelineActivity) *v1.PromoteUpdateStep {
+	for _, step := range activity.Spec.Steps {
+		if step.Promote != nil {
+			return step.Promote.Update
+		}
+	}
+	return nil
+}
+
+func TestPromoteCanary(t *testing.T) {
+	o := &PromoteOptions{
+		Application:  "myapp",
+		CanaryWeight: 20,
+	}
+	assert.Equal(t, []string{canaryEnabledValue + "=true", canaryWeightValue + "=20"}, o.helmSetValues())
+
+	values := map[string]interface{}{}
+	fn := o.createModifyValuesFn()
+	if assert.NotNil(t, fn) {
+		assert.NoError(t, fn(values))
+		canary := values["myapp"].(map[string]interface{})["canary"].(map[string]interface{})
+		assert.Equal(t, true, canary["enabled"])
+		assert.Equal(t, 20, canary["weight"])
+	}
+
+	ps := &v1.PromoteActivityStep{}
+	recordPromoteVersions(&v1.PipelineActivity{}, ps, &ReleaseInfo{Version: "1.2.3", CanaryWeight: o.CanaryWeight})
+	assert.True(t, ps.Canary)
+	assert.Equal(t, 20, ps.CanaryWeight)
+	assert.Contains(t, describePromoteVersion(ps), "canary 20%")
+
+	// promoting the canary sends all of the traffic to it
+	o.CanaryWeight = 0
+	o.CanaryPromote = true
+	assert.Equal(t, []string{canaryEnabledValue + "=false", canaryWeightValue + "=100"}, o.helmSetValues())
+	values = map[string]interface{}{}
+	assert.NoError(t, o.createModifyValuesFn()(values))
+	canary := values["myapp"].(map[string]interface{})["canary"].(map[string]interface{})
+	assert.Equal(t, false, canary["enabled"])
+	assert.Equal(t, 100, canary["weight"])
+
+	// invalid combinations are rejected
+	for _, opts := range []*PromoteOptions{
+		{CanaryWeight: 101},
+		{CanaryWeight: 20, CanaryPromote: true},
+		{CanaryWeight: 20, Rollback: true},
+	} {
+		opts.Application = "myapp"
+		opts.Environment = "production"
+		ConfigureTestOptions(&opts.CommonOptions, &gits.GitFake{}, &promoteTestHelmer{})
+		assert.Error(t, opts.Run())
+	}
+}
+
+func TestPromoteSetValues(t *testing.T) {
+	o := &PromoteOptions{
+		Application: "myapp",
+		SetValues:   []string{"resources.limits.memory=512Mi", "featureX.enabled=true", "replicaCount=3", "url=http://a.b/?c=d"},
+	}
+	assert.Equal(t, o.SetValues, o.helmSetValues())
+
+	values := map[string]interface{}{
+		"myapp": map[string]interface{}{
+			"replicaCount": 2,
+		},
+	}
+	fn := o.createModifyValuesFn()
+	if assert.NotNil(t, fn) {
+		assert.NoError(t, fn(values))
+		appValues := values["myapp"].(map[string]interface{})
+		assert.Equal(t, int64(3), appValues["replicaCount"])
+		assert.Equal(t, "http://a.b/?c=d", appValues["url"])
+		assert.Equal(t, true, appValues["featureX"].(map[string]interface{})["enabled"])
+		limits := appValues["resources"].(map[string]interface{})["limits"].(map[string]interface{})
+		assert.Equal(t, "512Mi", limits["memory"])
+	}
+
+	for _, text := range []string{"novalue", "=foo", ".a=b", "a..b=c"} {
+		_, _, err := parseSetValue(text)
+		assert.Error(t, err, text)
+	}
+	key, value, err := parseSetValue("a.b=")
+	assert.NoError(t, err)
+	assert.Equal(t, "a.b", key)
+	assert.Equal(t, "", value)
+}
+
+func TestPromoteValuesFile(t *testing.T) {
+	f := newPromoteTestFixture(t)
+	defer f.cleanup()
+	dir := f.tempDir("test-promote-values-")
+
+	o := &PromoteOptions{
+		Application: "myapp",
+	}
+	assert.NoError(t, o.validateValuesFile())
+	assert.Nil(t, o.helmValueFiles())
+
+	o.ValuesFile = filepath.Join(dir, "missing.yaml")
+	err := o.validateValuesFile()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not exist")
+	}
+
+	o.ValuesFile = dir
+	assert.Error(t, o.validateValuesFile(), "a directory is not a readable values file")
+
+	fileName := filepath.Join(dir, "values.yaml")
+	err = ioutil.WriteFile(fileName, []byte("replicaCount: 3\n"), util.DefaultWritePermissions)
+	assert.NoError(t, err)
+	cwd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(dir))
+	defer os.Chdir(cwd)
+	o.ValuesFile = "values.yaml"
+	assert.NoError(t, o.validateValuesFile())
+	resolved, err := filepath.EvalSymlinks(o.ValuesFile)
+	assert.NoError(t, err)
+	expected, err := filepath.EvalSymlinks(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, resolved)
+	assert.True(t, filepath.IsAbs(o.ValuesFile))
+	assert.Equal(t, []string{o.ValuesFile}, o.helmValueFiles())
+
+	ps := &v1.PromoteActivityStep{}
+	recordPromoteVersions(&v1.PipelineActivity{}, ps, &ReleaseInfo{Version: "1.2.3", ValuesFile: o.ValuesFile})
+	assert.Equal(t, o.ValuesFile, ps.ValuesFile)
+}
+
+func TestPromoteEnvironmentValuesConfig(t *testing.T) {
+	f := newPromoteTestFixture(t)
+	defer f.cleanup()
+	dir := f.tempDir("test-promote-env-values-")
+
+	configFile := filepath.Join(dir, "env-values.yaml")
+	writeConfig := func(text string) {
+		assert.NoError(t, ioutil.WriteFile(configFile, []byte(text), util.DefaultWritePermissions))
+	}
+	err := ioutil.WriteFile(filepath.Join(dir, "production-values.yaml"), []byte("replicaCount: 3\ningress:\n  host: myapp.example.com\n"), util.DefaultWritePermissions)
+	assert.NoError(t, err)
+
+	staging := kube.NewPermanentEnvironment("staging")
+	production := kube.NewPermanentEnvironment("production")
+	o := &PromoteOptions{
+		Application:             "myapp",
+		Version:                 "1.2.0",
+		EnvironmentValuesConfig: configFile,
+		SetValues:               []string{"debug=true"},
+		DryRun:                  true,
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging, production}, &gits.GitFake{}, &promoteTestHelmer{})
+
+	writeConfig("staging:\n  set:\n  - replicaCount=1\nproduction:\n  valuesFile: missing.yaml\n")
+	err = o.loadEnvironmentValuesConfig()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not exist")
+	}
+
+	writeConfig("staging:\n  set:\n  - novalue\n")
+	err = o.loadEnvironmentValuesConfig()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "environment staging")
+	}
+
+	writeConfig("staging:\n  set:\n  - replicaCount=1\nproduction:\n  valuesFile: production-values.yaml\n  set:\n  - ingress.tls=true\n")
+	assert.NoError(t, o.loadEnvironmentValuesConfig())
+
+	// the overrides of the target environment are passed to helm before the --set values
+	_, err = o.Promote(context.Background(), staging.Spec.Namespace, staging, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"replicaCount=1", "debug=true"}, o.helmSetValues())
+	assert.Empty(t, o.helmValueFiles())
+
+	_, err = o.Promote(context.Background(), production.Spec.Namespace, production, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ingress.tls=true", "debug=true"}, o.helmSetValues())
+	expected, err := filepath.Abs(filepath.Join(dir, "production-values.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{expected}, o.helmValueFiles())
+
+	// the overrides are written to the app values of a GitOps environment
+	values := map[string]interface{}{
+		"myapp": map[string]interface{}{
+			"replicaCount": 1,
+			"ingress": map[string]interface{}{
+				"class": "nginx",
+			},
+		},
+	}
+	assert.NoError(t, o.createModifyValuesFn()(values))
+	appValues := values["myapp"].(map[string]interface{})
+	assert.Equal(t, float64(3), appValues["replicaCount"])
+	assert.Equal(t, true, appValues["debug"])
+	ingress := appValues["ingress"].(map[string]interface{})
+	assert.Equal(t, "nginx", ingress["class"])
+	assert.Equal(t, "myapp.example.com", ingress["host"])
+	assert.Equal(t, true, ingress["tls"])
+
+	// environments without overrides are unchanged
+	o.SetValues = nil
+	o.environmentValues = o.environmentValuesConfig["test"]
+	assert.Nil(t, o.createModifyValuesFn())
+}
+
+func TestPromoteCommitSHA(t *testing.T) {
+	f := newPromoteTestFixture(t).setEnv(map[string]string{"JOB_NAME": "jstrachan/myapp/master", "BUILD_NUMBER": "3"})
+	defer f.cleanup()
+
+	production := kube.NewPermanentEnvironment("production")
+	gitter := &gits.GitFake{
+		Commits: []gits.GitCommit{
+			{SHA: "0a1b2c3d4e5f60718293a4b5c6d7e8f901234567"},
+			{SHA: "9f8e7d6c5b4a39281706f5e4d3c2b1a098765432"},
+		},
+	}
+	activity := newPromoteTestActivity("jstrachan-myapp-master-3", "myapp", "staging", "1.2.3", v1.ActivityStatusTypeSucceeded, time.Now())
+	activity.Spec.Steps[0].Promote.CommitSHA = "0a1b2c3d4e5f60718293a4b5c6d7e8f901234567"
+	o := &PromoteOptions{
+		Application: "myapp",
+	}
+	f.configure(o, nil, []runtime.Object{activity}, gitter, &promoteTestHelmer{})
+	jxClient, ns, err := o.JXClient()
+	assert.NoError(t, err)
+	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+
+	// the HEAD commit of the current directory is not used as it may not be the source of the promoted version
+	assert.NoError(t, o.resolveCommitSHA())
+	assert.Empty(t, o.CommitSHA)
+
+	// the commit recorded by an earlier promotion in the PipelineActivity is used
+	assert.Equal(t, "0a1b2c3d4e5f60718293a4b5c6d7e8f901234567", o.activityCommitSHA(o.createPromoteKey(production)))
+	f.setEnv(map[string]string{"BUILD_NUMBER": "4"})
+	assert.Empty(t, o.activityCommitSHA(o.createPromoteKey(production)), "there is no PipelineActivity for the build")
+	assert.Empty(t, o.activityCommitSHA(nil))
+
+	o.CommitSHA = " ABC1234 "
+	assert.NoError(t, o.resolveCommitSHA())
+	assert.Equal(t, "abc1234", o.CommitSHA)
+
+	o.PullRequestBodyTemplate = "Built from {{.CommitSHA}}"
+	_, body, err := o.renderPullRequestTemplates(production, o.createPromoteKey(production), "1.2.3", o.CommitSHA, "myapp to 1.2.3", "Promote myapp to version 1.2.3")
+	assert.NoError(t, err)
+	assert.Equal(t, "Built from abc1234", body)
+
+	ps := &v1.PromoteActivityStep{}
+	recordPromoteVersions(&v1.PipelineActivity{}, ps, &ReleaseInfo{Version: "1.2.3", CommitSHA: o.CommitSHA})
+	assert.Equal(t, "abc1234", ps.CommitSHA)
+
+	for _, sha := range []string{"abc", "not-a-sha", "abc1234 def"} {
+		o.CommitSHA = sha
+		err := o.resolveCommitSHA()
+		if assert.Error(t, err, sha) {
+			assert.Contains(t, err.Error(), "--commit-sha", sha)
+		}
+	}
+}
+
+func TestPromotePendingCommitStatesKeepWaiting(t *testing.T) {
+	env := kube.NewPermanentEnvironment("staging")
+	timeout := 50 * time.Millisecond
+	pollTime := 5 * time.Millisecond
+	o := &PromoteOptions{
+		Application:             "myapp",
+		TimeoutDuration:         &timeout,
+		PullRequestPollDuration: &pollTime,
+	}
+	newReleaseInfo := func(state string) *ReleaseInfo {
+		releaseInfo := &ReleaseInfo{
+			PullRequestInfo: newPromoteTestPullRequest(nil),
+		}
+		fakePR := releaseInfo.PullRequestInfo.GitProvider.(*gits.FakeProvider).Repositories["jstrachan"][0].PullRequests[1]
+		fakePR.Commits = []*gits.FakeCommit{
+			{
+				Commit: &gits.GitCommit{SHA: "abc123"},
+				Status: gits.CommitStatus(state),
+			},
+		}
+		return releaseInfo
+	}
+	for _, state := range []string{"pending", "queued", "expected", "requested", "waiting", "in-progress"} {
+		releaseInfo := newReleaseInfo(state)
+		err := o.waitForGitOpsPullRequest(context.Background(), env.Spec.Namespace, env, releaseInfo, time.Now().Add(timeout), timeout, o.createPromoteKey(env), o.createNotifier(env, releaseInfo))
+		if assert.Error(t, err, state) {
+			_, timedOut := err.(*pullRequestTimeoutError)
+			assert.True(t, timedOut, "the promotion should keep waiting while the status is %s but failed with: %s", state, err)
+		}
+		assert.False(t, pullRequestMerged(releaseInfo.PullRequestInfo.PullRequest), state)
+	}
+
+	for _, state := range []string{"failure", "error"} {
+		releaseInfo := newReleaseInfo(state)
+		err := o.waitForGitOpsPullRequest(context.Background(), env.Spec.Namespace, env, releaseInfo, time.Now().Add(timeout), timeout, o.createPromoteKey(env), o.createNotifier(env, releaseInfo))
+		if assert.Error(t, err, state) {
+			assert.Contains(t, err.Error(), "last commit has status "+state)
+		}
+	}
+}
+
+func TestPromotePullRequestPhaseTimeouts(t *testing.T) {
+	env := kube.NewPermanentEnvironment("staging")
+	timeout := 10 * time.Second
+	phaseTimeout := 50 * time.Millisecond
+	pollTime := 10 * time.Millisecond
+	o := &PromoteOptions{
+		Application:                      "myapp",
+		NoMergePullRequest:               true,
+		TimeoutDuration:                  &timeout,
+		PullRequestPollDuration:          &pollTime,
+		PullRequestCreateTimeoutDuration: &phaseTimeout,
+		PullRequestMergeTimeoutDuration:  &phaseTimeout,
+	}
+
+	// the Pull Request has no commits so it never becomes reviewable
+	releaseInfo := &ReleaseInfo{
+		PullRequestInfo: newPromoteTestPullRequest(nil),
+	}
+	start := time.Now()
+	err := o.waitForGitOpsPullRequest(context.Background(), env.Spec.Namespace, env, releaseInfo, start.Add(timeout), timeout, o.createPromoteKey(env), o.createNotifier(env, releaseInfo))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "create phase")
+		assert.Contains(t, err.Error(), phaseTimeout.String())
+	}
+	assert.True(t, time.Since(start) < timeout)
+
+	// the Pull Request is reviewable but never merges
+	releaseInfo = &ReleaseInfo{
+		PullRequestInfo: newPromoteTestPullRequest(nil),
+	}
+	fakePR := releaseInfo.PullRequestInfo.GitProvider.(*gits.FakeProvider).Repositories["jstrachan"][0].PullRequests[1]
+	fakePR.Commits = []*gits.FakeCommit{
+		{
+			Commit: &gits.GitCommit{SHA: "abc123"},
+			Status: gits.CommitStatusPending,
+		},
+	}
+	start = time.Now()
+	err = o.waitForGitOpsPullRequest(context.Background(), env.Spec.Namespace, env, releaseInfo, start.Add(timeout), timeout, o.createPromoteKey(env), o.createNotifier(env, releaseInfo))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "merge phase")
+	}
+	assert.True(t, time.Since(start) < timeout)
+
+	// the phases default to --timeout
+	o.PullRequestCreateTimeoutDuration = nil
+	o.PullRequestMergeTimeoutDuration = nil
+	end, duration := pullRequestPhaseDeadline(o.PullRequestMergeTimeoutDuration, start.Add(timeout), timeout)
+	assert.Equal(t, start.Add(timeout), end)
+	assert.Equal(t, timeout, duration)
+}
+
+// promoteTestUnqueryableProvider fails to query the status of the Pull Request a number of times as if it was not
+// created yet
+type promoteTestUnqueryableProvider struct {
+	*gits.FakeProvider
+
+	failures int
+	queries  int
+}
+
+func (p *promoteTestUnqueryableProvider) UpdatePullRequestStatus(pr *gits.GitPullRequest) error {
+	p.queries++
+	if p.queries <= p.failures {
+		return fmt.Errorf("pull request %s not found", pr.URL)
+	}
+	return p.FakeProvider.UpdatePullRequestStatus(pr)
+}
+
+func TestPromotePostPullRequestDelay(t *testing.T) {
+	o := &PromoteOptions{}
+	assert.NoError(t, o.parseDurations())
+	assert.Equal(t, waitAfterPullRequestCreated, o.postPullRequestDelay())
+
+	o.PostPullRequestDelay = "0"
+	assert.NoError(t, o.parseDurations())
+	assert.Equal(t, time.Duration(0), o.postPullRequestDelay())
+
+	o.PostPullRequestDelay = "-1s"
+	err := o.parseDurations()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--post-pr-delay")
+	}
+
+	// without a delay the first query of the new Pull Request is retried
+	oldRetryTime := pullRequestQueryRetryTime
+	defer func() {
+		pullRequestQueryRetryTime = oldRetryTime
+	}()
+	pullRequestQueryRetryTime = time.Millisecond
+
+	env := kube.NewPermanentEnvironment("staging")
+	timeout := 10 * time.Second
+	phaseTimeout := 20 * time.Millisecond
+	pollTime := 5 * time.Millisecond
+	o = &PromoteOptions{
+		Application:                      "myapp",
+		NoMergePullRequest:               true,
+		TimeoutDuration:                  &timeout,
+		PullRequestPollDuration:          &pollTime,
+		PullRequestCreateTimeoutDuration: &phaseTimeout,
+	}
+	for _, failures := range []int{pullRequestQueryRetries, pullRequestQueryRetries + 1} {
+		releaseInfo := &ReleaseInfo{
+			PullRequestInfo: newPromoteTestPullRequest(nil),
+		}
+		provider := &promoteTestUnqueryableProvider{
+			FakeProvider: releaseInfo.PullRequestInfo.GitProvider.(*gits.FakeProvider),
+			failures:     failures,
+		}
+		releaseInfo.PullRequestInfo.GitProvider = provider
+		err = o.waitForGitOpsPullRequest(context.Background(), env.Spec.Namespace, env, releaseInfo, time.Now().Add(timeout), timeout, o.createPromoteKey(env), o.createNotifier(env, releaseInfo))
+		if assert.Error(t, err) {
+			if failures <= pullRequestQueryRetries {
+				assert.Contains(t, err.Error(), "create phase", "the Pull Request is polled once it can be queried")
+			} else {
+				assert.Contains(t, err.Error(), "Failed to query the Pull Request status")
+			}
+		}
+	}
+}
+
+func TestPromoteProviderRetries(t *testing.T) {
+	oldBackoff := providerRetryBackoff
+	defer func() {
+		providerRetryBackoff = oldBackoff
+	}()
+	providerRetryBackoff = time.Millisecond
+
+	env := kube.NewPermanentEnvironment("staging")
+	o := &PromoteOptions{
+		ProviderRetries: 3,
+	}
+	transientErr := fmt.Errorf("GET https://api.github.com/repos/jstrachan/environment-staging/pulls/1: 502 Bad Gateway []")
+	query := func(failures int, err error) (int, error) {
+		queries := 0
+		answer := o.retryProviderQuery(context.Background(), env, "query the Pull Request", func() error {
+			queries++
+			if queries <= failures {
+				return err
+			}
+			return nil
+		})
+		return queries, answer
+	}
+
+	queries, err := query(3, transientErr)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, queries)
+
+	queries, err = query(4, transientErr)
+	assert.Equal(t, transientErr, err)
+	assert.Equal(t, 4, queries, "the query is retried up to --provider-retries times")
+
+	queries, err = query(1, fmt.Errorf("GET https://api.github.com/repos/jstrachan/environment-staging/pulls/1: 404 Not Found []"))
+	assert.Error(t, err)
+	assert.Equal(t, 1, queries, "genuine errors fail fast")
+
+	o.ProviderRetries = 0
+	queries, err = query(1, transientErr)
+	assert.Error(t, err)
+	assert.Equal(t, 1, queries)
+
+	o.Application = "myapp"
+	o.ProviderRetries = -1
+	err = o.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--provider-retries")
+	}
+}
+
+func TestPromoteTimeoutAction(t *testing.T) {
+	f := newPromoteTestFixture(t).setEnv(map[string]string{"JOB_NAME": "jstrachan/myapp/master", "BUILD_NUMBER": "3", "BUILD_URL": "https://jenkins/job/3/", "BUILD_LOG_URL": "https://jenkins/job/3/console"})
+	defer f.cleanup()
+
+	env := kube.NewPermanentEnvironment("staging")
+	timeout := 20 * time.Millisecond
+	pollTime := time.Millisecond
+	testCases := []struct {
+		action      string
+		closed      bool
+		status      v1.ActivityStatusType
+		description string
+	}{
+		{timeoutActionFail, false, v1.ActivityStatusTypeFailed, ""},
+		{timeoutActionClose, true, v1.ActivityStatusTypeFailed, "closed as the promotion timed out"},
+		{timeoutActionLeave, false, v1.ActivityStatusTypeNone, "left open as the promotion timed out"},
+	}
+	for _, tc := range testCases {
+		o := &PromoteOptions{
+			Application:             "myapp",
+			NoMergePullRequest:      true,
+			TimeoutAction:           tc.action,
+			TimeoutDuration:         &timeout,
+			PullRequestPollDuration: &pollTime,
+		}
+		f.configure(o, []runtime.Object{}, []runtime.Object{env}, nil, nil)
+		jxClient, ns, err := o.JXClient()
+		assert.NoError(t, err)
+		o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+
+		releaseInfo := &ReleaseInfo{
+			PullRequestInfo: newPromoteTestPullRequest(nil),
+		}
+		err = o.WaitForPromotion(context.Background(), env.Spec.Namespace, env, releaseInfo)
+		if assert.Error(t, err, tc.action) {
+			assert.Contains(t, err.Error(), "Timed out", tc.action)
+		}
+		assert.Equal(t, tc.closed, releaseInfo.PullRequestInfo.PullRequest.IsClosed(), tc.action)
+
+		activity, err := o.Activities.Get("jstrachan-myapp-master-3", metav1.GetOptions{})
+		if assert.NoError(t, err, tc.action) {
+			var pullRequestStep *v1.PromotePullRequestStep
+			for _, step := range activity.Spec.Steps {
+				if step.Promote != nil {
+					pullRequestStep = step.Promote.PullRequest
+				}
+			}
+			if assert.NotNil(t, pullRequestStep, tc.action) {
+				assert.Equal(t, tc.status, pullRequestStep.Status, tc.action)
+				assert.Equal(t, tc.description, pullRequestStep.Description, tc.action)
+			}
+		}
+	}
+}
+
+func TestPromoteCancellation(t *testing.T) {
+	f := newPromoteTestFixture(t).setEnv(map[string]string{"JOB_NAME": "jstrachan/myapp/master", "BUILD_NUMBER": "3", "BUILD_URL": "https://jenkins/job/3/", "BUILD_LOG_URL": "https://jenkins/job/3/console"})
+	defer f.cleanup()
+
+	env := kube.NewPermanentEnvironment("staging")
+	timeout := 10 * time.Second
+	pollTime := 5 * time.Millisecond
+	o := &PromoteOptions{
+		Application:             "myapp",
+		NoMergePullRequest:      true,
+		TimeoutDuration:         &timeout,
+		PullRequestPollDuration: &pollTime,
+	}
+	f.configure(o, []runtime.Object{}, []runtime.Object{env}, nil, nil)
+	jxClient, ns, err := o.JXClient()
+	assert.NoError(t, err)
+	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+
+	// the Pull Request never merges so the wait only ends when it is cancelled
+	releaseInfo := &ReleaseInfo{
+		PullRequestInfo: newPromoteTestPullRequest(nil),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	err = o.WaitForPromotion(ctx, env.Spec.Namespace, env, releaseInfo)
+	if assert.Error(t, err) {
+		_, cancelled := err.(*promoteCancelledError)
+		assert.True(t, cancelled, "expected the promotion to be cancelled but got: %s", err)
+	}
+	assert.True(t, time.Since(start) < timeout)
+
+	activity, err := o.Activities.Get("jstrachan-myapp-master-3", metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		var pullRequestStep *v1.PromotePullRequestStep
+		for _, step := range activity.Spec.Steps {
+			if step.Promote != nil {
+				pullRequestStep = step.Promote.PullRequest
+			}
+		}
+		if assert.NotNil(t, pullRequestStep) {
+			assert.Equal(t, v1.ActivityStatusTypeFailed, pullRequestStep.Status)
+			assert.Equal(t, "cancelled", pullRequestStep.Description)
+		}
+	}
+
+	// nothing is promoted once the context is cancelled
+	_, err = o.Promote(ctx, env.Spec.Namespace, env, false)
+	if assert.Error(t, err) {
+		_, cancelled := err.(*promoteCancelledError)
+		assert.True(t, cancelled, "expected the promotion to be cancelled but got: %s", err)
+	}
+
+	assert.NoError(t, sleepContext(context.Background(), time.Millisecond))
+	assert.Error(t, sleepContext(ctx, time.Hour))
+
+	// the retries are abandoned rather than waiting for their backoff once the context is cancelled
+	oldBackoff := providerRetryBackoff
+	defer func() {
+		providerRetryBackoff = oldBackoff
+	}()
+	providerRetryBackoff = time.Hour
+	o.ProviderRetries = 3
+	o.ChartRetries = 3
+	o.ChartRetryBackoff = time.Hour
+	o.NoHelmUpdate = true
+	o.MergeRetries = 3
+	o.MergeRetryInterval = time.Hour
+	start = time.Now()
+	err = o.retryProviderQuery(ctx, env, "query the Pull Request", func() error {
+		return fmt.Errorf("GET https://api.github.com/repos/jstrachan/environment-staging/pulls/1: 502 Bad Gateway []")
+	})
+	assert.True(t, isPromoteCancelled(err), "expected the query retries to be cancelled but got: %v", err)
+	err = o.retryOnChartNotFound(ctx, "myapp", func() error {
+		return &chartNotFoundError{chart: "myapp"}
+	})
+	assert.True(t, isPromoteCancelled(err), "expected the chart retries to be cancelled but got: %v", err)
+	info := newPromoteTestPullRequest(nil)
+	assert.NoError(t, o.mergePullRequest(context.Background(), info, o.createNotifier(env, nil)))
+	err = o.mergePullRequest(ctx, info, o.createNotifier(env, nil))
+	assert.True(t, isPromoteCancelled(err), "expected the merge retries to be cancelled but got: %v", err)
+	assert.True(t, time.Since(start) < timeout)
+}
+
+func TestPromoteAutoRebase(t *testing.T) {
+	env := kube.NewPermanentEnvironment("staging")
+	timeout := 10 * time.Second
+	phaseTimeout := 50 * time.Millisecond
+	pollTime := time.Millisecond
+	o := &PromoteOptions{
+		Application:                      "myapp",
+		NoMergePullRequest:               true,
+		AutoRebase:                       true,
+		MaxRebaseAttempts:                2,
+		TimeoutDuration:                  &timeout,
+		PullRequestPollDuration:          &pollTime,
+		PullRequestCreateTimeoutDuration: &phaseTimeout,
+		PullRequestMergeTimeoutDuration:  &phaseTimeout,
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, []runtime.Object{}, []runtime.Object{env}, &promoteTestGitter{}, &promoteTestHelmer{})
+
+	// the Pull Request always has conflicts so the promotion gives up after the maximum number of rebases
+	releaseInfo := &ReleaseInfo{
+		PullRequestInfo: newPromoteTestPullRequest(nil),
+	}
+	mergeable := false
+	releaseInfo.PullRequestInfo.PullRequest.Mergeable = &mergeable
+	start := time.Now()
+	err := o.waitForGitOpsPullRequest(context.Background(), env.Spec.Namespace, env, releaseInfo, start.Add(timeout), timeout, o.createPromoteKey(env), o.createNotifier(env, releaseInfo))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "after 2 rebase attempts")
+	}
+	assert.True(t, time.Since(start) < timeout)
+
+	// without --auto-rebase the promotion waits for the conflicts to be resolved
+	o.AutoRebase = false
+	start = time.Now()
+	err = o.waitForGitOpsPullRequest(context.Background(), env.Spec.Namespace, env, releaseInfo, start.Add(timeout), timeout, o.createPromoteKey(env), o.createNotifier(env, releaseInfo))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "create phase")
+	}
+}
+
+func TestPromoteRecordsPullRequestStatuses(t *testing.T) {
+	statuses := gitStatusesOfRef([]*gits.GitRepoStatus{
+		{URL: "https://ci/lint", State: "pending"},
+		{URL: "https://ci/build", State: "success", TargetURL: "https://jenkins/build/1"},
+		{URL: "https://ci/lint", State: "failure"},
+		nil,
+	})
+	assert.Equal(t, []v1.GitStatus{
+		{URL: "https://jenkins/build/1", Status: "success"},
+		{URL: "https://ci/lint", Status: "pending"},
+	}, statuses)
+
+	env := kube.NewPermanentEnvironment("staging")
+	timeout := 50 * time.Millisecond
+	pollTime := time.Millisecond
+	o := &PromoteOptions{
+		Application:             "myapp",
+		NoMergePullRequest:      true,
+		TimeoutDuration:         &timeout,
+		PullRequestPollDuration: &pollTime,
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, []runtime.Object{}, []runtime.Object{env}, &gits.GitFake{}, &promoteTestHelmer{})
+	jxClient, ns, err := o.JXClient()
+	assert.NoError(t, err)
+	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+	promoteKey := &kube.PromoteStepActivityKey{
+		PipelineActivityKey: kube.PipelineActivityKey{
+			Name:     "jstrachan-myapp-master-1",
+			Pipeline: "jstrachan/myapp/master",
+			Build:    "1",
+		},
+		Environment: env.Name,
+	}
+
+	// the statuses of the last commit are recorded before the Pull Request merges
+	releaseInfo := &ReleaseInfo{
+		PullRequestInfo: newPromoteTestPullRequest(nil),
+	}
+	provider := releaseInfo.PullRequestInfo.GitProvider.(*gits.FakeProvider)
+	provider.Repositories["jstrachan"][0].Commits = []*gits.FakeCommit{
+		{
+			Commit: &gits.GitCommit{SHA: "abc123", URL: "https://ci/build"},
+			Status: gits.CommitStatusPending,
+		},
+	}
+	err = o.waitForGitOpsPullRequest(context.Background(), env.Spec.Namespace, env, releaseInfo, time.Now().Add(timeout), timeout, promoteKey, o.createNotifier(env, releaseInfo))
+	assert.Error(t, err)
+
+	activity, err := o.Activities.Get(promoteKey.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	if assert.NotNil(t, promotePullRequestStep(activity)) {
+		assert.Equal(t, []v1.GitStatus{{URL: "https://ci/build", Status: "pending"}}, promotePullRequestStep(activity).Statuses)
+	}
+	assert.Nil(t, promoteUpdateStep(activity), "the update step is only started once the Pull Request merges")
+
+	// the update step only holds the statuses of the merge commit
+	merged := true
+	mergeSha := "def456"
+	releaseInfo.PullRequestInfo.PullRequest.Merged = &merged
+	releaseInfo.PullRequestInfo.PullRequest.MergeCommitSHA = &mergeSha
+	provider.Repositories["jstrachan"][0].Commits = append(provider.Repositories["jstrachan"][0].Commits, &gits.FakeCommit{
+		Commit: &gits.GitCommit{SHA: mergeSha, URL: "https://ci/deploy"},
+		Status: gits.CommitSatusSuccess,
+	})
+	err = o.waitForGitOpsPullRequest(context.Background(), env.Spec.Namespace, env, releaseInfo, time.Now().Add(timeout), timeout, promoteKey, o.createNotifier(env, releaseInfo))
+	assert.NoError(t, err)
+
+	activity, err = o.Activities.Get(promoteKey.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	if assert.NotNil(t, promoteUpdateStep(activity)) {
+		assert.Equal(t, []v1.GitStatus{{URL: "https://ci/deploy", Status: "success"}}, promoteUpdateStep(activity).Statuses)
+	}
+	if assert.NotNil(t, promotePullRequestStep(activity)) {
+		assert.Equal(t, []v1.GitStatus{{URL: "https://ci/build", Status: "pending"}}, promotePullRequestStep(activity).Statuses)
+	}
+}
+
+func TestPromoteNoWait(t *testing.T) {
+	env := kube.NewPermanentEnvironment("staging")
+	timeout := time.Hour
+	pollTime := time.Millisecond
+	o := &PromoteOptions{
+		Application:             "myapp",
+		NoWait:                  true,
+		TimeoutDuration:         &timeout,
+		PullRequestPollDuration: &pollTime,
+	}
+	assert.Equal(t, "--no-wait was specified", o.noWaitReason())
+
+	// the Pull Request never merges so waiting for it would time out
+	releaseInfo := &ReleaseInfo{
+		PullRequestInfo: newPromoteTestPullRequest(nil),
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, []runtime.Object{}, []runtime.Object{env}, &gits.GitFake{}, &promoteTestHelmer{})
+	start := time.Now()
+	assert.NoError(t, o.WaitForPromotion(context.Background(), env.Spec.Namespace, env, releaseInfo))
+	assert.True(t, time.Since(start) < time.Second, "should not poll the Pull Request")
+
+	// no timeout or poll time behaves like --no-wait
+	o.NoWait = false
+	assert.Equal(t, "", o.noWaitReason())
+	o.TimeoutDuration = nil
+	assert.Equal(t, "no --timeout was specified", o.noWaitReason())
+	assert.NoError(t, o.WaitForPromotion(context.Background(), env.Spec.Namespace, env, releaseInfo))
+	o.TimeoutDuration = &timeout
+	o.PullRequestPollDuration = nil
+	assert.Equal(t, "no --pull-request-poll-time was specified", o.noWaitReason())
+	assert.NoError(t, o.WaitForPromotion(context.Background(), env.Spec.Namespace, env, releaseInfo))
+}
+
+func TestPromoteHelmTimeout(t *testing.T) {
+	o := &PromoteOptions{}
+	assert.NoError(t, o.parseDurations())
+	assert.Nil(t, o.helmTimeout(), "there is no timeout")
+
+	// defaults to the --timeout
+	o.Timeout = "10m"
+	assert.NoError(t, o.parseDurations())
+	if assert.NotNil(t, o.helmTimeout()) {
+		assert.Equal(t, 600, *o.helmTimeout())
+	}
+
+	o.HelmTimeout = "90s"
+	assert.NoError(t, o.parseDurations())
+	if assert.NotNil(t, o.helmTimeout()) {
+		assert.Equal(t, 90, *o.helmTimeout())
+	}
+
+	for _, value := range []string{"5", "500ms"} {
+		o.HelmTimeout = value
+		err := o.parseDurations()
+		if assert.Error(t, err, value) {
+			assert.Contains(t, err.Error(), "--helm-timeout", value)
+		}
+	}
+}
+
+func TestPromoteHelmUpdateTTL(t *testing.T) {
+	f := newPromoteTestFixture(t)
+	defer f.cleanup()
+	helmHome := f.tempDir("test-promote-helm-home-")
+	f.setEnv(map[string]string{"HELM_HOME": helmHome})
+
+	staging := kube.NewPermanentEnvironment("staging")
+	helmer := &promoteTestHelmer{}
+	o := f.configure(&PromoteOptions{}, nil, []runtime.Object{staging}, nil, helmer)
+
+	// the repositories are updated once per run
+	o.helmRepoUpdate = &helmRepoUpdate{}
+	assert.NoError(t, o.updateHelmRepos(context.Background(), staging))
+	appOptions := o.forApplication(applicationVersion{Name: "other"})
+	assert.NoError(t, appOptions.updateHelmRepos(context.Background(), staging))
+	assert.Equal(t, 1, helmer.updates)
+	_, updated := lastHelmRepoUpdate()
+	assert.True(t, updated, "the time of the update is recorded")
+
+	// the next run updates the repositories again unless they were updated within the TTL
+	o.helmRepoUpdate = &helmRepoUpdate{}
+	assert.NoError(t, o.updateHelmRepos(context.Background(), staging))
+	assert.Equal(t, 2, helmer.updates)
+
+	o.HelmUpdateTTL = "1h"
+	assert.NoError(t, o.parseDurations())
+	o.helmRepoUpdate = &helmRepoUpdate{}
+	assert.NoError(t, o.updateHelmRepos(context.Background(), staging))
+	assert.Equal(t, 2, helmer.updates)
+
+	stale := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	assert.NoError(t, ioutil.WriteFile(helmRepoUpdateFile(), []byte(stale), util.DefaultWritePermissions))
+	o.helmRepoUpdate = &helmRepoUpdate{}
+	assert.NoError(t, o.updateHelmRepos(context.Background(), staging))
+	assert.Equal(t, 3, helmer.updates)
+
+	// --no-helm-update overrides the TTL
+	assert.NoError(t, os.Remove(helmRepoUpdateFile()))
+	o.NoHelmUpdate = true
+	o.helmRepoUpdate = &helmRepoUpdate{}
+	assert.NoError(t, o.updateHelmRepos(context.Background(), staging))
+	assert.Equal(t, 3, helmer.updates)
+
+	// a cancelled promotion stops waiting for an update that cannot be interrupted
+	o.NoHelmUpdate = false
+	o.HelmUpdateTTL = ""
+	assert.NoError(t, o.parseDurations())
+	helmer.updateRepo = make(chan struct{})
+	defer close(helmer.updateRepo)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	o.helmRepoUpdate = &helmRepoUpdate{}
+	err := o.updateHelmRepos(ctx, staging)
+	assert.True(t, isPromoteCancelled(err), "expected the update to be cancelled but got %v", err)
+
+	for _, value := range []string{"ten minutes", "-5m"} {
+		o.HelmUpdateTTL = value
+		err := o.parseDurations()
+		if assert.Error(t, err, value) {
+			assert.Contains(t, err.Error(), "--helm-update-ttl", value)
+		}
+	}
+}
+
+func TestPromoteKubeContext(t *testing.T) {
+	staging := kube.NewPermanentEnvironment("staging")
+	helmer := &promoteTestHelmer{}
+	o := &PromoteOptions{
+		Environment: "staging",
+		KubeContext: "prod-cluster",
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging}, &gits.GitFake{}, helmer)
+
+	contextClient := kubefake.NewSimpleClientset()
+	oldCreateKubeContextClient := createKubeContextClient
+	defer func() {
+		createKubeContextClient = oldCreateKubeContextClient
+	}()
+	contexts := []string{}
+	createKubeContextClient = func(context string) (kubernetes.Interface, error) {
+		contexts = append(contexts, context)
+		if context != "prod-cluster" {
+			return nil, util.InvalidOption(optionKubeContext, context, []string{"prod-cluster"})
+		}
+		return contextClient, nil
+	}
+
+	assert.NoError(t, o.useKubeContext())
+	assert.Equal(t, "prod-cluster", helmer.kubeContext)
+
+	targetNS, _, err := o.GetTargetNamespace("", "staging")
+	assert.NoError(t, err)
+	assert.Equal(t, "jx-staging", targetNS)
+	_, err = contextClient.CoreV1().Namespaces().Get(targetNS, metav1.GetOptions{})
+	assert.NoError(t, err, "the namespace is created in the cluster of the kube context")
+	assert.Equal(t, []string{"prod-cluster"}, contexts, "the client is created once")
+
+	// the context is validated up front
+	o = &PromoteOptions{
+		KubeContext: "missing",
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging}, &gits.GitFake{}, &promoteTestHelmer{})
+	err = o.useKubeContext()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "missing")
+	}
+}
+
+func TestPromotePollBackoff(t *testing.T) {
+	pollTime := 10 * time.Second
+	o := &PromoteOptions{
+		PullRequestPollDuration: &pollTime,
+	}
+
+	// the poll time is fixed by default
+	assert.Equal(t, pollTime, o.nextPollTime(pollTime, false))
+	assert.Equal(t, pollTime, o.nextPollTime(pollTime, true))
+
+	maxPollTime := 45 * time.Second
+	o.PollBackoffMaxDuration = &maxPollTime
+	next := o.nextPollTime(pollTime, false)
+	assert.Equal(t, 20*time.Second, next)
+	next = o.nextPollTime(next, false)
+	assert.Equal(t, 40*time.Second, next)
+	next = o.nextPollTime(next, false)
+	assert.Equal(t, maxPollTime, next)
+	next = o.nextPollTime(next, false)
+	assert.Equal(t, maxPollTime, next)
+
+	// the poll time is reset when the Pull Request changes
+	assert.Equal(t, pollTime, o.nextPollTime(next, true))
+
+	pr := newPromoteTestPullRequest(nil).PullRequest
+	state := pullRequestPollState(pr, "pending", map[string]string{})
+	assert.Equal(t, state, pullRequestPollState(pr, "pending", map[string]string{}))
+	assert.NotEqual(t, state, pullRequestPollState(pr, "success", map[string]string{}))
+	mergeSha := "def456"
+	pr.MergeCommitSHA = &mergeSha
+	assert.NotEqual(t, state, pullRequestPollState(pr, "pending", map[string]string{}))
+	state = pullRequestPollState(pr, "", map[string]string{"https://ci": "pending"})
+	assert.NotEqual(t, state, pullRequestPollState(pr, "", map[string]string{"https://ci": "success"}))
+}
+
+func TestPromoteWaitForReady(t *testing.T) {
+	oldPollTime := waitForReadyPollTime
+	waitForReadyPollTime = time.Millisecond
+	defer func() {
+		waitForReadyPollTime = oldPollTime
+	}()
+
+	replicas := int32(2)
+	labels := map[string]string{"release": "jx-staging-myapp"}
+	deployment := &appsv1beta1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "jx-staging-myapp",
+			Namespace:  "jx-staging",
+			Labels:     labels,
+			Generation: 2,
+		},
+		Spec: appsv1beta1.DeploymentSpec{
+			Replicas: &replicas,
+		},
+		Status: appsv1beta1.DeploymentStatus{
+			ObservedGeneration: 2,
+			UpdatedReplicas:    2,
+			ReadyReplicas:      1,
+		},
+	}
+	observed := int64(1)
+	statefulSet := &appsv1beta1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "jx-staging-myapp-db",
+			Namespace:  "jx-staging",
+			Labels:     labels,
+			Generation: 1,
+		},
+		Status: appsv1beta1.StatefulSetStatus{
+			ObservedGeneration: &observed,
+			UpdatedReplicas:    1,
+			ReadyReplicas:      1,
+		},
+	}
+	timeout := 20 * time.Millisecond
+	o := &PromoteOptions{
+		Application:     "myapp",
+		WaitForReady:    true,
+		TimeoutDuration: &timeout,
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, []runtime.Object{deployment, statefulSet}, nil, &gits.GitFake{}, &promoteTestHelmer{})
+
+	err := o.waitForReleaseReady(context.Background(), "jx-staging", "jx-staging-myapp")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Deployment jx-staging-myapp has 1/2 replicas ready")
+		assert.NotContains(t, err.Error(), "StatefulSet")
+	}
+
+	kubeClient, _, err := o.KubeClient()
+	assert.NoError(t, err)
+	deployment.Status.ReadyReplicas = 2
+	_, err = kubeClient.AppsV1beta1().Deployments("jx-staging").Update(deployment)
+	assert.NoError(t, err)
+	assert.NoError(t, o.waitForReleaseReady(context.Background(), "jx-staging", "jx-staging-myapp"))
+
+	// a release without any workloads is not waited for
+	assert.NoError(t, o.waitForReleaseReady(context.Background(), "jx-staging", "jx-staging-other"))
+}
+
+func TestPromoteCompletionWebhook(t *testing.T) {
+	events := []*PromoteCompletionEvent{}
+	signatures := []string{}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		event := &PromoteCompletionEvent{}
+		assert.NoError(t, json.Unmarshal(data, event))
+		events = append(events, event)
+		signature := r.Header.Get(completionWebhookSignatureHeader)
+		if signature != "" {
+			assert.Equal(t, completionWebhookSignature("s3cr3t", data), signature)
+		}
+		signatures = append(signatures, signature)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	staging := kube.NewPermanentEnvironment("staging")
+	timeout := 20 * time.Millisecond
+	pollTime := time.Millisecond
+	o := &PromoteOptions{
+		Application:             "myapp",
+		CompletionWebhookURL:    server.URL,
+		TimeoutDuration:         &timeout,
+		PullRequestPollDuration: &pollTime,
+	}
+
+	// the Pull Request never becomes reviewable so the promotion fails
+	releaseInfo := &ReleaseInfo{
+		Version:         "1.2.0",
+		StartTime:       time.Now().Add(-time.Minute),
+		PullRequestInfo: newPromoteTestPullRequest(nil),
+	}
+	assert.Error(t, o.WaitForPromotion(context.Background(), staging.Spec.Namespace, staging, releaseInfo))
+	if assert.Len(t, events, 1) {
+		event := events[0]
+		assert.Equal(t, "myapp", event.App)
+		assert.Equal(t, "1.2.0", event.Version)
+		assert.Equal(t, "staging", event.Environment)
+		assert.Equal(t, "jx-staging", event.Namespace)
+		assert.Equal(t, string(v1.ActivityStatusTypeFailed), event.Status)
+		assert.Equal(t, "https://github.com/jstrachan/environment-production/pull/1", event.PullRequestURL)
+		assert.NotEmpty(t, event.Error)
+		assert.True(t, event.Duration >= 60)
+	}
+	assert.Equal(t, []string{""}, signatures)
+
+	// successful promotions are signed when there is a secret
+	o.CompletionWebhookSecret = "s3cr3t"
+	mergeSha := "def456"
+	releaseInfo.PullRequestInfo.PullRequest.MergeCommitSHA = &mergeSha
+	o.notifyCompletion(staging.Spec.Namespace, staging, releaseInfo, nil)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, string(v1.ActivityStatusTypeSucceeded), events[1].Status)
+		assert.Equal(t, "def456", events[1].MergeSHA)
+		assert.Empty(t, events[1].Error)
+		assert.NotEmpty(t, signatures[1])
+	}
+
+	// a failure to post the event does not fail the promotion
+	status = http.StatusInternalServerError
+	assert.Error(t, postCompletionWebhook(server.URL, "", o.createCompletionEvent(staging.Spec.Namespace, staging, releaseInfo, nil)))
+	o.notifyCompletion(staging.Spec.Namespace, staging, releaseInfo, nil)
+	assert.Len(t, events, 4)
+}
+
+func TestPromoteMetrics(t *testing.T) {
+	metrics := buildPromoteMetrics("myapp", "staging", "succeeded", 1500*time.Millisecond)
+	assert.Contains(t, metrics, "# TYPE jx_promote_duration_seconds gauge\n")
+	assert.Contains(t, metrics, `jx_promote_duration_seconds{app="myapp",env="staging",status="succeeded"} 1.5`+"\n")
+	assert.Contains(t, metrics, "# TYPE jx_promote_total counter\n")
+	assert.Contains(t, metrics, `jx_promote_total{app="myapp",env="staging",status="succeeded"} 1`+"\n")
+	assert.Equal(t, `a\"b\\c\nd`, escapeMetricLabel("a\"b\\c\nd"))
+
+	paths := []string{}
+	bodies := []string{}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, string(data))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	o := &PromoteOptions{
+		Application:           "myapp",
+		Environment:           "staging",
+		MetricsPushgatewayURL: server.URL + "/",
+	}
+	o.pushMetrics(2*time.Second, fmt.Errorf("failed"))
+	assert.Equal(t, []string{"/metrics/job/jx_promote/app/myapp/env/staging"}, paths)
+	if assert.Len(t, bodies, 1) {
+		assert.Contains(t, bodies[0], `jx_promote_total{app="myapp",env="staging",status="failed"} 1`)
+	}
+
+	// a failure to push the metrics does not fail the promotion
+	status = http.StatusBadRequest
+	assert.Error(t, pushPromoteMetrics(server.URL, "myapp", "staging", metrics))
+	o.pushMetrics(time.Second, nil)
+	assert.Len(t, paths, 3)
+}
+
+func TestPromoteVersionFromGitTag(t *testing.T) {
+	tags := []string{"release-2019", "v1.2.0", "1.10.0", "v2.0.0-rc.1", "1.9.3", ""}
+	version, tag := findLatestSemVerTag(tags, false)
+	assert.Equal(t, "2.0.0-rc.1", version)
+	assert.Equal(t, "v2.0.0-rc.1", tag)
+	version, tag = findLatestSemVerTag(tags, true)
+	assert.Equal(t, "1.10.0", version)
+	assert.Equal(t, "1.10.0", tag)
+	version, _ = findLatestSemVerTag([]string{"latest", "1.2"}, false)
+	assert.Equal(t, "", version)
+
+	gitter := &gits.GitFake{
+		GitTags: []gits.GitTag{
+			{Name: "v1.2.0"},
+			{Name: "v1.3.0"},
+		},
+	}
+	o := &PromoteOptions{
+		Application:       "myapp",
+		VersionFromGitTag: true,
+	}
+	ConfigureTestOptions(&o.CommonOptions, gitter, &promoteTestHelmer{})
+	version, err := o.findVersionFromGitTag()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.3.0", version)
+
+	gitter.GitTags = []gits.GitTag{{Name: "latest"}}
+	_, err = o.findVersionFromGitTag()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "No semantic version git tag")
+	}
+
+	// an explicit version cannot be combined with the git tag
+	o.Version = "1.0.0"
+	err = o.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), optionVersionFromGitTag)
+	}
+}
+
+func TestPromoteFromPreview(t *testing.T) {
+	preview := kube.NewPreviewEnvironment("myapp-pr-3")
+	staging := kube.NewPermanentEnvironment("staging")
+	newRelease := func(name string, app string, version string, created time.Time) *v1.Release {
+		return &v1.Release{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         preview.Spec.Namespace,
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Spec: v1.ReleaseSpec{
+				Name:    app,
+				Version: version,
+			},
+		}
+	}
+	now := time.Now()
+	o := &PromoteOptions{
+		Application: "myapp",
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{
+		preview,
+		staging,
+		newRelease("myapp-0.0.0-sNAPSHOT-pr-3-1", "myapp", "0.0.0-SNAPSHOT-PR-3-1", now.Add(-time.Hour)),
+		newRelease("myapp-0.0.0-sNAPSHOT-pr-3-2", "myapp", "0.0.0-SNAPSHOT-PR-3-2", now),
+		newRelease("otherapp-1.0.0", "otherapp", "1.0.0", now.Add(time.Hour)),
+	}, &gits.GitFake{}, &promoteTestHelmer{})
+
+	version, err := o.findVersionFromPreview("myapp-pr-3")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.0.0-SNAPSHOT-PR-3-2", version, "the latest release of the app is promoted")
+
+	// only preview environments can be promoted from
+	_, err = o.findVersionFromPreview("staging")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "myapp-pr-3")
+	}
+
+	o.Application = "missing"
+	_, err = o.findVersionFromPreview("myapp-pr-3")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "No release of application missing found in namespace jx-preview-myapp-pr-3")
+	}
+
+	// an explicit version cannot be combined with the preview
+	o.Version = "1.0.0"
+	o.FromPreview = "myapp-pr-3"
+	err = o.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), optionFromPreview)
+	}
+}
+
+func TestPromoteVerifyPullRequestVersions(t *testing.T) {
+	helmer := &promoteTestHelmer{
+		versions: map[string][]string{
+			"myapp": {"1.0.0", "1.1.0"},
+			"other": {"2.0.0"},
+		},
+	}
+	o := &PromoteOptions{
+		Application:  "myapp",
+		Version:      "1.1.0",
+		NoHelmUpdate: true,
+	}
+	o.helm = helmer
+	assert.NoError(t, o.verifyPullRequestVersions(context.Background()))
+
+	o.Version = "1.2.0"
+	err := o.verifyPullRequestVersions(context.Background())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Could not find version 1.2.0 of app myapp")
+		assert.Contains(t, err.Error(), "Available versions: 1.0.0, 1.1.0")
+	}
+
+	// the latest version and rollbacks are resolved from the helm repositories and environment
+	o.Version = ""
+	assert.NoError(t, o.verifyPullRequestVersions(context.Background()))
+	o.Version = "1.2.0"
+	o.Rollback = true
+	assert.NoError(t, o.verifyPullRequestVersions(context.Background()))
+	o.Rollback = false
+
+	// a missing version of any of the applications fails the promotion
+	o.applications = []applicationVersion{
+		{Name: "myapp", Version: "1.0.0"},
+		{Name: "other", Version: "2.1.0"},
+	}
+	err = o.verifyPullRequestVersions(context.Background())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Could not find version 2.1.0 of app other")
+	}
+
+	// a version which is published late is found by retrying
+	o.applications = nil
+	o.Version = "1.0.0"
+	o.ChartRetries = 1
+	helmer.missingSearches = len(helmer.searched) + 1
+	assert.NoError(t, o.verifyPullRequestVersions(context.Background()))
+}
+
+func TestPromoteNamespaceSelector(t *testing.T) {
+	namespaces := []runtime.Object{}
+	for _, name := range []string{"preview-a1b2", "preview-c3d4"} {
+		namespaces = append(namespaces, &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					"pool": "preview",
+					"slot": strings.TrimPrefix(name, "preview-"),
+				},
+			},
+		})
+	}
+	o := &PromoteOptions{
+		NamespaceSelector: "slot=c3d4",
+	}
+	o.DryRun = true
+	ConfigureTestOptionsWithResources(&o.CommonOptions, namespaces, nil, &gits.GitFake{}, &promoteTestHelmer{})
+
+	targetNS, env, err := o.GetTargetNamespace("", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "preview-c3d4", targetNS)
+	assert.Nil(t, env)
+
+	o.NamespaceSelector = "pool=preview"
+	_, _, err = o.GetTargetNamespace("", "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "matches more than one namespace: preview-a1b2, preview-c3d4")
+	}
+
+	o.NamespaceSelector = "pool=missing"
+	_, _, err = o.GetTargetNamespace("", "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "No namespace matches the selector pool=missing")
+	}
+
+	o.NamespaceSelector = "pool=preview"
+	_, _, err = o.GetTargetNamespace("", "staging")
+	assert.Error(t, err, "the selector is an alternative to --env")
+
+	o.NamespaceSelector = "pool in ("
+	_, _, err = o.GetTargetNamespace("", "")
+	assert.Error(t, err)
+}
+
+func TestPromoteChartPath(t *testing.T) {
+	f := newPromoteTestFixture(t)
+	defer f.cleanup()
+	chartDir := f.tempDir("test-promote-chart-")
+	err := ioutil.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("name: myapp\nversion: 1.2.4-hotfix.1\n"), util.DefaultWritePermissions)
+	assert.NoError(t, err)
+
+	staging := kube.NewPermanentEnvironment("staging")
+	production := kube.NewPermanentEnvironment("production")
+	production.Spec.PromotionStrategy = v1.PromotionStrategyTypeManual
+	production.Spec.Source.URL = "https://github.com/jstrachan/environment-production.git"
+
+	helmer := &promoteTestHelmer{}
+	o := &PromoteOptions{
+		ChartPath: chartDir,
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{staging, production}, &gits.GitFake{}, helmer)
+
+	// the application and version default to those of the chart
+	assert.NoError(t, o.resolveChartPath())
+	assert.Equal(t, "myapp", o.Application)
+	assert.Equal(t, "1.2.4-hotfix.1", o.Version)
+
+	o.DryRun = true
+	releaseInfo, err := o.Promote(context.Background(), staging.Spec.Namespace, staging, false)
+	assert.NoError(t, err)
+	assert.Equal(t, chartDir, releaseInfo.FullAppName)
+	assert.Equal(t, "1.2.4-hotfix.1", releaseInfo.Version)
+	assert.Empty(t, helmer.searched, "the chart is not looked up in the helm repositories")
+
+	// GitOps environments can only reference charts in helm repositories
+	_, err = o.Promote(context.Background(), production.Spec.Namespace, production, false)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "promoted via a Pull Request")
+	}
+
+	o.Version = "1.2.3"
+	err = o.resolveChartPath()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not match the version 1.2.4-hotfix.1")
+	}
+
+	o.Version = ""
+	o.ChartPath = filepath.Join(chartDir, "missing")
+	err = o.resolveChartPath()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "has no Chart.yaml")
+	}
+}
+
+func TestPromoteEnvironmentWithoutSource(t *testing.T) {
+	production := kube.NewPermanentEnvironment("production")
+	production.Spec.PromotionStrategy = v1.PromotionStrategyTypeManual
+	production.Spec.Source.Kind = v1.EnvironmentRepositoryTypeGit
+
+	helmer := &promoteTestHelmer{}
+	o := &PromoteOptions{
+		Application: "myapp",
+		Version:     "1.2.0",
+	}
+	ConfigureTestOptionsWithResources(&o.CommonOptions, nil, []runtime.Object{production}, &promoteTestGitter{}, helmer)
+	jxClient, ns, err := o.JXClient()
+	assert.NoError(t, err)
+	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+
+	// the environment is not quietly promoted via helm
+	_, err = o.Promote(context.Background(), production.Spec.Namespace, production, true)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "has no source URL")
+		assert.Contains(t, err.Error(), "jx edit env production --git-url")
+	}
+	assert.Empty(t, helmer.upgrades)
+
+	testCases := []struct {
+		name   string
+		source v1.EnvironmentRepository
+		valid  bool
+	}{
+		{"no source", v1.EnvironmentRepository{}, true},
+		{"source", v1.EnvironmentRepository{Kind: v1.EnvironmentRepositoryTypeGit, URL: "https://github.com/jstrachan/environment-production.git"}, true},
+		{"git kind without URL", v1.EnvironmentRepository{Kind: v1.EnvironmentRepositoryTypeGit}, false},
+		{"ref without URL", v1.EnvironmentRepository{Ref: "master"}, false},
+		{"blank URL", v1.EnvironmentRepository{URL: "  "}, false},
+		{"invalid URL", v1.EnvironmentRepository{URL: "environment-production"}, false},
+	}
+	for _, tc := range testCases {
+		env := kube.NewPermanentEnvironment("production")
+		env.Spec.Source = tc.source
+		err := validateEnvironmentSource(env)
+		assert.Equal(t, tc.valid, err == nil, "%s: %v", tc.name, err)
+	}
+
+	// only permanent environments are promoted via git
+	preview := kube.NewPreviewEnvironment("pr-1")
+	preview.Spec.Source.Kind = v1.EnvironmentRepositoryTypeGit
+	assert.NoError(t, validateEnvironmentSource(preview))
+}
+
+func TestPromoteHelmRepoName(t *testing.T) {
+	testCases := []struct {
+		repoName    string
+		fullAppName string
+	}{
+		{"myrepo", "myrepo/myapp"},
+		{"myrepo/", "myrepo/myapp"},
+		{" /myrepo/ ", "myrepo/myapp"},
+		{"", "myapp"},
+		{"  ", "myapp"},
+	}
+	for _, tc := range testCases {
+		repoName, err := normalizeHelmRepoName(tc.repoName)
+		assert.NoError(t, err, tc.repoName)
+		o := &PromoteOptions{
+			Application:       "myapp",
+			LocalHelmRepoName: repoName,
+		}
+		assert.Equal(t, tc.fullAppName, o.fullAppName(), tc.repoName)
+	}
+
+	for _, repoName := range []string{"my/repo", "my repo", "-repo", "repo:8080"} {
+		_, err := normalizeHelmRepoName(repoName)
+		if assert.Error(t, err, repoName) {
+			assert.Contains(t, err.Error(), "--helm-repo-name", repoName)
+		}
+	}
+}
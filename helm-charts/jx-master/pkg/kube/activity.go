@@ -32,6 +32,12 @@ type PromoteStepActivityKey struct {
 	ApplicationURL string
 }
 
+// IsValid returns true if the key is not nil and names a PipelineActivity so that a promotion without a
+// PipelineActivity can be given a nil key
+func (k *PromoteStepActivityKey) IsValid() bool {
+	return k != nil && k.PipelineActivityKey.IsValid()
+}
+
 type PromotePullRequestFn func(*v1.PipelineActivity, *v1.PipelineActivityStep, *v1.PromoteActivityStep, *v1.PromotePullRequestStep) error
 type PromoteUpdateFn func(*v1.PipelineActivity, *v1.PipelineActivityStep, *v1.PromoteActivityStep, *v1.PromoteUpdateStep) error
 
@@ -87,6 +87,7 @@ func CompletePromotionPullRequest(a *v1.PipelineActivity, s *v1.PipelineActivity
 }
 
 func FailedPromotionPullRequest(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromotePullRequestStep) error {
+	FailedPromote(ps)
 	if p.StartedTimestamp == nil {
 		p.StartedTimestamp = &metav1.Time{
 			Time: time.Now(),
@@ -101,6 +102,19 @@ func FailedPromotionPullRequest(a *v1.PipelineActivity, s *v1.PipelineActivitySt
 	return nil
 }
 
+// WaitingForApprovalPromotionUpdate marks the update step of the promotion as waiting for approval
+func WaitingForApprovalPromotionUpdate(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromoteUpdateStep) error {
+	StartPromote(ps)
+	if p.StartedTimestamp == nil {
+		p.StartedTimestamp = &metav1.Time{
+			Time: time.Now(),
+		}
+	}
+	p.Status = v1.ActivityStatusTypeWaitingForApproval
+	p.Description = "awaiting approval"
+	return nil
+}
+
 func CompletePromotionUpdate(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromoteUpdateStep) error {
 	CompletePromote(ps)
 	pullRequest := ps.PullRequest
@@ -112,11 +126,7 @@ func CompletePromotionUpdate(a *v1.PipelineActivity, s *v1.PipelineActivityStep,
 			Time: time.Now(),
 		}
 	}
-	if p.CompletedTimestamp == nil {
-		p.CompletedTimestamp = &metav1.Time{
-			Time: time.Now(),
-		}
-	}
+	completePromotionUpdateStep(p)
 	p.Status = v1.ActivityStatusTypeSucceeded
 	return nil
 }
@@ -132,11 +142,21 @@ func FailedPromotionUpdate(a *v1.PipelineActivity, s *v1.PipelineActivityStep, p
 			Time: time.Now(),
 		}
 	}
+	completePromotionUpdateStep(p)
+	p.Status = v1.ActivityStatusTypeFailed
+	return nil
+}
+
+// completePromotionUpdateStep stamps the completion time of the update step and records how long the update took
+func completePromotionUpdateStep(p *v1.PromoteUpdateStep) {
 	if p.CompletedTimestamp == nil {
 		p.CompletedTimestamp = &metav1.Time{
 			Time: time.Now(),
 		}
 	}
-	p.Status = v1.ActivityStatusTypeFailed
-	return nil
+	if p.Duration == nil && p.StartedTimestamp != nil {
+		p.Duration = &metav1.Duration{
+			Duration: p.CompletedTimestamp.Sub(p.StartedTimestamp.Time),
+		}
+	}
 }
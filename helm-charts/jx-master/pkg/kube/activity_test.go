@@ -147,6 +147,9 @@ func TestCreateOrUpdateActivities(t *testing.T) {
 	assert.NotNil(t, updateStep, "Promote should have an Update")
 	assert.NotNil(t, updateStep.StartedTimestamp, "Promote should have a Update.StartedTimestamp")
 	assert.NotNil(t, updateStep.CompletedTimestamp, "Promote should have a Update.CompletedTimestamp")
+	if assert.NotNil(t, updateStep.Duration, "Promote should have a Update.Duration") {
+		assert.Equal(t, updateStep.CompletedTimestamp.Sub(updateStep.StartedTimestamp.Time), updateStep.Duration.Duration)
+	}
 
 	assert.NotNil(t, promote.StartedTimestamp, "promote should have a StartedTimestamp")
 	assert.NotNil(t, promote.CompletedTimestamp, "promote should have a CompletedTimestamp")
@@ -157,3 +160,31 @@ func TestCreateOrUpdateActivities(t *testing.T) {
 
 	//tests.Debugf("Has Promote %#v\n", promote)
 }
+
+func TestFailedPromotionUpdate(t *testing.T) {
+	started := time.Now().Add(-time.Minute)
+	ps := &v1.PromoteActivityStep{}
+	p := &v1.PromoteUpdateStep{
+		CoreActivityStep: v1.CoreActivityStep{
+			StartedTimestamp: &meta_v1.Time{Time: started},
+		},
+	}
+	err := FailedPromotionUpdate(nil, nil, ps, p)
+	assert.NoError(t, err)
+	assert.Equal(t, v1.ActivityStatusTypeFailed, p.Status)
+	assert.Equal(t, v1.ActivityStatusTypeFailed, ps.Status)
+	assert.NotNil(t, p.CompletedTimestamp)
+	assert.NotNil(t, ps.CompletedTimestamp)
+	if assert.NotNil(t, p.Duration) {
+		assert.True(t, p.Duration.Duration >= time.Minute)
+	}
+
+	// the promotion is completed when its Pull Request fails to merge
+	ps = &v1.PromoteActivityStep{}
+	pr := &v1.PromotePullRequestStep{}
+	err = FailedPromotionPullRequest(nil, nil, ps, pr)
+	assert.NoError(t, err)
+	assert.Equal(t, v1.ActivityStatusTypeFailed, pr.Status)
+	assert.Equal(t, v1.ActivityStatusTypeFailed, ps.Status)
+	assert.NotNil(t, ps.CompletedTimestamp)
+}
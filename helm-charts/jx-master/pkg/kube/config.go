@@ -3,6 +3,7 @@ package kube
 import (
 	"fmt"
 	"io/ioutil"
+	"sort"
 
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
@@ -74,3 +75,15 @@ func Server(config *api.Config, context *api.Context) string {
 	}
 	return ""
 }
+
+// ContextNames returns the sorted names of the contexts of the configuration
+func ContextNames(config *api.Config) []string {
+	names := []string{}
+	if config != nil {
+		for name := range config.Contexts {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
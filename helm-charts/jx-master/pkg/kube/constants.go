@@ -146,6 +146,10 @@ const (
 	// LabelValueDevEnvironment is the value of the LabelTeam label for Development environments (system namespace)
 	LabelValueDevEnvironment = "dev"
 
+	// LabelProtected indicates an Environment is protected so that promotions to it require approval when using
+	// 'jx promote --require-approval'
+	LabelProtected = "jenkins.io/protected"
+
 	// LabelJobKind the kind of job
 	LabelJobKind = "jenkins.io/job-kind"
 
@@ -172,6 +176,14 @@ const (
 	// AnnotationLocalDir the local directory that is sync'd to the DevPod
 	AnnotationLocalDir = "jenkins.io/local-dir"
 
+	// AnnotationNamespaceSelector the label selector used to discover the namespace of an Environment which does not
+	// specify its namespace
+	AnnotationNamespaceSelector = "jenkins.io/namespace-selector"
+
+	// AnnotationPromoteApprovedByPrefix the prefix of the annotation on a PipelineActivity which records the user
+	// approving the promotion to an environment. The environment name is appended to the prefix
+	AnnotationPromoteApprovedByPrefix = "jenkins.io/promote-approved-by-"
+
 	// AnnotationIsDefaultStorageClass used to indicate a storageclass is default
 	AnnotationIsDefaultStorageClass = "storageclass.kubernetes.io/is-default-class"
 
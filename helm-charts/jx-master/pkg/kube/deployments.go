@@ -134,3 +134,42 @@ func GetDeploymentPods(client kubernetes.Interface, name, namespace string) ([]v
 
 	return pods.Items, err
 }
+
+// GetReleaseWorkloadsNotReady returns the Deployments and StatefulSets of the helm release which do not have all of
+// their desired replicas updated and ready. Returns false if the release has no Deployments or StatefulSets
+func GetReleaseWorkloadsNotReady(client kubernetes.Interface, ns string, releaseName string) ([]string, bool, error) {
+	options := metav1.ListOptions{
+		LabelSelector: "release=" + releaseName,
+	}
+	notReady := []string{}
+	deployments, err := client.AppsV1beta1().Deployments(ns).List(options)
+	if err != nil {
+		return notReady, false, fmt.Errorf("Failed to list the Deployments of release %s in namespace %s: %s", releaseName, ns, err)
+	}
+	statefulSets, err := client.AppsV1beta1().StatefulSets(ns).List(options)
+	if err != nil {
+		return notReady, false, fmt.Errorf("Failed to list the StatefulSets of release %s in namespace %s: %s", releaseName, ns, err)
+	}
+	for _, d := range deployments.Items {
+		desired := desiredReplicas(d.Spec.Replicas)
+		status := d.Status
+		if status.ObservedGeneration < d.Generation || status.UpdatedReplicas < desired || status.ReadyReplicas < desired {
+			notReady = append(notReady, fmt.Sprintf("Deployment %s has %d/%d replicas ready", d.Name, status.ReadyReplicas, desired))
+		}
+	}
+	for _, s := range statefulSets.Items {
+		desired := desiredReplicas(s.Spec.Replicas)
+		status := s.Status
+		if status.ObservedGeneration == nil || *status.ObservedGeneration < s.Generation || status.UpdatedReplicas < desired || status.ReadyReplicas < desired {
+			notReady = append(notReady, fmt.Sprintf("StatefulSet %s has %d/%d replicas ready", s.Name, status.ReadyReplicas, desired))
+		}
+	}
+	return notReady, len(deployments.Items)+len(statefulSets.Items) > 0, nil
+}
+
+func desiredReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
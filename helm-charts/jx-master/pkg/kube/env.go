@@ -697,6 +697,111 @@ func GetEnvironments(jxClient versioned.Interface, ns string) (map[string]*v1.En
 	return m, envNames, nil
 }
 
+// DiscoverEnvironmentNamespace returns the namespace of the environment. If the environment does not specify a namespace it
+// is discovered using the label selector in the AnnotationNamespaceSelector annotation of the environment which must
+// match exactly one namespace. An empty namespace is returned if there is no namespace or selector
+func DiscoverEnvironmentNamespace(kubeClient kubernetes.Interface, env *v1.Environment) (string, error) {
+	if env.Spec.Namespace != "" {
+		return env.Spec.Namespace, nil
+	}
+	selector := ""
+	if env.Annotations != nil {
+		selector = env.Annotations[AnnotationNamespaceSelector]
+	}
+	if selector == "" {
+		return "", nil
+	}
+	return FindNamespaceBySelector(kubeClient, selector, "environment "+env.Name)
+}
+
+// FindNamespaceBySelector returns the namespace which matches the label selector. An error is returned unless exactly
+// one namespace matches. The owner describes where the selector comes from in the error messages
+func FindNamespaceBySelector(kubeClient kubernetes.Interface, selector string, owner string) (string, error) {
+	namespaces, err := kubeClient.CoreV1().Namespaces().List(metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to find the namespace of %s using selector %s: %s", owner, selector, err)
+	}
+	names := []string{}
+	for _, ns := range namespaces.Items {
+		names = append(names, ns.Name)
+	}
+	switch len(names) {
+	case 0:
+		return "", fmt.Errorf("No namespace matches the selector %s of %s", selector, owner)
+	case 1:
+		return names[0], nil
+	default:
+		sort.Strings(names)
+		return "", fmt.Errorf("The selector %s of %s matches more than one namespace: %s", selector, owner, strings.Join(names, ", "))
+	}
+}
+
+// FindEnvironmentByNameOrLabel returns the environment with the given name or if there is no environment of that name
+// the environment whose label matches it. An error listing every conflicting environment is returned if the name or
+// label is used by more than one environment
+func FindEnvironmentByNameOrLabel(envs map[string]*v1.Environment, name string) (*v1.Environment, error) {
+	key := strings.ToLower(name)
+	matches := DuplicateEnvironmentLabels(envs)[key]
+	if len(matches) > 1 {
+		conflicts := []string{}
+		for _, match := range matches {
+			kinds := []string{}
+			if strings.ToLower(match) == key {
+				kinds = append(kinds, "name")
+			}
+			if strings.EqualFold(envs[match].Spec.Label, name) {
+				kinds = append(kinds, "label")
+			}
+			conflicts = append(conflicts, fmt.Sprintf("%s (%s)", match, strings.Join(kinds, " and ")))
+		}
+		return nil, fmt.Errorf("The environment %s is ambiguous as it is the name or label of the environments: %s", name, strings.Join(conflicts, ", "))
+	}
+	env := envs[name]
+	if env != nil {
+		return env, nil
+	}
+	for _, e := range envs {
+		if e != nil && strings.EqualFold(e.Spec.Label, name) {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+// DuplicateEnvironmentLabels returns the sorted names of the environments which share the same label, or whose label
+// is the name of another environment, keyed by the lower case label or name
+func DuplicateEnvironmentLabels(envs map[string]*v1.Environment) map[string][]string {
+	keys := map[string][]string{}
+	add := func(key string, name string) {
+		key = strings.ToLower(key)
+		for _, n := range keys[key] {
+			if n == name {
+				return
+			}
+		}
+		keys[key] = append(keys[key], name)
+	}
+	for name, env := range envs {
+		if env == nil {
+			continue
+		}
+		add(name, name)
+		if env.Spec.Label != "" {
+			add(env.Spec.Label, name)
+		}
+	}
+	answer := map[string][]string{}
+	for key, names := range keys {
+		if len(names) > 1 {
+			sort.Strings(names)
+			answer[key] = names
+		}
+	}
+	return answer
+}
+
 // GetEnvironments returns the namespace name for a given environment
 func GetEnvironmentNamespace(jxClient versioned.Interface, ns, environment string) (string, error) {
 	env, err := jxClient.JenkinsV1().Environments(ns).Get(environment, metav1.GetOptions{})
@@ -5,7 +5,9 @@ import (
 
 	"github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestSortEnvironments(t *testing.T) {
@@ -99,3 +101,95 @@ func TestReplaceMakeVariable(t *testing.T) {
 	assert.Equal(t, "BAR", actual[2], "line 2")
 	assert.Equal(t, "NAMESPACE := "+expectedValue, actual[3], "line 3")
 }
+
+func TestFindEnvironmentByNameOrLabel(t *testing.T) {
+	staging := NewPermanentEnvironment("staging")
+	stagingEU := NewPermanentEnvironment("staging-eu")
+	stagingEU.Spec.Label = "Staging"
+	production := NewPermanentEnvironment("production")
+	envs := map[string]*v1.Environment{
+		staging.Name:    staging,
+		stagingEU.Name:  stagingEU,
+		production.Name: production,
+	}
+
+	assert.Equal(t, map[string][]string{"staging": {"staging", "staging-eu"}}, DuplicateEnvironmentLabels(envs))
+
+	env, err := FindEnvironmentByNameOrLabel(envs, "staging-eu")
+	assert.NoError(t, err)
+	assert.Equal(t, stagingEU, env)
+
+	env, err = FindEnvironmentByNameOrLabel(envs, "Production")
+	assert.NoError(t, err)
+	assert.Equal(t, production, env)
+
+	env, err = FindEnvironmentByNameOrLabel(envs, "STAGING")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "staging (name and label), staging-eu (label)")
+	}
+	assert.Nil(t, env)
+
+	// the name of an environment conflicts with the label of another environment
+	prod := NewPermanentEnvironment("prod")
+	prod.Spec.Label = "Live"
+	production.Spec.Label = "prod"
+	live := NewPermanentEnvironment("live")
+	envs[prod.Name] = prod
+	envs[live.Name] = live
+	assert.Equal(t, map[string][]string{
+		"staging": {"staging", "staging-eu"},
+		"prod":    {"prod", "production"},
+		"live":    {"live", "prod"},
+	}, DuplicateEnvironmentLabels(envs))
+
+	env, err = FindEnvironmentByNameOrLabel(envs, "prod")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "prod (name), production (label)")
+	}
+	assert.Nil(t, env)
+
+	env, err = FindEnvironmentByNameOrLabel(envs, "unknown")
+	assert.NoError(t, err)
+	assert.Nil(t, env)
+}
+
+func TestDiscoverEnvironmentNamespace(t *testing.T) {
+	namespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: labels,
+			},
+		}
+	}
+	kubeClient := fake.NewSimpleClientset(
+		namespace("jx-staging-a1b2c3", map[string]string{"env": "staging", "team": "jx"}),
+		namespace("jx-test-1", map[string]string{"env": "test"}),
+		namespace("jx-test-2", map[string]string{"env": "test"}),
+	)
+
+	env := NewPermanentEnvironment("staging")
+	ns, err := DiscoverEnvironmentNamespace(kubeClient, env)
+	assert.NoError(t, err)
+	assert.Equal(t, "jx-staging", ns, "an explicit namespace should be used")
+
+	env.Spec.Namespace = ""
+	ns, err = DiscoverEnvironmentNamespace(kubeClient, env)
+	assert.NoError(t, err)
+	assert.Equal(t, "", ns, "no namespace without a selector")
+
+	env.Annotations = map[string]string{AnnotationNamespaceSelector: "env=staging,team=jx"}
+	ns, err = DiscoverEnvironmentNamespace(kubeClient, env)
+	assert.NoError(t, err)
+	assert.Equal(t, "jx-staging-a1b2c3", ns)
+
+	env.Annotations[AnnotationNamespaceSelector] = "env=test"
+	_, err = DiscoverEnvironmentNamespace(kubeClient, env)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "jx-test-1, jx-test-2")
+	}
+
+	env.Annotations[AnnotationNamespaceSelector] = "env=production"
+	_, err = DiscoverEnvironmentNamespace(kubeClient, env)
+	assert.Error(t, err)
+}
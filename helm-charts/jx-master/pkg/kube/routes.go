@@ -0,0 +1,61 @@
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// OpenShiftRouteGroupVersion is the API group version of the OpenShift Route resources
+const OpenShiftRouteGroupVersion = "route.openshift.io/v1"
+
+// route is the subset of an OpenShift Route needed to find its URL so that Routes can be read without the OpenShift
+// client libraries
+type route struct {
+	Spec struct {
+		Host string `json:"host"`
+		TLS  *struct {
+			Termination string `json:"termination"`
+		} `json:"tls,omitempty"`
+	} `json:"spec"`
+}
+
+// HasOpenShiftRoutes returns true if the cluster serves the OpenShift Route API
+func HasOpenShiftRoutes(client kubernetes.Interface) bool {
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(OpenShiftRouteGroupVersion)
+	if err != nil || resources == nil {
+		return false
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == "routes" {
+			return true
+		}
+	}
+	return false
+}
+
+// FindRouteURL returns the URL of the OpenShift Route with the given name or a blank string if the Route has no host
+func FindRouteURL(client kubernetes.Interface, namespace string, name string) (string, error) {
+	data, err := client.CoreV1().RESTClient().Get().AbsPath("/apis", OpenShiftRouteGroupVersion, "namespaces", namespace, "routes", name).DoRaw()
+	if err != nil {
+		return "", err
+	}
+	return routeURL(data)
+}
+
+// routeURL returns the URL of the given JSON OpenShift Route or a blank string if the Route has no host
+func routeURL(data []byte) (string, error) {
+	r := &route{}
+	err := json.Unmarshal(data, r)
+	if err != nil {
+		return "", fmt.Errorf("Failed to unmarshal the Route: %s", err)
+	}
+	if r.Spec.Host == "" {
+		return "", nil
+	}
+	if r.Spec.TLS != nil {
+		return "https://" + r.Spec.Host, nil
+	}
+	return "http://" + r.Spec.Host, nil
+}
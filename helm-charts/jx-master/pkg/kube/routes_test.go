@@ -0,0 +1,41 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHasOpenShiftRoutes(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	assert.False(t, HasOpenShiftRoutes(client))
+
+	client.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: OpenShiftRouteGroupVersion,
+			APIResources: []metav1.APIResource{
+				{Name: "routes", Kind: "Route", Namespaced: true},
+			},
+		},
+	}
+	assert.True(t, HasOpenShiftRoutes(client))
+}
+
+func TestRouteURL(t *testing.T) {
+	url, err := routeURL([]byte(`{"kind":"Route","spec":{"host":"myapp-jx-staging.apps.example.com","to":{"kind":"Service","name":"myapp"}}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "http://myapp-jx-staging.apps.example.com", url)
+
+	url, err = routeURL([]byte(`{"kind":"Route","spec":{"host":"myapp.example.com","tls":{"termination":"edge"}}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://myapp.example.com", url)
+
+	url, err = routeURL([]byte(`{"kind":"Route","spec":{}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "", url)
+
+	_, err = routeURL([]byte(`not json`))
+	assert.Error(t, err)
+}
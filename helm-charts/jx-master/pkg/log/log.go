@@ -2,24 +2,40 @@ package log
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/fatih/color"
 )
 
+var output io.Writer = os.Stdout
+
+// SetOutput sets the writer that log messages are written to and returns a function which restores the previous writer
+func SetOutput(out io.Writer) func() {
+	previousOutput := output
+	previousColorOutput := color.Output
+	output = out
+	color.Output = out
+	return func() {
+		output = previousOutput
+		color.Output = previousColorOutput
+	}
+}
+
 func Infof(msg string, args ...interface{}) {
 	Info(fmt.Sprintf(msg, args...))
 }
 
 func Info(msg string) {
-	fmt.Print(msg)
+	fmt.Fprint(output, msg)
 }
 
 func Infoln(msg string) {
-	fmt.Println(msg)
+	fmt.Fprintln(output, msg)
 }
 
 func Blank() {
-	fmt.Println()
+	fmt.Fprintln(output)
 }
 
 func Warnf(msg string, args ...interface{}) {
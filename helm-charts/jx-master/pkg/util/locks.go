@@ -0,0 +1,58 @@
+package util
+
+import "sync"
+
+// KeyedMutex provides a separate mutual exclusion lock for each key so that work for the same key is serialized
+// while work for different keys can run concurrently. The lock of a key is removed once it is no longer held or
+// waited for. The zero value is ready to use
+type KeyedMutex struct {
+	mutex sync.Mutex
+	locks map[string]*keyedLock
+}
+
+// keyedLock is the lock of a key along with the number of callers holding or waiting for it
+type keyedLock struct {
+	sync.Mutex
+	references int
+}
+
+// Lock locks the mutex for the given key, blocking until it is available
+func (k *KeyedMutex) Lock(key string) {
+	k.mutex.Lock()
+	if k.locks == nil {
+		k.locks = map[string]*keyedLock{}
+	}
+	lock := k.locks[key]
+	if lock == nil {
+		lock = &keyedLock{}
+		k.locks[key] = lock
+	}
+	lock.references++
+	k.mutex.Unlock()
+
+	lock.Lock()
+}
+
+// Unlock unlocks the mutex for the given key
+func (k *KeyedMutex) Unlock(key string) {
+	k.mutex.Lock()
+	lock := k.locks[key]
+	if lock != nil {
+		lock.references--
+		if lock.references <= 0 {
+			delete(k.locks, key)
+		}
+	}
+	k.mutex.Unlock()
+
+	if lock != nil {
+		lock.Unlock()
+	}
+}
+
+// Len returns the number of keys whose lock is held or waited for
+func (k *KeyedMutex) Len() int {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	return len(k.locks)
+}
@@ -0,0 +1,76 @@
+package util
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// runLocked runs the given number of workers for each key and returns the maximum number of workers for any key which
+// held the lock at the same time along with the maximum number of concurrent workers overall
+func runLocked(locks *KeyedMutex, keys []string, workers int) (int, int) {
+	var mutex sync.Mutex
+	running := map[string]int{}
+	total := 0
+	maxPerKey := 0
+	maxTotal := 0
+
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				locks.Lock(key)
+				defer locks.Unlock(key)
+
+				mutex.Lock()
+				running[key]++
+				total++
+				if running[key] > maxPerKey {
+					maxPerKey = running[key]
+				}
+				if total > maxTotal {
+					maxTotal = total
+				}
+				mutex.Unlock()
+
+				time.Sleep(20 * time.Millisecond)
+
+				mutex.Lock()
+				running[key]--
+				total--
+				mutex.Unlock()
+			}(key)
+		}
+	}
+	wg.Wait()
+	return maxPerKey, maxTotal
+}
+
+func TestKeyedMutexSerializesSameKey(t *testing.T) {
+	locks := &KeyedMutex{}
+	maxPerKey, maxTotal := runLocked(locks, []string{"myapp"}, 5)
+	assert.Equal(t, 1, maxPerKey)
+	assert.Equal(t, 1, maxTotal)
+}
+
+func TestKeyedMutexAllowsDifferentKeysConcurrently(t *testing.T) {
+	locks := &KeyedMutex{}
+	maxPerKey, maxTotal := runLocked(locks, []string{"myapp", "otherapp", "thirdapp"}, 3)
+	assert.Equal(t, 1, maxPerKey)
+	assert.True(t, maxTotal > 1, "different keys should be locked concurrently but max concurrency was %d", maxTotal)
+}
+
+func TestKeyedMutexRemovesUnusedLocks(t *testing.T) {
+	locks := &KeyedMutex{}
+	runLocked(locks, []string{"myapp", "otherapp"}, 3)
+	assert.Equal(t, 0, locks.Len())
+
+	locks.Lock("myapp")
+	assert.Equal(t, 1, locks.Len())
+	locks.Unlock("myapp")
+	assert.Equal(t, 0, locks.Len())
+}